@@ -1,6 +1,10 @@
 package fosite
 
-import jose "gopkg.in/square/go-jose.v2"
+import (
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
 
 // Client represents a client or an app.
 type Client interface {
@@ -42,6 +46,19 @@ type ClientWithSecretRotation interface {
 	GetRotatedHashes() [][]byte
 }
 
+// ClientWithSecretExpiry extends ClientWithSecretRotation by the expiry of the secret and of the rotated secrets.
+// Expired secrets are rejected by client authentication.
+type ClientWithSecretExpiry interface {
+	ClientWithSecretRotation
+
+	// GetSecretExpiresAt returns the time the secret expires at. The zero time means that the secret does not expire.
+	GetSecretExpiresAt() time.Time
+
+	// GetRotatedSecretsExpiresAt returns the times the rotated secrets expire at, in the order of GetRotatedHashes.
+	// Rotated secrets without or with a zero expiry time do not expire.
+	GetRotatedSecretsExpiresAt() []time.Time
+}
+
 // OpenIDConnectClient represents a client capable of performing OpenID Connect requests.
 type OpenIDConnectClient interface {
 	// GetRequestURIs is an array of request_uri values that are pre-registered by the RP for use at the OP. Servers MAY
@@ -70,6 +87,28 @@ type OpenIDConnectClient interface {
 	GetTokenEndpointAuthSigningAlgorithm() string
 }
 
+// JWTIntrospectionClient is a client which receives encrypted JWT-secured introspection responses as defined in
+// RFC 9701. The response is encrypted with a key from the client's JSON Web Key Set with use "enc".
+type JWTIntrospectionClient interface {
+	OpenIDConnectClient
+
+	// GetIntrospectionEncryptedResponseAlgorithm returns the JWE "alg" used to encrypt introspection responses, for
+	// example "RSA-OAEP-256". If empty, introspection responses are signed but not encrypted.
+	GetIntrospectionEncryptedResponseAlgorithm() string
+
+	// GetIntrospectionEncryptedResponseEncryption returns the JWE "enc" used to encrypt introspection responses.
+	GetIntrospectionEncryptedResponseEncryption() string
+}
+
+// IntrospectionClient is a client whose permission to call the introspection endpoint is decided by the client itself
+// rather than by Fosite.IntrospectionScope.
+type IntrospectionClient interface {
+	Client
+
+	// IsIntrospectionAllowed returns true if the client may introspect tokens.
+	IsIntrospectionAllowed() bool
+}
+
 // DefaultClient is a simple default implementation of the Client interface.
 type DefaultClient struct {
 	ID             string   `json:"id"`
@@ -81,6 +120,12 @@ type DefaultClient struct {
 	Scopes         []string `json:"scopes"`
 	Audience       []string `json:"audience"`
 	Public         bool     `json:"public"`
+
+	// SecretExpiresAt is the time Secret expires at. The zero time means that it does not expire.
+	SecretExpiresAt time.Time `json:"secret_expires_at"`
+	// RotatedSecretsExpiresAt are the times RotatedSecrets expire at, by index. Missing or zero times mean that the
+	// rotated secret does not expire.
+	RotatedSecretsExpiresAt []time.Time `json:"rotated_secrets_expires_at,omitempty"`
 }
 
 type DefaultOpenIDConnectClient struct {
@@ -91,6 +136,8 @@ type DefaultOpenIDConnectClient struct {
 	RequestURIs                       []string            `json:"request_uris"`
 	RequestObjectSigningAlgorithm     string              `json:"request_object_signing_alg"`
 	TokenEndpointAuthSigningAlgorithm string              `json:"token_endpoint_auth_signing_alg"`
+	IntrospectionEncryptedResponseAlg string              `json:"introspection_encrypted_response_alg,omitempty"`
+	IntrospectionEncryptedResponseEnc string              `json:"introspection_encrypted_response_enc,omitempty"`
 }
 
 func (c *DefaultClient) GetID() string {
@@ -113,10 +160,40 @@ func (c *DefaultClient) GetHashedSecret() []byte {
 	return c.Secret
 }
 
+// WithHashedSecret implements ClientWithSecretHashing.
+func (c *DefaultClient) WithHashedSecret(hash []byte) Client {
+	copied := *c
+	copied.Secret = hash
+	return &copied
+}
+
 func (c *DefaultClient) GetRotatedHashes() [][]byte {
 	return c.RotatedSecrets
 }
 
+// GetSecretExpiresAt implements ClientWithSecretExpiry.
+func (c *DefaultClient) GetSecretExpiresAt() time.Time {
+	return c.SecretExpiresAt
+}
+
+// GetRotatedSecretsExpiresAt implements ClientWithSecretExpiry.
+func (c *DefaultClient) GetRotatedSecretsExpiresAt() []time.Time {
+	return c.RotatedSecretsExpiresAt
+}
+
+// RotateSecret replaces the secret by secret, which expires at expiresAt. The replaced secret is kept as rotated
+// secret, which is accepted until cutover.
+func (c *DefaultClient) RotateSecret(secret []byte, expiresAt time.Time, cutover time.Time) {
+	// Align the expiry times with the rotated secrets, which may have been set without.
+	expiry := make([]time.Time, len(c.RotatedSecrets), len(c.RotatedSecrets)+1)
+	copy(expiry, c.RotatedSecretsExpiresAt)
+
+	c.RotatedSecrets = append(c.RotatedSecrets, c.Secret)
+	c.RotatedSecretsExpiresAt = append(expiry, cutover)
+	c.Secret = secret
+	c.SecretExpiresAt = expiresAt
+}
+
 func (c *DefaultClient) GetScopes() Arguments {
 	return c.Scopes
 }
@@ -145,6 +222,13 @@ func (c *DefaultClient) GetResponseTypes() Arguments {
 	return Arguments(c.ResponseTypes)
 }
 
+// WithHashedSecret implements ClientWithSecretHashing.
+func (c *DefaultOpenIDConnectClient) WithHashedSecret(hash []byte) Client {
+	copied := *c
+	copied.DefaultClient = c.DefaultClient.WithHashedSecret(hash).(*DefaultClient)
+	return &copied
+}
+
 func (c *DefaultOpenIDConnectClient) GetJSONWebKeysURI() string {
 	return c.JSONWebKeysURI
 }
@@ -172,3 +256,14 @@ func (c *DefaultOpenIDConnectClient) GetTokenEndpointAuthMethod() string {
 func (c *DefaultOpenIDConnectClient) GetRequestURIs() []string {
 	return c.RequestURIs
 }
+
+func (c *DefaultOpenIDConnectClient) GetIntrospectionEncryptedResponseAlgorithm() string {
+	return c.IntrospectionEncryptedResponseAlg
+}
+
+func (c *DefaultOpenIDConnectClient) GetIntrospectionEncryptedResponseEncryption() string {
+	if c.IntrospectionEncryptedResponseEnc == "" && c.IntrospectionEncryptedResponseAlg != "" {
+		return "A128CBC-HS256"
+	}
+	return c.IntrospectionEncryptedResponseEnc
+}
@@ -146,7 +146,7 @@ func (f *Fosite) DefaultClientAuthenticationStrategy(ctx context.Context, r *htt
 			default:
 				return nil, errorsx.WithStack(ErrInvalidClient.WithHintf("The 'client_assertion' request parameter uses unsupported signing algorithm '%s'.", t.Header["alg"]))
 			}
-		})
+		}, jwt.WithClock(f.GetClock()), jwt.WithLeeway(f.ClockSkewLeeway))
 		if err != nil {
 			// Do not re-process already enhanced errors
 			var e *jwt.ValidationError
@@ -157,7 +157,7 @@ func (f *Fosite) DefaultClientAuthenticationStrategy(ctx context.Context, r *htt
 				return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the integrity of the 'client_assertion' value.").WithWrap(err).WithDebug(err.Error()))
 			}
 			return nil, err
-		} else if err := token.Claims.Valid(); err != nil {
+		} else if err := token.Claims.ValidAt(f.GetClock().Now(), f.ClockSkewLeeway); err != nil {
 			return nil, errorsx.WithStack(ErrInvalidClient.WithHint("Unable to verify the request object because its claims could not be validated, check if the expiry time is set correctly.").WithWrap(err).WithDebug(err.Error()))
 		}
 
@@ -192,7 +192,8 @@ func (f *Fosite) DefaultClientAuthenticationStrategy(ctx context.Context, r *htt
 		if err != nil {
 			return nil, errorsx.WithStack(err)
 		}
-		if err := f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0)); err != nil {
+		// The assertion is accepted until it expired including the leeway, so its jti must be remembered as long.
+		if err := f.Store.SetClientAssertionJWT(ctx, jti, time.Unix(expiry, 0).Add(f.ClockSkewLeeway)); err != nil {
 			return nil, err
 		}
 
@@ -244,26 +245,50 @@ func (f *Fosite) DefaultClientAuthenticationStrategy(ctx context.Context, r *htt
 	}
 
 	// Enforce client authentication
-	if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
+	if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); errors.Is(err, ErrInvalidClient) {
+		return nil, err
+	} else if err != nil {
 		return nil, errorsx.WithStack(ErrInvalidClient.WithWrap(err).WithDebug(err.Error()))
 	}
 
 	return client, nil
 }
 
+// ClientSecretRehashHook is called with the plain text secret of a client after it has been verified against a hash
+// which the Hasher reports as outdated, see HasherWithRehash. The hook may hash the secret again and persist the
+// upgraded hash, for example by passing a copy of the client with the plain text secret to ClientWriter.UpdateClient.
+// The client has been authenticated already, so the hook should handle its errors itself.
+type ClientSecretRehashHook func(ctx context.Context, client Client, secret []byte)
+
+// checkClientSecret compares clientSecret with the secret and the rotated secrets of the client. Matching secrets which
+// have expired, see ClientWithSecretExpiry, are rejected with ErrInvalidClient.
 func (f *Fosite) checkClientSecret(ctx context.Context, client Client, clientSecret []byte) error {
+	var secretExpiresAt time.Time
+	var rotatedExpiresAt []time.Time
+	if c, ok := client.(ClientWithSecretExpiry); ok {
+		secretExpiresAt, rotatedExpiresAt = c.GetSecretExpiresAt(), c.GetRotatedSecretsExpiresAt()
+	}
+	now := f.GetClock().Now()
+
 	var err error
 	err = f.Hasher.Compare(ctx, client.GetHashedSecret(), clientSecret)
 	if err == nil {
+		if !secretExpiresAt.IsZero() && !now.Before(secretExpiresAt) {
+			return errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client secret has expired."))
+		}
+		f.rehashClientSecret(ctx, client, clientSecret)
 		return nil
 	}
 	cc, ok := client.(ClientWithSecretRotation)
 	if !ok {
 		return err
 	}
-	for _, hash := range cc.GetRotatedHashes() {
+	for i, hash := range cc.GetRotatedHashes() {
 		err = f.Hasher.Compare(ctx, hash, clientSecret)
 		if err == nil {
+			if i < len(rotatedExpiresAt) && !rotatedExpiresAt[i].IsZero() && !now.Before(rotatedExpiresAt[i]) {
+				return errorsx.WithStack(ErrInvalidClient.WithHint("The OAuth 2.0 Client secret has been rotated and is no longer accepted."))
+			}
 			return nil
 		}
 	}
@@ -271,6 +296,17 @@ func (f *Fosite) checkClientSecret(ctx context.Context, client Client, clientSec
 	return err
 }
 
+// rehashClientSecret calls ClientSecretRehashHook if the hash of the client's primary secret is outdated. Rotated
+// secrets are not rehashed.
+func (f *Fosite) rehashClientSecret(ctx context.Context, client Client, clientSecret []byte) {
+	if f.ClientSecretRehashHook == nil {
+		return
+	}
+	if h, ok := f.Hasher.(HasherWithRehash); ok && h.NeedsRehash(client.GetHashedSecret()) {
+		f.ClientSecretRehashHook(ctx, client, clientSecret)
+	}
+}
+
 func findPublicKey(t *jwt.Token, set *jose.JSONWebKeySet, expectsRSAKey bool) (interface{}, error) {
 	keys := set.Keys
 	if len(keys) == 0 {
@@ -605,3 +605,135 @@ func TestAuthenticateClientTwice(t *testing.T) {
 	assert.EqualError(t, err, ErrJTIKnown.Error())
 	assert.Nil(t, c)
 }
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestAuthenticateClientWithClockSkewLeeway(t *testing.T) {
+	const at = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+	key := internal.MustRSAKey()
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{ID: "bar"},
+		JSONWebKeys: &jose.JSONWebKeySet{
+			Keys: []jose.JSONWebKey{{KeyID: "kid-foo", Use: "sig", Key: &key.PublicKey}},
+		},
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	store := storage.NewMemoryStore()
+	store.Clients[client.ID] = client
+
+	// The clock of the client is two minutes ahead of the server's clock.
+	now := time.Now().Truncate(time.Second)
+	assertion := func(jti string) url.Values {
+		return url.Values{"client_id": {"bar"}, "client_assertion_type": {at}, "client_assertion": {mustGenerateRSAAssertion(t, jwt.MapClaims{
+			"sub": "bar",
+			"iss": "bar",
+			"jti": jti,
+			"aud": "token-url",
+			"iat": now.Add(time.Minute * 2).Unix(),
+			"nbf": now.Add(time.Minute * 2).Unix(),
+			"exp": now.Add(time.Minute * 3).Unix(),
+		}, key, "kid-foo")}}
+	}
+
+	f := &Fosite{
+		JWKSFetcherStrategy: NewDefaultJWKSFetcherStrategy(),
+		Store:               store,
+		TokenURL:            "token-url",
+		Clock:               fixedClock(now),
+	}
+
+	_, err := f.AuthenticateClient(nil, new(http.Request), assertion("no-leeway"))
+	require.Error(t, err)
+
+	f.ClockSkewLeeway = time.Minute * 5
+	c, err := f.AuthenticateClient(nil, new(http.Request), assertion("leeway"))
+	require.NoError(t, err)
+	assert.Equal(t, client, c)
+
+	// The jti must be remembered until the assertion expired including the leeway.
+	store.Clock = fixedClock(now.Add(time.Minute * 7))
+	assert.Error(t, store.ClientAssertionJWTValid(nil, "leeway"))
+	store.Clock = fixedClock(now.Add(time.Minute * 9))
+	assert.NoError(t, store.ClientAssertionJWTValid(nil, "leeway"))
+}
+
+func TestAuthenticateClientRehashesSecret(t *testing.T) {
+	ctx := context.Background()
+	legacyHash, err := (&BCrypt{WorkFactor: 4}).Hash(ctx, []byte("secret"))
+	require.NoError(t, err)
+
+	hasher := &MultiHasher{Hasher: &PBKDF2{Iterations: 1000}}
+	store := storage.NewMemoryStore()
+	store.Hasher = hasher
+	store.Clients["foo"] = &DefaultClient{ID: "foo", Secret: legacyHash, RotatedSecrets: [][]byte{legacyHash}, GrantTypes: []string{"client_credentials"}}
+
+	var rehashed int
+	f := &Fosite{
+		Store:  store,
+		Hasher: hasher,
+		ClientSecretRehashHook: func(ctx context.Context, client Client, secret []byte) {
+			rehashed++
+			updated := *client.(*DefaultClient)
+			updated.Secret = secret
+			require.NoError(t, store.UpdateClient(ctx, &updated))
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := f.AuthenticateClient(ctx, &http.Request{Header: clientBasicAuthHeader("foo", "secret")}, url.Values{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1, rehashed)
+
+	client, err := store.GetClient(ctx, "foo")
+	require.NoError(t, err)
+	assert.False(t, hasher.NeedsRehash(client.GetHashedSecret()))
+	assert.NoError(t, hasher.Compare(ctx, client.GetHashedSecret(), []byte("secret")))
+}
+
+func TestAuthenticateClientWithExpiringSecrets(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	hasher := &BCrypt{WorkFactor: 4}
+	hash := func(secret string) []byte {
+		h, err := hasher.Hash(ctx, []byte(secret))
+		require.NoError(t, err)
+		return h
+	}
+
+	store := storage.NewMemoryStore()
+	store.Clients["foo"] = &DefaultClient{
+		ID:                      "foo",
+		Secret:                  hash("current"),
+		SecretExpiresAt:         now.Add(time.Hour),
+		RotatedSecrets:          [][]byte{hash("cut-over"), hash("in-cutover"), hash("never-expires")},
+		RotatedSecretsExpiresAt: []time.Time{now.Add(-time.Minute), now.Add(time.Minute)},
+	}
+	f := &Fosite{Store: store, Hasher: hasher, Clock: fixedClock(now)}
+	authenticate := func(secret string) error {
+		_, err := f.AuthenticateClient(ctx, &http.Request{Header: clientBasicAuthHeader("foo", secret)}, url.Values{})
+		return err
+	}
+
+	for _, secret := range []string{"current", "in-cutover", "never-expires"} {
+		assert.NoError(t, authenticate(secret), secret)
+	}
+
+	err := authenticate("cut-over")
+	require.True(t, errors.Is(err, ErrInvalidClient), "%+v", err)
+	assert.Contains(t, ErrorToRFC6749Error(err).HintField, "rotated")
+
+	err = authenticate("unknown")
+	require.True(t, errors.Is(err, ErrInvalidClient), "%+v", err)
+	assert.NotContains(t, ErrorToRFC6749Error(err).HintField, "rotated")
+
+	f.Clock = fixedClock(now.Add(time.Hour))
+	err = authenticate("current")
+	require.True(t, errors.Is(err, ErrInvalidClient), "%+v", err)
+	assert.Contains(t, ErrorToRFC6749Error(err).HintField, "expired")
+}
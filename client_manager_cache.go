@@ -0,0 +1,271 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultClientCacheMaxEntries = 1000
+	defaultClientCacheTTL        = time.Minute
+)
+
+// CachingClientManager is a ClientManager which caches the clients returned by the decorated ClientManager in memory.
+// Clients are cached for TTL and the least recently used clients are evicted once MaxEntries is reached. Concurrent
+// lookups of the same client are deduplicated, only one of them reaches the decorated ClientManager.
+//
+// Unknown clients, which are reported as ErrNotFound, can be cached for NegativeTTL to slow down the enumeration of
+// client IDs. Other errors are never cached.
+//
+// Updated or deleted clients must be invalidated with InvalidateClient, which CachingClientStorage does for the
+// clients written through it. Other instances of the authorization server
+// do not learn about these invalidations, so keep TTL short when running more than one instance. Cached clients are
+// shared between callers and must not be modified.
+type CachingClientManager struct {
+	// ClientManager is the decorated client manager. JTIs are passed on to it.
+	ClientManager
+
+	// MaxEntries bounds the number of cached clients. Defaults to 1000.
+	MaxEntries int
+
+	// TTL is the time a client is cached for. Defaults to one minute.
+	TTL time.Duration
+
+	// NegativeTTL is the time an unknown client is cached for. Unknown clients are not cached if it is zero.
+	NegativeTTL time.Duration
+
+	// Clock is used to expire cached clients. Defaults to SystemClock.
+	Clock Clock
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*clientCacheCall
+	epoch   uint64
+}
+
+var _ ClientManager = new(CachingClientManager)
+
+type clientCacheEntry struct {
+	id        string
+	expiresAt time.Time
+	client    Client
+	err       error
+}
+
+// clientCacheCall is a lookup of the decorated ClientManager which is in flight.
+type clientCacheCall struct {
+	done   chan struct{}
+	client Client
+	err    error
+}
+
+// NewCachingClientManager returns a CachingClientManager for manager using the default limits.
+func NewCachingClientManager(manager ClientManager) *CachingClientManager {
+	return &CachingClientManager{ClientManager: manager}
+}
+
+// GetClient returns the cached client or loads it from the decorated ClientManager.
+func (c *CachingClientManager) GetClient(ctx context.Context, id string) (Client, error) {
+	c.mu.Lock()
+	if entry, ok := c.get(id); ok {
+		c.mu.Unlock()
+		return entry.client, entry.err
+	}
+
+	if call, ok := c.calls[id]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.client, call.err
+		case <-ctx.Done():
+			return nil, errors.WithStack(ctx.Err())
+		}
+	}
+
+	call := &clientCacheCall{done: make(chan struct{})}
+	if c.calls == nil {
+		c.calls = make(map[string]*clientCacheCall)
+	}
+	c.calls[id] = call
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	call.client, call.err = c.ClientManager.GetClient(ctx, id)
+	close(call.done)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.calls[id] == call {
+		delete(c.calls, id)
+	}
+
+	// Clients invalidated while the lookup was in flight might have been loaded before they were updated.
+	if epoch != c.epoch {
+		return call.client, call.err
+	}
+
+	now := TimeNow(c.Clock)
+	if call.err == nil {
+		c.set(&clientCacheEntry{id: id, expiresAt: now.Add(c.getTTL()), client: call.client})
+	} else if c.NegativeTTL > 0 && errors.Is(call.err, ErrNotFound) {
+		c.set(&clientCacheEntry{id: id, expiresAt: now.Add(c.NegativeTTL), err: call.err})
+	}
+	return call.client, call.err
+}
+
+// InvalidateClient removes the client with the given ID from the cache. It must be called whenever a client is
+// created, updated or deleted.
+func (c *CachingClientManager) InvalidateClient(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Lookups in flight right now might return the client as it was before, make sure they are not cached and not
+	// shared with later lookups.
+	c.epoch++
+	delete(c.calls, id)
+
+	if element, ok := c.entries[id]; ok {
+		c.remove(element)
+	}
+}
+
+// InvalidateAll removes all clients from the cache.
+func (c *CachingClientManager) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.epoch++
+	c.calls = nil
+	c.entries = nil
+	c.lru = nil
+}
+
+// CachingClientStorage is a CachingClientManager which writes clients through to the decorated storage and
+// invalidates them in the cache, so that it can be used as ClientRegistrationStorage.
+type CachingClientStorage struct {
+	*CachingClientManager
+
+	storage ClientRegistrationStorage
+}
+
+var _ ClientRegistrationStorage = new(CachingClientStorage)
+
+// NewCachingClientStorage returns a CachingClientStorage for storage using the default limits.
+func NewCachingClientStorage(storage interface {
+	ClientManager
+	ClientRegistrationStorage
+}) *CachingClientStorage {
+	return &CachingClientStorage{CachingClientManager: NewCachingClientManager(storage), storage: storage}
+}
+
+// CreateClient implements ClientWriter.
+func (c *CachingClientStorage) CreateClient(ctx context.Context, client Client) error {
+	// Unknown clients might be cached.
+	defer c.InvalidateClient(client.GetID())
+	return c.storage.CreateClient(ctx, client)
+}
+
+// UpdateClient implements ClientWriter.
+func (c *CachingClientStorage) UpdateClient(ctx context.Context, client Client) error {
+	defer c.InvalidateClient(client.GetID())
+	return c.storage.UpdateClient(ctx, client)
+}
+
+// DeleteClient implements ClientWriter.
+func (c *CachingClientStorage) DeleteClient(ctx context.Context, id string) error {
+	defer c.InvalidateClient(id)
+	return c.storage.DeleteClient(ctx, id)
+}
+
+// ListClients implements ClientWriter. Listed clients are neither read from nor added to the cache.
+func (c *CachingClientStorage) ListClients(ctx context.Context, limit int, pageToken string) ([]Client, string, error) {
+	return c.storage.ListClients(ctx, limit, pageToken)
+}
+
+// SetRegistrationAccessTokenSignature implements ClientRegistrationStorage.
+func (c *CachingClientStorage) SetRegistrationAccessTokenSignature(ctx context.Context, clientID string, signature string) error {
+	return c.storage.SetRegistrationAccessTokenSignature(ctx, clientID, signature)
+}
+
+// GetRegistrationAccessTokenSignature implements ClientRegistrationStorage.
+func (c *CachingClientStorage) GetRegistrationAccessTokenSignature(ctx context.Context, clientID string) (string, error) {
+	return c.storage.GetRegistrationAccessTokenSignature(ctx, clientID)
+}
+
+func (c *CachingClientManager) get(id string) (*clientCacheEntry, bool) {
+	element, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*clientCacheEntry)
+	if !entry.expiresAt.After(TimeNow(c.Clock)) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+func (c *CachingClientManager) set(entry *clientCacheEntry) {
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.lru = list.New()
+	}
+
+	if element, ok := c.entries[entry.id]; ok {
+		c.remove(element)
+	}
+
+	c.entries[entry.id] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.getMaxEntries() {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachingClientManager) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*clientCacheEntry)
+	delete(c.entries, entry.id)
+}
+
+func (c *CachingClientManager) getMaxEntries() int {
+	if c.MaxEntries <= 0 {
+		return defaultClientCacheMaxEntries
+	}
+	return c.MaxEntries
+}
+
+func (c *CachingClientManager) getTTL() time.Duration {
+	if c.TTL <= 0 {
+		return defaultClientCacheTTL
+	}
+	return c.TTL
+}
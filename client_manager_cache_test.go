@@ -0,0 +1,203 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+// countingClientManager counts the lookups reaching the store and blocks them until release is closed.
+type countingClientManager struct {
+	*storage.MemoryStore
+	lookups int32
+	release chan struct{}
+}
+
+func (m *countingClientManager) GetClient(ctx context.Context, id string) (Client, error) {
+	atomic.AddInt32(&m.lookups, 1)
+	if m.release != nil {
+		<-m.release
+	}
+	return m.MemoryStore.GetClient(ctx, id)
+}
+
+func TestCachingClientManager(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	newCache := func() (*CachingClientManager, *countingClientManager) {
+		store := &countingClientManager{MemoryStore: storage.NewMemoryStore()}
+		store.Clients["foo"] = &DefaultClient{ID: "foo"}
+		cache := NewCachingClientManager(store)
+		cache.Clock = fixedClock(now)
+		return cache, store
+	}
+
+	t.Run("case=clients are cached for TTL", func(t *testing.T) {
+		cache, store := newCache()
+		for i := 0; i < 3; i++ {
+			client, err := cache.GetClient(ctx, "foo")
+			require.NoError(t, err)
+			assert.Equal(t, "foo", client.GetID())
+		}
+		assert.EqualValues(t, 1, store.lookups)
+
+		cache.Clock = fixedClock(now.Add(time.Minute))
+		_, err := cache.GetClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, store.lookups)
+	})
+
+	t.Run("case=invalidation", func(t *testing.T) {
+		cache, store := newCache()
+		_, err := cache.GetClient(ctx, "foo")
+		require.NoError(t, err)
+
+		store.Clients["foo"] = &DefaultClient{ID: "foo", Public: true}
+		cache.InvalidateClient("foo")
+		client, err := cache.GetClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.True(t, client.IsPublic())
+
+		cache.InvalidateAll()
+		_, err = cache.GetClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.EqualValues(t, 3, store.lookups)
+	})
+
+	t.Run("case=least recently used clients are evicted", func(t *testing.T) {
+		cache, store := newCache()
+		cache.MaxEntries = 1
+		store.Clients["bar"] = &DefaultClient{ID: "bar"}
+
+		for _, id := range []string{"foo", "bar", "bar", "foo"} {
+			_, err := cache.GetClient(ctx, id)
+			require.NoError(t, err)
+		}
+		assert.EqualValues(t, 3, store.lookups)
+	})
+
+	t.Run("case=unknown clients", func(t *testing.T) {
+		cache, store := newCache()
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetClient(ctx, "unknown")
+			assert.True(t, errors.Is(err, ErrNotFound))
+		}
+		assert.EqualValues(t, 2, store.lookups, "unknown clients are not cached by default")
+
+		cache.NegativeTTL = time.Second
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetClient(ctx, "unknown")
+			assert.True(t, errors.Is(err, ErrNotFound))
+		}
+		assert.EqualValues(t, 3, store.lookups)
+
+		cache.Clock = fixedClock(now.Add(time.Second))
+		_, err := cache.GetClient(ctx, "unknown")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.EqualValues(t, 4, store.lookups)
+	})
+
+	t.Run("case=concurrent lookups are deduplicated", func(t *testing.T) {
+		cache, store := newCache()
+		store.release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client, err := cache.GetClient(ctx, "foo")
+				if assert.NoError(t, err) {
+					assert.Equal(t, "foo", client.GetID())
+				}
+			}()
+		}
+
+		for atomic.LoadInt32(&store.lookups) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		// Give the other lookups time to wait for the one in flight.
+		time.Sleep(10 * time.Millisecond)
+		close(store.release)
+		wg.Wait()
+		assert.EqualValues(t, 1, store.lookups)
+	})
+
+	t.Run("case=lookups in flight are not cached when invalidated", func(t *testing.T) {
+		cache, store := newCache()
+		store.release = make(chan struct{})
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := cache.GetClient(ctx, "foo")
+			assert.NoError(t, err)
+		}()
+
+		for atomic.LoadInt32(&store.lookups) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cache.InvalidateClient("foo")
+		close(store.release)
+		<-done
+
+		_, err := cache.GetClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, store.lookups)
+	})
+
+	t.Run("case=clients written through the cache are invalidated", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		store.Hasher = &BCrypt{WorkFactor: 4}
+		cache := NewCachingClientStorage(store)
+		cache.Clock = fixedClock(now)
+		cache.NegativeTTL = time.Minute
+
+		_, err := cache.GetClient(ctx, "bar")
+		assert.True(t, errors.Is(err, ErrNotFound))
+		require.NoError(t, cache.CreateClient(ctx, &DefaultClient{ID: "bar", Public: true, GrantTypes: []string{"client_credentials"}}))
+		client, err := cache.GetClient(ctx, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, Arguments{"client_credentials"}, client.GetGrantTypes())
+
+		require.NoError(t, cache.UpdateClient(ctx, &DefaultClient{ID: "bar", Public: true, GrantTypes: []string{"refresh_token"}}))
+		client, err = cache.GetClient(ctx, "bar")
+		require.NoError(t, err)
+		assert.Equal(t, Arguments{"refresh_token"}, client.GetGrantTypes())
+
+		require.NoError(t, cache.DeleteClient(ctx, "bar"))
+		_, err = cache.GetClient(ctx, "bar")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
@@ -23,6 +23,7 @@ package fosite
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -52,4 +53,16 @@ func TestDefaultClient(t *testing.T) {
 	assert.Equal(t, "authorization_code", sc.GetGrantTypes()[0])
 
 	var _ ClientWithSecretRotation = sc
+	var _ ClientWithSecretExpiry = sc
+}
+
+func TestDefaultClientRotateSecret(t *testing.T) {
+	now := time.Now()
+	c := &DefaultClient{ID: "1", Secret: []byte("secret-2"), RotatedSecrets: [][]byte{[]byte("secret-1")}}
+
+	c.RotateSecret([]byte("secret-3"), now.Add(time.Hour), now.Add(time.Minute))
+	assert.Equal(t, []byte("secret-3"), c.GetHashedSecret())
+	assert.Equal(t, now.Add(time.Hour), c.GetSecretExpiresAt())
+	assert.Equal(t, [][]byte{[]byte("secret-1"), []byte("secret-2")}, c.GetRotatedHashes())
+	assert.Equal(t, []time.Time{{}, now.Add(time.Minute)}, c.GetRotatedSecretsExpiresAt())
 }
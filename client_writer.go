@@ -0,0 +1,247 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+)
+
+// DefaultClientListLimit is the number of clients listed by ClientWriter.ListClients if no limit is given.
+const DefaultClientListLimit = 100
+
+// ClientWriter creates, updates, deletes and lists the clients of a ClientManager.
+type ClientWriter interface {
+	// CreateClient validates the client using ValidateClient and stores it. The secret of the client is expected in
+	// plain text and replaced by its hash before the client is stored, the client passed in is not modified. Rotated
+	// secrets are expected to be hashes already. It returns ErrConflict if a client with the same ID exists.
+	CreateClient(ctx context.Context, client Client) error
+
+	// UpdateClient validates the client using ValidateClient and replaces the stored client with the same ID. The
+	// secret is hashed like in CreateClient. An empty secret or the hash of the stored secret, as returned by
+	// GetClient, keeps the stored secret. It returns ErrNotFound if the client does not exist.
+	UpdateClient(ctx context.Context, client Client) error
+
+	// DeleteClient deletes the client with the given ID. It returns ErrNotFound if the client does not exist.
+	DeleteClient(ctx context.Context, id string) error
+
+	// ListClients returns up to limit clients ordered by ID, starting after the client with ID pageToken. It returns
+	// the page token of the next page, which is empty on the last page. Limit defaults to DefaultClientListLimit.
+	ListClients(ctx context.Context, limit int, pageToken string) (clients []Client, nextPageToken string, err error)
+}
+
+// ClientWithSecretHashing is a Client whose secret can be replaced by its hash. Clients passed to a ClientWriter
+// must implement it to have their secret hashed.
+type ClientWithSecretHashing interface {
+	Client
+
+	// WithHashedSecret returns a copy of the client with its secret replaced by hash. The client itself is not
+	// modified.
+	WithHashedSecret(hash []byte) Client
+}
+
+// HashClientSecret returns a copy of the client with its plain text secret replaced by its hash, or the client itself
+// if it has no secret. Rotated secrets are not hashed, they are expected to be hashes already.
+func HashClientSecret(ctx context.Context, hasher Hasher, client Client) (Client, error) {
+	secret := client.GetHashedSecret()
+	if len(secret) == 0 {
+		return client, nil
+	}
+
+	c, ok := client.(ClientWithSecretHashing)
+	if !ok {
+		return nil, errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The secret of client '%s' can not be hashed because the client does not implement fosite.ClientWithSecretHashing.", client.GetID()))
+	}
+
+	hash, err := hasher.Hash(ctx, secret)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return c.WithHashedSecret(hash), nil
+}
+
+// PrepareClient validates the client using ValidateClient and hashes its secret using HashClientSecret, as required
+// before a ClientWriter stores it. It returns the client to be stored, the client passed in is not modified.
+//
+// When updating a client, stored is the client as stored right now. Its secret is kept if the updated confidential
+// client has none or has the stored hash as secret, for example because it has been read with GetClient.
+func PrepareClient(ctx context.Context, hasher Hasher, client Client, stored Client) (Client, error) {
+	if stored != nil && !client.IsPublic() && len(stored.GetHashedSecret()) > 0 &&
+		(len(client.GetHashedSecret()) == 0 || bytes.Equal(client.GetHashedSecret(), stored.GetHashedSecret())) {
+		c, ok := client.(ClientWithSecretHashing)
+		if !ok {
+			return nil, errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("The secret of client '%s' can not be kept because the client does not implement fosite.ClientWithSecretHashing.", client.GetID()))
+		}
+		prepared := c.WithHashedSecret(stored.GetHashedSecret())
+		if err := ValidateClient(prepared); err != nil {
+			return nil, err
+		}
+		return prepared, nil
+	}
+
+	if err := ValidateClient(client); err != nil {
+		return nil, err
+	}
+	return HashClientSecret(ctx, hasher, client)
+}
+
+// ValidateClient validates the metadata of a client before it is stored:
+//
+// - Redirect URIs must be absolute, must not contain a fragment and must use TLS unless they point to a loopback
+// interface.
+// - Clients using the authorization code or implicit grant must have at least one redirect URI, and the response
+// types must match the grant types as described in RFC 7591, Section 2.1.
+// - Public clients must not have a secret, confidential clients must have one unless they authenticate with
+// private_key_jwt.
+// - Clients authenticating with private_key_jwt must have either JSON Web Keys or a JSON Web Key Set URI, but not
+// both.
+func ValidateClient(client Client) error {
+	if client.GetID() == "" {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("The client ID must not be empty."))
+	}
+
+	for _, redirectURI := range client.GetRedirectURIs() {
+		if err := validateClientRedirectURI(redirectURI); err != nil {
+			return err
+		}
+	}
+
+	if err := validateClientGrantTypes(client); err != nil {
+		return err
+	}
+	return validateClientAuthentication(client)
+}
+
+func validateClientRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("Redirect URI '%s' is not a valid URL.", redirectURI).WithWrap(err).WithDebug(err.Error()))
+	} else if !u.IsAbs() {
+		return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("Redirect URI '%s' must be an absolute URI.", redirectURI))
+	} else if u.Fragment != "" || strings.Contains(redirectURI, "#") {
+		return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("Redirect URI '%s' must not contain a fragment.", redirectURI))
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("Redirect URI '%s' must contain a host.", redirectURI))
+		}
+	case "http":
+		if !isLoopbackHost(u.Hostname()) {
+			return errorsx.WithStack(ErrInvalidRedirectURI.WithHintf("Redirect URI '%s' must use the https scheme unless it points to a loopback interface.", redirectURI))
+		}
+	}
+	return nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func validateClientGrantTypes(client Client) error {
+	grantTypes := client.GetGrantTypes()
+	usesAuthorizationCode, usesImplicit := grantTypes.Has("authorization_code"), grantTypes.Has("implicit")
+	if !usesAuthorizationCode && !usesImplicit {
+		// Response types are only used by the authorization endpoint.
+		return nil
+	}
+
+	if len(client.GetRedirectURIs()) == 0 {
+		return errorsx.WithStack(ErrInvalidRedirectURI.WithHint("Clients using the authorization_code or implicit grant must have at least one redirect URI."))
+	}
+
+	var hasCode, hasToken bool
+	for _, responseType := range client.GetResponseTypes() {
+		for _, value := range strings.Fields(responseType) {
+			switch value {
+			case "code":
+				if !usesAuthorizationCode {
+					return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Response type '%s' requires grant type 'authorization_code'.", responseType))
+				}
+				hasCode = true
+			case "token", "id_token":
+				if !usesImplicit {
+					return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Response type '%s' requires grant type 'implicit'.", responseType))
+				}
+				hasToken = true
+			}
+		}
+	}
+
+	if usesAuthorizationCode && !hasCode {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Grant type 'authorization_code' requires a response type containing 'code'."))
+	} else if usesImplicit && !hasToken {
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Grant type 'implicit' requires a response type containing 'token' or 'id_token'."))
+	}
+	return nil
+}
+
+func validateClientAuthentication(client Client) error {
+	method := ""
+	if oidcClient, ok := client.(OpenIDConnectClient); ok {
+		method = oidcClient.GetTokenEndpointAuthMethod()
+
+		hasJWKS, hasJWKSURI := oidcClient.GetJSONWebKeys() != nil, oidcClient.GetJSONWebKeysURI() != ""
+		if hasJWKS && hasJWKSURI {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("The client must not have both JSON Web Keys and a JSON Web Key Set URI."))
+		} else if method == "private_key_jwt" && !hasJWKS && !hasJWKSURI {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Clients authenticating with 'private_key_jwt' must have JSON Web Keys or a JSON Web Key Set URI."))
+		}
+	}
+
+	hasSecret := len(client.GetHashedSecret()) > 0
+	switch method {
+	case "", "client_secret_basic", "client_secret_post":
+		if client.IsPublic() {
+			if method != "" {
+				return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Public clients must use token endpoint authentication method 'none', not '%s'.", method))
+			} else if hasSecret {
+				return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Public clients must not have a secret."))
+			}
+		} else if !hasSecret {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Confidential clients must have a secret."))
+		}
+	case "none":
+		if !client.IsPublic() {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Token endpoint authentication method 'none' is only allowed for public clients."))
+		} else if hasSecret {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Public clients must not have a secret."))
+		}
+	case "private_key_jwt":
+		if client.IsPublic() {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Public clients must use token endpoint authentication method 'none'."))
+		}
+	default:
+		return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Token endpoint authentication method '%s' is not supported.", method))
+	}
+	return nil
+}
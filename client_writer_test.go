@@ -0,0 +1,208 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+)
+
+func TestValidateClient(t *testing.T) {
+	newClient := func() *DefaultClient {
+		return &DefaultClient{
+			ID:            "foo",
+			Secret:        []byte("secret"),
+			RedirectURIs:  []string{"https://example.com/cb"},
+			GrantTypes:    []string{"authorization_code", "refresh_token"},
+			ResponseTypes: []string{"code"},
+		}
+	}
+	newOIDCClient := func(method string) *DefaultOpenIDConnectClient {
+		return &DefaultOpenIDConnectClient{DefaultClient: newClient(), TokenEndpointAuthMethod: method}
+	}
+
+	for _, tc := range []struct {
+		description string
+		client      func() Client
+		expectErr   error
+	}{
+		{description: "valid client", client: func() Client { return newClient() }},
+		{
+			description: "empty ID",
+			client:      func() Client { c := newClient(); c.ID = ""; return c },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "loopback redirect URI without TLS",
+			client: func() Client {
+				c := newClient()
+				c.RedirectURIs = []string{"http://127.0.0.1:8080/cb", "http://localhost/cb"}
+				return c
+			},
+		},
+		{
+			description: "custom scheme redirect URI",
+			client:      func() Client { c := newClient(); c.RedirectURIs = []string{"com.example.app:/cb"}; return c },
+		},
+		{
+			description: "redirect URI without TLS",
+			client:      func() Client { c := newClient(); c.RedirectURIs = []string{"http://example.com/cb"}; return c },
+			expectErr:   ErrInvalidRedirectURI,
+		},
+		{
+			description: "relative redirect URI",
+			client:      func() Client { c := newClient(); c.RedirectURIs = []string{"/cb"}; return c },
+			expectErr:   ErrInvalidRedirectURI,
+		},
+		{
+			description: "redirect URI with fragment",
+			client:      func() Client { c := newClient(); c.RedirectURIs = []string{"https://example.com/cb#foo"}; return c },
+			expectErr:   ErrInvalidRedirectURI,
+		},
+		{
+			description: "authorization code grant without redirect URIs",
+			client:      func() Client { c := newClient(); c.RedirectURIs = nil; return c },
+			expectErr:   ErrInvalidRedirectURI,
+		},
+		{
+			description: "client credentials grant without redirect URIs",
+			client: func() Client {
+				c := newClient()
+				c.RedirectURIs, c.GrantTypes, c.ResponseTypes = nil, []string{"client_credentials"}, nil
+				return c
+			},
+		},
+		{
+			description: "response type token without implicit grant",
+			client:      func() Client { c := newClient(); c.ResponseTypes = []string{"code", "token"}; return c },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "hybrid response type",
+			client: func() Client {
+				c := newClient()
+				c.GrantTypes, c.ResponseTypes = []string{"authorization_code", "implicit"}, []string{"code id_token"}
+				return c
+			},
+		},
+		{
+			description: "implicit grant without token response type",
+			client:      func() Client { c := newClient(); c.GrantTypes = []string{"implicit"}; return c },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "confidential client without secret",
+			client:      func() Client { c := newClient(); c.Secret = nil; return c },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "public client with secret",
+			client:      func() Client { c := newClient(); c.Public = true; return c },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "public client",
+			client: func() Client {
+				c := newOIDCClient("none")
+				c.Public, c.Secret = true, nil
+				return c
+			},
+		},
+		{
+			description: "auth method none for confidential client",
+			client:      func() Client { return newOIDCClient("none") },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "private_key_jwt without keys",
+			client:      func() Client { return newOIDCClient("private_key_jwt") },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+		{
+			description: "private_key_jwt with key set URI",
+			client: func() Client {
+				c := newOIDCClient("private_key_jwt")
+				c.Secret, c.JSONWebKeysURI = nil, "https://example.com/jwks.json"
+				return c
+			},
+		},
+		{
+			description: "keys and key set URI",
+			client: func() Client {
+				c := newOIDCClient("private_key_jwt")
+				c.JSONWebKeysURI, c.JSONWebKeys = "https://example.com/jwks.json", new(jose.JSONWebKeySet)
+				return c
+			},
+			expectErr: ErrInvalidClientMetadata,
+		},
+		{
+			description: "unsupported auth method",
+			client:      func() Client { return newOIDCClient("client_secret_jwt") },
+			expectErr:   ErrInvalidClientMetadata,
+		},
+	} {
+		t.Run("case="+tc.description, func(t *testing.T) {
+			err := ValidateClient(tc.client())
+			if tc.expectErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tc.expectErr), "%+v", err)
+			}
+		})
+	}
+}
+
+func TestPrepareClient(t *testing.T) {
+	ctx := context.Background()
+	hasher := &BCrypt{WorkFactor: 4}
+	newClient := func(secret string) *DefaultClient {
+		return &DefaultClient{ID: "foo", Secret: []byte(secret), GrantTypes: []string{"client_credentials"}}
+	}
+
+	plain := newClient("secret")
+	client, err := PrepareClient(ctx, hasher, plain, nil)
+	require.NoError(t, err)
+	require.NoError(t, hasher.Compare(ctx, client.GetHashedSecret(), []byte("secret")))
+	assert.Equal(t, []byte("secret"), plain.Secret, "the client passed in is not modified")
+
+	updated, err := PrepareClient(ctx, hasher, newClient(""), client)
+	require.NoError(t, err)
+	assert.Equal(t, client.GetHashedSecret(), updated.GetHashedSecret())
+
+	updated, err = PrepareClient(ctx, hasher, newClient(string(client.GetHashedSecret())), client)
+	require.NoError(t, err)
+	assert.Equal(t, client.GetHashedSecret(), updated.GetHashedSecret(), "the stored hash is not hashed again")
+
+	updated, err = PrepareClient(ctx, hasher, newClient("new-secret"), client)
+	require.NoError(t, err)
+	require.NoError(t, hasher.Compare(ctx, updated.GetHashedSecret(), []byte("new-secret")))
+
+	_, err = PrepareClient(ctx, hasher, newClient(""), nil)
+	assert.True(t, errors.Is(err, ErrInvalidClientMetadata))
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rsa"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/token/jwt"
 )
 
@@ -15,23 +16,50 @@ func Compose(config *Config, storage interface{}, strategy interface{}, hasher f
 	}
 
 	f := &fosite.Fosite{
-		Store:                        storage.(fosite.Storage),
-		AuthorizeEndpointHandlers:    fosite.AuthorizeEndpointHandlers{},
-		TokenEndpointHandlers:        fosite.TokenEndpointHandlers{},
-		TokenIntrospectionHandlers:   fosite.TokenIntrospectionHandlers{},
-		RevocationHandlers:           fosite.RevocationHandlers{},
-		Hasher:                       hasher,
-		ScopeStrategy:                config.GetScopeStrategy(),
-		AudienceMatchingStrategy:     config.GetAudienceStrategy(),
-		SendDebugMessagesToClients:   config.SendDebugMessagesToClients,
-		TokenURL:                     config.TokenURL,
-		JWKSFetcherStrategy:          config.GetJWKSFetcherStrategy(),
-		MinParameterEntropy:          config.GetMinParameterEntropy(),
-		UseLegacyErrorFormat:         config.UseLegacyErrorFormat,
-		ClientAuthenticationStrategy: config.GetClientAuthenticationStrategy(),
-		ResponseModeHandlerExtension: config.ResponseModeHandlerExtension,
-		MessageCatalog:               config.MessageCatalog,
-		FormPostHTMLTemplate:         config.FormPostHTMLTemplate,
+		Store:                           storage.(fosite.Storage),
+		AuthorizeEndpointHandlers:       fosite.AuthorizeEndpointHandlers{},
+		TokenEndpointHandlers:           fosite.TokenEndpointHandlers{},
+		TokenIntrospectionHandlers:      fosite.TokenIntrospectionHandlers{},
+		RevocationHandlers:              fosite.RevocationHandlers{},
+		Hasher:                          hasher,
+		ScopeStrategy:                   config.GetScopeStrategy(),
+		AudienceMatchingStrategy:        config.GetAudienceStrategy(),
+		SendDebugMessagesToClients:      config.SendDebugMessagesToClients,
+		TokenURL:                        config.TokenURL,
+		JWKSFetcherStrategy:             config.GetJWKSFetcherStrategy(),
+		MinParameterEntropy:             config.GetMinParameterEntropy(),
+		UseLegacyErrorFormat:            config.UseLegacyErrorFormat,
+		ClientAuthenticationStrategy:    config.GetClientAuthenticationStrategy(),
+		ResponseModeHandlerExtension:    config.ResponseModeHandlerExtension,
+		MessageCatalog:                  config.MessageCatalog,
+		FormPostHTMLTemplate:            config.FormPostHTMLTemplate,
+		Clock:                           config.GetClock(),
+		ClockSkewLeeway:                 config.ClockSkewLeeway,
+		IntrospectionScope:              config.IntrospectionScope,
+		IntrospectionAudienceRestricted: config.IntrospectionAudienceRestricted,
+		IntrospectionResponseBuilder:    config.GetIntrospectionResponseBuilder(),
+		ClientSecretRehashHook:          config.ClientSecretRehashHook,
+
+		ClientRegistrationURL:                        config.ClientRegistrationURL,
+		ClientRegistrationInitialAccessTokenStrategy: config.ClientRegistrationInitialAccessTokenStrategy,
+		ClientRegistrationOpen:                       config.ClientRegistrationOpen,
+		ClientRegistrationAllowedScopes:              config.ClientRegistrationAllowedScopes,
+		ClientRegistrationAllowedGrantTypes:          config.ClientRegistrationAllowedGrantTypes,
+		ClientRegistrationAllowedAudiences:           config.ClientRegistrationAllowedAudiences,
+		ClientRegistrationSoftwareStatementKeys:      config.ClientRegistrationSoftwareStatementKeys,
+		ClientRegistrationSoftwareStatementRequired:  config.ClientRegistrationSoftwareStatementRequired,
+	}
+
+	if config.IntrospectionJWTResponseIssuer != "" {
+		if cs, ok := strategy.(*CommonStrategy); ok && cs.JWTStrategy != nil {
+			f.IntrospectionJWTResponseStrategy = cs.JWTStrategy
+			f.IntrospectionJWTResponseIssuer = config.IntrospectionJWTResponseIssuer
+		}
+	}
+
+	// JSON Web Token access tokens are rejected once their jti has been revoked, if the storage keeps a denylist.
+	if denylist, ok := storage.(oauth2.JTIDenylistStorage); ok {
+		setJWTDenylist(strategy, denylist)
 	}
 
 	for _, factory := range factories {
@@ -53,6 +81,16 @@ func Compose(config *Config, storage interface{}, strategy interface{}, hasher f
 	return f
 }
 
+// setJWTDenylist sets the denylist of the JWT access token strategy, unless it has one already.
+func setJWTDenylist(strategy interface{}, denylist oauth2.JTIDenylistStorage) {
+	if cs, ok := strategy.(*CommonStrategy); ok {
+		strategy = cs.CoreStrategy
+	}
+	if js, ok := strategy.(*oauth2.DefaultJWTStrategy); ok && js.Denylist == nil {
+		js.Denylist = denylist
+	}
+}
+
 func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *rsa.PrivateKey) fosite.OAuth2Provider {
 	return Compose(
 		config,
@@ -62,6 +100,8 @@ func ComposeAllEnabled(config *Config, storage interface{}, secret []byte, key *
 			OpenIDConnectTokenStrategy: NewOpenIDConnectStrategy(config, key),
 			JWTStrategy: &jwt.RS256JWTStrategy{
 				PrivateKey: key,
+				Clock:      config.GetClock(),
+				Leeway:     config.ClockSkewLeeway,
 			},
 		},
 		nil,
@@ -16,6 +16,7 @@ func OAuth2ClientCredentialsGrantFactory(config *Config, storage interface{}, st
 			AccessTokenStrategy: strategy.(oauth2.AccessTokenStrategy),
 			AccessTokenStorage:  storage.(oauth2.AccessTokenStorage),
 			AccessTokenLifespan: config.GetAccessTokenLifespan(),
+			Clock:               config.GetClock(),
 		},
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
@@ -31,9 +32,12 @@ func OAuth2RefreshTokenGrantFactory(config *Config, storage interface{}, strateg
 		TokenRevocationStorage:   storage.(oauth2.TokenRevocationStorage),
 		AccessTokenLifespan:      config.GetAccessTokenLifespan(),
 		RefreshTokenLifespan:     config.GetRefreshTokenLifespan(),
+		RefreshTokenGracePeriod:  config.RefreshTokenGracePeriod,
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
 		RefreshTokenScopes:       config.GetRefreshTokenScopes(),
+		Clock:                    config.GetClock(),
+		RevocationListeners:      config.RevocationListeners,
 	}
 }
 
@@ -43,6 +47,7 @@ func OAuth2TokenRevocationFactory(config *Config, storage interface{}, strategy
 		TokenRevocationStorage: storage.(oauth2.TokenRevocationStorage),
 		AccessTokenStrategy:    strategy.(oauth2.AccessTokenStrategy),
 		RefreshTokenStrategy:   strategy.(oauth2.RefreshTokenStrategy),
+		RevocationListeners:    config.RevocationListeners,
 	}
 }
 
@@ -62,11 +67,16 @@ func OAuth2TokenIntrospectionFactory(config *Config, storage interface{}, strate
 // statelessly, meaning it uses only the data available in the JWT itself, and does not access the
 // storage implementation at all.
 //
-// Due to the stateless nature of this factory, THE BUILT-IN REVOCATION MECHANISMS WILL NOT WORK.
-// If you need revocation, you can validate JWTs statefully, using the other factories.
+// Due to the stateless nature of this factory, THE BUILT-IN REVOCATION MECHANISMS WILL NOT WORK unless the storage
+// implements oauth2.JTIDenylistStorage, in which case revoked JWTs are rejected using the denylist. If you need
+// revocation otherwise, you can validate JWTs statefully, using the other factories.
 func OAuth2StatelessJWTIntrospectionFactory(config *Config, storage interface{}, strategy interface{}) interface{} {
+	denylist, _ := storage.(oauth2.JTIDenylistStorage)
 	return &oauth2.StatelessJWTValidator{
 		JWTStrategy:   strategy.(jwt.JWTStrategy),
 		ScopeStrategy: config.GetScopeStrategy(),
+		Clock:         config.GetClock(),
+		Leeway:        config.ClockSkewLeeway,
+		Denylist:      denylist,
 	}
 }
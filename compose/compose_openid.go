@@ -8,6 +8,7 @@ func OpenIDConnectRefreshFactory(config *Config, storage interface{}, strategy i
 	return &openid.OpenIDConnectRefreshHandler{
 		IDTokenHandleHelper: &openid.IDTokenHandleHelper{
 			IDTokenStrategy: strategy.(openid.OpenIDConnectTokenStrategy),
+			Clock:           config.GetClock(),
 		},
 	}
 }
@@ -14,6 +14,7 @@ func OAuth2TokenExchangeFactory(config *Config, storage interface{}, strategy in
 			AccessTokenStrategy:  strategy.(oauth2.AccessTokenStrategy),
 			AccessTokenLifespan:  config.GetAccessTokenLifespan(),
 			RefreshTokenLifespan: config.GetRefreshTokenLifespan(),
+			Clock:                config.GetClock(),
 		},
 		ScopeStrategy:            config.GetScopeStrategy(),
 		AudienceMatchingStrategy: config.GetAudienceStrategy(),
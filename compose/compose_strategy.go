@@ -1,9 +1,12 @@
 package compose
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
+	"time"
 
+	"github.com/ory/fosite"
 	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/handler/openid"
 	"github.com/ory/fosite/token/hmac"
@@ -16,16 +19,55 @@ type CommonStrategy struct {
 	jwt.JWTStrategy
 }
 
+// DecodeAccessToken implements oauth2.JWTAccessTokenDecoder if the CoreStrategy does.
+func (s *CommonStrategy) DecodeAccessToken(ctx context.Context, token string) (*jwt.Token, error) {
+	return oauth2.DecodeAccessToken(ctx, s.CoreStrategy, token)
+}
+
 func NewOAuth2HMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *oauth2.HMACSHAStrategy {
-	return &oauth2.HMACSHAStrategy{
-		Enigma: &hmac.HMACStrategy{
-			GlobalSecret:         secret,
-			RotatedGlobalSecrets: rotatedSecrets,
-			TokenEntropy:         config.GetTokenEntropy(),
-		},
+	strategy := &oauth2.HMACSHAStrategy{
+		Enigma:                newHMACStrategy(config, secret, rotatedSecrets),
 		AccessTokenLifespan:   config.GetAccessTokenLifespan(),
 		AuthorizeCodeLifespan: config.GetAuthorizeCodeLifespan(),
 		RefreshTokenLifespan:  config.GetRefreshTokenLifespan(),
+		AccessTokenPrefix:     config.GetHMACTokenPrefix(fosite.AccessToken),
+		RefreshTokenPrefix:    config.GetHMACTokenPrefix(fosite.RefreshToken),
+		AuthorizeCodePrefix:   config.GetHMACTokenPrefix(fosite.AuthorizeCode),
+		Clock:                 config.GetClock(),
+	}
+
+	if config.HMACSecretPerTokenType {
+		strategy.AccessTokenEnigma = newHMACStrategy(config, hmac.DeriveSecret(secret, string(fosite.AccessToken)), hmac.DeriveSecrets(rotatedSecrets, string(fosite.AccessToken)))
+		strategy.RefreshTokenEnigma = newHMACStrategy(config, hmac.DeriveSecret(secret, string(fosite.RefreshToken)), hmac.DeriveSecrets(rotatedSecrets, string(fosite.RefreshToken)))
+		strategy.AuthorizeCodeEnigma = newHMACStrategy(config, hmac.DeriveSecret(secret, string(fosite.AuthorizeCode)), hmac.DeriveSecrets(rotatedSecrets, string(fosite.AuthorizeCode)))
+	}
+
+	return strategy
+}
+
+// NewOAuth2StatelessRefreshStrategy returns a strategy which issues encrypted, self-contained refresh tokens and
+// delegates access tokens and authorize codes to strategy. The secret must be at least 32 byte long and should differ
+// from the HMAC secret.
+func NewOAuth2StatelessRefreshStrategy(config *Config, strategy oauth2.CoreStrategy, storage oauth2.RefreshTokenFamilyStorage, secret []byte, rotatedSecrets [][]byte) *oauth2.StatelessRefreshTokenCoreStrategy {
+	return &oauth2.StatelessRefreshTokenCoreStrategy{
+		AccessTokenStrategy:   strategy,
+		AuthorizeCodeStrategy: strategy,
+		StatelessRefreshTokenStrategy: &oauth2.EncryptedRefreshTokenStrategy{
+			Secret:         secret,
+			RotatedSecrets: rotatedSecrets,
+			Storage:        storage,
+			Prefix:         config.GetHMACTokenPrefix(fosite.RefreshToken),
+			Clock:          config.GetClock(),
+		},
+	}
+}
+
+func newHMACStrategy(config *Config, secret []byte, rotatedSecrets [][]byte) *hmac.HMACStrategy {
+	return &hmac.HMACStrategy{
+		GlobalSecret:         secret,
+		RotatedGlobalSecrets: rotatedSecrets,
+		TokenEntropy:         config.GetTokenEntropy(),
+		UseKeyIDs:            config.HMACTokenKeyIDs,
 	}
 }
 
@@ -33,8 +75,10 @@ func NewOAuth2JWTStrategy(key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy)
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.RS256JWTStrategy{
 			PrivateKey: key,
+			Clock:      strategy.Clock,
 		},
 		HMACSHAStrategy: strategy,
+		Clock:           strategy.Clock,
 	}
 }
 
@@ -42,9 +86,33 @@ func NewOAuth2JWTECDSAStrategy(key *ecdsa.PrivateKey, strategy *oauth2.HMACSHASt
 	return &oauth2.DefaultJWTStrategy{
 		JWTStrategy: &jwt.ES256JWTStrategy{
 			PrivateKey: key,
+			Clock:      strategy.Clock,
 		},
 		HMACSHAStrategy: strategy,
+		Clock:           strategy.Clock,
+	}
+}
+
+// NewOAuth2JWTStrategyWithConfig works like NewOAuth2JWTStrategy but also applies the clock skew leeway of config.
+func NewOAuth2JWTStrategyWithConfig(config *Config, key *rsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	return withLeeway(NewOAuth2JWTStrategy(key, strategy), config.ClockSkewLeeway)
+}
+
+// NewOAuth2JWTECDSAStrategyWithConfig works like NewOAuth2JWTECDSAStrategy but also applies the clock skew leeway of
+// config.
+func NewOAuth2JWTECDSAStrategyWithConfig(config *Config, key *ecdsa.PrivateKey, strategy *oauth2.HMACSHAStrategy) *oauth2.DefaultJWTStrategy {
+	return withLeeway(NewOAuth2JWTECDSAStrategy(key, strategy), config.ClockSkewLeeway)
+}
+
+func withLeeway(strategy *oauth2.DefaultJWTStrategy, leeway time.Duration) *oauth2.DefaultJWTStrategy {
+	switch s := strategy.JWTStrategy.(type) {
+	case *jwt.RS256JWTStrategy:
+		s.Leeway = leeway
+	case *jwt.ES256JWTStrategy:
+		s.Leeway = leeway
 	}
+	strategy.Leeway = leeway
+	return strategy
 }
 
 // Deprecated: Use NewOAuth2JWTStrategy(key, strategy).WithIssuer(issuer) instead.
@@ -61,10 +129,13 @@ func NewOpenIDConnectStrategy(config *Config, key *rsa.PrivateKey) *openid.Defau
 	return &openid.DefaultStrategy{
 		JWTStrategy: &jwt.RS256JWTStrategy{
 			PrivateKey: key,
+			Clock:      config.GetClock(),
+			Leeway:     config.ClockSkewLeeway,
 		},
 		Expiry:              config.GetIDTokenLifespan(),
 		Issuer:              config.IDTokenIssuer,
 		MinParameterEntropy: config.GetMinParameterEntropy(),
+		Clock:               config.GetClock(),
 	}
 }
 
@@ -72,9 +143,12 @@ func NewOpenIDConnectECDSAStrategy(config *Config, key *ecdsa.PrivateKey) *openi
 	return &openid.DefaultStrategy{
 		JWTStrategy: &jwt.ES256JWTStrategy{
 			PrivateKey: key,
+			Clock:      config.GetClock(),
+			Leeway:     config.ClockSkewLeeway,
 		},
 		Expiry:              config.GetIDTokenLifespan(),
 		Issuer:              config.IDTokenIssuer,
 		MinParameterEntropy: config.GetMinParameterEntropy(),
+		Clock:               config.GetClock(),
 	}
 }
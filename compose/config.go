@@ -5,7 +5,10 @@ import (
 	"net/url"
 	"time"
 
+	"gopkg.in/square/go-jose.v2"
+
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
 	"github.com/ory/fosite/i18n"
 )
 
@@ -17,6 +20,11 @@ type Config struct {
 	// refresh tokens that never expire.
 	RefreshTokenLifespan time.Duration
 
+	// RefreshTokenGracePeriod sets how long a rotated refresh token remains usable, returning the same tokens it was
+	// rotated to. The window is passed to the storage which must implement oauth2.RefreshTokenGracePeriodStorage.
+	// Defaults to zero, which disables the grace period.
+	RefreshTokenGracePeriod time.Duration
+
 	// AuthorizeCodeLifespan sets how long an authorize code is going to be valid. Defaults to fifteen minutes.
 	AuthorizeCodeLifespan time.Duration
 
@@ -68,6 +76,20 @@ type Config struct {
 	// Defaults to 32.
 	TokenEntropy int
 
+	// HMACTokenPrefix, if set, is prepended to HMAC tokens together with an abbreviation of the token type. For
+	// example "xx" results in access tokens starting with "xx_at_", refresh tokens with "xx_rt_" and authorize codes
+	// with "xx_ac_", which allows secret scanners to detect leaked tokens. Defaults to no prefix.
+	HMACTokenPrefix string
+
+	// HMACTokenKeyIDs, if set, embeds the identifier of the signing secret in HMAC tokens so that validation does not
+	// have to try every rotated secret. Tokens without a key identifier are still accepted. Defaults to false.
+	HMACTokenKeyIDs bool
+
+	// HMACSecretPerTokenType, if set, derives a separate secret for access tokens, refresh tokens and authorize codes
+	// from the global secret using HKDF. A token presented as the wrong type then fails signature validation. Enabling
+	// this option invalidates all previously issued HMAC tokens. Defaults to false.
+	HMACSecretPerTokenType bool
+
 	// RedirectSecureChecker is a function that returns true if the provided URL can be securely used as a redirect URL.
 	RedirectSecureChecker func(*url.URL) bool
 
@@ -104,6 +126,67 @@ type Config struct {
 
 	// FormPostHTMLTemplate sets html template for rendering the authorization response when the request has response_mode=form_post.
 	FormPostHTMLTemplate *template.Template
+
+	// Clock is used by all handlers and strategies for time-based validation. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+
+	// ClockSkewLeeway is the tolerated clock skew when validating the exp, nbf and iat claims of JSON Web Tokens, for
+	// example client assertions and ID token hints. Defaults to no leeway.
+	ClockSkewLeeway time.Duration
+
+	// IntrospectionJWTResponseIssuer enables JWT-secured introspection responses (RFC 9701) and sets the issuer of these
+	// responses. Responses are signed using the JWTStrategy of the composed strategy.
+	IntrospectionJWTResponseIssuer string
+
+	// IntrospectionScope is the scope required to call the introspection endpoint. Defaults to no required scope.
+	IntrospectionScope string
+
+	// IntrospectionAudienceRestricted reports tokens as inactive to callers which are not part of the token's audience.
+	IntrospectionAudienceRestricted bool
+
+	// IntrospectionTokenIssuer is reported as "iss" when introspecting tokens.
+	IntrospectionTokenIssuer string
+
+	// IntrospectionResponseBuilder builds introspection responses. Defaults to fosite.DefaultIntrospectionResponseBuilder.
+	IntrospectionResponseBuilder fosite.IntrospectionResponseBuilder
+
+	// ClientSecretRehashHook is called when a client secret has been verified against an outdated hash, see
+	// fosite.HasherWithRehash.
+	ClientSecretRehashHook fosite.ClientSecretRehashHook
+
+	// ClientRegistrationURL enables dynamic client registration at the given URL, see
+	// fosite.Fosite.NewRegistrationRequest. The storage must implement fosite.ClientRegistrationStorage.
+	ClientRegistrationURL string
+
+	// ClientRegistrationInitialAccessTokenStrategy validates the initial access tokens required to register clients.
+	ClientRegistrationInitialAccessTokenStrategy fosite.InitialAccessTokenStrategy
+
+	// ClientRegistrationOpen allows anyone to register clients without initial access token.
+	ClientRegistrationOpen bool
+
+	// ClientRegistrationAllowedScopes, ClientRegistrationAllowedGrantTypes and ClientRegistrationAllowedAudiences
+	// restrict the scopes, grant types and audiences of registered clients. A nil list does not restrict them.
+	ClientRegistrationAllowedScopes     []string
+	ClientRegistrationAllowedGrantTypes []string
+	ClientRegistrationAllowedAudiences  []string
+
+	// ClientRegistrationSoftwareStatementKeys are the keys trusted to sign software statements.
+	ClientRegistrationSoftwareStatementKeys *jose.JSONWebKeySet
+
+	// ClientRegistrationSoftwareStatementRequired rejects client registration requests without software statement.
+	ClientRegistrationSoftwareStatementRequired bool
+
+	// RevocationListeners are notified whenever the token revocation and refresh token handlers revoke tokens.
+	RevocationListeners oauth2.RevocationListeners
+}
+
+// GetIntrospectionResponseBuilder returns the introspection response builder to be used. Defaults to
+// fosite.DefaultIntrospectionResponseBuilder reporting IntrospectionTokenIssuer as issuer.
+func (c *Config) GetIntrospectionResponseBuilder() fosite.IntrospectionResponseBuilder {
+	if c.IntrospectionResponseBuilder == nil {
+		return &fosite.DefaultIntrospectionResponseBuilder{Issuer: c.IntrospectionTokenIssuer}
+	}
+	return c.IntrospectionResponseBuilder
 }
 
 // GetScopeStrategy returns the scope strategy to be used. Defaults to glob scope strategy.
@@ -179,6 +262,23 @@ func (c *Config) GetTokenEntropy() int {
 	return c.TokenEntropy
 }
 
+// GetHMACTokenPrefix returns the prefix for HMAC tokens of the given type, or an empty string if no prefix is configured.
+func (c *Config) GetHMACTokenPrefix(tokenType fosite.TokenType) string {
+	if c.HMACTokenPrefix == "" {
+		return ""
+	}
+
+	switch tokenType {
+	case fosite.AccessToken:
+		return c.HMACTokenPrefix + "_at_"
+	case fosite.RefreshToken:
+		return c.HMACTokenPrefix + "_rt_"
+	case fosite.AuthorizeCode:
+		return c.HMACTokenPrefix + "_ac_"
+	}
+	return c.HMACTokenPrefix + "_"
+}
+
 // GetRedirectSecureChecker returns the checker to check if redirect URI is secure. Defaults to fosite.IsRedirectURISecure.
 func (c *Config) GetRedirectSecureChecker() func(*url.URL) bool {
 
@@ -220,3 +320,11 @@ func (c *Config) GetJWTMaxDuration() time.Duration {
 func (c *Config) GetClientAuthenticationStrategy() fosite.ClientAuthenticationStrategy {
 	return c.ClientAuthenticationStrategy
 }
+
+// GetClock returns the configured clock. Defaults to fosite.SystemClock.
+func (c *Config) GetClock() fosite.Clock {
+	if c.Clock == nil {
+		return fosite.SystemClock{}
+	}
+	return c.Clock
+}
@@ -226,6 +226,33 @@ var (
 		ErrorField:       errInvalidTarget,
 		CodeField:        http.StatusBadRequest,
 	}
+
+	// ErrInvalidRedirectURI and ErrInvalidClientMetadata are defined by RFC 7591, Section 3.2.2.
+	ErrInvalidRedirectURI = &RFC6749Error{
+		DescriptionField: "The value of one or more redirection URIs is invalid.",
+		ErrorField:       errInvalidRedirectURIName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrInvalidClientMetadata = &RFC6749Error{
+		DescriptionField: "The value of one of the client metadata fields is invalid and the server has rejected this request.",
+		ErrorField:       errInvalidClientMetadataName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrConflict = &RFC6749Error{
+		DescriptionField: "The resource(s) already exist.",
+		ErrorField:       errConflictName,
+		CodeField:        http.StatusConflict,
+	}
+	ErrInvalidSoftwareStatement = &RFC6749Error{
+		DescriptionField: "The software statement presented is invalid.",
+		ErrorField:       errInvalidSoftwareStatementName,
+		CodeField:        http.StatusBadRequest,
+	}
+	ErrUnapprovedSoftwareStatement = &RFC6749Error{
+		DescriptionField: "The software statement presented is not approved for use by this authorization server.",
+		ErrorField:       errUnapprovedSoftwareStatementName,
+		CodeField:        http.StatusBadRequest,
+	}
 )
 
 const (
@@ -258,12 +285,17 @@ const (
 	errTokenClaimName              = "token_claim"
 	errTokenInactiveName           = "token_inactive"
 	// errAuthorizationCodeInactiveName = "authorization_code_inactive"
-	errUnknownErrorName             = "error"
-	errRequestNotSupportedName      = "request_not_supported"
-	errRequestURINotSupportedName   = "request_uri_not_supported"
-	errRegistrationNotSupportedName = "registration_not_supported"
-	errJTIKnownName                 = "jti_known"
-	errInvalidTarget                = "invalid_target"
+	errUnknownErrorName                = "error"
+	errRequestNotSupportedName         = "request_not_supported"
+	errRequestURINotSupportedName      = "request_uri_not_supported"
+	errRegistrationNotSupportedName    = "registration_not_supported"
+	errJTIKnownName                    = "jti_known"
+	errInvalidTarget                   = "invalid_target"
+	errInvalidRedirectURIName          = "invalid_redirect_uri"
+	errInvalidClientMetadataName       = "invalid_client_metadata"
+	errConflictName                    = "conflict"
+	errInvalidSoftwareStatementName    = "invalid_software_statement"
+	errUnapprovedSoftwareStatementName = "unapproved_software_statement"
 )
 
 type (
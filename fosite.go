@@ -5,8 +5,12 @@ import (
 	"html/template"
 	"net/http"
 	"reflect"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/fosite/i18n"
+	"github.com/ory/fosite/token/jwt"
 )
 
 // AuthorizeEndpointHandlers is a list of AuthorizeEndpointHandler
@@ -90,12 +94,72 @@ type Fosite struct {
 	// MinParameterEntropy controls the minimum size of state and nonce parameters. Defaults to fosite.MinParameterEntropy.
 	MinParameterEntropy int
 
+	// Clock is used for all time-based validation. Defaults to SystemClock.
+	Clock Clock
+
+	// ClockSkewLeeway is the tolerated clock skew when validating the exp, nbf and iat claims of JSON Web Tokens
+	// issued by other parties, such as client assertions.
+	ClockSkewLeeway time.Duration
+
+	// IntrospectionJWTResponseStrategy signs JWT-secured introspection responses as defined in RFC 9701. If nil,
+	// introspection responses are always plain JSON.
+	IntrospectionJWTResponseStrategy jwt.JWTStrategy
+
+	// IntrospectionJWTResponseIssuer is the "iss" claim of JWT-secured introspection responses.
+	IntrospectionJWTResponseIssuer string
+
+	// IntrospectionScope, if set, is the scope required to call the introspection endpoint. Callers authenticating with
+	// a bearer token need a token granting this scope, callers authenticating with client credentials need a client
+	// allowed to request it. Clients implementing IntrospectionClient are authorized by IsIntrospectionAllowed instead.
+	IntrospectionScope string
+
+	// IntrospectionAudienceRestricted, if true, reports tokens as inactive unless their granted audience contains the
+	// client ID of the caller.
+	IntrospectionAudienceRestricted bool
+
+	// IntrospectionResponseBuilder builds the introspection responses of active tokens. Defaults to
+	// DefaultIntrospectionResponseBuilder.
+	IntrospectionResponseBuilder IntrospectionResponseBuilder
+
+	// ClientRegistrationURL is the URL of the client registration endpoint. Clients are managed at this URL followed by
+	// "/" and their ID. Dynamic client registration is disabled if it is empty or if Store does not implement
+	// ClientRegistrationStorage.
+	ClientRegistrationURL string
+
+	// ClientRegistrationInitialAccessTokenStrategy validates the initial access tokens required to register clients.
+	// Unless ClientRegistrationOpen is true, clients can not be registered if it is nil.
+	ClientRegistrationInitialAccessTokenStrategy InitialAccessTokenStrategy
+
+	// ClientRegistrationOpen, if true, allows anyone to register clients without initial access token. Initial access
+	// tokens sent anyway are still validated by ClientRegistrationInitialAccessTokenStrategy, if set.
+	ClientRegistrationOpen bool
+
+	// ClientRegistrationAllowedScopes, ClientRegistrationAllowedGrantTypes and ClientRegistrationAllowedAudiences
+	// restrict the metadata of registered clients. Registration and update requests with scopes, grant types or
+	// audiences which are not allowed are rejected. Scopes are matched using ScopeStrategy and audiences using
+	// AudienceMatchingStrategy. A nil list does not restrict the respective metadata.
+	ClientRegistrationAllowedScopes     []string
+	ClientRegistrationAllowedGrantTypes []string
+	ClientRegistrationAllowedAudiences  []string
+
+	// ClientRegistrationSoftwareStatementKeys are the trusted keys used to verify software statements. Software
+	// statements are rejected if nil.
+	ClientRegistrationSoftwareStatementKeys *jose.JSONWebKeySet
+
+	// ClientRegistrationSoftwareStatementRequired, if true, rejects client registration requests without a software
+	// statement.
+	ClientRegistrationSoftwareStatementRequired bool
+
 	// FormPostHTMLTemplate sets html template for rendering the authorization response when the request has response_mode=form_post. Defaults to fosite.FormPostDefaultTemplate
 	FormPostHTMLTemplate *template.Template
 
 	// ClientAuthenticationStrategy provides an extension point to plug a strategy to authenticate clients
 	ClientAuthenticationStrategy ClientAuthenticationStrategy
 
+	// ClientSecretRehashHook, if set, is called when a client secret has been verified against a hash which Hasher
+	// reports as outdated. Hasher must implement HasherWithRehash, for example MultiHasher.
+	ClientSecretRehashHook ClientSecretRehashHook
+
 	ResponseModeHandlerExtension ResponseModeHandler
 
 	// MessageCatalog is the catalog of messages used for i18n
@@ -132,3 +196,20 @@ func (f *Fosite) GetMinParameterEntropy() int {
 		return f.MinParameterEntropy
 	}
 }
+
+// GetIntrospectionResponseBuilder returns the configured IntrospectionResponseBuilder. Defaults to
+// DefaultIntrospectionResponseBuilder.
+func (f *Fosite) GetIntrospectionResponseBuilder() IntrospectionResponseBuilder {
+	if f.IntrospectionResponseBuilder == nil {
+		return new(DefaultIntrospectionResponseBuilder)
+	}
+	return f.IntrospectionResponseBuilder
+}
+
+// GetClock returns Clock if set. Defaults to fosite.SystemClock.
+func (f *Fosite) GetClock() Clock {
+	if f.Clock == nil {
+		return SystemClock{}
+	}
+	return f.Clock
+}
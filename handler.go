@@ -55,3 +55,17 @@ type RevocationHandler interface {
 	// RevokeToken handles access and refresh token revocation.
 	RevokeToken(ctx context.Context, token string, tokenType TokenType, client Client) error
 }
+
+// BulkRevocationHandler is a RevocationHandler which revokes many tokens at once, for example after the resource owner
+// changed their password or an administrator disabled a client. Handlers which are unable to perform the revocation
+// return ErrUnknownRequest.
+type BulkRevocationHandler interface {
+	// RevokeBySubject revokes all access and refresh tokens issued for the given subject.
+	RevokeBySubject(ctx context.Context, subject string) error
+
+	// RevokeByClient revokes all access and refresh tokens issued to the client with the given ID.
+	RevokeByClient(ctx context.Context, clientID string) error
+
+	// RevokeByGrant revokes all access and refresh tokens issued for the request with the given ID.
+	RevokeByGrant(ctx context.Context, requestID string) error
+}
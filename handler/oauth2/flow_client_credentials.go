@@ -2,7 +2,6 @@ package oauth2
 
 import (
 	"context"
-	"time"
 
 	"github.com/ory/x/errorsx"
 
@@ -40,7 +39,10 @@ func (c *ClientCredentialsGrantHandler) HandleTokenEndpointRequest(_ context.Con
 	}
 	// if the client is not public, he has already been authenticated by the access request handler.
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan))
+	now := fosite.TimeNow(c.Clock)
+	session := request.GetSession()
+	session.SetExpiresAt(fosite.AccessToken, now.Add(c.AccessTokenLifespan))
+	fosite.SetIssuedAt(session, fosite.AccessToken, now)
 	return nil
 }
 
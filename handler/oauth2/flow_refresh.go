@@ -25,9 +25,20 @@ type RefreshTokenGrantHandler struct {
 	// RefreshTokenLifespan defines the lifetime of a refresh token.
 	RefreshTokenLifespan time.Duration
 
+	// RefreshTokenGracePeriod keeps rotated refresh tokens usable for the given duration, during which they return the
+	// tokens they were rotated to. Requires TokenRevocationStorage to implement RefreshTokenGracePeriodStorage and has
+	// no effect on self-contained refresh tokens.
+	RefreshTokenGracePeriod time.Duration
+
 	ScopeStrategy            fosite.ScopeStrategy
 	AudienceMatchingStrategy fosite.AudienceMatchingStrategy
 	RefreshTokenScopes       []string
+
+	// Clock is used to compute token expiry. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+
+	// RevocationListeners are notified after refresh token rotation and reuse detection revoked tokens.
+	RevocationListeners RevocationListeners
 }
 
 // HandleTokenEndpointRequest implements https://tools.ietf.org/html/rfc6749#section-6
@@ -42,7 +53,7 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 
 	refresh := request.GetRequestForm().Get("refresh_token")
 	signature := c.RefreshTokenStrategy.RefreshTokenSignature(refresh)
-	originalRequest, err := c.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, request.GetSession())
+	originalRequest, err := c.getRefreshTokenSession(ctx, refresh, signature, request.GetSession())
 	if errors.Is(err, fosite.ErrInactiveToken) {
 		// Detected refresh token reuse
 		if rErr := c.handleRefreshTokenReuse(ctx, signature, originalRequest); rErr != nil {
@@ -54,7 +65,9 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 		return errorsx.WithStack(fosite.ErrInvalidGrant.WithWrap(err).WithDebugf("The refresh token has not been found: %s", err.Error()))
 	} else if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
-	} else if err := c.RefreshTokenStrategy.ValidateRefreshToken(ctx, originalRequest, refresh); err != nil {
+	} else if err := c.RefreshTokenStrategy.ValidateRefreshToken(ctx, originalRequest, refresh); errors.Is(err, fosite.ErrTemporarilyUnavailable) {
+		return err
+	} else if err != nil {
 		// The authorization server MUST ... validate the refresh token.
 		// This needs to happen after store retrieval for the session to be hydrated properly
 		return errorsx.WithStack(fosite.ErrInvalidRequest.WithWrap(err).WithDebug(err.Error()))
@@ -91,10 +104,13 @@ func (c *RefreshTokenGrantHandler) HandleTokenEndpointRequest(ctx context.Contex
 		request.GrantAudience(audience)
 	}
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan).Round(time.Second))
+	now := fosite.TimeNow(c.Clock)
+	request.GetSession().SetExpiresAt(fosite.AccessToken, now.Add(c.AccessTokenLifespan).Round(time.Second))
+	fosite.SetIssuedAt(request.GetSession(), fosite.AccessToken, now)
 	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, now.Add(c.RefreshTokenLifespan).Round(time.Second))
 	}
+	fosite.SetIssuedAt(request.GetSession(), fosite.RefreshToken, now)
 
 	return nil
 }
@@ -106,12 +122,20 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 	}
 
 	accessToken, accessSignature, err := c.AccessTokenStrategy.GenerateAccessToken(ctx, requester)
-	if err != nil {
+	if errors.Is(err, fosite.ErrTemporarilyUnavailable) {
+		return err
+	} else if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
+	if stateless, ok := c.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); ok {
+		return c.populateStatelessTokenEndpointResponse(ctx, stateless, requester, responder, accessToken, accessSignature)
+	}
+
 	refreshToken, refreshSignature, err := c.RefreshTokenStrategy.GenerateRefreshToken(ctx, requester)
-	if err != nil {
+	if errors.Is(err, fosite.ErrTemporarilyUnavailable) {
+		return err
+	} else if err != nil {
 		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
 	}
 
@@ -128,11 +152,32 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 	ts, err := c.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, nil)
 	if err != nil {
 		return err
-	} else if err := c.TokenRevocationStorage.RevokeAccessToken(ctx, ts.GetID()); err != nil {
+	}
+
+	graceStorage, withGracePeriod := c.TokenRevocationStorage.(RefreshTokenGracePeriodStorage)
+	withGracePeriod = withGracePeriod && c.RefreshTokenGracePeriod > 0
+	presented := requester.GetRequestForm().Get("refresh_token")
+	if withGracePeriod {
+		if replayed, err := c.replayRotatedTokens(ctx, graceStorage, presented, signature, responder); err != nil {
+			return err
+		} else if replayed {
+			return storage.MaybeCommitTx(ctx, c.TokenRevocationStorage)
+		}
+	}
+
+	if err := c.TokenRevocationStorage.RevokeAccessToken(ctx, ts.GetID()); err != nil {
 		return err
 	}
 
-	if err := c.TokenRevocationStorage.RevokeRefreshTokenMaybeGracePeriod(ctx, ts.GetID(), signature); err != nil {
+	if withGracePeriod {
+		sealedAccessToken, sealedRefreshToken, err := sealRotatedTokens(presented, accessToken, refreshToken)
+		if err != nil {
+			return err
+		}
+		if err := graceStorage.RotateRefreshTokenWithGracePeriod(ctx, ts.GetID(), signature, sealedAccessToken, sealedRefreshToken, c.RefreshTokenGracePeriod); err != nil {
+			return err
+		}
+	} else if err := c.TokenRevocationStorage.RevokeRefreshTokenMaybeGracePeriod(ctx, ts.GetID(), signature); err != nil {
 		return err
 	}
 
@@ -149,7 +194,7 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 
 	responder.SetAccessToken(accessToken)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(GetExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(GetExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, fosite.TimeNow(c.Clock)))
 	responder.SetScopes(requester.GetGrantedScopes())
 	responder.SetExtra("refresh_token", refreshToken)
 
@@ -157,9 +202,96 @@ func (c *RefreshTokenGrantHandler) PopulateTokenEndpointResponse(ctx context.Con
 		return err
 	}
 
+	c.RevocationListeners.notify(ctx, ts, rotationRevocation)
 	return nil
 }
 
+// populateStatelessTokenEndpointResponse rotates self-contained refresh tokens. Instead of replacing the refresh token
+// session, the refresh token family is advanced to the next generation which invalidates the presented refresh token.
+func (c *RefreshTokenGrantHandler) populateStatelessTokenEndpointResponse(ctx context.Context, strategy StatelessRefreshTokenStrategy, requester fosite.AccessRequester, responder fosite.AccessResponder, accessToken, accessSignature string) (err error) {
+	refresh := requester.GetRequestForm().Get("refresh_token")
+
+	ctx, err = storage.MaybeBeginTx(ctx, c.TokenRevocationStorage)
+	if err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	defer func() {
+		err = c.handleRefreshTokenEndpointStorageError(ctx, err)
+	}()
+
+	ts, err := strategy.DecodeRefreshToken(ctx, refresh, nil)
+	if err != nil {
+		return err
+	} else if err := c.TokenRevocationStorage.RevokeAccessToken(ctx, ts.GetID()); err != nil {
+		return err
+	}
+
+	storeReq := requester.Sanitize([]string{})
+	storeReq.SetID(ts.GetID())
+
+	refreshToken, err := strategy.RotateRefreshToken(ctx, refresh, storeReq)
+	if err != nil {
+		return err
+	}
+
+	if err = c.TokenRevocationStorage.CreateAccessTokenSession(ctx, accessSignature, storeReq); err != nil {
+		return err
+	}
+
+	responder.SetAccessToken(accessToken)
+	responder.SetTokenType("bearer")
+	responder.SetExpiresIn(GetExpiresIn(requester, fosite.AccessToken, c.AccessTokenLifespan, fosite.TimeNow(c.Clock)))
+	responder.SetScopes(requester.GetGrantedScopes())
+	responder.SetExtra("refresh_token", refreshToken)
+
+	if err = storage.MaybeCommitTx(ctx, c.TokenRevocationStorage); err != nil {
+		return err
+	}
+
+	c.RevocationListeners.notify(ctx, ts, rotationRevocation)
+	return nil
+}
+
+// replayRotatedTokens responds with the tokens issued when the presented refresh token with the given signature was
+// rotated if this happened within the grace period.
+func (c *RefreshTokenGrantHandler) replayRotatedTokens(ctx context.Context, graceStorage RefreshTokenGracePeriodStorage, presented, signature string, responder fosite.AccessResponder) (bool, error) {
+	sealedAccessToken, sealedRefreshToken, err := graceStorage.GetRotatedRefreshTokens(ctx, signature)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	accessToken, refreshToken, err := openRotatedTokens(presented, sealedAccessToken, sealedRefreshToken)
+	if err != nil {
+		return false, err
+	}
+
+	// The rotated tokens must not have been revoked in the meantime.
+	ar, err := c.TokenRevocationStorage.GetAccessTokenSession(ctx, c.AccessTokenStrategy.AccessTokenSignature(accessToken), nil)
+	if err != nil {
+		return false, err
+	} else if _, err := c.TokenRevocationStorage.GetRefreshTokenSession(ctx, c.RefreshTokenStrategy.RefreshTokenSignature(refreshToken), nil); err != nil {
+		return false, err
+	}
+
+	responder.SetAccessToken(accessToken)
+	responder.SetTokenType("bearer")
+	responder.SetExpiresIn(GetExpiresIn(ar, fosite.AccessToken, c.AccessTokenLifespan, fosite.TimeNow(c.Clock)))
+	responder.SetScopes(ar.GetGrantedScopes())
+	responder.SetExtra("refresh_token", refreshToken)
+	return true, nil
+}
+
+// getRefreshTokenSession looks up the request of the refresh token either in storage or, for self-contained refresh
+// tokens, in the token itself.
+func (c *RefreshTokenGrantHandler) getRefreshTokenSession(ctx context.Context, token, signature string, session fosite.Session) (fosite.Requester, error) {
+	if stateless, ok := c.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); ok {
+		return stateless.DecodeRefreshToken(ctx, token, session)
+	}
+	return c.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, session)
+}
+
 // Reference: https://tools.ietf.org/html/rfc6819#section-5.2.2.3
 //
 //	The basic idea is to change the refresh token
@@ -178,9 +310,16 @@ func (c *RefreshTokenGrantHandler) handleRefreshTokenReuse(ctx context.Context,
 		err = c.handleRefreshTokenEndpointStorageError(ctx, err)
 	}()
 
-	if err = c.TokenRevocationStorage.DeleteRefreshTokenSession(ctx, signature); err != nil {
+	if stateless, ok := c.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); ok {
+		// Self-contained refresh tokens are not stored, revoking the family invalidates all of them.
+		if err = stateless.RevokeRefreshTokenFamily(ctx, req.GetID()); err != nil && !errors.Is(err, fosite.ErrNotFound) {
+			return err
+		}
+	} else if err = c.TokenRevocationStorage.DeleteRefreshTokenSession(ctx, signature); err != nil {
 		return err
-	} else if err = c.TokenRevocationStorage.RevokeRefreshToken(
+	}
+
+	if err = c.TokenRevocationStorage.RevokeRefreshToken(
 		ctx, req.GetID(),
 	); err != nil && !errors.Is(err, fosite.ErrNotFound) {
 		return err
@@ -194,9 +333,21 @@ func (c *RefreshTokenGrantHandler) handleRefreshTokenReuse(ctx context.Context,
 		return err
 	}
 
+	c.RevocationListeners.notify(ctx, req, reuseRevocation)
 	return nil
 }
 
+var (
+	rotationRevocation = map[fosite.TokenType]RevocationReason{
+		fosite.RefreshToken: RevocationReasonRotation,
+		fosite.AccessToken:  RevocationReasonRotation,
+	}
+	reuseRevocation = map[fosite.TokenType]RevocationReason{
+		fosite.RefreshToken: RevocationReasonReuse,
+		fosite.AccessToken:  RevocationReasonReuse,
+	}
+)
+
 func (c *RefreshTokenGrantHandler) handleRefreshTokenEndpointStorageError(ctx context.Context, storageErr error) (err error) {
 	if storageErr == nil {
 		return nil
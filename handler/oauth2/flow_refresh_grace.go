@@ -0,0 +1,94 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+// The tokens a refresh token has been rotated to are passed to RefreshTokenGracePeriodStorage encrypted with a key
+// derived from the rotated refresh token. Only the signature of the rotated refresh token is stored, so the stored
+// tokens can only be decrypted by presenting the rotated refresh token again.
+
+// sealRotatedTokens encrypts the access and refresh token which replace the presented refresh token.
+func sealRotatedTokens(presented, accessToken, refreshToken string) (string, string, error) {
+	aead, err := rotatedTokensAEAD(presented)
+	if err != nil {
+		return "", "", err
+	}
+
+	sealed := make([]string, 2)
+	for i, token := range []string{accessToken, refreshToken} {
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+		}
+		sealed[i] = base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(token), nil))
+	}
+	return sealed[0], sealed[1], nil
+}
+
+// openRotatedTokens decrypts the access and refresh token sealed by sealRotatedTokens.
+func openRotatedTokens(presented, sealedAccessToken, sealedRefreshToken string) (string, string, error) {
+	aead, err := rotatedTokensAEAD(presented)
+	if err != nil {
+		return "", "", err
+	}
+
+	opened := make([]string, 2)
+	for i, sealed := range []string{sealedAccessToken, sealedRefreshToken} {
+		raw, err := base64.RawURLEncoding.DecodeString(sealed)
+		if err != nil {
+			return "", "", errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to decode the rotated tokens.").WithWrap(err).WithDebug(err.Error()))
+		} else if len(raw) < aead.NonceSize() {
+			return "", "", errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to decode the rotated tokens."))
+		}
+
+		token, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+		if err != nil {
+			return "", "", errorsx.WithStack(fosite.ErrServerError.WithHint("Unable to decrypt the rotated tokens.").WithWrap(err).WithDebug(err.Error()))
+		}
+		opened[i] = string(token)
+	}
+	return opened[0], opened[1], nil
+}
+
+func rotatedTokensAEAD(presented string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("fosite rotated refresh tokens\x00" + presented))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return aead, nil
+}
@@ -1001,3 +1001,71 @@ func TestRefreshFlowTransactional_PopulateTokenEndpointResponse(t *testing.T) {
 		})
 	}
 }
+
+func TestRefreshFlow_GracePeriod(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Second)
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	store := storage.NewMemoryStore()
+	store.Clock = fixedClock(now)
+	request := &fosite.Request{
+		ID:             "grant",
+		RequestedAt:    now,
+		Client:         client,
+		RequestedScope: fosite.Arguments{"offline"},
+		GrantedScope:   fosite.Arguments{"offline"},
+		Form:           url.Values{},
+		Session:        &fosite.DefaultSession{Subject: "peter"},
+	}
+	token, signature, err := hmacshaStrategy.GenerateRefreshToken(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateRefreshTokenSession(ctx, signature, request))
+
+	h := &RefreshTokenGrantHandler{
+		AccessTokenStrategy:      &hmacshaStrategy,
+		RefreshTokenStrategy:     &hmacshaStrategy,
+		TokenRevocationStorage:   store,
+		AccessTokenLifespan:      time.Hour,
+		RefreshTokenGracePeriod:  time.Minute,
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+		Clock:                    fixedClock(now),
+	}
+
+	refresh := func(token string) (fosite.AccessResponder, error) {
+		areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+		areq.GrantTypes = fosite.Arguments{"refresh_token"}
+		areq.Client = client
+		areq.Form = url.Values{"refresh_token": {token}}
+		if err := h.HandleTokenEndpointRequest(ctx, areq); err != nil {
+			return nil, err
+		}
+		aresp := fosite.NewAccessResponse()
+		return aresp, h.PopulateTokenEndpointResponse(ctx, areq, aresp)
+	}
+
+	first, err := refresh(token)
+	require.NoError(t, err)
+
+	// The storage must not learn the tokens the refresh token was rotated to.
+	rotated := store.RotatedRefreshTokens[signature]
+	assert.NotEmpty(t, rotated.AccessToken)
+	assert.NotContains(t, rotated.AccessToken, first.GetAccessToken())
+	assert.NotContains(t, rotated.RefreshToken, first.GetExtra("refresh_token"))
+
+	// A retry within the grace period returns the same tokens.
+	store.Clock = fixedClock(now.Add(30 * time.Second))
+	retry, err := refresh(token)
+	require.NoError(t, err)
+	assert.Equal(t, first.GetAccessToken(), retry.GetAccessToken())
+	assert.Equal(t, first.GetExtra("refresh_token"), retry.GetExtra("refresh_token"))
+
+	// After the grace period, using the old token is detected as reuse and revokes the grant.
+	store.Clock = fixedClock(now.Add(time.Minute))
+	_, err = refresh(token)
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+
+	_, err = refresh(first.GetExtra("refresh_token").(string))
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+}
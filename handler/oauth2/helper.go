@@ -12,6 +12,9 @@ type HandleHelper struct {
 	AccessTokenStorage   AccessTokenStorage
 	AccessTokenLifespan  time.Duration
 	RefreshTokenLifespan time.Duration
+
+	// Clock is used to compute token expiry. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
 }
 
 func (h *HandleHelper) IssueAccessToken(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) error {
@@ -24,7 +27,7 @@ func (h *HandleHelper) IssueAccessToken(ctx context.Context, requester fosite.Ac
 
 	responder.SetAccessToken(token)
 	responder.SetTokenType("bearer")
-	responder.SetExpiresIn(GetExpiresIn(requester, fosite.AccessToken, h.AccessTokenLifespan, time.Now().UTC()))
+	responder.SetExpiresIn(GetExpiresIn(requester, fosite.AccessToken, h.AccessTokenLifespan, fosite.TimeNow(h.Clock)))
 	responder.SetScopes(requester.GetGrantedScopes())
 	return nil
 }
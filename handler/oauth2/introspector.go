@@ -75,8 +75,14 @@ func (c *CoreValidator) introspectAccessToken(ctx context.Context, token string,
 }
 
 func (c *CoreValidator) introspectRefreshToken(ctx context.Context, token string, accessRequest fosite.AccessRequester, scopes []string) error {
-	sig := c.CoreStrategy.RefreshTokenSignature(token)
-	or, err := c.CoreStorage.GetRefreshTokenSession(ctx, sig, accessRequest.GetSession())
+	var or fosite.Requester
+	var err error
+	if stateless, ok := c.CoreStrategy.(StatelessRefreshTokenStrategy); ok {
+		or, err = stateless.DecodeRefreshToken(ctx, token, accessRequest.GetSession())
+	} else {
+		sig := c.CoreStrategy.RefreshTokenSignature(token)
+		or, err = c.CoreStorage.GetRefreshTokenSession(ctx, sig, accessRequest.GetSession())
+	}
 
 	if err != nil {
 		return errorsx.WithStack(fosite.ErrRequestUnauthorized.WithWrap(err).WithDebug(err.Error()))
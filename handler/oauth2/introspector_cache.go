@@ -0,0 +1,440 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+const (
+	defaultIntrospectionCacheMaxEntries  = 10000
+	defaultIntrospectionCacheTTL         = time.Minute
+	defaultIntrospectionCacheNegativeTTL = 5 * time.Second
+)
+
+// CachingTokenIntrospector is a fosite.TokenIntrospector which caches the results of Introspector in memory. Active
+// tokens are cached for at most TTL and never beyond their expiry, inactive tokens for NegativeTTL.
+//
+// Cached results are invalidated as soon as the request they belong to is revoked, provided the handlers revoking
+// tokens use a storage wrapped with NewInvalidatingRevocationStorage. Other instances of the authorization server do
+// not learn about these revocations, so keep TTL short when running more than one instance.
+//
+// Cache hits hydrate the access request with a clone of the cached session, hence all callers are expected to
+// introspect using the same session type.
+type CachingTokenIntrospector struct {
+	// Introspector is the decorated introspector.
+	Introspector fosite.TokenIntrospector
+
+	// MaxEntries bounds the number of cached results. Defaults to 10000.
+	MaxEntries int
+
+	// TTL is the maximum time an active token is cached for. Defaults to one minute.
+	TTL time.Duration
+
+	// NegativeTTL is the time an inactive token is cached for. Defaults to five seconds, negative values disable
+	// negative caching.
+	NegativeTTL time.Duration
+
+	// Clock is used to expire cached results. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+
+	mu        sync.Mutex
+	entries   map[string]*list.Element
+	lru       *list.List
+	byRequest map[string]map[string]struct{}
+	epoch     uint64
+}
+
+var _ fosite.TokenIntrospector = new(CachingTokenIntrospector)
+
+type introspectionCacheEntry struct {
+	key       string
+	expiresAt time.Time
+	tokenUse  fosite.TokenUse
+	request   fosite.Requester
+	err       error
+}
+
+// NewCachingTokenIntrospector returns a CachingTokenIntrospector for introspector using the default limits.
+func NewCachingTokenIntrospector(introspector fosite.TokenIntrospector) *CachingTokenIntrospector {
+	return &CachingTokenIntrospector{Introspector: introspector}
+}
+
+// IntrospectToken implements fosite.TokenIntrospector.
+func (c *CachingTokenIntrospector) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
+	key := introspectionCacheKey(token, tokenUse, scopes)
+
+	c.mu.Lock()
+	entry, ok := c.get(key)
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	if ok {
+		if entry.err != nil {
+			return "", entry.err
+		}
+
+		cached := fosite.NewRequest()
+		cached.Merge(entry.request)
+		if session := entry.request.GetSession(); session != nil {
+			cached.Session = session.Clone()
+		}
+		accessRequest.Merge(cached)
+		return entry.tokenUse, nil
+	}
+
+	tu, err := c.Introspector.IntrospectToken(ctx, token, tokenUse, accessRequest, scopes)
+	if err != nil {
+		if ttl := c.getNegativeTTL(); ttl > 0 && isCacheableIntrospectionError(err) {
+			c.set(epoch, &introspectionCacheEntry{key: key, expiresAt: fosite.TimeNow(c.Clock).Add(ttl), err: err})
+		}
+		return "", err
+	}
+
+	now := fosite.TimeNow(c.Clock)
+	expiresAt := now.Add(c.getTTL())
+	cacheTokenUse := tu
+	if cacheTokenUse == "" {
+		cacheTokenUse = fosite.AccessToken
+	}
+	if session := accessRequest.GetSession(); session != nil {
+		if exp := session.GetExpiresAt(fosite.TokenType(cacheTokenUse)); !exp.IsZero() && exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+
+	if expiresAt.After(now) {
+		snapshot := fosite.NewRequest()
+		snapshot.Merge(accessRequest)
+		if session := accessRequest.GetSession(); session != nil {
+			snapshot.Session = session.Clone()
+		}
+		c.set(epoch, &introspectionCacheEntry{key: key, expiresAt: expiresAt, tokenUse: tu, request: snapshot})
+	}
+
+	return tu, nil
+}
+
+// InvalidateRequest removes all cached results belonging to the request with the given ID.
+func (c *CachingTokenIntrospector) InvalidateRequest(requestID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Results of introspections which are in flight right now might belong to this request as well, make sure they
+	// are not cached.
+	c.epoch++
+
+	for key := range c.byRequest[requestID] {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+	delete(c.byRequest, requestID)
+}
+
+func (c *CachingTokenIntrospector) get(key string) (*introspectionCacheEntry, bool) {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*introspectionCacheEntry)
+	if !entry.expiresAt.After(fosite.TimeNow(c.Clock)) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry, true
+}
+
+func (c *CachingTokenIntrospector) set(epoch uint64, entry *introspectionCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if epoch != c.epoch {
+		return
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]*list.Element)
+		c.lru = list.New()
+		c.byRequest = make(map[string]map[string]struct{})
+	}
+
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	if entry.request != nil {
+		keys, ok := c.byRequest[entry.request.GetID()]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.byRequest[entry.request.GetID()] = keys
+		}
+		keys[entry.key] = struct{}{}
+	}
+
+	for c.lru.Len() > c.getMaxEntries() {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachingTokenIntrospector) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*introspectionCacheEntry)
+	delete(c.entries, entry.key)
+	if entry.request == nil {
+		return
+	}
+
+	if keys, ok := c.byRequest[entry.request.GetID()]; ok {
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(c.byRequest, entry.request.GetID())
+		}
+	}
+}
+
+func (c *CachingTokenIntrospector) getMaxEntries() int {
+	if c.MaxEntries <= 0 {
+		return defaultIntrospectionCacheMaxEntries
+	}
+	return c.MaxEntries
+}
+
+func (c *CachingTokenIntrospector) getTTL() time.Duration {
+	if c.TTL <= 0 {
+		return defaultIntrospectionCacheTTL
+	}
+	return c.TTL
+}
+
+func (c *CachingTokenIntrospector) getNegativeTTL() time.Duration {
+	if c.NegativeTTL == 0 {
+		return defaultIntrospectionCacheNegativeTTL
+	}
+	return c.NegativeTTL
+}
+
+// isCacheableIntrospectionError returns true if err states that the token is unknown or invalid. Errors hinting at
+// temporary failures are never cached.
+func isCacheableIntrospectionError(err error) bool {
+	if errors.Is(err, fosite.ErrServerError) || errors.Is(err, fosite.ErrTemporarilyUnavailable) {
+		return false
+	}
+
+	for _, cacheable := range []error{
+		fosite.ErrUnknownRequest,
+		fosite.ErrRequestUnauthorized,
+		fosite.ErrInactiveToken,
+		fosite.ErrTokenExpired,
+		fosite.ErrInvalidScope,
+		fosite.ErrTokenSignatureMismatch,
+		fosite.ErrInvalidTokenFormat,
+	} {
+		if errors.Is(err, cacheable) {
+			return true
+		}
+	}
+	return false
+}
+
+func introspectionCacheKey(token string, tokenUse fosite.TokenUse, scopes []string) string {
+	hash := sha256.Sum256([]byte(token))
+	return string(tokenUse) + "." + base64.RawURLEncoding.EncodeToString(hash[:]) + "." + strings.Join(scopes, " ")
+}
+
+// InvalidatingRevocationStorage is a TokenRevocationStorage which invalidates the results cached by Cache whenever
+// tokens are revoked, for example through the revocation endpoint or when refresh tokens are rotated. Transactions
+// are passed on to the wrapped storage, in which case the results are invalidated again once the transaction commits.
+//
+// Use NewInvalidatingRevocationStorage to keep RefreshTokenGracePeriodStorage and JTIDenylistStorage of the wrapped
+// storage visible to the handlers.
+type InvalidatingRevocationStorage struct {
+	TokenRevocationStorage
+	Cache *CachingTokenIntrospector
+}
+
+var (
+	_ TokenRevocationStorage     = new(InvalidatingRevocationStorage)
+	_ BulkTokenRevocationStorage = new(InvalidatingRevocationStorage)
+	_ storage.Transactional      = new(InvalidatingRevocationStorage)
+
+	_ RefreshTokenGracePeriodStorage = new(invalidatingGracePeriodDenylistStorage)
+	_ JTIDenylistStorage             = new(invalidatingGracePeriodDenylistStorage)
+	_ BulkTokenRevocationStorage     = new(invalidatingGracePeriodDenylistStorage)
+	_ storage.Transactional          = new(invalidatingGracePeriodDenylistStorage)
+)
+
+// NewInvalidatingRevocationStorage wraps s with an InvalidatingRevocationStorage. The returned storage implements
+// RefreshTokenGracePeriodStorage and JTIDenylistStorage if s does, so that refresh token grace periods and the
+// denylist of JSON Web Token access tokens keep working once the introspection cache is enabled.
+func NewInvalidatingRevocationStorage(s TokenRevocationStorage, cache *CachingTokenIntrospector) TokenRevocationStorage {
+	invalidating := &InvalidatingRevocationStorage{TokenRevocationStorage: s, Cache: cache}
+	graceStorage, withGracePeriod := s.(RefreshTokenGracePeriodStorage)
+	denylist, withDenylist := s.(JTIDenylistStorage)
+
+	switch {
+	case withGracePeriod && withDenylist:
+		return &invalidatingGracePeriodDenylistStorage{
+			invalidatingGracePeriodStorage: &invalidatingGracePeriodStorage{invalidating, graceStorage},
+			JTIDenylistStorage:             denylist,
+		}
+	case withGracePeriod:
+		return &invalidatingGracePeriodStorage{invalidating, graceStorage}
+	case withDenylist:
+		return &invalidatingDenylistStorage{invalidating, denylist}
+	}
+	return invalidating
+}
+
+type invalidatingGracePeriodStorage struct {
+	*InvalidatingRevocationStorage
+	graceStorage RefreshTokenGracePeriodStorage
+}
+
+// RotateRefreshTokenWithGracePeriod rotates the refresh token and invalidates the cached results of requestID.
+func (s *invalidatingGracePeriodStorage) RotateRefreshTokenWithGracePeriod(ctx context.Context, requestID, signature, sealedAccessToken, sealedRefreshToken string, gracePeriod time.Duration) error {
+	defer s.invalidate(ctx, requestID)
+	return s.graceStorage.RotateRefreshTokenWithGracePeriod(ctx, requestID, signature, sealedAccessToken, sealedRefreshToken, gracePeriod)
+}
+
+// GetRotatedRefreshTokens implements RefreshTokenGracePeriodStorage.
+func (s *invalidatingGracePeriodStorage) GetRotatedRefreshTokens(ctx context.Context, signature string) (string, string, error) {
+	return s.graceStorage.GetRotatedRefreshTokens(ctx, signature)
+}
+
+type invalidatingDenylistStorage struct {
+	*InvalidatingRevocationStorage
+	JTIDenylistStorage
+}
+
+type invalidatingGracePeriodDenylistStorage struct {
+	*invalidatingGracePeriodStorage
+	JTIDenylistStorage
+}
+
+type pendingInvalidationsKey struct{}
+
+type pendingInvalidations struct {
+	sync.Mutex
+	requestIDs []string
+}
+
+// RevokeRefreshToken revokes the refresh token and invalidates the cached results of requestID.
+func (s *InvalidatingRevocationStorage) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	defer s.invalidate(ctx, requestID)
+	return s.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
+}
+
+// RevokeRefreshTokenMaybeGracePeriod revokes the refresh token and invalidates the cached results of requestID.
+func (s *InvalidatingRevocationStorage) RevokeRefreshTokenMaybeGracePeriod(ctx context.Context, requestID string, signature string) error {
+	defer s.invalidate(ctx, requestID)
+	return s.TokenRevocationStorage.RevokeRefreshTokenMaybeGracePeriod(ctx, requestID, signature)
+}
+
+// RevokeAccessToken revokes the access token and invalidates the cached results of requestID.
+func (s *InvalidatingRevocationStorage) RevokeAccessToken(ctx context.Context, requestID string) error {
+	defer s.invalidate(ctx, requestID)
+	return s.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
+}
+
+// RevokeTokensBySubject revokes the tokens of subject and invalidates the cached results of the revoked requests. It
+// fails if the wrapped storage does not implement BulkTokenRevocationStorage.
+func (s *InvalidatingRevocationStorage) RevokeTokensBySubject(ctx context.Context, subject string) ([]string, error) {
+	return s.revokeBulk(ctx, func(bulk BulkTokenRevocationStorage) ([]string, error) {
+		return bulk.RevokeTokensBySubject(ctx, subject)
+	})
+}
+
+// RevokeTokensByClient revokes the tokens of the client and invalidates the cached results of the revoked requests.
+// It fails if the wrapped storage does not implement BulkTokenRevocationStorage.
+func (s *InvalidatingRevocationStorage) RevokeTokensByClient(ctx context.Context, clientID string) ([]string, error) {
+	return s.revokeBulk(ctx, func(bulk BulkTokenRevocationStorage) ([]string, error) {
+		return bulk.RevokeTokensByClient(ctx, clientID)
+	})
+}
+
+func (s *InvalidatingRevocationStorage) revokeBulk(ctx context.Context, revoke func(bulk BulkTokenRevocationStorage) ([]string, error)) ([]string, error) {
+	bulk, ok := s.TokenRevocationStorage.(BulkTokenRevocationStorage)
+	if !ok {
+		return nil, errors.New("the wrapped token revocation storage does not implement BulkTokenRevocationStorage")
+	}
+
+	requestIDs, err := revoke(bulk)
+	for _, requestID := range requestIDs {
+		s.invalidate(ctx, requestID)
+	}
+	return requestIDs, err
+}
+
+// BeginTX begins a transaction if the wrapped storage implements storage.Transactional.
+func (s *InvalidatingRevocationStorage) BeginTX(ctx context.Context) (context.Context, error) {
+	ctx, err := storage.MaybeBeginTx(ctx, s.TokenRevocationStorage)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, pendingInvalidationsKey{}, new(pendingInvalidations)), nil
+}
+
+// Commit commits the transaction of the wrapped storage and invalidates the results revoked within the transaction.
+func (s *InvalidatingRevocationStorage) Commit(ctx context.Context) error {
+	if err := storage.MaybeCommitTx(ctx, s.TokenRevocationStorage); err != nil {
+		return err
+	}
+
+	if pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations); ok {
+		pending.Lock()
+		defer pending.Unlock()
+		for _, requestID := range pending.requestIDs {
+			s.Cache.InvalidateRequest(requestID)
+		}
+	}
+	return nil
+}
+
+// Rollback rolls back the transaction of the wrapped storage.
+func (s *InvalidatingRevocationStorage) Rollback(ctx context.Context) error {
+	return storage.MaybeRollbackTx(ctx, s.TokenRevocationStorage)
+}
+
+func (s *InvalidatingRevocationStorage) invalidate(ctx context.Context, requestID string) {
+	s.Cache.InvalidateRequest(requestID)
+
+	if pending, ok := ctx.Value(pendingInvalidationsKey{}).(*pendingInvalidations); ok {
+		pending.Lock()
+		defer pending.Unlock()
+		pending.requestIDs = append(pending.requestIDs, requestID)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+type countingIntrospector struct {
+	fosite.TokenIntrospector
+	calls int
+}
+
+func (c *countingIntrospector) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
+	c.calls++
+	return c.TokenIntrospector.IntrospectToken(ctx, token, tokenUse, accessRequest, scopes)
+}
+
+func TestCachingTokenIntrospector(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	now := time.Now().UTC().Round(time.Second)
+
+	issue := func(t *testing.T, id string, exp time.Time) string {
+		request := &fosite.Request{
+			ID:           id,
+			RequestedAt:  now,
+			Client:       &fosite.DefaultClient{ID: "foo"},
+			GrantedScope: fosite.Arguments{"offline"},
+			Session: &fosite.DefaultSession{
+				Subject:   "peter",
+				ExpiresAt: map[fosite.TokenType]time.Time{fosite.AccessToken: exp},
+			},
+		}
+		token, signature, err := hmacshaStrategy.GenerateAccessToken(ctx, request)
+		require.NoError(t, err)
+		require.NoError(t, store.CreateAccessTokenSession(ctx, signature, request))
+		return token
+	}
+
+	newCache := func() (*CachingTokenIntrospector, *countingIntrospector) {
+		counter := &countingIntrospector{TokenIntrospector: &CoreValidator{
+			CoreStrategy:  &hmacshaStrategy,
+			CoreStorage:   store,
+			ScopeStrategy: fosite.HierarchicScopeStrategy,
+		}}
+		cache := NewCachingTokenIntrospector(counter)
+		cache.Clock = fixedClock(now)
+		return cache, counter
+	}
+
+	introspect := func(cache *CachingTokenIntrospector, token string) (fosite.AccessRequester, error) {
+		ar := fosite.NewAccessRequest(&fosite.DefaultSession{})
+		_, err := cache.IntrospectToken(ctx, token, fosite.AccessToken, ar, []string{"offline"})
+		return ar, err
+	}
+
+	t.Run("case=caches active tokens", func(t *testing.T) {
+		cache, counter := newCache()
+		token := issue(t, "cached", now.Add(time.Hour))
+
+		for i := 0; i < 3; i++ {
+			ar, err := introspect(cache, token)
+			require.NoError(t, err)
+			assert.Equal(t, "cached", ar.GetID())
+			assert.Equal(t, "peter", ar.GetSession().GetSubject())
+		}
+		assert.Equal(t, 1, counter.calls)
+
+		cache.Clock = fixedClock(now.Add(cache.getTTL()))
+		_, err := introspect(cache, token)
+		require.NoError(t, err)
+		assert.Equal(t, 2, counter.calls)
+	})
+
+	t.Run("case=does not cache beyond the token lifetime", func(t *testing.T) {
+		cache, counter := newCache()
+		token := issue(t, "short-lived", now.Add(10*time.Second))
+
+		_, err := introspect(cache, token)
+		require.NoError(t, err)
+
+		cache.Clock = fixedClock(now.Add(10 * time.Second))
+		_, err = introspect(cache, token)
+		require.NoError(t, err)
+		assert.Equal(t, 2, counter.calls)
+	})
+
+	t.Run("case=caches inactive tokens briefly", func(t *testing.T) {
+		cache, counter := newCache()
+
+		for i := 0; i < 2; i++ {
+			_, err := introspect(cache, "ory_at_foo.bar")
+			assert.True(t, errors.Is(err, fosite.ErrRequestUnauthorized))
+		}
+		assert.Equal(t, 1, counter.calls)
+
+		cache.Clock = fixedClock(now.Add(cache.getNegativeTTL()))
+		_, err := introspect(cache, "ory_at_foo.bar")
+		assert.Error(t, err)
+		assert.Equal(t, 2, counter.calls)
+	})
+
+	t.Run("case=revocation invalidates cached tokens", func(t *testing.T) {
+		cache, counter := newCache()
+		token := issue(t, "revoked", now.Add(time.Hour))
+
+		_, err := introspect(cache, token)
+		require.NoError(t, err)
+
+		h := &TokenRevocationHandler{
+			TokenRevocationStorage: &InvalidatingRevocationStorage{TokenRevocationStorage: store, Cache: cache},
+			RefreshTokenStrategy:   &hmacshaStrategy,
+			AccessTokenStrategy:    &hmacshaStrategy,
+		}
+		require.NoError(t, h.RevokeToken(ctx, token, fosite.AccessToken, &fosite.DefaultClient{ID: "foo"}))
+
+		_, err = introspect(cache, token)
+		assert.True(t, errors.Is(err, fosite.ErrRequestUnauthorized))
+		assert.Equal(t, 2, counter.calls)
+	})
+
+	t.Run("case=evicts the least recently used tokens", func(t *testing.T) {
+		cache, counter := newCache()
+		cache.MaxEntries = 2
+
+		tokens := make([]string, 3)
+		for i := range tokens {
+			tokens[i] = issue(t, fmt.Sprintf("evicted-%d", i), now.Add(time.Hour))
+			_, err := introspect(cache, tokens[i])
+			require.NoError(t, err)
+		}
+		assert.Len(t, cache.entries, 2)
+
+		_, err := introspect(cache, tokens[0])
+		require.NoError(t, err)
+		assert.Equal(t, 4, counter.calls)
+	})
+}
+
+type revocationOnlyStorage struct {
+	TokenRevocationStorage
+}
+
+func TestNewInvalidatingRevocationStorage(t *testing.T) {
+	cache := NewCachingTokenIntrospector(nil)
+
+	t.Run("case=keeps the capabilities of the wrapped storage", func(t *testing.T) {
+		s := NewInvalidatingRevocationStorage(storage.NewMemoryStore(), cache)
+
+		_, ok := s.(RefreshTokenGracePeriodStorage)
+		assert.True(t, ok, "grace periods must remain enabled")
+		_, ok = s.(JTIDenylistStorage)
+		assert.True(t, ok, "the JWT access token denylist must remain enabled")
+		_, ok = s.(BulkTokenRevocationStorage)
+		assert.True(t, ok)
+	})
+
+	t.Run("case=does not add capabilities", func(t *testing.T) {
+		s := NewInvalidatingRevocationStorage(&revocationOnlyStorage{TokenRevocationStorage: storage.NewMemoryStore()}, cache)
+
+		_, ok := s.(RefreshTokenGracePeriodStorage)
+		assert.False(t, ok)
+		_, ok = s.(JTIDenylistStorage)
+		assert.False(t, ok)
+	})
+
+	t.Run("case=invalidates cached results when rotating refresh tokens", func(t *testing.T) {
+		ctx := context.Background()
+		store := storage.NewMemoryStore()
+		request := &fosite.Request{ID: "rotated", Client: &fosite.DefaultClient{ID: "foo"}, Session: &fosite.DefaultSession{}}
+		require.NoError(t, store.CreateRefreshTokenSession(ctx, "rotated", request))
+
+		epoch := cache.epoch
+		s := NewInvalidatingRevocationStorage(store, cache).(RefreshTokenGracePeriodStorage)
+		require.NoError(t, s.RotateRefreshTokenWithGracePeriod(ctx, "rotated", "rotated", "access", "refresh", time.Minute))
+		assert.Greater(t, cache.epoch, epoch)
+	})
+}
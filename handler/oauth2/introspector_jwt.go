@@ -25,6 +25,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/jwt"
 )
@@ -32,6 +35,15 @@ import (
 type StatelessJWTValidator struct {
 	jwt.JWTStrategy
 	ScopeStrategy fosite.ScopeStrategy
+
+	// Clock is used to validate the time based claims. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+
+	// Leeway is the tolerated clock skew when validating the exp, iat and nbf claims.
+	Leeway time.Duration
+
+	// Denylist, if set, is consulted to reject revoked tokens. Without it, revoked tokens stay valid until they expire.
+	Denylist JTIDenylistStorage
 }
 
 // AccessTokenJWTToRequest tries to reconstruct fosite.Request from a JWT.
@@ -85,8 +97,13 @@ func AccessTokenJWTToRequest(token *jwt.Token) fosite.Requester {
 }
 
 func (v *StatelessJWTValidator) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
-	t, err := validate(ctx, v.JWTStrategy, token)
-	if err != nil {
+	t, err := validate(ctx, v.JWTStrategy, token, v.Clock, v.Leeway)
+	if errors.Is(err, fosite.ErrInvalidTokenFormat) {
+		// The token is not a JSON Web Token, let other introspectors, for example the RemoteTokenIntrospector, handle it.
+		return "", errorsx.WithStack(fosite.ErrUnknownRequest.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return "", err
+	} else if err := validateNotRevoked(ctx, v.Denylist, t); err != nil {
 		return "", err
 	}
 
@@ -0,0 +1,264 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pborman/uuid"
+	josejwt "gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
+)
+
+const clientAssertionJWTBearerType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// RemoteTokenIntrospector is a fosite.TokenIntrospector which introspects tokens using the RFC 7662 introspection
+// endpoint of a remote authorization server, for example a resource server without access to the authorization
+// server's storage. It can be combined with StatelessJWTValidator to validate JSON Web Tokens locally and all other
+// tokens remotely.
+//
+// The introspected request is hydrated into the session of the access request if it is a *fosite.DefaultSession,
+// otherwise the session is replaced with a *fosite.DefaultSession.
+type RemoteTokenIntrospector struct {
+	// IntrospectionURL is the URL of the remote introspection endpoint.
+	IntrospectionURL string
+
+	// ClientID and ClientSecret authenticate the resource server at the introspection endpoint.
+	ClientID     string
+	ClientSecret string
+
+	// AuthMethod is the client authentication method, one of "client_secret_basic", "client_secret_post" and
+	// "private_key_jwt". Defaults to "client_secret_basic".
+	AuthMethod string
+
+	// AssertionStrategy signs the client assertions when using "private_key_jwt".
+	AssertionStrategy jwt.JWTStrategy
+
+	// AssertionAudience is the audience of client assertions. Fosite expects its token endpoint URL. Defaults to
+	// IntrospectionURL.
+	AssertionAudience string
+
+	// HTTPClient performs the introspection requests. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	ScopeStrategy fosite.ScopeStrategy
+
+	// Clock is used to issue client assertions. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+}
+
+var _ fosite.TokenIntrospector = new(RemoteTokenIntrospector)
+
+type remoteIntrospectionResponse struct {
+	Active    bool             `json:"active"`
+	Scope     string           `json:"scope"`
+	ClientID  string           `json:"client_id"`
+	Username  string           `json:"username"`
+	TokenType string           `json:"token_type"`
+	ExpiresAt int64            `json:"exp"`
+	IssuedAt  int64            `json:"iat"`
+	Subject   string           `json:"sub"`
+	Audience  josejwt.Audience `json:"aud"`
+	JTI       string           `json:"jti"`
+}
+
+// IntrospectToken implements fosite.TokenIntrospector.
+func (c *RemoteTokenIntrospector) IntrospectToken(ctx context.Context, token string, tokenUse fosite.TokenUse, accessRequest fosite.AccessRequester, scopes []string) (fosite.TokenUse, error) {
+	form := url.Values{"token": {token}}
+	if tokenUse != "" {
+		form.Set("token_type_hint", string(tokenUse))
+	}
+
+	req, err := c.newRequest(ctx, form)
+	if err != nil {
+		return "", err
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusInternalServerError {
+		return "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithDebugf("The introspection endpoint responded with status code %d.", res.StatusCode))
+	} else if res.StatusCode != http.StatusOK {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithDebugf("The introspection endpoint responded with status code %d, the resource server might not be allowed to introspect tokens.", res.StatusCode))
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	var raw map[string]interface{}
+	var claims remoteIntrospectionResponse
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if err := json.Unmarshal(body, &claims); err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if !claims.Active {
+		return "", errorsx.WithStack(fosite.ErrInactiveToken.WithHint("The introspection endpoint reported the token as inactive."))
+	}
+
+	if claims.TokenType != "" {
+		tokenUse = fosite.AccessToken
+	}
+
+	requester := c.toRequest(token, raw, &claims, tokenUse, accessRequest.GetSession())
+	if err := matchScopes(c.ScopeStrategy, requester.GetGrantedScopes(), scopes); err != nil {
+		return tokenUse, err
+	}
+
+	accessRequest.Merge(requester)
+	return tokenUse, nil
+}
+
+func (c *RemoteTokenIntrospector) newRequest(ctx context.Context, form url.Values) (*http.Request, error) {
+	switch c.AuthMethod {
+	case "", "client_secret_basic":
+	case "client_secret_post":
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+	case "private_key_jwt":
+		assertion, err := c.clientAssertion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("client_id", c.ClientID)
+		form.Set("client_assertion_type", clientAssertionJWTBearerType)
+		form.Set("client_assertion", assertion)
+	default:
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithDebugf("Client authentication method '%s' is not supported.", c.AuthMethod))
+	}
+
+	req, err := http.NewRequest("POST", c.IntrospectionURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if c.AuthMethod == "" || c.AuthMethod == "client_secret_basic" {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	return req, nil
+}
+
+func (c *RemoteTokenIntrospector) clientAssertion(ctx context.Context) (string, error) {
+	if c.AssertionStrategy == nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Client authentication method 'private_key_jwt' requires an assertion strategy."))
+	}
+
+	audience := c.AssertionAudience
+	if audience == "" {
+		audience = c.IntrospectionURL
+	}
+
+	now := fosite.TimeNow(c.Clock)
+	assertion, _, err := c.AssertionStrategy.Generate(ctx, jwt.MapClaims{
+		"iss": c.ClientID,
+		"sub": c.ClientID,
+		"aud": audience,
+		"jti": uuid.New(),
+		"iat": now.Unix(),
+		"exp": now.Add(time.Minute).Unix(),
+	}, &jwt.Headers{})
+	if err != nil {
+		return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return assertion, nil
+}
+
+func (c *RemoteTokenIntrospector) toRequest(token string, raw map[string]interface{}, claims *remoteIntrospectionResponse, tokenUse fosite.TokenUse, session fosite.Session) fosite.Requester {
+	s, ok := session.(*fosite.DefaultSession)
+	if !ok || s == nil {
+		s = new(fosite.DefaultSession)
+	}
+
+	s.Subject = claims.Subject
+	s.Username = claims.Username
+
+	tokenType := fosite.TokenType(tokenUse)
+	if tokenType == "" {
+		tokenType = fosite.AccessToken
+	}
+	if claims.ExpiresAt > 0 {
+		s.SetExpiresAt(tokenType, time.Unix(claims.ExpiresAt, 0).UTC())
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt > 0 {
+		issuedAt = time.Unix(claims.IssuedAt, 0).UTC()
+		s.SetIssuedAt(tokenType, issuedAt)
+	}
+
+	for name, value := range raw {
+		switch name {
+		case "active", "scope", "client_id", "username", "token_type", "exp", "iat", "sub", "aud":
+			continue
+		default:
+			s.GetExtraClaims()[name] = value
+		}
+	}
+
+	// Requests are identified by their ID, e.g. when caching them, so tokens without a jti need a distinct ID as well.
+	id := claims.JTI
+	if id == "" {
+		hash := sha256.Sum256([]byte(token))
+		id = base64.RawURLEncoding.EncodeToString(hash[:])
+	}
+
+	scopes := fosite.RemoveEmpty(strings.Split(claims.Scope, " "))
+	return &fosite.Request{
+		ID:          id,
+		RequestedAt: issuedAt,
+		Client:      &fosite.DefaultClient{ID: claims.ClientID},
+		// The remote endpoint only reports granted scopes and audiences, so we assume they were requested as well.
+		RequestedScope:    scopes,
+		GrantedScope:      scopes,
+		RequestedAudience: fosite.Arguments(claims.Audience),
+		GrantedAudience:   fosite.Arguments(claims.Audience),
+		Form:              url.Values{},
+		Session:           s,
+	}
+}
@@ -22,9 +22,11 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ory/x/errorsx"
 
@@ -35,6 +37,7 @@ import (
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestIntrospectToken(t *testing.T) {
@@ -117,3 +120,46 @@ func TestIntrospectToken(t *testing.T) {
 		})
 	}
 }
+
+func TestIntrospectAuthorizeCodeAccessTokenIssuedAt(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	requestedAt := time.Now().UTC().Add(-5 * time.Minute).Round(time.Second)
+	issuedAt := requestedAt.Add(3 * time.Minute)
+
+	strategy := hmacshaStrategy
+	request := &fosite.Request{
+		ID:           "authorize-code",
+		RequestedAt:  requestedAt,
+		Client:       &fosite.DefaultClient{ID: "foo"},
+		GrantedScope: fosite.Arguments{"offline"},
+		Session:      &fosite.DefaultSession{Subject: "peter"},
+	}
+
+	// The code is issued when the authorization request is made and exchanged for an access token later on.
+	strategy.Clock = fixedClock(requestedAt)
+	_, codeSignature, err := strategy.GenerateAuthorizeCode(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAuthorizeCodeSession(ctx, codeSignature, request))
+
+	strategy.Clock = fixedClock(issuedAt)
+	token, signature, err := strategy.GenerateAccessToken(ctx, request)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAccessTokenSession(ctx, signature, request))
+
+	v := &CoreValidator{
+		CoreStrategy:  &strategy,
+		CoreStorage:   store,
+		ScopeStrategy: fosite.HierarchicScopeStrategy,
+	}
+	ar := fosite.NewAccessRequest(&fosite.DefaultSession{})
+	tu, err := v.IntrospectToken(ctx, token, fosite.AccessToken, ar, []string{"offline"})
+	require.NoError(t, err)
+
+	response := new(fosite.DefaultIntrospectionResponseBuilder).BuildIntrospectionResponse(&fosite.IntrospectionResponse{
+		Active:          true,
+		AccessRequester: ar,
+		TokenUse:        tu,
+	}, nil)
+	assert.Equal(t, issuedAt.Unix(), response["iat"])
+}
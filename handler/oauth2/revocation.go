@@ -29,20 +29,29 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type TokenRevocationHandler struct {
 	TokenRevocationStorage TokenRevocationStorage
 	RefreshTokenStrategy   RefreshTokenStrategy
 	AccessTokenStrategy    AccessTokenStrategy
+
+	// RevocationListeners are notified after tokens have been revoked.
+	RevocationListeners RevocationListeners
 }
 
+var _ fosite.BulkRevocationHandler = new(TokenRevocationHandler)
+
 // RevokeToken implements https://tools.ietf.org/html/rfc7009#section-2.1
 // The token type hint indicates which token type check should be performed first.
 func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string, tokenType fosite.TokenType, client fosite.Client) error {
 	discoveryFuncs := []func() (request fosite.Requester, err error){
 		func() (request fosite.Requester, err error) {
 			// Refresh token
+			if stateless, ok := r.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); ok {
+				return stateless.DecodeRefreshToken(ctx, token, nil)
+			}
 			signature := r.RefreshTokenStrategy.RefreshTokenSignature(token)
 			return r.TokenRevocationStorage.GetRefreshTokenSession(ctx, signature, nil)
 		},
@@ -53,15 +62,20 @@ func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string,
 		},
 	}
 
+	tokenTypes := []fosite.TokenType{fosite.RefreshToken, fosite.AccessToken}
+
 	// Token type hinting
 	if tokenType == fosite.AccessToken {
 		discoveryFuncs[0], discoveryFuncs[1] = discoveryFuncs[1], discoveryFuncs[0]
+		tokenTypes[0], tokenTypes[1] = tokenTypes[1], tokenTypes[0]
 	}
 
 	var ar fosite.Requester
 	var err1, err2 error
+	presented := tokenTypes[0]
 	if ar, err1 = discoveryFuncs[0](); err1 != nil {
 		ar, err2 = discoveryFuncs[1]()
+		presented = tokenTypes[1]
 	}
 	// err2 can only be not nil if first err1 was not nil
 	if err2 != nil {
@@ -72,11 +86,127 @@ func (r *TokenRevocationHandler) RevokeToken(ctx context.Context, token string,
 		return errorsx.WithStack(fosite.ErrUnauthorizedClient)
 	}
 
+	if err := r.revokeJWT(ctx, token); err != nil {
+		return err
+	}
+
 	requestID := ar.GetID()
+	if err := r.revokeRefreshTokenFamily(ctx, requestID); err != nil {
+		return err
+	}
+
 	err1 = r.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
 	err2 = r.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
 
-	return storeErrorsToRevocationError(err1, err2)
+	if err := storeErrorsToRevocationError(err1, err2); err != nil {
+		return err
+	}
+
+	reasons := map[fosite.TokenType]RevocationReason{fosite.RefreshToken: RevocationReasonCascade, fosite.AccessToken: RevocationReasonCascade}
+	reasons[presented] = RevocationReasonExplicit
+	r.RevocationListeners.notify(ctx, ar, reasons)
+	return nil
+}
+
+// RevokeBySubject implements fosite.BulkRevocationHandler. It requires TokenRevocationStorage to implement
+// BulkTokenRevocationStorage and returns fosite.ErrUnknownRequest otherwise.
+func (r *TokenRevocationHandler) RevokeBySubject(ctx context.Context, subject string) error {
+	return r.revokeBulk(ctx, &fosite.Request{Session: &fosite.DefaultSession{Subject: subject}}, func(s BulkTokenRevocationStorage) ([]string, error) {
+		return s.RevokeTokensBySubject(ctx, subject)
+	})
+}
+
+// RevokeByClient implements fosite.BulkRevocationHandler. It requires TokenRevocationStorage to implement
+// BulkTokenRevocationStorage and returns fosite.ErrUnknownRequest otherwise.
+func (r *TokenRevocationHandler) RevokeByClient(ctx context.Context, clientID string) error {
+	return r.revokeBulk(ctx, &fosite.Request{Client: &fosite.DefaultClient{ID: clientID}}, func(s BulkTokenRevocationStorage) ([]string, error) {
+		return s.RevokeTokensByClient(ctx, clientID)
+	})
+}
+
+// RevokeByGrant implements fosite.BulkRevocationHandler.
+func (r *TokenRevocationHandler) RevokeByGrant(ctx context.Context, requestID string) error {
+	if err := r.revokeRefreshTokenFamily(ctx, requestID); err != nil {
+		return err
+	}
+
+	err1 := r.TokenRevocationStorage.RevokeRefreshToken(ctx, requestID)
+	err2 := r.TokenRevocationStorage.RevokeAccessToken(ctx, requestID)
+	if err := storeErrorsToRevocationError(err1, err2); err != nil {
+		return err
+	}
+
+	r.RevocationListeners.notify(ctx, &fosite.Request{ID: requestID}, explicitRevocation)
+	return nil
+}
+
+var explicitRevocation = map[fosite.TokenType]RevocationReason{
+	fosite.RefreshToken: RevocationReasonExplicit,
+	fosite.AccessToken:  RevocationReasonExplicit,
+}
+
+// revokeBulk revokes the tokens using the bulk storage. The known client or subject of the revoked requests is taken
+// from template.
+func (r *TokenRevocationHandler) revokeBulk(ctx context.Context, template *fosite.Request, revoke func(s BulkTokenRevocationStorage) ([]string, error)) error {
+	storage, ok := r.TokenRevocationStorage.(BulkTokenRevocationStorage)
+	if !ok {
+		return errorsx.WithStack(fosite.ErrUnknownRequest.WithDebug("The token revocation storage does not support bulk revocation."))
+	}
+
+	// On error, the requests revoked so far are returned as well and must be handled like fully revoked requests.
+	requestIDs, revokeErr := revoke(storage)
+
+	// Self-contained refresh tokens are not stored, so we revoke the families of all requests with stored tokens.
+	for _, requestID := range requestIDs {
+		if err := r.revokeRefreshTokenFamily(ctx, requestID); err != nil {
+			return err
+		}
+	}
+
+	for _, requestID := range requestIDs {
+		template.ID = requestID
+		r.RevocationListeners.notify(ctx, template, explicitRevocation)
+	}
+
+	if revokeErr != nil {
+		return errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(revokeErr).WithDebug(revokeErr.Error()))
+	}
+	return nil
+}
+
+func (r *TokenRevocationHandler) revokeRefreshTokenFamily(ctx context.Context, requestID string) error {
+	if stateless, ok := r.RefreshTokenStrategy.(StatelessRefreshTokenStrategy); ok {
+		if err := stateless.RevokeRefreshTokenFamily(ctx, requestID); err != nil && !errors.Is(err, fosite.ErrNotFound) {
+			return errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+	return nil
+}
+
+// revokeJWT adds the jti of token to the denylist if token is a JSON Web Token access token and the
+// TokenRevocationStorage implements JTIDenylistStorage.
+func (r *TokenRevocationHandler) revokeJWT(ctx context.Context, token string) error {
+	denylist, ok := r.TokenRevocationStorage.(JTIDenylistStorage)
+	if !ok {
+		return nil
+	}
+
+	t, err := DecodeAccessToken(ctx, r.AccessTokenStrategy, token)
+	if err != nil {
+		// The token is not a JSON Web Token access token, for example a refresh token.
+		return nil
+	}
+
+	claims := jwt.JWTClaims{}
+	claims.FromMapClaims(t.Claims)
+	if claims.JTI == "" {
+		return nil
+	}
+
+	if err := denylist.RevokeJTI(ctx, claims.JTI, claims.ExpiresAt); err != nil {
+		return errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
 }
 
 func storeErrorsToRevocationError(err1, err2 error) error {
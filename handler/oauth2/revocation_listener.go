@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+
+	"github.com/ory/fosite"
+)
+
+// RevocationReason describes why a token was revoked.
+type RevocationReason string
+
+const (
+	// RevocationReasonExplicit is used for tokens revoked at the revocation endpoint or using bulk revocation.
+	RevocationReasonExplicit RevocationReason = "explicit"
+
+	// RevocationReasonRotation is used for tokens replaced during the refresh token grant.
+	RevocationReasonRotation RevocationReason = "rotation"
+
+	// RevocationReasonReuse is used for tokens revoked because a refresh token was used more than once, which
+	// indicates that the refresh token was leaked.
+	RevocationReasonReuse RevocationReason = "reuse"
+
+	// RevocationReasonCascade is used for tokens revoked together with another token of the same grant, for example
+	// the access tokens of an explicitly revoked refresh token.
+	RevocationReasonCascade RevocationReason = "cascade"
+)
+
+// RevocationEvent describes the revocation of the tokens of one type issued for a request.
+type RevocationEvent struct {
+	RequestID string
+	TokenType fosite.TokenType
+	Reason    RevocationReason
+
+	// Client and Subject are empty if they are unknown, for example for bulk revocation by client or grant.
+	Client  fosite.Client
+	Subject string
+}
+
+// RevocationListener is notified after tokens have been revoked and the revocation has been committed. Listeners are
+// invoked synchronously and should hand off slow work, such as pushing invalidations to remote caches.
+type RevocationListener interface {
+	TokenRevoked(ctx context.Context, event *RevocationEvent)
+}
+
+// RevocationListenerFunc is an adapter to allow the use of ordinary functions as RevocationListener.
+type RevocationListenerFunc func(ctx context.Context, event *RevocationEvent)
+
+// TokenRevoked calls f(ctx, event).
+func (f RevocationListenerFunc) TokenRevoked(ctx context.Context, event *RevocationEvent) {
+	f(ctx, event)
+}
+
+// RevocationListeners is a list of RevocationListener.
+type RevocationListeners []RevocationListener
+
+// notify invokes all listeners with one event per token type.
+func (l RevocationListeners) notify(ctx context.Context, request fosite.Requester, reasons map[fosite.TokenType]RevocationReason) {
+	if len(l) == 0 {
+		return
+	}
+
+	var subject string
+	if session := request.GetSession(); session != nil {
+		subject = session.GetSubject()
+	}
+
+	for _, tokenType := range []fosite.TokenType{fosite.RefreshToken, fosite.AccessToken} {
+		reason, ok := reasons[tokenType]
+		if !ok {
+			continue
+		}
+
+		for _, listener := range l {
+			listener.TokenRevoked(ctx, &RevocationEvent{
+				RequestID: request.GetID(),
+				TokenType: tokenType,
+				Reason:    reason,
+				Client:    request.GetClient(),
+				Subject:   subject,
+			})
+		}
+	}
+}
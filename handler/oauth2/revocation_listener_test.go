@@ -0,0 +1,147 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+type recordingRevocationListener struct {
+	events []RevocationEvent
+}
+
+func (l *recordingRevocationListener) TokenRevoked(_ context.Context, event *RevocationEvent) {
+	l.events = append(l.events, *event)
+}
+
+func (l *recordingRevocationListener) reasons() map[fosite.TokenType]RevocationReason {
+	reasons := map[fosite.TokenType]RevocationReason{}
+	for _, event := range l.events {
+		reasons[event.TokenType] = event.Reason
+	}
+	return reasons
+}
+
+func TestRevocationListeners(t *testing.T) {
+	ctx := context.Background()
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	issue := func(t *testing.T, store *storage.MemoryStore) (string, string) {
+		request := &fosite.Request{
+			ID:             "grant",
+			RequestedAt:    time.Now().UTC(),
+			Client:         client,
+			RequestedScope: fosite.Arguments{"offline"},
+			GrantedScope:   fosite.Arguments{"offline"},
+			Form:           url.Values{},
+			Session:        &fosite.DefaultSession{Subject: "peter"},
+		}
+		at, atSignature, err := hmacshaStrategy.GenerateAccessToken(ctx, request)
+		require.NoError(t, err)
+		rt, rtSignature, err := hmacshaStrategy.GenerateRefreshToken(ctx, request)
+		require.NoError(t, err)
+		require.NoError(t, store.CreateAccessTokenSession(ctx, atSignature, request))
+		require.NoError(t, store.CreateRefreshTokenSession(ctx, rtSignature, request))
+		return at, rt
+	}
+
+	t.Run("case=explicit revocation", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		at, _ := issue(t, store)
+		listener := new(recordingRevocationListener)
+
+		h := &TokenRevocationHandler{
+			TokenRevocationStorage: store,
+			RefreshTokenStrategy:   &hmacshaStrategy,
+			AccessTokenStrategy:    &hmacshaStrategy,
+			RevocationListeners:    RevocationListeners{listener},
+		}
+		require.NoError(t, h.RevokeToken(ctx, at, fosite.AccessToken, client))
+
+		require.Len(t, listener.events, 2)
+		assert.Equal(t, map[fosite.TokenType]RevocationReason{
+			fosite.AccessToken:  RevocationReasonExplicit,
+			fosite.RefreshToken: RevocationReasonCascade,
+		}, listener.reasons())
+		for _, event := range listener.events {
+			assert.Equal(t, "grant", event.RequestID)
+			assert.Equal(t, "foo", event.Client.GetID())
+			assert.Equal(t, "peter", event.Subject)
+		}
+
+		listener.events = nil
+		require.NoError(t, h.RevokeBySubject(ctx, "peter"))
+		require.Len(t, listener.events, 2)
+		assert.Equal(t, RevocationEvent{RequestID: "grant", TokenType: fosite.RefreshToken, Reason: RevocationReasonExplicit, Subject: "peter"}, listener.events[0])
+	})
+
+	t.Run("case=rotation and reuse", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		_, rt := issue(t, store)
+		listener := new(recordingRevocationListener)
+
+		h := &RefreshTokenGrantHandler{
+			AccessTokenStrategy:      &hmacshaStrategy,
+			RefreshTokenStrategy:     &hmacshaStrategy,
+			TokenRevocationStorage:   store,
+			AccessTokenLifespan:      time.Hour,
+			ScopeStrategy:            fosite.HierarchicScopeStrategy,
+			AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+			RevocationListeners:      RevocationListeners{RevocationListenerFunc(listener.TokenRevoked)},
+		}
+
+		refresh := func() error {
+			areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+			areq.GrantTypes = fosite.Arguments{"refresh_token"}
+			areq.Client = client
+			areq.Form = url.Values{"refresh_token": {rt}}
+			if err := h.HandleTokenEndpointRequest(ctx, areq); err != nil {
+				return err
+			}
+			return h.PopulateTokenEndpointResponse(ctx, areq, fosite.NewAccessResponse())
+		}
+
+		require.NoError(t, refresh())
+		assert.Equal(t, map[fosite.TokenType]RevocationReason{
+			fosite.AccessToken:  RevocationReasonRotation,
+			fosite.RefreshToken: RevocationReasonRotation,
+		}, listener.reasons())
+
+		listener.events = nil
+		assert.True(t, errors.Is(refresh(), fosite.ErrInactiveToken))
+		assert.Equal(t, map[fosite.TokenType]RevocationReason{
+			fosite.AccessToken:  RevocationReasonReuse,
+			fosite.RefreshToken: RevocationReasonReuse,
+		}, listener.reasons())
+		assert.Equal(t, "peter", listener.events[0].Subject)
+	})
+}
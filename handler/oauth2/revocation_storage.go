@@ -23,6 +23,7 @@ package oauth2
 
 import (
 	"context"
+	"time"
 )
 
 // TokenRevocationStorage provides the storage implementation
@@ -59,3 +60,50 @@ type TokenRevocationStorage interface {
 	// token as well.
 	RevokeAccessToken(ctx context.Context, requestID string) error
 }
+
+// BulkTokenRevocationStorage revokes all tokens of a subject or client at once. Implementations return the IDs of
+// the revoked requests so that caches, for example the CachingTokenIntrospector, can be invalidated. If revocation
+// fails part way, the IDs of the requests revoked so far are returned together with the error.
+type BulkTokenRevocationStorage interface {
+	// RevokeTokensBySubject revokes all access and refresh tokens issued for subject.
+	RevokeTokensBySubject(ctx context.Context, subject string) (requestIDs []string, err error)
+
+	// RevokeTokensByClient revokes all access and refresh tokens issued to the client with the given ID.
+	RevokeTokensByClient(ctx context.Context, clientID string) (requestIDs []string, err error)
+}
+
+// JTIDenylistStorage stores the IDs (jti) of revoked JSON Web Token access tokens until the tokens expire. Self-contained
+// access tokens remain valid after revocation unless their validators consult the denylist.
+//
+// Implementations which store access token sessions also denylist the jti of sessions implementing
+// AccessTokenJTIContainer when RevokeAccessToken revokes them, so that revoking a grant, for example by revoking its
+// refresh token or all tokens of its subject, revokes its JSON Web Token access tokens as well.
+type JTIDenylistStorage interface {
+	// RevokeJTI adds jti to the denylist. The entry may be removed once exp has passed, a zero exp never expires.
+	RevokeJTI(ctx context.Context, jti string, exp time.Time) error
+
+	// IsJTIRevoked returns true if jti is on the denylist and has not expired yet.
+	IsJTIRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RefreshTokenGracePeriodStorage keeps rotated refresh tokens usable for a grace period. Clients retrying a refresh
+// request within the grace period, for example after losing the response on a flaky network, receive the tokens which
+// were issued by the first request instead of triggering refresh token reuse detection.
+//
+// The access and refresh token are passed to the storage encrypted with a key derived from the rotated refresh token,
+// which is not stored. They must be stored as they are.
+type RefreshTokenGracePeriodStorage interface {
+	// RotateRefreshTokenWithGracePeriod revokes the refresh token with the given signature once gracePeriod has
+	// passed and remembers the encrypted access and refresh token replacing it until then. Revoking the refresh tokens
+	// of requestID must revoke refresh tokens within their grace period as well.
+	//
+	// A refresh token is rotated only once: rotating a refresh token which does not exist, is inactive or has been
+	// rotated already fails with fosite.ErrNotFound, or fosite.ErrSerializationFailure if the conflict is detected
+	// when committing a transaction.
+	RotateRefreshTokenWithGracePeriod(ctx context.Context, requestID, signature, sealedAccessToken, sealedRefreshToken string, gracePeriod time.Duration) error
+
+	// GetRotatedRefreshTokens returns the encrypted access and refresh token which replaced the refresh token with the
+	// given signature. It returns fosite.ErrNotFound if the refresh token has not been rotated or its grace period is
+	// over.
+	GetRotatedRefreshTokens(ctx context.Context, signature string) (sealedAccessToken, sealedRefreshToken string, err error)
+}
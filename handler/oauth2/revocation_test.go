@@ -22,14 +22,19 @@
 package oauth2
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
 )
 
 func TestRevokeToken(t *testing.T) {
@@ -234,3 +239,162 @@ func TestRevokeToken(t *testing.T) {
 		})
 	}
 }
+
+func TestBulkRevocation(t *testing.T) {
+	ctx := context.Background()
+
+	issue := func(t *testing.T, store *storage.MemoryStore, id, subject, clientID string) (string, string) {
+		request := &fosite.Request{
+			ID:           id,
+			RequestedAt:  time.Now().UTC(),
+			Client:       &fosite.DefaultClient{ID: clientID},
+			GrantedScope: fosite.Arguments{"offline"},
+			Session: &fosite.DefaultSession{
+				Subject: subject,
+				ExpiresAt: map[fosite.TokenType]time.Time{
+					fosite.AccessToken:  time.Now().UTC().Add(time.Hour),
+					fosite.RefreshToken: time.Now().UTC().Add(time.Hour),
+				},
+			},
+		}
+		at, atSignature, err := hmacshaStrategy.GenerateAccessToken(ctx, request)
+		require.NoError(t, err)
+		rt, rtSignature, err := hmacshaStrategy.GenerateRefreshToken(ctx, request)
+		require.NoError(t, err)
+		require.NoError(t, store.CreateAccessTokenSession(ctx, atSignature, request))
+		require.NoError(t, store.CreateRefreshTokenSession(ctx, rtSignature, request))
+		return at, rt
+	}
+
+	isActive := func(store *storage.MemoryStore, at, rt string) (bool, bool) {
+		_, atErr := store.GetAccessTokenSession(ctx, hmacshaStrategy.AccessTokenSignature(at), nil)
+		_, rtErr := store.GetRefreshTokenSession(ctx, hmacshaStrategy.RefreshTokenSignature(rt), nil)
+		return atErr == nil, rtErr == nil
+	}
+
+	for _, c := range []struct {
+		description string
+		revoke      func(h *TokenRevocationHandler) error
+		revoked     []bool
+	}{
+		{
+			description: "subject",
+			revoke: func(h *TokenRevocationHandler) error {
+				return h.RevokeBySubject(ctx, "peter")
+			},
+			revoked: []bool{true, true, false},
+		},
+		{
+			description: "client",
+			revoke: func(h *TokenRevocationHandler) error {
+				return h.RevokeByClient(ctx, "foo")
+			},
+			revoked: []bool{true, false, true},
+		},
+		{
+			description: "grant",
+			revoke: func(h *TokenRevocationHandler) error {
+				return h.RevokeByGrant(ctx, "peter-bar")
+			},
+			revoked: []bool{false, true, false},
+		},
+	} {
+		t.Run("case=revokes by "+c.description, func(t *testing.T) {
+			store := storage.NewMemoryStore()
+			tokens := [][2]string{}
+			for _, grant := range [][3]string{{"peter-foo", "peter", "foo"}, {"peter-bar", "peter", "bar"}, {"alice-foo", "alice", "foo"}} {
+				at, rt := issue(t, store, grant[0], grant[1], grant[2])
+				tokens = append(tokens, [2]string{at, rt})
+			}
+
+			require.NoError(t, c.revoke(&TokenRevocationHandler{
+				TokenRevocationStorage: store,
+				RefreshTokenStrategy:   &hmacshaStrategy,
+				AccessTokenStrategy:    &hmacshaStrategy,
+			}))
+
+			for k, token := range tokens {
+				atActive, rtActive := isActive(store, token[0], token[1])
+				assert.Equal(t, !c.revoked[k], atActive, "access token %d", k)
+				assert.Equal(t, !c.revoked[k], rtActive, "refresh token %d", k)
+			}
+		})
+	}
+
+	t.Run("case=removes revoked requests from all indexes", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		issue(t, store, "peter-foo", "peter", "foo")
+		issue(t, store, "alice-foo", "alice", "foo")
+
+		h := &TokenRevocationHandler{TokenRevocationStorage: store, RefreshTokenStrategy: &hmacshaStrategy, AccessTokenStrategy: &hmacshaStrategy}
+		require.NoError(t, h.RevokeBySubject(ctx, "peter"))
+		assert.NotContains(t, store.SubjectRequestIDs, "peter")
+		assert.Equal(t, map[string]struct{}{"alice-foo": {}}, store.ClientRequestIDs["foo"])
+
+		require.NoError(t, h.RevokeByClient(ctx, "foo"))
+		assert.Empty(t, store.SubjectRequestIDs)
+		assert.Empty(t, store.ClientRequestIDs)
+	})
+
+	t.Run("case=notifies about requests revoked before an error", func(t *testing.T) {
+		listener := new(recordingRevocationListener)
+		h := &TokenRevocationHandler{
+			TokenRevocationStorage: &failingBulkRevocationStorage{revoked: []string{"revoked"}},
+			RefreshTokenStrategy:   &hmacshaStrategy,
+			AccessTokenStrategy:    &hmacshaStrategy,
+			RevocationListeners:    RevocationListeners{listener},
+		}
+
+		assert.True(t, errors.Is(h.RevokeByClient(ctx, "foo"), fosite.ErrTemporarilyUnavailable))
+		require.NotEmpty(t, listener.events)
+		for _, event := range listener.events {
+			assert.Equal(t, "revoked", event.RequestID)
+		}
+	})
+
+	t.Run("case=invalidates cached introspection results", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		at, _ := issue(t, store, "cached", "peter", "foo")
+		cache := NewCachingTokenIntrospector(&CoreValidator{
+			CoreStrategy:  &hmacshaStrategy,
+			CoreStorage:   store,
+			ScopeStrategy: fosite.HierarchicScopeStrategy,
+		})
+
+		_, err := cache.IntrospectToken(ctx, at, fosite.AccessToken, fosite.NewAccessRequest(&fosite.DefaultSession{}), nil)
+		require.NoError(t, err)
+
+		h := &TokenRevocationHandler{
+			TokenRevocationStorage: &InvalidatingRevocationStorage{TokenRevocationStorage: store, Cache: cache},
+			RefreshTokenStrategy:   &hmacshaStrategy,
+			AccessTokenStrategy:    &hmacshaStrategy,
+		}
+		require.NoError(t, h.RevokeBySubject(ctx, "peter"))
+
+		_, err = cache.IntrospectToken(ctx, at, fosite.AccessToken, fosite.NewAccessRequest(&fosite.DefaultSession{}), nil)
+		assert.True(t, errors.Is(err, fosite.ErrRequestUnauthorized))
+	})
+
+	t.Run("case=is unknown without bulk storage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		h := &TokenRevocationHandler{TokenRevocationStorage: internal.NewMockTokenRevocationStorage(ctrl)}
+		assert.True(t, errors.Is(h.RevokeByClient(ctx, "foo"), fosite.ErrUnknownRequest))
+		assert.True(t, errors.Is((&fosite.Fosite{RevocationHandlers: fosite.RevocationHandlers{h}}).RevokeByClient(ctx, "foo"), fosite.ErrServerError))
+	})
+}
+
+// failingBulkRevocationStorage fails bulk revocation after revoking the requests in revoked.
+type failingBulkRevocationStorage struct {
+	TokenRevocationStorage
+	revoked []string
+}
+
+func (s *failingBulkRevocationStorage) RevokeTokensBySubject(context.Context, string) ([]string, error) {
+	return s.revoked, errors.New("connection lost")
+}
+
+func (s *failingBulkRevocationStorage) RevokeTokensByClient(context.Context, string) ([]string, error) {
+	return s.revoked, errors.New("connection lost")
+}
@@ -66,3 +66,19 @@ type RefreshTokenStorage interface {
 
 	DeleteRefreshTokenSession(ctx context.Context, signature string) (err error)
 }
+
+// RefreshTokenFamilyStorage keeps track of the current generation of refresh token families issued by a
+// StatelessRefreshTokenStrategy. A family is identified by the ID of the request the first refresh token was issued for.
+type RefreshTokenFamilyStorage interface {
+	// GetRefreshTokenFamilyGeneration returns the current generation of the family. Families which were never rotated
+	// are at generation zero. If the family has been revoked, this method must return fosite.ErrInactiveToken.
+	GetRefreshTokenFamilyGeneration(ctx context.Context, familyID string) (generation uint64, err error)
+
+	// RotateRefreshTokenFamily advances the family from generation to generation + 1. If the family is no longer at
+	// generation, this method must return fosite.ErrSerializationFailure. If the family has been revoked, it must
+	// return fosite.ErrInactiveToken.
+	RotateRefreshTokenFamily(ctx context.Context, familyID string, generation uint64) (err error)
+
+	// RevokeRefreshTokenFamily revokes all refresh tokens of the family.
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) (err error)
+}
@@ -24,7 +24,10 @@ package oauth2
 import (
 	"context"
 
+	"github.com/ory/x/errorsx"
+
 	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/jwt"
 )
 
 type CoreStrategy interface {
@@ -39,14 +42,62 @@ type AccessTokenStrategy interface {
 	ValidateAccessToken(ctx context.Context, requester fosite.Requester, token string) (err error)
 }
 
+// JWTAccessTokenDecoder is implemented by AccessTokenStrategy implementations which issue JSON Web Tokens. It is used
+// to revoke self-contained access tokens by their jti.
+type JWTAccessTokenDecoder interface {
+	// DecodeAccessToken verifies the signature of token and returns it without validating its claims. It returns
+	// fosite.ErrInvalidTokenFormat if the strategy does not issue JSON Web Tokens.
+	DecodeAccessToken(ctx context.Context, token string) (*jwt.Token, error)
+}
+
 type RefreshTokenStrategy interface {
 	RefreshTokenSignature(token string) string
 	GenerateRefreshToken(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
 	ValidateRefreshToken(ctx context.Context, requester fosite.Requester, token string) (err error)
 }
 
+// StatelessRefreshTokenStrategy is a RefreshTokenStrategy which issues self-contained refresh tokens. These tokens
+// carry the sanitized request they were issued for and are never written to RefreshTokenStorage.
+type StatelessRefreshTokenStrategy interface {
+	RefreshTokenStrategy
+
+	// DecodeRefreshToken restores the request the refresh token was issued for and hydrates session. If the token was
+	// rotated or revoked, the request is returned together with fosite.ErrInactiveToken. Like RefreshTokenStorage, it
+	// returns fosite.ErrNotFound if the token is unknown or can not be decoded.
+	DecodeRefreshToken(ctx context.Context, token string, session fosite.Session) (request fosite.Requester, err error)
+
+	// RotateRefreshToken invalidates token and returns its successor for requester.
+	RotateRefreshToken(ctx context.Context, token string, requester fosite.Requester) (rotated string, err error)
+
+	// RevokeRefreshTokenFamily invalidates all refresh tokens issued for the request with the given ID.
+	RevokeRefreshTokenFamily(ctx context.Context, requestID string) (err error)
+}
+
 type AuthorizeCodeStrategy interface {
 	AuthorizeCodeSignature(token string) string
 	GenerateAuthorizeCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error)
 	ValidateAuthorizeCode(ctx context.Context, requester fosite.Requester, token string) (err error)
 }
+
+// StatelessRefreshTokenCoreStrategy is a CoreStrategy which issues self-contained refresh tokens using a
+// StatelessRefreshTokenStrategy while access tokens and authorize codes are issued by another strategy.
+type StatelessRefreshTokenCoreStrategy struct {
+	AccessTokenStrategy
+	AuthorizeCodeStrategy
+	StatelessRefreshTokenStrategy
+}
+
+// DecodeAccessToken implements JWTAccessTokenDecoder if the AccessTokenStrategy does.
+func (s *StatelessRefreshTokenCoreStrategy) DecodeAccessToken(ctx context.Context, token string) (*jwt.Token, error) {
+	return DecodeAccessToken(ctx, s.AccessTokenStrategy, token)
+}
+
+// DecodeAccessToken decodes token using strategy if it implements JWTAccessTokenDecoder and returns
+// fosite.ErrInvalidTokenFormat otherwise.
+func DecodeAccessToken(ctx context.Context, strategy AccessTokenStrategy, token string) (*jwt.Token, error) {
+	decoder, ok := strategy.(JWTAccessTokenDecoder)
+	if !ok {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithDebug("The access token strategy does not issue JSON Web Tokens."))
+	}
+	return decoder.DecodeAccessToken(ctx, token)
+}
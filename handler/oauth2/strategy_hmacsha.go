@@ -23,6 +23,7 @@ package oauth2
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/ory/x/errorsx"
@@ -36,61 +37,116 @@ type HMACSHAStrategy struct {
 	AccessTokenLifespan   time.Duration
 	RefreshTokenLifespan  time.Duration
 	AuthorizeCodeLifespan time.Duration
+
+	// AccessTokenPrefix, RefreshTokenPrefix and AuthorizeCodePrefix are prepended to the respective tokens, for
+	// example "xx_at_", "xx_rt_" and "xx_ac_". The prefixes allow secret scanners to detect leaked tokens and are
+	// required to be present when a token is validated. Empty prefixes are ignored.
+	AccessTokenPrefix   string
+	RefreshTokenPrefix  string
+	AuthorizeCodePrefix string
+
+	// AccessTokenEnigma, RefreshTokenEnigma and AuthorizeCodeEnigma, if set, are used instead of Enigma for the
+	// respective token type. With separate secrets a token presented as the wrong type fails signature validation,
+	// and a leaked secret only compromises a single token type. See hmac.DeriveSecret for deriving the secrets from
+	// a single global secret.
+	AccessTokenEnigma   *enigma.HMACStrategy
+	RefreshTokenEnigma  *enigma.HMACStrategy
+	AuthorizeCodeEnigma *enigma.HMACStrategy
+
+	// Clock is used to check whether tokens have expired. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
 }
 
 func (h HMACSHAStrategy) AccessTokenSignature(token string) string {
-	return h.Enigma.Signature(token)
+	return h.enigmaFor(fosite.AccessToken).Signature(strings.TrimPrefix(token, h.AccessTokenPrefix))
 }
 func (h HMACSHAStrategy) RefreshTokenSignature(token string) string {
-	return h.Enigma.Signature(token)
+	return h.enigmaFor(fosite.RefreshToken).Signature(strings.TrimPrefix(token, h.RefreshTokenPrefix))
 }
 func (h HMACSHAStrategy) AuthorizeCodeSignature(token string) string {
-	return h.Enigma.Signature(token)
+	return h.enigmaFor(fosite.AuthorizeCode).Signature(strings.TrimPrefix(token, h.AuthorizeCodePrefix))
 }
 
-func (h HMACSHAStrategy) GenerateAccessToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+func (h HMACSHAStrategy) GenerateAccessToken(ctx context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return h.generate(ctx, fosite.AccessToken, h.AccessTokenPrefix, requester)
 }
 
-func (h HMACSHAStrategy) ValidateAccessToken(_ context.Context, r fosite.Requester, token string) (err error) {
+func (h HMACSHAStrategy) ValidateAccessToken(ctx context.Context, r fosite.Requester, token string) (err error) {
 	var exp = r.GetSession().GetExpiresAt(fosite.AccessToken)
-	if exp.IsZero() && r.GetRequestedAt().Add(h.AccessTokenLifespan).Before(time.Now().UTC()) {
+	if exp.IsZero() && r.GetRequestedAt().Add(h.AccessTokenLifespan).Before(fosite.TimeNow(h.Clock)) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Access token expired at '%s'.", r.GetRequestedAt().Add(h.AccessTokenLifespan)))
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && exp.Before(fosite.TimeNow(h.Clock)) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Access token expired at '%s'.", exp))
 	}
-	return h.Enigma.Validate(token)
+	return h.validate(ctx, fosite.AccessToken, h.AccessTokenPrefix, token)
 }
 
-func (h HMACSHAStrategy) GenerateRefreshToken(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+func (h HMACSHAStrategy) GenerateRefreshToken(ctx context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return h.generate(ctx, fosite.RefreshToken, h.RefreshTokenPrefix, requester)
 }
 
-func (h HMACSHAStrategy) ValidateRefreshToken(_ context.Context, r fosite.Requester, token string) (err error) {
+func (h HMACSHAStrategy) ValidateRefreshToken(ctx context.Context, r fosite.Requester, token string) (err error) {
 	var exp = r.GetSession().GetExpiresAt(fosite.RefreshToken)
 	if exp.IsZero() {
 		// Unlimited lifetime
-		return h.Enigma.Validate(token)
+		return h.validate(ctx, fosite.RefreshToken, h.RefreshTokenPrefix, token)
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && exp.Before(fosite.TimeNow(h.Clock)) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", exp))
 	}
-	return h.Enigma.Validate(token)
+	return h.validate(ctx, fosite.RefreshToken, h.RefreshTokenPrefix, token)
 }
 
-func (h HMACSHAStrategy) GenerateAuthorizeCode(_ context.Context, _ fosite.Requester) (token string, signature string, err error) {
-	return h.Enigma.Generate()
+func (h HMACSHAStrategy) GenerateAuthorizeCode(ctx context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	return h.generate(ctx, fosite.AuthorizeCode, h.AuthorizeCodePrefix, requester)
 }
 
-func (h HMACSHAStrategy) ValidateAuthorizeCode(_ context.Context, r fosite.Requester, token string) (err error) {
+func (h HMACSHAStrategy) ValidateAuthorizeCode(ctx context.Context, r fosite.Requester, token string) (err error) {
 	var exp = r.GetSession().GetExpiresAt(fosite.AuthorizeCode)
-	if exp.IsZero() && r.GetRequestedAt().Add(h.AuthorizeCodeLifespan).Before(time.Now().UTC()) {
+	if exp.IsZero() && r.GetRequestedAt().Add(h.AuthorizeCodeLifespan).Before(fosite.TimeNow(h.Clock)) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", r.GetRequestedAt().Add(h.AuthorizeCodeLifespan)))
 	}
-	if !exp.IsZero() && exp.Before(time.Now().UTC()) {
+	if !exp.IsZero() && exp.Before(fosite.TimeNow(h.Clock)) {
 		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Authorize code expired at '%s'.", exp))
 	}
 
-	return h.Enigma.Validate(token)
+	return h.validate(ctx, fosite.AuthorizeCode, h.AuthorizeCodePrefix, token)
+}
+
+func (h HMACSHAStrategy) enigmaFor(tokenType fosite.TokenType) *enigma.HMACStrategy {
+	var e *enigma.HMACStrategy
+	switch tokenType {
+	case fosite.AccessToken:
+		e = h.AccessTokenEnigma
+	case fosite.RefreshToken:
+		e = h.RefreshTokenEnigma
+	case fosite.AuthorizeCode:
+		e = h.AuthorizeCodeEnigma
+	}
+
+	if e == nil {
+		return h.Enigma
+	}
+	return e
+}
+
+func (h HMACSHAStrategy) generate(ctx context.Context, tokenType fosite.TokenType, prefix string, requester fosite.Requester) (string, string, error) {
+	token, signature, err := h.enigmaFor(tokenType).Generate(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Every token is minted here, so this is where its issuance time is recorded for introspection.
+	if requester != nil {
+		fosite.SetIssuedAt(requester.GetSession(), tokenType, fosite.TimeNow(h.Clock))
+	}
+	return prefix + token, signature, nil
+}
+
+func (h HMACSHAStrategy) validate(ctx context.Context, tokenType fosite.TokenType, prefix string, token string) error {
+	if !strings.HasPrefix(token, prefix) {
+		return errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("Token is expected to start with '%s'.", prefix))
+	}
+	return h.enigmaFor(tokenType).Validate(ctx, strings.TrimPrefix(token, prefix))
 }
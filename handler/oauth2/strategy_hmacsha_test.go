@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/ory/fosite"
 	"github.com/ory/fosite/token/hmac"
@@ -170,3 +171,81 @@ func TestHMACAuthorizeCode(t *testing.T) {
 		})
 	}
 }
+
+func TestHMACTokenPrefixes(t *testing.T) {
+	strategy := HMACSHAStrategy{
+		Enigma:                &hmac.HMACStrategy{GlobalSecret: []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar"), UseKeyIDs: true},
+		AccessTokenLifespan:   time.Hour * 24,
+		AuthorizeCodeLifespan: time.Hour * 24,
+		AccessTokenPrefix:     "xx_at_",
+		RefreshTokenPrefix:    "xx_rt_",
+		AuthorizeCodePrefix:   "xx_ac_",
+	}
+
+	accessToken, accessSignature, err := strategy.GenerateAccessToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(accessToken, "xx_at_v1."), accessToken)
+	assert.Equal(t, accessSignature, strategy.AccessTokenSignature(accessToken))
+	require.NoError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, accessToken))
+
+	refreshToken, refreshSignature, err := strategy.GenerateRefreshToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(refreshToken, "xx_rt_v1."), refreshToken)
+	assert.Equal(t, refreshSignature, strategy.RefreshTokenSignature(refreshToken))
+	require.NoError(t, strategy.ValidateRefreshToken(nil, &hmacValidCase, refreshToken))
+
+	code, codeSignature, err := strategy.GenerateAuthorizeCode(nil, &hmacValidCase)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(code, "xx_ac_v1."), code)
+	assert.Equal(t, codeSignature, strategy.AuthorizeCodeSignature(code))
+	require.NoError(t, strategy.ValidateAuthorizeCode(nil, &hmacValidCase, code))
+
+	err = strategy.ValidateAccessToken(nil, &hmacValidCase, refreshToken)
+	assert.EqualError(t, err, fosite.ErrInvalidTokenFormat.Error())
+	err = strategy.ValidateRefreshToken(nil, &hmacValidCase, strings.TrimPrefix(refreshToken, "xx_rt_"))
+	assert.EqualError(t, err, fosite.ErrInvalidTokenFormat.Error())
+}
+
+func TestHMACSecretPerTokenType(t *testing.T) {
+	secret := []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar")
+	strategy := HMACSHAStrategy{
+		Enigma:                &hmac.HMACStrategy{GlobalSecret: secret},
+		AccessTokenEnigma:     &hmac.HMACStrategy{GlobalSecret: hmac.DeriveSecret(secret, string(fosite.AccessToken))},
+		RefreshTokenEnigma:    &hmac.HMACStrategy{GlobalSecret: hmac.DeriveSecret(secret, string(fosite.RefreshToken))},
+		AccessTokenLifespan:   time.Hour * 24,
+		AuthorizeCodeLifespan: time.Hour * 24,
+	}
+
+	accessToken, _, err := strategy.GenerateAccessToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	refreshToken, _, err := strategy.GenerateRefreshToken(nil, &hmacValidCase)
+	require.NoError(t, err)
+	code, _, err := strategy.GenerateAuthorizeCode(nil, &hmacValidCase)
+	require.NoError(t, err)
+
+	require.NoError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, accessToken))
+	require.NoError(t, strategy.ValidateRefreshToken(nil, &hmacValidCase, refreshToken))
+	require.NoError(t, strategy.ValidateAuthorizeCode(nil, &hmacValidCase, code))
+
+	assert.EqualError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, refreshToken), fosite.ErrTokenSignatureMismatch.Error())
+	assert.EqualError(t, strategy.ValidateRefreshToken(nil, &hmacValidCase, accessToken), fosite.ErrTokenSignatureMismatch.Error())
+	assert.EqualError(t, strategy.ValidateAccessToken(nil, &hmacValidCase, code), fosite.ErrTokenSignatureMismatch.Error())
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestHMACClock(t *testing.T) {
+	strategy := hmacshaStrategy
+	strategy.Clock = fixedClock(time.Now().Add(-time.Hour * 2))
+
+	token, _, err := strategy.GenerateAccessToken(nil, &hmacExpiredCase)
+	require.NoError(t, err)
+
+	// The token expired an hour ago, but not from the point of view of the clock.
+	require.NoError(t, strategy.ValidateAccessToken(nil, &hmacExpiredCase, token))
+	require.Error(t, hmacshaStrategy.ValidateAccessToken(nil, &hmacExpiredCase, token))
+}
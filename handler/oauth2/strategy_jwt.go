@@ -39,6 +39,15 @@ type DefaultJWTStrategy struct {
 	HMACSHAStrategy *HMACSHAStrategy
 	Issuer          string
 	ScopeField      jwt.JWTScopeFieldEnum
+
+	// Clock is used to set and validate the time based claims. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+
+	// Leeway is the tolerated clock skew when validating the exp, iat and nbf claims.
+	Leeway time.Duration
+
+	// Denylist, if set, is consulted when validating access tokens to reject revoked tokens.
+	Denylist JTIDenylistStorage
 }
 
 func (h *DefaultJWTStrategy) WithIssuer(issuer string) *DefaultJWTStrategy {
@@ -51,6 +60,11 @@ func (h *DefaultJWTStrategy) WithScopeField(scopeField jwt.JWTScopeFieldEnum) *D
 	return h
 }
 
+func (h *DefaultJWTStrategy) WithDenylist(denylist JTIDenylistStorage) *DefaultJWTStrategy {
+	h.Denylist = denylist
+	return h
+}
+
 func (h DefaultJWTStrategy) signature(token string) string {
 	split := strings.Split(token, ".")
 	if len(split) != 3 {
@@ -69,8 +83,16 @@ func (h *DefaultJWTStrategy) GenerateAccessToken(ctx context.Context, requester
 }
 
 func (h *DefaultJWTStrategy) ValidateAccessToken(ctx context.Context, _ fosite.Requester, token string) error {
-	_, err := validate(ctx, h.JWTStrategy, token)
-	return err
+	t, err := validate(ctx, h.JWTStrategy, token, h.Clock, h.Leeway)
+	if err != nil {
+		return err
+	}
+	return validateNotRevoked(ctx, h.Denylist, t)
+}
+
+// DecodeAccessToken implements JWTAccessTokenDecoder.
+func (h *DefaultJWTStrategy) DecodeAccessToken(ctx context.Context, token string) (*jwt.Token, error) {
+	return h.JWTStrategy.Decode(ctx, token)
 }
 
 func (h DefaultJWTStrategy) RefreshTokenSignature(token string) string {
@@ -97,21 +119,41 @@ func (h *DefaultJWTStrategy) ValidateAuthorizeCode(ctx context.Context, req fosi
 	return h.HMACSHAStrategy.ValidateAuthorizeCode(ctx, req, token)
 }
 
-func validate(ctx context.Context, jwtStrategy jwt.JWTStrategy, token string) (t *jwt.Token, err error) {
+func validate(ctx context.Context, jwtStrategy jwt.JWTStrategy, token string, clock fosite.Clock, leeway time.Duration) (t *jwt.Token, err error) {
 	t, err = jwtStrategy.Decode(ctx, token)
 	if err == nil {
-		err = t.Claims.Valid()
+		err = t.Claims.ValidAt(fosite.TimeNow(clock), leeway)
 		return
 	}
 
 	var e *jwt.ValidationError
-	if err != nil && errors.As(err, &e) {
+	if errors.Is(err, jwt.ErrSignerUnavailable) {
+		err = errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil && errors.As(err, &e) {
 		err = errorsx.WithStack(toRFCErr(e).WithWrap(err).WithDebug(err.Error()))
 	}
 
 	return
 }
 
+func validateNotRevoked(ctx context.Context, denylist JTIDenylistStorage, t *jwt.Token) error {
+	if denylist == nil {
+		return nil
+	}
+
+	jti, _ := t.Claims["jti"].(string)
+	if jti == "" {
+		return nil
+	}
+
+	if revoked, err := denylist.IsJTIRevoked(ctx, jti); err != nil {
+		return errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if revoked {
+		return errorsx.WithStack(fosite.ErrInactiveToken.WithHint("Token has been revoked."))
+	}
+	return nil
+}
+
 func toRFCErr(v *jwt.ValidationError) *fosite.RFC6749Error {
 	switch {
 	case v == nil:
@@ -147,13 +189,30 @@ func (h *DefaultJWTStrategy) generate(ctx context.Context, tokenType fosite.Toke
 				requester.GetGrantedAudience(),
 			).
 			WithDefaults(
-				time.Now().UTC(),
+				fosite.TimeNow(h.Clock),
 				h.Issuer,
 			).
 			WithScopeField(
 				h.ScopeField,
 			)
 
-		return h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), jwtSession.GetJWTHeader())
+		mapClaims := claims.ToMapClaims()
+		token, signature, err := h.JWTStrategy.Generate(ctx, mapClaims, jwtSession.GetJWTHeader())
+		if errors.Is(err, jwt.ErrSignerUnavailable) {
+			return "", "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+		} else if err != nil {
+			return "", "", err
+		}
+
+		if iat, ok := mapClaims["iat"].(int64); ok {
+			fosite.SetIssuedAt(requester.GetSession(), tokenType, time.Unix(iat, 0).UTC())
+		}
+
+		// The stored session remembers the jti so that the token can be denylisted when its grant is revoked.
+		if container, ok := jwtSession.(AccessTokenJTIContainer); ok && tokenType == fosite.AccessToken {
+			jti, _ := mapClaims["jti"].(string)
+			container.SetAccessTokenJTI(jti)
+		}
+		return token, signature, nil
 	}
 }
@@ -40,13 +40,33 @@ type JWTSessionContainer interface {
 	fosite.Session
 }
 
+// AccessTokenJTIContainer is implemented by sessions which remember the ID (jti) of the last JSON Web Token access
+// token issued for them, see JTIDenylistStorage.
+type AccessTokenJTIContainer interface {
+	// GetAccessTokenJTI returns the jti of the access token.
+	GetAccessTokenJTI() string
+
+	// SetAccessTokenJTI sets the jti of the access token.
+	SetAccessTokenJTI(jti string)
+}
+
 // JWTSession Container for the JWT session.
 type JWTSession struct {
-	JWTClaims *jwt.JWTClaims
-	JWTHeader *jwt.Headers
-	ExpiresAt map[fosite.TokenType]time.Time
-	Username  string
-	Subject   string
+	JWTClaims      *jwt.JWTClaims
+	JWTHeader      *jwt.Headers
+	ExpiresAt      map[fosite.TokenType]time.Time
+	IssuedAt       map[fosite.TokenType]time.Time
+	Username       string
+	Subject        string
+	AccessTokenJTI string
+}
+
+func (j *JWTSession) GetAccessTokenJTI() string {
+	return j.AccessTokenJTI
+}
+
+func (j *JWTSession) SetAccessTokenJTI(jti string) {
+	j.AccessTokenJTI = jti
 }
 
 func (j *JWTSession) GetJWTClaims() jwt.JWTClaimsContainer {
@@ -118,3 +138,19 @@ func (s *JWTSession) GetExtraClaims() map[string]interface{} {
 	// We make a clone so that WithScopeField does not change the original value.
 	return s.Clone().(*JWTSession).GetJWTClaims().WithScopeField(jwt.JWTScopeFieldString).ToMapClaims()
 }
+
+// SetIssuedAt implements fosite.IssuedAtSession for JWTSession.
+func (j *JWTSession) SetIssuedAt(key fosite.TokenType, iat time.Time) {
+	if j.IssuedAt == nil {
+		j.IssuedAt = make(map[fosite.TokenType]time.Time)
+	}
+	j.IssuedAt[key] = iat
+}
+
+// GetIssuedAt implements fosite.IssuedAtSession for JWTSession.
+func (j *JWTSession) GetIssuedAt(key fosite.TokenType) time.Time {
+	if j == nil {
+		return time.Time{}
+	}
+	return j.IssuedAt[key]
+}
@@ -22,6 +22,7 @@
 package oauth2
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -242,3 +243,21 @@ func TestAccessToken(t *testing.T) {
 		}
 	}
 }
+
+func TestAccessTokenSignerUnavailable(t *testing.T) {
+	unavailable := &DefaultJWTStrategy{
+		JWTStrategy: &jwt.RS256JWTStrategy{
+			PrivateKey: &jwt.FileSigner{Path: "/does/not/exist.pem"},
+		},
+	}
+
+	_, _, err := unavailable.GenerateAccessToken(context.Background(), jwtValidCase(fosite.AccessToken))
+	require.Error(t, err)
+	assert.EqualError(t, err, fosite.ErrTemporarilyUnavailable.Error())
+
+	token, _, err := j.GenerateAccessToken(context.Background(), jwtValidCase(fosite.AccessToken))
+	require.NoError(t, err)
+
+	err = unavailable.ValidateAccessToken(context.Background(), jwtValidCase(fosite.AccessToken), token)
+	assert.EqualError(t, err, fosite.ErrTemporarilyUnavailable.Error())
+}
@@ -0,0 +1,263 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/token/hmac"
+)
+
+// encryptedRefreshTokenVersion is the first segment of every encrypted refresh token.
+const encryptedRefreshTokenVersion = "v1"
+
+// EncryptedRefreshTokenStrategy is a StatelessRefreshTokenStrategy which encrypts the sanitized request into the refresh
+// token using AES-256-GCM. Refresh tokens are never written to storage. Instead, every refresh token belongs to a
+// family, identified by the request ID, and carries a generation counter. Only the current generation of each family
+// is kept in Storage, which is enough to detect the reuse of rotated refresh tokens.
+type EncryptedRefreshTokenStrategy struct {
+	// Secret is the key used to encrypt refresh tokens. It must be at least 32 byte long; only the first 32 byte are used.
+	Secret []byte
+
+	// RotatedSecrets are used to decrypt refresh tokens which were encrypted with a previous secret.
+	RotatedSecrets [][]byte
+
+	// Storage keeps track of the current generation of every refresh token family.
+	Storage RefreshTokenFamilyStorage
+
+	// Prefix is prepended to refresh tokens, see HMACSHAStrategy.RefreshTokenPrefix.
+	Prefix string
+
+	// Clock is used to check whether refresh tokens have expired. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+}
+
+var _ StatelessRefreshTokenStrategy = new(EncryptedRefreshTokenStrategy)
+
+type encryptedRefreshToken struct {
+	FamilyID           string          `json:"fid"`
+	Generation         uint64          `json:"gen"`
+	RequestedAt        time.Time       `json:"rat"`
+	ClientID           string          `json:"cid"`
+	SubjectTokenClient string          `json:"stc,omitempty"`
+	RequestedScope     []string        `json:"rsc,omitempty"`
+	GrantedScope       []string        `json:"gsc,omitempty"`
+	RequestedAudience  []string        `json:"rau,omitempty"`
+	GrantedAudience    []string        `json:"gau,omitempty"`
+	Session            json.RawMessage `json:"ses"`
+}
+
+// RefreshTokenSignature returns an empty string because encrypted refresh tokens are not stored.
+func (s *EncryptedRefreshTokenStrategy) RefreshTokenSignature(_ string) string {
+	return ""
+}
+
+// GenerateRefreshToken issues the first refresh token of the family identified by the request ID.
+func (s *EncryptedRefreshTokenStrategy) GenerateRefreshToken(_ context.Context, requester fosite.Requester) (token string, signature string, err error) {
+	token, err = s.encrypt(requester, 0)
+	return token, "", err
+}
+
+// ValidateRefreshToken checks that token can be decrypted and has not expired.
+func (s *EncryptedRefreshTokenStrategy) ValidateRefreshToken(_ context.Context, requester fosite.Requester, token string) error {
+	if _, err := s.decrypt(token); err != nil {
+		return err
+	}
+
+	if exp := requester.GetSession().GetExpiresAt(fosite.RefreshToken); !exp.IsZero() && exp.Before(fosite.TimeNow(s.Clock)) {
+		return errorsx.WithStack(fosite.ErrTokenExpired.WithHintf("Refresh token expired at '%s'.", exp))
+	}
+	return nil
+}
+
+// DecodeRefreshToken restores the request token was issued for and hydrates session. If token is not the current
+// generation of its family, or the family was revoked, the request is returned together with fosite.ErrInactiveToken.
+// Tokens which can not be decrypted result in fosite.ErrNotFound.
+func (s *EncryptedRefreshTokenStrategy) DecodeRefreshToken(ctx context.Context, token string, session fosite.Session) (fosite.Requester, error) {
+	payload, err := s.decrypt(token)
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrNotFound.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if session != nil {
+		if err := json.Unmarshal(payload.Session, session); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	request := &fosite.Request{
+		ID:                payload.FamilyID,
+		RequestedAt:       payload.RequestedAt,
+		Client:            &fosite.DefaultClient{ID: payload.ClientID},
+		RequestedScope:    payload.RequestedScope,
+		GrantedScope:      payload.GrantedScope,
+		RequestedAudience: payload.RequestedAudience,
+		GrantedAudience:   payload.GrantedAudience,
+		Form:              url.Values{},
+		Session:           session,
+	}
+	if payload.SubjectTokenClient != "" {
+		request.SubjectTokenClient = &fosite.DefaultClient{ID: payload.SubjectTokenClient}
+	}
+
+	generation, err := s.Storage.GetRefreshTokenFamilyGeneration(ctx, payload.FamilyID)
+	if errors.Is(err, fosite.ErrInactiveToken) {
+		return request, err
+	} else if err != nil {
+		return nil, err
+	} else if payload.Generation < generation {
+		return request, errorsx.WithStack(fosite.ErrInactiveToken.WithDebug("The refresh token has already been rotated."))
+	} else if payload.Generation > generation {
+		return nil, errorsx.WithStack(fosite.ErrNotFound.WithDebug("The refresh token generation is unknown."))
+	}
+
+	return request, nil
+}
+
+// RotateRefreshToken advances the family of token to the next generation and returns a refresh token of that
+// generation for requester.
+func (s *EncryptedRefreshTokenStrategy) RotateRefreshToken(ctx context.Context, token string, requester fosite.Requester) (string, error) {
+	payload, err := s.decrypt(token)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.Storage.RotateRefreshTokenFamily(ctx, payload.FamilyID, payload.Generation); err != nil {
+		return "", err
+	}
+
+	return s.encrypt(requester, payload.Generation+1)
+}
+
+// RevokeRefreshTokenFamily revokes all refresh tokens issued for the request with the given ID.
+func (s *EncryptedRefreshTokenStrategy) RevokeRefreshTokenFamily(ctx context.Context, requestID string) error {
+	return s.Storage.RevokeRefreshTokenFamily(ctx, requestID)
+}
+
+func (s *EncryptedRefreshTokenStrategy) encrypt(requester fosite.Requester, generation uint64) (string, error) {
+	fosite.SetIssuedAt(requester.GetSession(), fosite.RefreshToken, fosite.TimeNow(s.Clock))
+	session, err := json.Marshal(requester.GetSession())
+	if err != nil {
+		return "", errorsx.WithStack(err)
+	}
+
+	payload := &encryptedRefreshToken{
+		FamilyID:          requester.GetID(),
+		Generation:        generation,
+		RequestedAt:       requester.GetRequestedAt(),
+		ClientID:          requester.GetClient().GetID(),
+		RequestedScope:    requester.GetRequestedScopes(),
+		GrantedScope:      requester.GetGrantedScopes(),
+		RequestedAudience: requester.GetRequestedAudience(),
+		GrantedAudience:   requester.GetGrantedAudience(),
+		Session:           session,
+	}
+	if stc := requester.GetSubjectTokenClient(); stc != nil {
+		payload.SubjectTokenClient = stc.GetID()
+	}
+
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return "", errorsx.WithStack(err)
+	}
+
+	aead, err := newRefreshTokenAEAD(s.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := hmac.RandomBytes(aead.NonceSize())
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext := aead.Seal(nonce, nonce, plaintext, []byte(encryptedRefreshTokenVersion))
+	return s.Prefix + encryptedRefreshTokenVersion + "." + base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+func (s *EncryptedRefreshTokenStrategy) decrypt(token string) (*encryptedRefreshToken, error) {
+	if !strings.HasPrefix(token, s.Prefix) {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithHintf("Token is expected to start with '%s'.", s.Prefix))
+	}
+
+	split := strings.Split(strings.TrimPrefix(token, s.Prefix), ".")
+	if len(split) != 2 || split[0] != encryptedRefreshTokenVersion {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(split[1])
+	if err != nil {
+		return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	for _, secret := range append([][]byte{s.Secret}, s.RotatedSecrets...) {
+		aead, err := newRefreshTokenAEAD(secret)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(ciphertext) < aead.NonceSize() {
+			return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+		}
+
+		plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], []byte(encryptedRefreshTokenVersion))
+		if err != nil {
+			continue
+		}
+
+		var payload encryptedRefreshToken
+		if err := json.Unmarshal(plaintext, &payload); err != nil {
+			return nil, errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithWrap(err).WithDebug(err.Error()))
+		}
+		return &payload, nil
+	}
+
+	return nil, errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+}
+
+func newRefreshTokenAEAD(secret []byte) (cipher.AEAD, error) {
+	if len(secret) < 32 {
+		return nil, errors.Errorf("secret for encrypting refresh tokens is expected to be 32 byte long, got %d byte", len(secret))
+	}
+
+	block, err := aes.NewCipher(secret[:32])
+	if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return aead, nil
+}
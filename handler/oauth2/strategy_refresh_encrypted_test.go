@@ -0,0 +1,205 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package oauth2
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+)
+
+func newEncryptedRefreshTokenStrategy(store RefreshTokenFamilyStorage) *EncryptedRefreshTokenStrategy {
+	return &EncryptedRefreshTokenStrategy{
+		Secret:  []byte("foobarfoobarfoobarfoobarfoobarfoobarfoobarfoobar"),
+		Storage: store,
+		Prefix:  "ory_rt_",
+	}
+}
+
+func TestEncryptedRefreshTokenStrategy(t *testing.T) {
+	store := storage.NewMemoryStore()
+	strategy := newEncryptedRefreshTokenStrategy(store)
+	ctx := context.Background()
+
+	request := &fosite.Request{
+		ID:             "request-id",
+		RequestedAt:    time.Now().UTC().Round(time.Second),
+		Client:         &fosite.DefaultClient{ID: "foo"},
+		GrantedScope:   fosite.Arguments{"offline"},
+		RequestedScope: fosite.Arguments{"offline"},
+		Session: &fosite.DefaultSession{
+			Subject:   "peter",
+			ExpiresAt: map[fosite.TokenType]time.Time{fosite.RefreshToken: time.Now().UTC().Add(time.Hour).Round(time.Second)},
+		},
+	}
+
+	token, signature, err := strategy.GenerateRefreshToken(ctx, request)
+	require.NoError(t, err)
+	assert.Empty(t, signature)
+	assert.Contains(t, token, "ory_rt_v1.")
+	require.NoError(t, strategy.ValidateRefreshToken(ctx, request, token))
+
+	t.Run("case=decodes the request", func(t *testing.T) {
+		session := new(fosite.DefaultSession)
+		decoded, err := strategy.DecodeRefreshToken(ctx, token, session)
+		require.NoError(t, err)
+		assert.Equal(t, "request-id", decoded.GetID())
+		assert.Equal(t, "foo", decoded.GetClient().GetID())
+		assert.Equal(t, request.RequestedAt, decoded.GetRequestedAt())
+		assert.Equal(t, request.GrantedScope, decoded.GetGrantedScopes())
+		assert.Equal(t, "peter", session.Subject)
+	})
+
+	t.Run("case=rejects tampered tokens", func(t *testing.T) {
+		_, err := strategy.DecodeRefreshToken(ctx, token+"a", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+		assert.Error(t, strategy.ValidateRefreshToken(ctx, request, token[:len(token)-2]))
+		assert.Error(t, strategy.ValidateRefreshToken(ctx, request, "ory_at_v1.foo"))
+	})
+
+	t.Run("case=accepts rotated secrets", func(t *testing.T) {
+		rotated := newEncryptedRefreshTokenStrategy(store)
+		rotated.Secret = []byte("barfoobarfoobarfoobarfoobarfoobarfoobarfoo")
+		rotated.RotatedSecrets = [][]byte{strategy.Secret}
+		_, err := rotated.DecodeRefreshToken(ctx, token, nil)
+		require.NoError(t, err)
+	})
+
+	t.Run("case=rotation invalidates the previous generation", func(t *testing.T) {
+		next, err := strategy.RotateRefreshToken(ctx, token, request)
+		require.NoError(t, err)
+
+		_, err = strategy.DecodeRefreshToken(ctx, next, nil)
+		require.NoError(t, err)
+
+		decoded, err := strategy.DecodeRefreshToken(ctx, token, nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		require.NotNil(t, decoded)
+		assert.Equal(t, "request-id", decoded.GetID())
+
+		_, err = strategy.RotateRefreshToken(ctx, token, request)
+		assert.True(t, errors.Is(err, fosite.ErrSerializationFailure))
+
+		require.NoError(t, strategy.RevokeRefreshTokenFamily(ctx, "request-id"))
+		_, err = strategy.DecodeRefreshToken(ctx, next, nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+	})
+}
+
+func TestRefreshFlow_StatelessRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	strategy := newEncryptedRefreshTokenStrategy(store)
+	client := &fosite.DefaultClient{ID: "foo", GrantTypes: fosite.Arguments{"refresh_token"}, Scopes: []string{"offline"}}
+
+	h := &RefreshTokenGrantHandler{
+		AccessTokenStrategy:      &hmacshaStrategy,
+		RefreshTokenStrategy:     strategy,
+		TokenRevocationStorage:   store,
+		AccessTokenLifespan:      time.Hour,
+		RefreshTokenLifespan:     time.Hour,
+		ScopeStrategy:            fosite.HierarchicScopeStrategy,
+		AudienceMatchingStrategy: fosite.DefaultAudienceMatchingStrategy,
+	}
+
+	refresh := func(t *testing.T, token string) (string, error) {
+		areq := fosite.NewAccessRequest(&fosite.DefaultSession{})
+		areq.GrantTypes = fosite.Arguments{"refresh_token"}
+		areq.Client = client
+		areq.Form = url.Values{"refresh_token": {token}}
+
+		if err := h.HandleTokenEndpointRequest(ctx, areq); err != nil {
+			return "", err
+		}
+
+		aresp := fosite.NewAccessResponse()
+		if err := h.PopulateTokenEndpointResponse(ctx, areq, aresp); err != nil {
+			return "", err
+		}
+		assert.NotEmpty(t, aresp.GetAccessToken())
+		return aresp.GetExtra("refresh_token").(string), nil
+	}
+
+	initial, _, err := strategy.GenerateRefreshToken(ctx, &fosite.Request{
+		ID:           "request-id",
+		Client:       client,
+		GrantedScope: fosite.Arguments{"offline"},
+		Session:      &fosite.DefaultSession{Subject: "peter"},
+	})
+	require.NoError(t, err)
+
+	second, err := refresh(t, initial)
+	require.NoError(t, err)
+	assert.Empty(t, store.RefreshTokens, "self-contained refresh tokens must not be stored")
+
+	third, err := refresh(t, second)
+	require.NoError(t, err)
+
+	t.Run("case=unknown token", func(t *testing.T) {
+		_, err := refresh(t, "ory_rt_v1.foo")
+		assert.True(t, errors.Is(err, fosite.ErrInvalidGrant))
+	})
+
+	t.Run("case=reuse revokes the family", func(t *testing.T) {
+		_, err := refresh(t, initial)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+
+		_, err = refresh(t, third)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+	})
+}
+
+func TestTokenRevocation_StatelessRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+	strategy := newEncryptedRefreshTokenStrategy(store)
+	client := &fosite.DefaultClient{ID: "foo"}
+
+	token, _, err := strategy.GenerateRefreshToken(ctx, &fosite.Request{
+		ID:      "request-id",
+		Client:  client,
+		Session: &fosite.DefaultSession{},
+	})
+	require.NoError(t, err)
+
+	h := &TokenRevocationHandler{
+		TokenRevocationStorage: store,
+		RefreshTokenStrategy:   strategy,
+		AccessTokenStrategy:    &hmacshaStrategy,
+	}
+
+	err = h.RevokeToken(ctx, token, fosite.RefreshToken, &fosite.DefaultClient{ID: "bar"})
+	assert.True(t, errors.Is(err, fosite.ErrUnauthorizedClient))
+
+	require.NoError(t, h.RevokeToken(ctx, token, fosite.RefreshToken, client))
+
+	_, err = strategy.DecodeRefreshToken(ctx, token, nil)
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+}
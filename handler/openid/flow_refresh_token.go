@@ -86,7 +86,7 @@ func (c *OpenIDConnectRefreshHandler) PopulateTokenEndpointResponse(ctx context.
 	claims.AccessTokenHash = c.GetAccessTokenHash(ctx, requester, responder)
 	claims.JTI = uuid.New()
 	claims.CodeHash = ""
-	claims.IssuedAt = time.Now().Truncate(time.Second)
+	claims.IssuedAt = fosite.TimeNow(c.Clock).Truncate(time.Second)
 
 	return c.IssueExplicitIDToken(ctx, requester, responder)
 }
@@ -11,6 +11,9 @@ import (
 
 type IDTokenHandleHelper struct {
 	IDTokenStrategy OpenIDConnectTokenStrategy
+
+	// Clock is used to set the time based claims. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
 }
 
 func (i *IDTokenHandleHelper) GetAccessTokenHash(ctx context.Context, requester fosite.AccessRequester, responder fosite.AccessResponder) string {
@@ -33,6 +33,7 @@ type DefaultSession struct {
 	Claims    *jwt.IDTokenClaims
 	Headers   *jwt.Headers
 	ExpiresAt map[fosite.TokenType]time.Time
+	IssuedAt  map[fosite.TokenType]time.Time
 	Username  string
 	Subject   string
 	Extra     map[string]interface{}
@@ -125,6 +126,9 @@ type DefaultStrategy struct {
 	Issuer string
 
 	MinParameterEntropy int
+
+	// Clock is used to set and validate the time based claims. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
 }
 
 func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.Requester) (token string, err error) {
@@ -149,7 +153,7 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		}
 
 		// Adds a bit of wiggle room for timing issues
-		if claims.AuthTime.After(time.Now().UTC().Add(time.Second * 5)) {
+		if claims.AuthTime.After(fosite.TimeNow(h.Clock).Add(time.Second * 5)) {
 			return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to validate OpenID Connect request because authentication time is in the future."))
 		}
 
@@ -192,7 +196,9 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 		if tokenHintString := requester.GetRequestForm().Get("id_token_hint"); tokenHintString != "" {
 			tokenHint, err := h.JWTStrategy.Decode(ctx, tokenHintString)
 			var ve *jwt.ValidationError
-			if errors.As(err, &ve) && ve.Has(jwt.ValidationErrorExpired) {
+			if errors.Is(err, jwt.ErrSignerUnavailable) {
+				return "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+			} else if errors.As(err, &ve) && ve.Has(jwt.ValidationErrorExpired) {
 				// Expired ID Tokens are allowed as values to id_token_hint
 			} else if err != nil {
 				return "", errorsx.WithStack(fosite.ErrServerError.WithWrap(err).WithDebugf("Unable to decode id token from 'id_token_hint' parameter because %s.", err.Error()))
@@ -207,15 +213,15 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 	}
 
 	if claims.ExpiresAt.IsZero() {
-		claims.ExpiresAt = time.Now().UTC().Add(h.Expiry)
+		claims.ExpiresAt = fosite.TimeNow(h.Clock).Add(h.Expiry)
 	}
 
-	if claims.ExpiresAt.Before(time.Now().UTC()) {
+	if claims.ExpiresAt.Before(fosite.TimeNow(h.Clock)) {
 		return "", errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to generate id token because expiry claim can not be in the past."))
 	}
 
 	if claims.AuthTime.IsZero() {
-		claims.AuthTime = time.Now().Truncate(time.Second).UTC()
+		claims.AuthTime = fosite.TimeNow(h.Clock).Truncate(time.Second)
 	}
 
 	if claims.Issuer == "" {
@@ -232,8 +238,27 @@ func (h DefaultStrategy) GenerateIDToken(ctx context.Context, requester fosite.R
 	}
 
 	claims.Audience = stringslice.Unique(append(claims.Audience, requester.GetClient().GetID()))
-	claims.IssuedAt = time.Now().UTC()
+	claims.IssuedAt = fosite.TimeNow(h.Clock)
 
 	token, _, err = h.JWTStrategy.Generate(ctx, claims.ToMapClaims(), sess.IDTokenHeaders())
+	if errors.Is(err, jwt.ErrSignerUnavailable) {
+		return "", errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	}
 	return token, err
 }
+
+// SetIssuedAt implements fosite.IssuedAtSession for DefaultSession.
+func (s *DefaultSession) SetIssuedAt(key fosite.TokenType, iat time.Time) {
+	if s.IssuedAt == nil {
+		s.IssuedAt = make(map[fosite.TokenType]time.Time)
+	}
+	s.IssuedAt[key] = iat
+}
+
+// GetIssuedAt implements fosite.IssuedAtSession for DefaultSession.
+func (s *DefaultSession) GetIssuedAt(key fosite.TokenType) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	return s.IssuedAt[key]
+}
@@ -20,6 +20,9 @@ type OpenIDConnectRequestValidator struct {
 	AllowedPrompt       []string
 	Strategy            jwt.JWTStrategy
 	IsRedirectURISecure func(*url.URL) bool
+
+	// Clock is used to validate the auth_time claim. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
 }
 
 func NewOpenIDConnectRequestValidator(prompt []string, strategy jwt.JWTStrategy) *OpenIDConnectRequestValidator {
@@ -98,7 +101,7 @@ func (v *OpenIDConnectRequestValidator) ValidatePrompt(ctx context.Context, req
 	}
 
 	// Adds a bit of wiggle room for timing issues
-	if claims.AuthTime.After(time.Now().UTC().Add(time.Second * 5)) {
+	if claims.AuthTime.After(fosite.TimeNow(v.Clock).Add(time.Second * 5)) {
 		return errorsx.WithStack(fosite.ErrServerError.WithDebug("Failed to validate OpenID Connect request because authentication time is in the future."))
 	}
 
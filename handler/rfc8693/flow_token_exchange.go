@@ -115,10 +115,13 @@ func (c *Handler) HandleTokenEndpointRequest(ctx context.Context, request fosite
 	subjectClientAct := subjectReq.GetSession().(fosite.ExtraClaimsSession).GetExtraClaims()["act"]
 	createActHistory(subjectClientAct, client, request)
 
-	request.GetSession().SetExpiresAt(fosite.AccessToken, time.Now().UTC().Add(c.AccessTokenLifespan))
+	now := fosite.TimeNow(c.Clock)
+	request.GetSession().SetExpiresAt(fosite.AccessToken, now.Add(c.AccessTokenLifespan))
+	fosite.SetIssuedAt(request.GetSession(), fosite.AccessToken, now)
 	if c.RefreshTokenLifespan > -1 {
-		request.GetSession().SetExpiresAt(fosite.RefreshToken, time.Now().UTC().Add(c.RefreshTokenLifespan).Round(time.Second))
+		request.GetSession().SetExpiresAt(fosite.RefreshToken, now.Add(c.RefreshTokenLifespan).Round(time.Second))
 	}
+	fosite.SetIssuedAt(request.GetSession(), fosite.RefreshToken, now)
 
 	return nil
 }
@@ -136,7 +139,9 @@ func (c *Handler) PopulateTokenEndpointResponse(ctx context.Context, request fos
 
 	if request.GetGrantedScopes().HasOneOf(c.RefreshTokenScopes...) {
 		refresh, refreshSignature, err := c.RefreshTokenStrategy.GenerateRefreshToken(ctx, request)
-		if err != nil {
+		if errors.Is(err, fosite.ErrTemporarilyUnavailable) {
+			return err
+		} else if err != nil {
 			return errors.WithStack(fosite.ErrServerError.WithDebug(err.Error()))
 		}
 		if refreshSignature != "" {
@@ -21,7 +21,15 @@
 
 package fosite
 
-import "context"
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMismatchedHashAndData is returned by the hashers of this package, except BCrypt, if the data does not match the
+// hash.
+var ErrMismatchedHashAndData = errors.New("hash does not match data")
 
 // Hasher defines how a oauth2-compatible hasher should look like.
 type Hasher interface {
@@ -32,3 +40,13 @@ type Hasher interface {
 	// Hash creates a hash from data or returns an error.
 	Hash(ctx context.Context, data []byte) ([]byte, error)
 }
+
+// HasherWithRehash is a Hasher which detects hashes created by another algorithm or with other parameters than the
+// ones it uses to create new hashes.
+type HasherWithRehash interface {
+	Hasher
+
+	// NeedsRehash returns true if the data of hash should be hashed again, because hash has been created by another
+	// algorithm or with outdated parameters.
+	NeedsRehash(hash []byte) bool
+}
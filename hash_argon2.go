@@ -0,0 +1,140 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// DefaultArgon2idMemory is the default memory of Argon2id in KiB.
+	DefaultArgon2idMemory uint32 = 64 * 1024
+	// DefaultArgon2idIterations is the default number of passes over the memory of Argon2id.
+	DefaultArgon2idIterations uint32 = 3
+	// DefaultArgon2idParallelism is the default number of threads of Argon2id.
+	DefaultArgon2idParallelism uint8 = 4
+
+	argon2idPrefix     = "$argon2id$"
+	argon2SaltLength   = 16
+	argon2HashLength   = 32
+	argon2idHashFormat = "$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s"
+)
+
+// Argon2id implements the Hasher interface by using Argon2id. Hashes are encoded in the PHC string format, for example
+// "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>", and contain the parameters they have been created with.
+type Argon2id struct {
+	// Memory in KiB. Defaults to DefaultArgon2idMemory.
+	Memory uint32
+	// Iterations defaults to DefaultArgon2idIterations.
+	Iterations uint32
+	// Parallelism defaults to DefaultArgon2idParallelism.
+	Parallelism uint8
+}
+
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+}
+
+func (a *Argon2id) params() argon2idParams {
+	p := argon2idParams{memory: a.Memory, iterations: a.Iterations, parallelism: a.Parallelism}
+	if p.memory == 0 {
+		p.memory = DefaultArgon2idMemory
+	}
+	if p.iterations == 0 {
+		p.iterations = DefaultArgon2idIterations
+	}
+	if p.parallelism == 0 {
+		p.parallelism = DefaultArgon2idParallelism
+	}
+	return p
+}
+
+func (a *Argon2id) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	p := a.params()
+	key := argon2.IDKey(data, salt, p.iterations, p.memory, p.parallelism, argon2HashLength)
+	return []byte(fmt.Sprintf(argon2idHashFormat, argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (a *Argon2id) Compare(ctx context.Context, hash, data []byte) error {
+	p, salt, key, err := decodeArgon2idHash(hash)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(key, argon2.IDKey(data, salt, p.iterations, p.memory, p.parallelism, uint32(len(key)))) != 1 {
+		return errorsx.WithStack(ErrMismatchedHashAndData)
+	}
+	return nil
+}
+
+// NeedsRehash implements HasherWithRehash. It returns true if hash is no Argon2id hash or has been created with other
+// parameters.
+func (a *Argon2id) NeedsRehash(hash []byte) bool {
+	p, _, _, err := decodeArgon2idHash(hash)
+	return err != nil || p != a.params()
+}
+
+func decodeArgon2idHash(hash []byte) (p argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return p, nil, nil, errors.New("hash is not an Argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, errors.Wrap(err, "unable to decode the version of the Argon2id hash")
+	} else if version != argon2.Version {
+		return p, nil, nil, errors.Errorf("Argon2id version %d is not supported", version)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, errors.Wrap(err, "unable to decode the parameters of the Argon2id hash")
+	} else if p.iterations == 0 || p.parallelism == 0 {
+		return p, nil, nil, errors.New("Argon2id hash has invalid parameters")
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, errors.Wrap(err, "unable to decode the salt of the Argon2id hash")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, nil, nil, errors.Wrap(err, "unable to decode the Argon2id hash")
+	}
+	if len(salt) == 0 || len(key) != argon2HashLength {
+		return p, nil, nil, errors.New("Argon2id hash has an invalid salt or key length")
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgon2id(t *testing.T) {
+	ctx := context.Background()
+	hasher := &Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+	hash, err := hasher.Hash(ctx, []byte("hello world"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=1$"), "%s", hash)
+
+	other, err := hasher.Hash(ctx, []byte("hello world"))
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "hashes must be salted")
+
+	assert.NoError(t, hasher.Compare(ctx, hash, []byte("hello world")))
+	assert.True(t, errors.Is(hasher.Compare(ctx, hash, []byte("hello")), ErrMismatchedHashAndData))
+	assert.Error(t, hasher.Compare(ctx, []byte("$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$aGFzaA"), []byte("hello world")))
+	assert.Error(t, hasher.Compare(ctx, []byte("$2a$10$invalid"), []byte("hello world")))
+
+	for _, malformed := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$$",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$argon2id$v=19$m=1024,t=1,p=1$$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+	} {
+		assert.Error(t, hasher.Compare(ctx, []byte(malformed), []byte("")), "%s", malformed)
+		assert.Error(t, hasher.Compare(ctx, []byte(malformed), []byte("hello world")), "%s", malformed)
+	}
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, (&Argon2id{Memory: 2048, Iterations: 1, Parallelism: 1}).NeedsRehash(hash))
+	assert.True(t, hasher.NeedsRehash([]byte("$pbkdf2-sha256$i=1$c2FsdA$aGFzaA")))
+}
@@ -53,3 +53,14 @@ func (b *BCrypt) Compare(ctx context.Context, hash, data []byte) error {
 	}
 	return nil
 }
+
+// NeedsRehash implements HasherWithRehash. It returns true if hash is no bcrypt hash or has not been created with
+// WorkFactor.
+func (b *BCrypt) NeedsRehash(hash []byte) bool {
+	workFactor := b.WorkFactor
+	if workFactor == 0 {
+		workFactor = DefaultBCryptWorkFactor
+	}
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != workFactor
+}
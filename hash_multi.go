@@ -0,0 +1,69 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"bytes"
+	"context"
+)
+
+// MultiHasher hashes data using Hasher and compares hashes using the algorithm identified by their prefix, so that
+// secrets can be migrated from one algorithm to another. BCrypt ("$2a$", "$2b$" and "$2y$"), Argon2id ("$argon2id$")
+// and PBKDF2 ("$pbkdf2-sha256$") hashes are recognized, hashes of other formats are compared using Hasher.
+//
+// All hashes not created by Hasher with its current parameters need a rehash, see HasherWithRehash.
+type MultiHasher struct {
+	// Hasher creates new hashes. Defaults to BCrypt.
+	Hasher Hasher
+}
+
+func (m *MultiHasher) hasher() Hasher {
+	if m.Hasher == nil {
+		return new(BCrypt)
+	}
+	return m.Hasher
+}
+
+func (m *MultiHasher) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	return m.hasher().Hash(ctx, data)
+}
+
+func (m *MultiHasher) Compare(ctx context.Context, hash, data []byte) error {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$2a$")), bytes.HasPrefix(hash, []byte("$2b$")), bytes.HasPrefix(hash, []byte("$2y$")):
+		return new(BCrypt).Compare(ctx, hash, data)
+	case bytes.HasPrefix(hash, []byte(argon2idPrefix)):
+		return new(Argon2id).Compare(ctx, hash, data)
+	case bytes.HasPrefix(hash, []byte(pbkdf2Prefix)):
+		return new(PBKDF2).Compare(ctx, hash, data)
+	default:
+		return m.hasher().Compare(ctx, hash, data)
+	}
+}
+
+// NeedsRehash implements HasherWithRehash. It returns false if Hasher does not implement HasherWithRehash.
+func (m *MultiHasher) NeedsRehash(hash []byte) bool {
+	if h, ok := m.hasher().(HasherWithRehash); ok {
+		return h.NeedsRehash(hash)
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiHasher(t *testing.T) {
+	ctx := context.Background()
+	data := []byte("hello world")
+	hasher := &MultiHasher{Hasher: &Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1}}
+
+	for _, legacy := range []HasherWithRehash{
+		&BCrypt{WorkFactor: 4},
+		&PBKDF2{Iterations: 1000},
+		&Argon2id{Memory: 2048, Iterations: 1, Parallelism: 1},
+	} {
+		hash, err := legacy.Hash(ctx, data)
+		require.NoError(t, err)
+		assert.NoError(t, hasher.Compare(ctx, hash, data), "%s", hash)
+		assert.Error(t, hasher.Compare(ctx, hash, []byte("hello")), "%s", hash)
+		assert.True(t, hasher.NeedsRehash(hash), "%s", hash)
+	}
+
+	hash, err := hasher.Hash(ctx, data)
+	require.NoError(t, err)
+	assert.NoError(t, hasher.Compare(ctx, hash, data))
+	assert.False(t, hasher.NeedsRehash(hash))
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// DefaultPBKDF2Iterations is the default number of iterations of PBKDF2-SHA256.
+	DefaultPBKDF2Iterations = 600000
+
+	pbkdf2Prefix     = "$pbkdf2-sha256$"
+	pbkdf2SaltLength = 16
+	pbkdf2HashLength = 32
+	pbkdf2HashFormat = "$pbkdf2-sha256$i=%d$%s$%s"
+)
+
+// PBKDF2 implements the Hasher interface by using PBKDF2 with HMAC-SHA256. Hashes are encoded as
+// "$pbkdf2-sha256$i=<iterations>$<salt>$<hash>" and contain the number of iterations they have been created with.
+type PBKDF2 struct {
+	// Iterations defaults to DefaultPBKDF2Iterations.
+	Iterations int
+}
+
+func (p *PBKDF2) iterations() int {
+	if p.Iterations == 0 {
+		return DefaultPBKDF2Iterations
+	}
+	return p.Iterations
+}
+
+func (p *PBKDF2) Hash(ctx context.Context, data []byte) ([]byte, error) {
+	salt := make([]byte, pbkdf2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+
+	iterations := p.iterations()
+	key := pbkdf2.Key(data, salt, iterations, pbkdf2HashLength, sha256.New)
+	return []byte(fmt.Sprintf(pbkdf2HashFormat, iterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))), nil
+}
+
+func (p *PBKDF2) Compare(ctx context.Context, hash, data []byte) error {
+	iterations, salt, key, err := decodePBKDF2Hash(hash)
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare(key, pbkdf2.Key(data, salt, iterations, len(key), sha256.New)) != 1 {
+		return errorsx.WithStack(ErrMismatchedHashAndData)
+	}
+	return nil
+}
+
+// NeedsRehash implements HasherWithRehash. It returns true if hash is no PBKDF2-SHA256 hash or has been created with
+// another number of iterations.
+func (p *PBKDF2) NeedsRehash(hash []byte) bool {
+	iterations, _, _, err := decodePBKDF2Hash(hash)
+	return err != nil || iterations != p.iterations()
+}
+
+func decodePBKDF2Hash(hash []byte) (iterations int, salt, key []byte, err error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 5 || parts[1] != "pbkdf2-sha256" {
+		return 0, nil, nil, errors.New("hash is not a PBKDF2-SHA256 hash")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "i=%d", &iterations); err != nil {
+		return 0, nil, nil, errors.Wrap(err, "unable to decode the iterations of the PBKDF2-SHA256 hash")
+	} else if iterations <= 0 {
+		return 0, nil, nil, errors.Errorf("PBKDF2-SHA256 hash has invalid number of iterations %d", iterations)
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[3]); err != nil {
+		return 0, nil, nil, errors.Wrap(err, "unable to decode the salt of the PBKDF2-SHA256 hash")
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return 0, nil, nil, errors.Wrap(err, "unable to decode the PBKDF2-SHA256 hash")
+	}
+	if len(salt) == 0 || len(key) != pbkdf2HashLength {
+		return 0, nil, nil, errors.New("PBKDF2-SHA256 hash has an invalid salt or key length")
+	}
+	return iterations, salt, key, nil
+}
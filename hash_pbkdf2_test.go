@@ -0,0 +1,64 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPBKDF2(t *testing.T) {
+	ctx := context.Background()
+	hasher := &PBKDF2{Iterations: 1000}
+
+	hash, err := hasher.Hash(ctx, []byte("hello world"))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$pbkdf2-sha256$i=1000$"), "%s", hash)
+
+	assert.NoError(t, hasher.Compare(ctx, hash, []byte("hello world")))
+	assert.True(t, errors.Is(hasher.Compare(ctx, hash, []byte("hello")), ErrMismatchedHashAndData))
+
+	// Secrets are not truncated, unlike with bcrypt.
+	long := []byte(strings.Repeat("a", 100))
+	hash, err = hasher.Hash(ctx, long)
+	require.NoError(t, err)
+	assert.Error(t, hasher.Compare(ctx, hash, long[:72]))
+
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, new(PBKDF2).NeedsRehash(hash))
+	assert.Error(t, hasher.Compare(ctx, []byte("$pbkdf2-sha256$i=0$c2FsdA$aGFzaA"), long))
+
+	for _, malformed := range []string{
+		"$pbkdf2-sha256$i=1000$$",
+		"$pbkdf2-sha256$i=1000$c2FsdHNhbHRzYWx0c2FsdA$",
+		"$pbkdf2-sha256$i=1000$$aGFzaGhhc2hoYXNoaGFzaGhhc2hoYXNoaGFzaGhhc2g",
+		"$pbkdf2-sha256$i=1000$c2FsdHNhbHRzYWx0c2FsdA$aGFzaA",
+	} {
+		assert.Error(t, hasher.Compare(ctx, []byte(malformed), []byte("")), "%s", malformed)
+		assert.Error(t, hasher.Compare(ctx, []byte(malformed), long), "%s", malformed)
+	}
+}
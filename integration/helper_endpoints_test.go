@@ -161,6 +161,9 @@ func tokenEndpointHandler(t *testing.T, provider fosite.OAuth2Provider) func(rw
 		if accessRequest.GetRequestedScopes().Has("fosite") {
 			accessRequest.GrantScope("fosite")
 		}
+		for _, a := range accessRequest.GetRequestedAudience() {
+			accessRequest.GrantAudience(a)
+		}
 
 		response, err := provider.NewAccessResponse(ctx, accessRequest)
 		if err != nil {
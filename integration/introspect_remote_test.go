@@ -0,0 +1,138 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package integration_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	goauth "golang.org/x/oauth2"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/compose"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestRemoteTokenIntrospector(t *testing.T) {
+	key := internal.MustRSAKey()
+	fositeStore.Clients["remote-introspector"] = &fosite.DefaultOpenIDConnectClient{
+		DefaultClient: &fosite.DefaultClient{
+			ID:     "remote-introspector",
+			Scopes: []string{"fosite"},
+		},
+		JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "remote-introspector", Algorithm: "RS256", Use: "sig"},
+		}},
+		TokenEndpointAuthMethod: "private_key_jwt",
+	}
+	defer delete(fositeStore.Clients, "remote-introspector")
+
+	f := compose.Compose(&compose.Config{TokenURL: tokenURL}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	token, err := newOAuth2AppClient(ts).Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		description  string
+		introspector *oauth2.RemoteTokenIntrospector
+	}{
+		{
+			description: "client_secret_basic",
+			introspector: &oauth2.RemoteTokenIntrospector{
+				ClientID:     "my-client",
+				ClientSecret: "foobar",
+			},
+		},
+		{
+			description: "client_secret_post",
+			introspector: &oauth2.RemoteTokenIntrospector{
+				ClientID:     "my-client",
+				ClientSecret: "foobar",
+				AuthMethod:   "client_secret_post",
+			},
+		},
+		{
+			description: "private_key_jwt",
+			introspector: &oauth2.RemoteTokenIntrospector{
+				ClientID:          "remote-introspector",
+				AuthMethod:        "private_key_jwt",
+				AssertionStrategy: &jwt.RS256JWTStrategy{PrivateKey: key},
+				AssertionAudience: tokenURL,
+			},
+		},
+	} {
+		t.Run("method="+c.description, func(t *testing.T) {
+			c.introspector.IntrospectionURL = ts.URL + "/introspect"
+			c.introspector.ScopeStrategy = fosite.HierarchicScopeStrategy
+
+			// JSON Web Tokens are validated locally, all other tokens by the remote authorization server.
+			rs := &fosite.Fosite{TokenIntrospectionHandlers: fosite.TokenIntrospectionHandlers{
+				&oauth2.StatelessJWTValidator{
+					JWTStrategy:   &jwt.RS256JWTStrategy{PrivateKey: internal.MustRSAKey()},
+					ScopeStrategy: fosite.HierarchicScopeStrategy,
+				},
+				c.introspector,
+			}}
+
+			tu, ar, err := rs.IntrospectToken(goauth.NoContext, token.AccessToken, fosite.AccessToken, new(fosite.DefaultSession), "fosite")
+			require.NoError(t, err)
+			assert.Equal(t, fosite.AccessToken, tu)
+			assert.Equal(t, "my-client", ar.GetClient().GetID())
+			assert.Equal(t, fosite.Arguments{"fosite"}, ar.GetGrantedScopes())
+			assert.False(t, ar.GetSession().GetExpiresAt(fosite.AccessToken).IsZero())
+
+			_, _, err = rs.IntrospectToken(goauth.NoContext, token.AccessToken, fosite.AccessToken, new(fosite.DefaultSession), "offline")
+			assert.True(t, errors.Is(err, fosite.ErrInvalidScope))
+
+			_, _, err = rs.IntrospectToken(goauth.NoContext, "ory_at_foo.bar", fosite.AccessToken, new(fosite.DefaultSession))
+			assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		})
+	}
+}
+
+func TestRemoteTokenIntrospectorWithoutJTI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		_, _ = rw.Write([]byte(`{"active":true,"client_id":"my-client","scope":"fosite"}`))
+	}))
+	defer ts.Close()
+
+	introspector := &oauth2.RemoteTokenIntrospector{IntrospectionURL: ts.URL, ClientID: "my-client", ClientSecret: "foobar"}
+	ids := map[string]bool{}
+	for _, token := range []string{"foo", "bar"} {
+		ar := fosite.NewAccessRequest(new(fosite.DefaultSession))
+		_, err := introspector.IntrospectToken(goauth.NoContext, token, fosite.AccessToken, ar, nil)
+		require.NoError(t, err)
+		assert.NotEmpty(t, ar.GetID())
+		ids[ar.GetID()] = true
+	}
+	assert.Len(t, ids, 2, "tokens without a jti must not share a request ID")
+}
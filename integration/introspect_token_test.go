@@ -24,6 +24,8 @@ package integration_test
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/parnurzeal/gorequest"
@@ -143,3 +145,101 @@ func runIntrospectTokenTest(t *testing.T, strategy oauth2.AccessTokenStrategy, i
 		})
 	}
 }
+
+func TestIntrospectTokenAuthorization(t *testing.T) {
+	f := compose.Compose(&compose.Config{
+		IntrospectionScope:              "fosite",
+		IntrospectionAudienceRestricted: true,
+	}, fositeStore, hmacStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory)
+	ts := mockServer(t, f, &fosite.DefaultSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	oauthClient.Scopes = []string{"offline"}
+	withoutScope, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	oauthClient.Scopes = []string{"fosite"}
+	withScope, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	oauthClient.EndpointParams = url.Values{"audience": {"gateway"}}
+	forGateway, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	for k, c := range []struct {
+		prepare    func(*gorequest.SuperAgent) *gorequest.SuperAgent
+		token      string
+		statusCode int
+		isActive   bool
+	}{
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.Set("Authorization", "bearer "+withoutScope.AccessToken)
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret)
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusOK,
+			isActive:   false,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.Set("Authorization", "bearer "+withScope.AccessToken)
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusOK,
+			isActive:   false,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.SetBasicAuth("gateway", "foobar")
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusOK,
+			isActive:   true,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.SendMap(map[string]interface{}{"client_id": "public-client"})
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			prepare: func(s *gorequest.SuperAgent) *gorequest.SuperAgent {
+				return s.SetBasicAuth("public-client", "")
+			},
+			token:      forGateway.AccessToken,
+			statusCode: http.StatusUnauthorized,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			var res struct {
+				Active bool `json:"active"`
+			}
+			s := gorequest.New().Post(ts.URL + "/introspect").
+				Type("form").
+				SendStruct(map[string]string{"token": c.token})
+			resp, body, errs := c.prepare(s).End()
+			require.Len(t, errs, 0)
+			assert.Equal(t, c.statusCode, resp.StatusCode, body)
+			if c.statusCode == http.StatusOK {
+				require.NoError(t, json.Unmarshal([]byte(body), &res))
+				assert.Equal(t, c.isActive, res.Active, body)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/parnurzeal/gorequest"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	goauth "golang.org/x/oauth2"
@@ -72,3 +73,54 @@ func runRevokeTokenTest(t *testing.T, strategy oauth2.AccessTokenStrategy) {
 	require.Len(t, errs, 0)
 	assert.Equal(t, http.StatusUnauthorized, hres.StatusCode)
 }
+
+func TestRevokeJWTAccessToken(t *testing.T) {
+	f := compose.Compose(new(compose.Config), fositeStore, jwtStrategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2StatelessJWTIntrospectionFactory, compose.OAuth2TokenRevocationFactory)
+	ts := mockServer(t, f, &oauth2.JWTSession{})
+	defer ts.Close()
+
+	oauthClient := newOAuth2AppClient(ts)
+	token, err := oauthClient.Token(goauth.NoContext)
+	require.NoError(t, err)
+
+	strategy := *jwtStrategy
+	require.NoError(t, strategy.WithDenylist(fositeStore).ValidateAccessToken(goauth.NoContext, nil, token.AccessToken))
+
+	hres, _, errs := gorequest.New().Get(ts.URL+"/info").
+		Set("Authorization", "bearer "+token.AccessToken).
+		End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusOK, hres.StatusCode)
+
+	resp, _, errs := gorequest.New().Post(ts.URL+"/revoke").
+		SetBasicAuth(oauthClient.ClientID, oauthClient.ClientSecret).
+		Type("form").
+		SendStruct(map[string]string{"token": token.AccessToken}).End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.True(t, errors.Is(strategy.ValidateAccessToken(goauth.NoContext, nil, token.AccessToken), fosite.ErrInactiveToken))
+
+	hres, _, errs = gorequest.New().Get(ts.URL+"/info").
+		Set("Authorization", "bearer "+token.AccessToken).
+		End()
+	require.Len(t, errs, 0)
+	assert.Equal(t, http.StatusUnauthorized, hres.StatusCode)
+}
+
+func TestRevokeJWTAccessTokensByClient(t *testing.T) {
+	strategy := *jwtStrategy
+	strategy.Denylist = nil
+	f := compose.Compose(new(compose.Config), fositeStore, &strategy, nil, compose.OAuth2ClientCredentialsGrantFactory, compose.OAuth2TokenIntrospectionFactory, compose.OAuth2TokenRevocationFactory)
+	assert.Equal(t, fositeStore, strategy.Denylist, "the denylist of the storage is used")
+
+	ts := mockServer(t, f, &oauth2.JWTSession{})
+	defer ts.Close()
+
+	token, err := newOAuth2AppClient(ts).Token(goauth.NoContext)
+	require.NoError(t, err)
+	require.NoError(t, strategy.ValidateAccessToken(goauth.NoContext, nil, token.AccessToken))
+
+	require.NoError(t, f.(*fosite.Fosite).RevokeByClient(goauth.NoContext, "my-client"))
+	assert.True(t, errors.Is(strategy.ValidateAccessToken(goauth.NoContext, nil, token.AccessToken), fosite.ErrInactiveToken))
+}
@@ -124,47 +124,54 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 	token := r.PostForm.Get("token")
 	tokenTypeHint := r.PostForm.Get("token_type_hint")
 	scope := r.PostForm.Get("scope")
+	jwtResponseRequested := acceptsIntrospectionJWT(r)
+
+	var caller Client
 	if clientToken := AccessTokenFromRequest(r); clientToken != "" {
 		if token == clientToken {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Bearer and introspection token are identical."))
 		}
 
-		if tu, _, err := f.IntrospectToken(ctx, clientToken, AccessToken, session.Clone()); err != nil {
+		if tu, car, err := f.IntrospectToken(ctx, clientToken, AccessToken, session.Clone()); err != nil {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("HTTP Authorization header missing, malformed, or credentials used are invalid."))
 		} else if tu != "" && tu != AccessToken {
 			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHintf("HTTP Authorization header did not provide a token of type 'access_token', got type '%s'.", tu))
+		} else if !f.isIntrospectionAllowed(car.GetClient(), car.GetGrantedScopes()) {
+			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestForbidden.WithHint("The access token in the HTTP Authorization header is not allowed to introspect tokens."))
+		} else {
+			caller = car.GetClient()
 		}
 	} else {
-		id, secret, ok := r.BasicAuth()
-		if !ok {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("HTTP Authorization header missing."))
-		}
-
-		clientID, err := url.QueryUnescape(id)
-		if err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client ID from HTTP basic authorization header, make sure it is properly encoded.").WithWrap(err).WithDebug(err.Error()))
-		}
-
-		clientSecret, err := url.QueryUnescape(secret)
+		client, err := f.authenticateIntrospectionClient(ctx, r)
 		if err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client Secret from HTTP basic authorization header, make sure it is properly encoded.").WithWrap(err).WithDebug(err.Error()))
+			return &IntrospectionResponse{Active: false}, err
 		}
 
-		client, err := f.Store.GetClient(ctx, clientID)
-		if err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to find OAuth 2.0 Client from HTTP basic authorization header.").WithWrap(err).WithDebug(err.Error()))
-		}
-
-		// Enforce client authentication
-		if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
-			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid."))
+		if !f.isIntrospectionAllowed(client, client.GetScopes()) {
+			return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrRequestForbidden.WithHint("The OAuth 2.0 Client is not allowed to introspect tokens."))
 		}
+		caller = client
 	}
 
 	tu, ar, err := f.IntrospectToken(ctx, token, TokenUse(tokenTypeHint), session, RemoveEmpty(strings.Split(scope, " "))...)
 	if err != nil {
-		return &IntrospectionResponse{Active: false}, errorsx.WithStack(ErrInactiveToken.WithHint("An introspection strategy indicated that the token is inactive.").WithWrap(err).WithDebug(err.Error()))
+		return &IntrospectionResponse{
+			Active:               false,
+			Caller:               caller,
+			JWTResponseRequested: jwtResponseRequested,
+			Context:              ctx,
+		}, errorsx.WithStack(ErrInactiveToken.WithHint("An introspection strategy indicated that the token is inactive.").WithWrap(err).WithDebug(err.Error()))
 	}
+
+	if f.IntrospectionAudienceRestricted && (caller == nil || !ar.GetGrantedAudience().Has(caller.GetID())) {
+		return &IntrospectionResponse{
+			Active:               false,
+			Caller:               caller,
+			JWTResponseRequested: jwtResponseRequested,
+			Context:              ctx,
+		}, errorsx.WithStack(ErrInactiveToken.WithHint("The token is not intended for the OAuth 2.0 Client performing the introspection request."))
+	}
+
 	accessTokenType := ""
 
 	if tu == AccessToken {
@@ -172,21 +179,96 @@ func (f *Fosite) NewIntrospectionRequest(ctx context.Context, r *http.Request, s
 	}
 
 	return &IntrospectionResponse{
-		Active:          true,
-		AccessRequester: ar,
-		TokenUse:        tu,
-		AccessTokenType: accessTokenType,
+		Active:               true,
+		AccessRequester:      ar,
+		TokenUse:             tu,
+		AccessTokenType:      accessTokenType,
+		Caller:               caller,
+		JWTResponseRequested: jwtResponseRequested,
+		Context:              ctx,
 	}, nil
 }
 
+// authenticateIntrospectionClient authenticates the client calling the introspection endpoint using HTTP basic
+// authorization or, if the header is missing, using the client authentication methods of the token endpoint, for
+// example client_secret_post or private_key_jwt.
+func (f *Fosite) authenticateIntrospectionClient(ctx context.Context, r *http.Request) (Client, error) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		if r.PostForm.Get("client_id") == "" && r.PostForm.Get("client_assertion") == "" {
+			return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("HTTP Authorization header missing."))
+		}
+
+		client, err := f.AuthenticateClient(ctx, r, r.PostForm)
+		if err != nil {
+			return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid.").WithWrap(err).WithDebug(err.Error()))
+		} else if client.IsPublic() {
+			// AuthenticateClient accepts public clients without any credentials, but they must not introspect tokens.
+			return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Public OAuth 2.0 Clients are not allowed to introspect tokens."))
+		}
+		return client, nil
+	}
+
+	clientID, err := url.QueryUnescape(id)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client ID from HTTP basic authorization header, make sure it is properly encoded.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	clientSecret, err := url.QueryUnescape(secret)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to decode OAuth 2.0 Client Secret from HTTP basic authorization header, make sure it is properly encoded.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	client, err := f.Store.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Unable to find OAuth 2.0 Client from HTTP basic authorization header.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if client.IsPublic() {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("Public OAuth 2.0 Clients are not allowed to introspect tokens."))
+	}
+
+	// Enforce client authentication
+	if err := f.checkClientSecret(ctx, client, []byte(clientSecret)); err != nil {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("OAuth 2.0 Client credentials are invalid."))
+	}
+	return client, nil
+}
+
+// isIntrospectionAllowed decides whether caller, holding the given scopes, may call the introspection endpoint.
+func (f *Fosite) isIntrospectionAllowed(caller Client, scopes []string) bool {
+	if ic, ok := caller.(IntrospectionClient); ok {
+		return ic.IsIntrospectionAllowed()
+	} else if f.IntrospectionScope == "" {
+		return true
+	}
+
+	scopeStrategy := f.ScopeStrategy
+	if scopeStrategy == nil {
+		scopeStrategy = WildcardScopeStrategy
+	}
+	return scopeStrategy(scopes, f.IntrospectionScope)
+}
+
 type IntrospectionResponse struct {
 	Active          bool            `json:"active"`
 	AccessRequester AccessRequester `json:"extra"`
 	TokenUse        TokenUse        `json:"token_use,omitempty"`
 	AccessTokenType string          `json:"token_type,omitempty"`
 	Lang            language.Tag    `json:"-"`
+
+	// Caller is the authenticated client which performed the introspection request.
+	Caller Client `json:"-"`
+
+	// JWTResponseRequested is true if the caller accepts JWT-secured introspection responses (RFC 9701).
+	JWTResponseRequested bool `json:"-"`
+
+	// Context is the context of the introspection request, it is used to sign JWT-secured introspection responses.
+	Context context.Context `json:"-"`
 }
 
+var _ JWTIntrospectionResponder = new(IntrospectionResponse)
+
 func (r *IntrospectionResponse) IsActive() bool {
 	return r.Active
 }
@@ -202,3 +284,18 @@ func (r *IntrospectionResponse) GetTokenUse() TokenUse {
 func (r *IntrospectionResponse) GetAccessTokenType() string {
 	return r.AccessTokenType
 }
+
+func (r *IntrospectionResponse) IsJWTResponseRequested() bool {
+	return r.JWTResponseRequested
+}
+
+func (r *IntrospectionResponse) GetCaller() Client {
+	return r.Caller
+}
+
+func (r *IntrospectionResponse) GetContext() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+	return r.Context
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// IntrospectionResponseBuilder builds the members of the introspection response for an active token. Inactive tokens
+// are always reported as {"active": false}.
+type IntrospectionResponseBuilder interface {
+	// BuildIntrospectionResponse returns the members of the introspection response for r. caller is the client which
+	// performed the introspection request, or nil if unknown.
+	BuildIntrospectionResponse(r IntrospectionResponder, caller Client) map[string]interface{}
+}
+
+// IntrospectionResponseModifier adds or removes members of an introspection response, for example depending on the
+// calling client. caller is nil if unknown.
+type IntrospectionResponseModifier func(response map[string]interface{}, r IntrospectionResponder, caller Client)
+
+// accessTokenJTISession is implemented by sessions which remember the jti of their JSON Web Token access token, for
+// example oauth2.JWTSession.
+type accessTokenJTISession interface {
+	GetAccessTokenJTI() string
+}
+
+// DefaultIntrospectionResponseBuilder includes the members defined in
+// https://tools.ietf.org/html/rfc7662#section-2.2 and, as extensions, the "cnf" and "act" claims. Extra claims of the
+// session are included as well, unless they collide with a member Fosite is authoritative for.
+type DefaultIntrospectionResponseBuilder struct {
+	// Issuer is reported as "iss" unless the session's extra claims contain an issuer already.
+	Issuer string
+
+	// Modifiers are applied, in order, to every response.
+	Modifiers []IntrospectionResponseModifier
+}
+
+var _ IntrospectionResponseBuilder = new(DefaultIntrospectionResponseBuilder)
+
+// BuildIntrospectionResponse implements IntrospectionResponseBuilder.
+func (b *DefaultIntrospectionResponseBuilder) BuildIntrospectionResponse(r IntrospectionResponder, caller Client) map[string]interface{} {
+	ar := r.GetAccessRequester()
+	session := ar.GetSession()
+	tokenUse := r.GetTokenUse()
+	if tokenUse == "" {
+		tokenUse = AccessToken
+	}
+
+	response := map[string]interface{}{}
+	if b.Issuer != "" {
+		response["iss"] = b.Issuer
+	}
+
+	issuedAt := ar.GetRequestedAt()
+	if s, ok := session.(IssuedAtSession); ok && !s.GetIssuedAt(tokenUse).IsZero() {
+		issuedAt = s.GetIssuedAt(tokenUse)
+	}
+
+	// The token ID defaults to the request ID, which identifies HMAC tokens. JSON Web Token access tokens carry their
+	// own jti, which sessions may remember. Both may be overridden by the extra claims of the session, as may "nbf"
+	// which is only reported if the session has one.
+	if s, ok := session.(accessTokenJTISession); ok && tokenUse == AccessToken && s.GetAccessTokenJTI() != "" {
+		response["jti"] = s.GetAccessTokenJTI()
+	} else if ar.GetID() != "" {
+		response["jti"] = ar.GetID()
+	}
+
+	if extraClaimsSession, ok := session.(ExtraClaimsSession); ok {
+		for name, value := range extraClaimsSession.GetExtraClaims() {
+			switch name {
+			// We do not allow these to be set through extra claims.
+			case "active", "exp", "client_id", "scope", "iat", "sub", "aud", "username", "token_type":
+				continue
+			default:
+				response[name] = value
+			}
+		}
+	}
+
+	response["active"] = true
+	if exp := session.GetExpiresAt(tokenUse); !exp.IsZero() {
+		response["exp"] = exp.Unix()
+	}
+	if !issuedAt.IsZero() {
+		response["iat"] = issuedAt.Unix()
+	}
+	if ar.GetClient().GetID() != "" {
+		response["client_id"] = ar.GetClient().GetID()
+	}
+	if len(ar.GetGrantedScopes()) > 0 {
+		response["scope"] = strings.Join(ar.GetGrantedScopes(), " ")
+	}
+	if session.GetSubject() != "" {
+		response["sub"] = session.GetSubject()
+	}
+	if len(ar.GetGrantedAudience()) > 0 {
+		response["aud"] = ar.GetGrantedAudience()
+	}
+	if session.GetUsername() != "" {
+		response["username"] = session.GetUsername()
+	}
+	if r.GetAccessTokenType() != "" {
+		response["token_type"] = r.GetAccessTokenType()
+	}
+
+	// The token exchange handler stores the actor as a serialized JSON object.
+	if act, ok := response["act"].(string); ok {
+		var actor map[string]interface{}
+		if err := json.Unmarshal([]byte(act), &actor); err == nil {
+			response["act"] = actor
+		}
+	}
+	if s, ok := session.(ConfirmationSession); ok {
+		if cnf := s.GetConfirmation(); len(cnf) > 0 {
+			response["cnf"] = cnf
+		}
+	}
+
+	for _, modify := range b.Modifiers {
+		modify(response, r, caller)
+	}
+
+	return response
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/ory/fosite"
+)
+
+type confirmationSession struct {
+	*DefaultSession
+}
+
+func (s *confirmationSession) GetConfirmation() map[string]interface{} {
+	return map[string]interface{}{"x5t#S256": "bwcK0esc3ACC3DB2Y5_lESsXE8o9ltc05O89jdN-dg2"}
+}
+
+type accessTokenJTISession struct {
+	*DefaultSession
+}
+
+func (s *accessTokenJTISession) GetAccessTokenJTI() string {
+	return "jwt-id"
+}
+
+func TestDefaultIntrospectionResponseBuilder(t *testing.T) {
+	requestedAt := time.Now().Add(-time.Hour).Round(time.Second)
+	issuedAt := time.Now().Round(time.Second)
+
+	newResponse := func() *IntrospectionResponse {
+		sess := &DefaultSession{Subject: "peter"}
+		sess.SetExpiresAt(AccessToken, issuedAt.Add(time.Hour))
+		sess.SetIssuedAt(AccessToken, issuedAt)
+		sess.GetExtraClaims()["act"] = `{"client_id":"gateway"}`
+		sess.GetExtraClaims()["jti"] = "token-id"
+		sess.GetExtraClaims()["token_type"] = "invalid"
+
+		ar := NewAccessRequest(sess)
+		ar.RequestedAt = requestedAt
+		ar.Client = &DefaultClient{ID: "foo"}
+		return &IntrospectionResponse{
+			Active:          true,
+			AccessRequester: ar,
+			TokenUse:        AccessToken,
+			AccessTokenType: BearerAccessToken,
+		}
+	}
+
+	t.Run("case=includes standard members", func(t *testing.T) {
+		b := &DefaultIntrospectionResponseBuilder{Issuer: "https://auth.example.com"}
+		response := b.BuildIntrospectionResponse(newResponse(), nil)
+
+		assert.Equal(t, true, response["active"])
+		assert.Equal(t, "https://auth.example.com", response["iss"])
+		assert.Equal(t, "bearer", response["token_type"])
+		assert.Equal(t, "token-id", response["jti"])
+		assert.Equal(t, issuedAt.Unix(), response["iat"])
+		assert.NotContains(t, response, "nbf", "the session has no nbf claim")
+		assert.Equal(t, issuedAt.Add(time.Hour).Unix(), response["exp"])
+		assert.Equal(t, map[string]interface{}{"client_id": "gateway"}, response["act"])
+		assert.NotContains(t, response, "cnf")
+	})
+
+	t.Run("case=falls back to the request time", func(t *testing.T) {
+		r := newResponse()
+		r.AccessRequester.GetSession().(*DefaultSession).IssuedAt = nil
+		response := new(DefaultIntrospectionResponseBuilder).BuildIntrospectionResponse(r, nil)
+
+		assert.Equal(t, requestedAt.Unix(), response["iat"])
+		assert.NotContains(t, response, "iss")
+	})
+
+	t.Run("case=reports the request ID and nbf of the session", func(t *testing.T) {
+		r := newResponse()
+		delete(r.AccessRequester.GetSession().(*DefaultSession).Extra, "jti")
+		r.AccessRequester.GetSession().(*DefaultSession).Extra["nbf"] = requestedAt.Unix()
+		response := new(DefaultIntrospectionResponseBuilder).BuildIntrospectionResponse(r, nil)
+
+		assert.Equal(t, r.AccessRequester.GetID(), response["jti"])
+		assert.Equal(t, requestedAt.Unix(), response["nbf"])
+
+		r.AccessRequester.(*AccessRequest).Session = &accessTokenJTISession{DefaultSession: r.AccessRequester.GetSession().(*DefaultSession)}
+		response = new(DefaultIntrospectionResponseBuilder).BuildIntrospectionResponse(r, nil)
+		assert.Equal(t, "jwt-id", response["jti"], "JSON Web Tokens are reported with their own jti")
+	})
+
+	t.Run("case=includes the confirmation", func(t *testing.T) {
+		r := newResponse()
+		r.AccessRequester.(*AccessRequest).Session = &confirmationSession{DefaultSession: r.AccessRequester.GetSession().(*DefaultSession)}
+		response := new(DefaultIntrospectionResponseBuilder).BuildIntrospectionResponse(r, nil)
+
+		assert.Equal(t, map[string]interface{}{"x5t#S256": "bwcK0esc3ACC3DB2Y5_lESsXE8o9ltc05O89jdN-dg2"}, response["cnf"])
+	})
+
+	t.Run("case=modifiers filter members per caller", func(t *testing.T) {
+		b := &DefaultIntrospectionResponseBuilder{Modifiers: []IntrospectionResponseModifier{
+			func(response map[string]interface{}, _ IntrospectionResponder, caller Client) {
+				if caller == nil || caller.GetID() != "trusted" {
+					delete(response, "sub")
+				}
+			},
+		}}
+
+		assert.NotContains(t, b.BuildIntrospectionResponse(newResponse(), &DefaultClient{ID: "untrusted"}), "sub")
+		assert.Equal(t, "peter", b.BuildIntrospectionResponse(newResponse(), &DefaultClient{ID: "trusted"})["sub"])
+	})
+}
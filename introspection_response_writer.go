@@ -24,7 +24,6 @@ package fosite
 import (
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -56,7 +55,7 @@ func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 	}
 
 	// Inactive token errors should never written out as an error.
-	if !errors.Is(err, ErrInactiveToken) && (errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrRequestUnauthorized)) {
+	if !errors.Is(err, ErrInactiveToken) && (errors.Is(err, ErrInvalidRequest) || errors.Is(err, ErrRequestUnauthorized) || errors.Is(err, ErrRequestForbidden)) {
 		f.writeJsonError(rw, nil, err)
 		return
 	}
@@ -195,7 +194,17 @@ func (f *Fosite) WriteIntrospectionError(rw http.ResponseWriter, err error) {
 //	{
 //	  "active": false
 //	}
+//
+// If the caller accepts "application/token-introspection+jwt" and Fosite.IntrospectionJWTResponseStrategy is set, the
+// response is instead wrapped in a signed, and optionally encrypted, JSON Web Token as defined in RFC 9701. To respond
+// with a JSON Web Token for inactive tokens as well, pass the IntrospectionResponder returned together with
+// ErrInactiveToken by NewIntrospectionRequest to this method.
 func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder) {
+	if jr, ok := r.(JWTIntrospectionResponder); ok && jr.IsJWTResponseRequested() && f.IntrospectionJWTResponseStrategy != nil {
+		f.writeIntrospectionJWTResponse(rw, jr)
+		return
+	}
+
 	if !r.IsActive() {
 		_ = json.NewEncoder(rw).Encode(&struct {
 			Active bool `json:"active"`
@@ -203,50 +212,22 @@ func (f *Fosite) WriteIntrospectionResponse(rw http.ResponseWriter, r Introspect
 		return
 	}
 
-	response := map[string]interface{}{
-		"active": true,
-	}
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	_ = json.NewEncoder(rw).Encode(f.introspectionResponseClaims(r))
+}
 
-	extraClaimsSession, ok := r.GetAccessRequester().GetSession().(ExtraClaimsSession)
-	if ok {
-		extraClaims := extraClaimsSession.GetExtraClaims()
-		if extraClaims != nil {
-			for name, value := range extraClaims {
-				switch name {
-				// We do not allow these to be set through extra claims.
-				case "exp", "client_id", "scope", "iat", "sub", "aud", "username":
-					continue
-				default:
-					response[name] = value
-				}
-			}
-		}
+// introspectionResponseClaims returns the members of the introspection response for r.
+func (f *Fosite) introspectionResponseClaims(r IntrospectionResponder) map[string]interface{} {
+	if !r.IsActive() {
+		return map[string]interface{}{"active": false}
 	}
 
-	if !r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).IsZero() {
-		response["exp"] = r.GetAccessRequester().GetSession().GetExpiresAt(AccessToken).Unix()
-	}
-	if r.GetAccessRequester().GetClient().GetID() != "" {
-		response["client_id"] = r.GetAccessRequester().GetClient().GetID()
-	}
-	if len(r.GetAccessRequester().GetGrantedScopes()) > 0 {
-		response["scope"] = strings.Join(r.GetAccessRequester().GetGrantedScopes(), " ")
-	}
-	if !r.GetAccessRequester().GetRequestedAt().IsZero() {
-		response["iat"] = r.GetAccessRequester().GetRequestedAt().Unix()
-	}
-	if r.GetAccessRequester().GetSession().GetSubject() != "" {
-		response["sub"] = r.GetAccessRequester().GetSession().GetSubject()
-	}
-	if len(r.GetAccessRequester().GetGrantedAudience()) > 0 {
-		response["aud"] = r.GetAccessRequester().GetGrantedAudience()
-	}
-	if r.GetAccessRequester().GetSession().GetUsername() != "" {
-		response["username"] = r.GetAccessRequester().GetSession().GetUsername()
+	var caller Client
+	if jr, ok := r.(JWTIntrospectionResponder); ok {
+		caller = jr.GetCaller()
 	}
 
-	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
-	rw.Header().Set("Cache-Control", "no-store")
-	rw.Header().Set("Pragma", "no-cache")
-	_ = json.NewEncoder(rw).Encode(response)
+	return f.GetIntrospectionResponseBuilder().BuildIntrospectionResponse(r, caller)
 }
@@ -0,0 +1,179 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// IntrospectionJWTMediaType is the media type of JWT-secured introspection responses as defined in RFC 9701.
+const IntrospectionJWTMediaType = "application/token-introspection+jwt"
+
+// introspectionJWTType is the "typ" header of JWT-secured introspection responses.
+const introspectionJWTType = "token-introspection+jwt"
+
+func acceptsIntrospectionJWT(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept)); err == nil && mediaType == IntrospectionJWTMediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// introspectionJWTHeader is a jwt.Mapper which, unlike jwt.Headers, allows to set the "typ" header.
+type introspectionJWTHeader map[string]interface{}
+
+func (h introspectionJWTHeader) ToMap() map[string]interface{} {
+	return h
+}
+
+func (h introspectionJWTHeader) Add(key string, value interface{}) {
+	h[key] = value
+}
+
+func (h introspectionJWTHeader) Get(key string) interface{} {
+	return h[key]
+}
+
+// writeIntrospectionJWTResponse responds with the introspection response wrapped in a JSON Web Token as defined in
+// https://www.rfc-editor.org/rfc/rfc9701.html#section-5
+func (f *Fosite) writeIntrospectionJWTResponse(rw http.ResponseWriter, r JWTIntrospectionResponder) {
+	token, err := f.generateIntrospectionJWT(r.GetContext(), r)
+	if err != nil {
+		f.writeJsonError(rw, nil, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", IntrospectionJWTMediaType)
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+	_, _ = rw.Write([]byte(token))
+}
+
+func (f *Fosite) generateIntrospectionJWT(ctx context.Context, r JWTIntrospectionResponder) (string, error) {
+	claims := jwt.MapClaims{
+		"iat":                 f.GetClock().Now().UTC().Unix(),
+		"token_introspection": f.introspectionResponseClaims(r),
+	}
+	if f.IntrospectionJWTResponseIssuer != "" {
+		claims["iss"] = f.IntrospectionJWTResponseIssuer
+	}
+	if caller := r.GetCaller(); caller != nil {
+		claims["aud"] = caller.GetID()
+	}
+
+	token, _, err := f.IntrospectionJWTResponseStrategy.Generate(ctx, claims, introspectionJWTHeader{"typ": introspectionJWTType})
+	if errors.Is(err, jwt.ErrSignerUnavailable) {
+		return "", errorsx.WithStack(ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
+	} else if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	client, ok := r.GetCaller().(JWTIntrospectionClient)
+	if !ok || client.GetIntrospectionEncryptedResponseAlgorithm() == "" {
+		return token, nil
+	}
+
+	return f.encryptIntrospectionJWT(client, token)
+}
+
+// encryptIntrospectionJWT wraps the signed token in a JSON Web Encryption for the client as defined in
+// https://www.rfc-editor.org/rfc/rfc9701.html#section-5
+func (f *Fosite) encryptIntrospectionJWT(client JWTIntrospectionClient, token string) (string, error) {
+	alg := jose.KeyAlgorithm(client.GetIntrospectionEncryptedResponseAlgorithm())
+	key, err := f.findClientEncryptionJWK(client, alg)
+	if err != nil {
+		return "", err
+	}
+
+	encrypter, err := jose.NewEncrypter(
+		jose.ContentEncryption(client.GetIntrospectionEncryptedResponseEncryption()),
+		jose.Recipient{Algorithm: alg, Key: key.Key, KeyID: key.KeyID},
+		(&jose.EncrypterOptions{}).WithType(introspectionJWTType).WithContentType("JWT"),
+	)
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	encrypted, err := encrypter.Encrypt([]byte(token))
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	serialized, err := encrypted.CompactSerialize()
+	if err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return serialized, nil
+}
+
+func (f *Fosite) findClientEncryptionJWK(client OpenIDConnectClient, alg jose.KeyAlgorithm) (*jose.JSONWebKey, error) {
+	if set := client.GetJSONWebKeys(); set != nil {
+		return findEncryptionKey(set, alg)
+	}
+
+	if location := client.GetJSONWebKeysURI(); len(location) > 0 && f.JWKSFetcherStrategy != nil {
+		keys, err := f.JWKSFetcherStrategy.Resolve(location, false)
+		if err != nil {
+			return nil, err
+		}
+
+		if key, err := findEncryptionKey(keys, alg); err == nil {
+			return key, nil
+		}
+
+		keys, err = f.JWKSFetcherStrategy.Resolve(location, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return findEncryptionKey(keys, alg)
+	}
+
+	return nil, errorsx.WithStack(ErrServerError.WithDebug("The OAuth 2.0 Client has no JSON Web Keys set registered, but they are needed to encrypt the introspection response."))
+}
+
+func findEncryptionKey(set *jose.JSONWebKeySet, alg jose.KeyAlgorithm) (*jose.JSONWebKey, error) {
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "enc" {
+			continue
+		} else if key.Algorithm != "" && key.Algorithm != string(alg) {
+			continue
+		}
+
+		if public := key.Public(); public.Valid() {
+			return &public, nil
+		}
+	}
+
+	return nil, errorsx.WithStack(ErrServerError.WithDebugf("Unable to find a public key with use='enc' for algorithm '%s' in the JSON Web Key Set of the OAuth 2.0 Client.", alg))
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestWriteIntrospectionResponseJWT(t *testing.T) {
+	key := internal.MustRSAKey()
+	f := &Fosite{
+		IntrospectionJWTResponseStrategy: &jwt.RS256JWTStrategy{PrivateKey: key},
+		IntrospectionJWTResponseIssuer:   "https://auth.example.com",
+	}
+
+	newResponse := func(caller Client, active bool) *IntrospectionResponse {
+		sess := &DefaultSession{Subject: "peter"}
+		sess.SetExpiresAt(AccessToken, time.Now().Add(time.Hour))
+		ar := NewAccessRequest(sess)
+		ar.Client = &DefaultClient{ID: "foo"}
+		return &IntrospectionResponse{
+			Active:               active,
+			TokenUse:             AccessToken,
+			AccessRequester:      ar,
+			Caller:               caller,
+			JWTResponseRequested: true,
+		}
+	}
+
+	parse := func(t *testing.T, raw string) jwt.MapClaims {
+		token, err := jwt.Parse(raw, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+		require.NoError(t, err)
+		assert.Equal(t, "token-introspection+jwt", token.Header["typ"])
+		return token.Claims
+	}
+
+	t.Run("case=signed response", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, newResponse(&DefaultClient{ID: "rs"}, true))
+
+		assert.Equal(t, IntrospectionJWTMediaType, rw.Header().Get("Content-Type"))
+		claims := parse(t, rw.Body.String())
+		assert.Equal(t, "https://auth.example.com", claims["iss"])
+		assert.Equal(t, "rs", claims["aud"])
+
+		introspection, ok := claims["token_introspection"].(map[string]interface{})
+		require.True(t, ok)
+		assert.Equal(t, true, introspection["active"])
+		assert.Equal(t, "foo", introspection["client_id"])
+		assert.Equal(t, "peter", introspection["sub"])
+	})
+
+	t.Run("case=inactive token", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, newResponse(&DefaultClient{ID: "rs"}, false))
+
+		claims := parse(t, rw.Body.String())
+		assert.Equal(t, map[string]interface{}{"active": false}, claims["token_introspection"])
+	})
+
+	t.Run("case=encrypted response", func(t *testing.T) {
+		encryptionKey := internal.MustRSAKey()
+		caller := &DefaultOpenIDConnectClient{
+			DefaultClient: &DefaultClient{ID: "rs"},
+			JSONWebKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{Key: &encryptionKey.PublicKey, KeyID: "sig", Use: "sig"},
+				{Key: &encryptionKey.PublicKey, KeyID: "enc", Use: "enc"},
+			}},
+			IntrospectionEncryptedResponseAlg: string(jose.RSA_OAEP_256),
+		}
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, newResponse(caller, true))
+		assert.Equal(t, IntrospectionJWTMediaType, rw.Header().Get("Content-Type"))
+
+		encrypted, err := jose.ParseEncrypted(rw.Body.String())
+		require.NoError(t, err)
+		assert.Equal(t, "enc", encrypted.Header.KeyID)
+		assert.EqualValues(t, "JWT", encrypted.Header.ExtraHeaders[jose.HeaderContentType])
+
+		decrypted, err := encrypted.Decrypt(encryptionKey)
+		require.NoError(t, err)
+		assert.Equal(t, "rs", parse(t, string(decrypted))["aud"])
+	})
+
+	t.Run("case=falls back to JSON if no JWT was requested", func(t *testing.T) {
+		res := newResponse(&DefaultClient{ID: "rs"}, true)
+		res.JWTResponseRequested = false
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, res)
+		assert.Equal(t, "application/json;charset=UTF-8", rw.Header().Get("Content-Type"))
+	})
+
+	t.Run("case=signs with the request context", func(t *testing.T) {
+		type ctxKey struct{}
+		strategy := &contextRecordingJWTStrategy{JWTStrategy: f.IntrospectionJWTResponseStrategy}
+		f := *f
+		f.IntrospectionJWTResponseStrategy = strategy
+
+		res := newResponse(&DefaultClient{ID: "rs"}, true)
+		res.Context = context.WithValue(context.Background(), ctxKey{}, "request")
+
+		rw := httptest.NewRecorder()
+		f.WriteIntrospectionResponse(rw, res)
+		assert.Equal(t, IntrospectionJWTMediaType, rw.Header().Get("Content-Type"))
+		require.NotNil(t, strategy.ctx)
+		assert.Equal(t, "request", strategy.ctx.Value(ctxKey{}))
+	})
+}
+
+type contextRecordingJWTStrategy struct {
+	jwt.JWTStrategy
+	ctx context.Context
+}
+
+func (s *contextRecordingJWTStrategy) Generate(ctx context.Context, claims jwt.MapClaims, header jwt.Mapper) (string, string, error) {
+	s.ctx = ctx
+	return s.JWTStrategy.Generate(ctx, claims, header)
+}
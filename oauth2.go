@@ -162,6 +162,23 @@ type OAuth2Provider interface {
 	// WriteIntrospectionResponse responds with token metadata discovered by token introspection as defined in
 	// https://tools.ietf.org/search/rfc7662#section-2.2
 	WriteIntrospectionResponse(rw http.ResponseWriter, r IntrospectionResponder)
+
+	// NewRegistrationRequest handles client registration and client configuration requests. POST requests register a
+	// client, GET, PUT and DELETE requests read, update and delete the client identified by the last segment of the
+	// request path.
+	//
+	// The following specs must be considered in any implementation of this method:
+	// * https://tools.ietf.org/html/rfc7591#section-3 (everything)
+	// * https://tools.ietf.org/html/rfc7592#section-2 (everything)
+	NewRegistrationRequest(ctx context.Context, r *http.Request) (*RegistrationResponse, error)
+
+	// WriteRegistrationError responds with an error if client registration or management failed as defined in
+	// https://tools.ietf.org/html/rfc7591#section-3.2.2
+	WriteRegistrationError(rw http.ResponseWriter, err error)
+
+	// WriteRegistrationResponse writes the client information response as defined in
+	// https://tools.ietf.org/html/rfc7591#section-3.2.1 and https://tools.ietf.org/html/rfc7592#section-2
+	WriteRegistrationResponse(rw http.ResponseWriter, response *RegistrationResponse)
 }
 
 // IntrospectionResponder is the response object that will be returned when token introspection was successful,
@@ -183,6 +200,22 @@ type IntrospectionResponder interface {
 	GetAccessTokenType() string
 }
 
+// JWTIntrospectionResponder is an IntrospectionResponder which knows whether the caller asked for a JWT-secured
+// introspection response as defined in RFC 9701.
+type JWTIntrospectionResponder interface {
+	IntrospectionResponder
+
+	// IsJWTResponseRequested returns true if the caller accepts "application/token-introspection+jwt" responses.
+	IsJWTResponseRequested() bool
+
+	// GetCaller returns the authenticated client which performed the introspection request, or nil if the caller
+	// could not be authenticated.
+	GetCaller() Client
+
+	// GetContext returns the context of the introspection request.
+	GetContext() context.Context
+}
+
 // Requester is an abstract interface for handling requests in Fosite.
 type Requester interface {
 	// SetID sets the unique identifier.
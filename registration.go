@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ClientRegistrationStorage stores dynamically registered clients together with their registration access tokens.
+type ClientRegistrationStorage interface {
+	ClientWriter
+
+	// SetRegistrationAccessTokenSignature stores the signature of the registration access token of the client,
+	// replacing the previous one. An empty signature removes the stored signature.
+	SetRegistrationAccessTokenSignature(ctx context.Context, clientID string, signature string) error
+
+	// GetRegistrationAccessTokenSignature returns the signature of the registration access token of the client or
+	// ErrNotFound if there is none.
+	GetRegistrationAccessTokenSignature(ctx context.Context, clientID string) (string, error)
+}
+
+// InitialAccessTokenStrategy validates the initial access token authorizing a client registration request, see
+// https://tools.ietf.org/html/rfc7591#section-3. It returns an error if the token is invalid.
+type InitialAccessTokenStrategy func(ctx context.Context, r *http.Request, token string) error
+
+// ClientMetadata is the client metadata of client registration requests and responses as defined in
+// https://tools.ietf.org/html/rfc7591#section-2. Metadata which is not supported is ignored.
+type ClientMetadata struct {
+	RedirectURIs                      []string            `json:"redirect_uris,omitempty"`
+	TokenEndpointAuthMethod           string              `json:"token_endpoint_auth_method,omitempty"`
+	TokenEndpointAuthSigningAlgorithm string              `json:"token_endpoint_auth_signing_alg,omitempty"`
+	GrantTypes                        []string            `json:"grant_types,omitempty"`
+	ResponseTypes                     []string            `json:"response_types,omitempty"`
+	Scope                             string              `json:"scope,omitempty"`
+	Audience                          []string            `json:"audience,omitempty"`
+	JSONWebKeysURI                    string              `json:"jwks_uri,omitempty"`
+	JSONWebKeys                       *jose.JSONWebKeySet `json:"jwks,omitempty"`
+	RequestURIs                       []string            `json:"request_uris,omitempty"`
+	RequestObjectSigningAlgorithm     string              `json:"request_object_signing_alg,omitempty"`
+}
+
+// RegistrationRequest is the body of client registration and update requests.
+type RegistrationRequest struct {
+	ClientMetadata
+
+	// ClientID and ClientSecret are sent when updating a client, see https://tools.ietf.org/html/rfc7592#section-2.2.
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+
+	SoftwareStatement string `json:"software_statement,omitempty"`
+}
+
+// RegistrationResponse is the response to client registration and management requests as defined in
+// https://tools.ietf.org/html/rfc7591#section-3.2.1 and https://tools.ietf.org/html/rfc7592#section-3.
+type RegistrationResponse struct {
+	ClientMetadata
+
+	ClientID string `json:"client_id,omitempty"`
+
+	// ClientSecret is only returned when the secret is issued, because only its hash is stored.
+	ClientSecret     string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt int64  `json:"client_id_issued_at,omitempty"`
+	// ClientSecretExpiresAt is the time the secret expires at in seconds since the epoch, or 0 if it does not expire.
+	ClientSecretExpiresAt int64 `json:"client_secret_expires_at"`
+
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri,omitempty"`
+
+	// StatusCode is the HTTP status code of the response. Responses with status code 204 have no body.
+	StatusCode int `json:"-"`
+}
+
+// newClientFromMetadata returns the client described by metadata. Clients using token endpoint authentication
+// method "none" are public.
+func newClientFromMetadata(id string, metadata *ClientMetadata) *DefaultOpenIDConnectClient {
+	client := &DefaultOpenIDConnectClient{
+		DefaultClient: &DefaultClient{
+			ID:            id,
+			RedirectURIs:  metadata.RedirectURIs,
+			GrantTypes:    metadata.GrantTypes,
+			ResponseTypes: metadata.ResponseTypes,
+			Scopes:        strings.Fields(metadata.Scope),
+			Audience:      metadata.Audience,
+		},
+		JSONWebKeysURI:                    metadata.JSONWebKeysURI,
+		JSONWebKeys:                       metadata.JSONWebKeys,
+		TokenEndpointAuthMethod:           metadata.TokenEndpointAuthMethod,
+		RequestURIs:                       metadata.RequestURIs,
+		RequestObjectSigningAlgorithm:     metadata.RequestObjectSigningAlgorithm,
+		TokenEndpointAuthSigningAlgorithm: metadata.TokenEndpointAuthSigningAlgorithm,
+	}
+
+	// Defaults as defined in https://tools.ietf.org/html/rfc7591#section-2.
+	if client.TokenEndpointAuthMethod == "" {
+		client.TokenEndpointAuthMethod = "client_secret_basic"
+	}
+	if len(client.GrantTypes) == 0 {
+		client.GrantTypes = []string{"authorization_code"}
+	}
+	if len(client.ResponseTypes) == 0 {
+		client.ResponseTypes = []string{"code"}
+	}
+	client.Public = client.TokenEndpointAuthMethod == "none"
+	return client
+}
+
+// clientMetadataFromClient returns the metadata of the client.
+func clientMetadataFromClient(client Client) ClientMetadata {
+	metadata := ClientMetadata{
+		RedirectURIs:  client.GetRedirectURIs(),
+		GrantTypes:    client.GetGrantTypes(),
+		ResponseTypes: client.GetResponseTypes(),
+		Scope:         strings.Join(client.GetScopes(), " "),
+		Audience:      client.GetAudience(),
+	}
+	if oidcClient, ok := client.(OpenIDConnectClient); ok {
+		metadata.TokenEndpointAuthMethod = oidcClient.GetTokenEndpointAuthMethod()
+		metadata.TokenEndpointAuthSigningAlgorithm = oidcClient.GetTokenEndpointAuthSigningAlgorithm()
+		metadata.JSONWebKeysURI = oidcClient.GetJSONWebKeysURI()
+		metadata.JSONWebKeys = oidcClient.GetJSONWebKeys()
+		metadata.RequestURIs = oidcClient.GetRequestURIs()
+		metadata.RequestObjectSigningAlgorithm = oidcClient.GetRequestObjectSigningAlgorithm()
+	}
+	return metadata
+}
@@ -0,0 +1,359 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite/token/jwt"
+)
+
+// NewRegistrationRequest implements dynamic client registration (RFC 7591) and client management (RFC 7592).
+//
+// Registered clients are DefaultOpenIDConnectClient and receive a random ID, a random secret unless they are public or
+// authenticate with private_key_jwt, and a registration access token. The registration access token authorizes the
+// client configuration requests at the registration client URI, which is ClientRegistrationURL followed by "/" and
+// the client ID. Deleting a client revokes all tokens issued to it, see RevokeByClient.
+//
+// Registration requests must carry an initial access token as bearer token, which is validated by
+// ClientRegistrationInitialAccessTokenStrategy, unless ClientRegistrationOpen is true. The scopes, grant types and
+// audiences of registered clients are restricted by ClientRegistrationAllowedScopes,
+// ClientRegistrationAllowedGrantTypes and ClientRegistrationAllowedAudiences. Software statements are verified against ClientRegistrationSoftwareStatementKeys and their claims
+// take precedence over the metadata of the request.
+func (f *Fosite) NewRegistrationRequest(ctx context.Context, r *http.Request) (*RegistrationResponse, error) {
+	ctx = context.WithValue(ctx, RequestContextKey, r)
+
+	store, ok := f.Store.(ClientRegistrationStorage)
+	if !ok || f.ClientRegistrationURL == "" {
+		return nil, errorsx.WithStack(ErrRegistrationNotSupported.WithHint("Dynamic client registration is not enabled."))
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		return f.registerClient(ctx, r, store)
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHintf("HTTP method is '%s' but expected one of 'POST', 'GET', 'PUT' or 'DELETE'.", r.Method))
+	}
+
+	client, err := f.authenticateRegistrationAccessToken(ctx, r, store)
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		return f.newRegistrationResponse(client, http.StatusOK), nil
+	case http.MethodPut:
+		return f.updateRegisteredClient(ctx, r, store, client)
+	}
+
+	// Tokens issued to the client must not outlive it.
+	if err := f.RevokeByClient(ctx, client.GetID()); err != nil {
+		return nil, err
+	} else if err := store.DeleteClient(ctx, client.GetID()); err != nil {
+		return nil, err
+	} else if err := store.SetRegistrationAccessTokenSignature(ctx, client.GetID(), ""); err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return &RegistrationResponse{StatusCode: http.StatusNoContent}, nil
+}
+
+func (f *Fosite) registerClient(ctx context.Context, r *http.Request, store ClientRegistrationStorage) (*RegistrationResponse, error) {
+	if token := bearerTokenFromHeader(r); token == "" {
+		if !f.ClientRegistrationOpen {
+			return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("An initial access token is required to register clients."))
+		}
+	} else if f.ClientRegistrationInitialAccessTokenStrategy == nil {
+		if !f.ClientRegistrationOpen {
+			return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("The initial access token is invalid.").WithDebug("No initial access token strategy is configured."))
+		}
+	} else if err := f.ClientRegistrationInitialAccessTokenStrategy(ctx, r, token); err != nil {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("The initial access token is invalid.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	request, err := f.decodeRegistrationRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	client := newClientFromMetadata(uuid.New(), &request.ClientMetadata)
+	if err := f.checkRegistrationPolicy(client); err != nil {
+		return nil, err
+	}
+
+	secret, err := issueClientSecret(client)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomRegistrationString()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.CreateClient(ctx, client); err != nil {
+		return nil, err
+	}
+	if err := store.SetRegistrationAccessTokenSignature(ctx, client.GetID(), registrationAccessTokenSignature(token)); err != nil {
+		// Without a registration access token the client could not be managed.
+		_ = store.DeleteClient(ctx, client.GetID())
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+
+	response := f.newRegistrationResponse(client, http.StatusCreated)
+	response.ClientSecret = secret
+	response.ClientIDIssuedAt = f.GetClock().Now().Unix()
+	response.RegistrationAccessToken = token
+	return response, nil
+}
+
+// updateRegisteredClient replaces the metadata of the client as defined in https://tools.ietf.org/html/rfc7592#section-2.2.
+// Omitted metadata is reset to its default value.
+func (f *Fosite) updateRegisteredClient(ctx context.Context, r *http.Request, store ClientRegistrationStorage, client Client) (*RegistrationResponse, error) {
+	request, err := f.decodeRegistrationRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.ClientID != client.GetID() {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The client_id in the request body must match the ID of the client being updated."))
+	} else if request.ClientSecret != "" {
+		if err := f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(request.ClientSecret)); err != nil {
+			return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("The client_secret in the request body does not match the secret of the client being updated.").WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+
+	updated := newClientFromMetadata(client.GetID(), &request.ClientMetadata)
+	if err := f.checkRegistrationPolicy(updated); err != nil {
+		return nil, err
+	}
+
+	// The stored secret is kept together with its expiry and the rotated secrets unless the client had none, for
+	// example because it was public before.
+	var secret string
+	if len(client.GetHashedSecret()) == 0 {
+		if secret, err = issueClientSecret(updated); err != nil {
+			return nil, err
+		}
+	} else if !updated.Public {
+		if c, ok := client.(ClientWithSecretRotation); ok {
+			updated.RotatedSecrets = c.GetRotatedHashes()
+		}
+		if c, ok := client.(ClientWithSecretExpiry); ok {
+			updated.SecretExpiresAt, updated.RotatedSecretsExpiresAt = c.GetSecretExpiresAt(), c.GetRotatedSecretsExpiresAt()
+		}
+	}
+
+	if err := store.UpdateClient(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	response := f.newRegistrationResponse(updated, http.StatusOK)
+	response.ClientSecret = secret
+	return response, nil
+}
+
+// checkRegistrationPolicy rejects clients with scopes, grant types or audiences which may not be registered.
+func (f *Fosite) checkRegistrationPolicy(client *DefaultOpenIDConnectClient) error {
+	if f.ClientRegistrationAllowedScopes != nil {
+		scopeStrategy := f.ScopeStrategy
+		if scopeStrategy == nil {
+			scopeStrategy = WildcardScopeStrategy
+		}
+		for _, scope := range client.Scopes {
+			if !scopeStrategy(f.ClientRegistrationAllowedScopes, scope) {
+				return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Clients may not be registered with scope '%s'.", scope))
+			}
+		}
+	}
+
+	if f.ClientRegistrationAllowedGrantTypes != nil {
+		for _, grantType := range client.GrantTypes {
+			if !StringInSlice(grantType, f.ClientRegistrationAllowedGrantTypes) {
+				return errorsx.WithStack(ErrInvalidClientMetadata.WithHintf("Clients may not be registered with grant type '%s'.", grantType))
+			}
+		}
+	}
+
+	if f.ClientRegistrationAllowedAudiences != nil {
+		audienceStrategy := f.AudienceMatchingStrategy
+		if audienceStrategy == nil {
+			audienceStrategy = DefaultAudienceMatchingStrategy
+		}
+		if err := audienceStrategy(f.ClientRegistrationAllowedAudiences, client.Audience); err != nil {
+			return errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Clients may not be registered with the requested audience.").WithWrap(err).WithDebug(err.Error()))
+		}
+	}
+	return nil
+}
+
+// authenticateRegistrationAccessToken returns the client identified by the last segment of the request path if the
+// request carries its registration access token. Unknown clients are reported like invalid tokens, see
+// https://tools.ietf.org/html/rfc7592#section-2.1.
+func (f *Fosite) authenticateRegistrationAccessToken(ctx context.Context, r *http.Request, store ClientRegistrationStorage) (Client, error) {
+	token := bearerTokenFromHeader(r)
+	if token == "" {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("A registration access token is required to manage clients."))
+	}
+
+	id, err := url.PathUnescape(path.Base(r.URL.EscapedPath()))
+	if err != nil {
+		return nil, errorsx.WithStack(ErrInvalidRequest.WithHint("Unable to decode the client ID in the request path.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	signature, err := store.GetRegistrationAccessTokenSignature(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("The registration access token is invalid."))
+	} else if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	} else if subtle.ConstantTimeCompare([]byte(signature), []byte(registrationAccessTokenSignature(token))) != 1 {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("The registration access token is invalid."))
+	}
+
+	client, err := f.Store.GetClient(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, errorsx.WithStack(ErrRequestUnauthorized.WithHint("The registration access token is invalid."))
+	} else if err != nil {
+		return nil, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return client, nil
+}
+
+func (f *Fosite) decodeRegistrationRequest(r *http.Request) (*RegistrationRequest, error) {
+	var request RegistrationRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&request); err != nil {
+		return nil, errorsx.WithStack(ErrInvalidClientMetadata.WithHint("Unable to decode the client metadata, make sure to send a JSON object in the request body.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	if request.SoftwareStatement == "" {
+		if f.ClientRegistrationSoftwareStatementRequired {
+			return nil, errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("A software statement is required to register clients."))
+		}
+		return &request, nil
+	}
+
+	if err := f.applySoftwareStatement(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// applySoftwareStatement verifies the software statement of the request and replaces the metadata of the request with
+// the metadata asserted by the software statement, see https://tools.ietf.org/html/rfc7591#section-2.3.
+func (f *Fosite) applySoftwareStatement(request *RegistrationRequest) error {
+	keys := f.ClientRegistrationSoftwareStatementKeys
+	if keys == nil {
+		return errorsx.WithStack(ErrUnapprovedSoftwareStatement.WithHint("This authorization server does not accept software statements."))
+	}
+
+	token, err := jwt.Parse(request.SoftwareStatement, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method {
+		case jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512:
+			return findPublicKey(t, keys, true)
+		case jose.ES256, jose.ES384, jose.ES512:
+			return findPublicKey(t, keys, false)
+		default:
+			return nil, errorsx.WithStack(ErrInvalidSoftwareStatement.WithHintf("The software statement uses unsupported signing algorithm '%s'.", t.Header["alg"]))
+		}
+	}, jwt.WithClock(f.GetClock()), jwt.WithLeeway(f.ClockSkewLeeway))
+	if err != nil {
+		var ve *jwt.ValidationError
+		if errors.As(err, &ve) && (ve.Has(jwt.ValidationErrorUnverifiable) || ve.Has(jwt.ValidationErrorSignatureInvalid)) {
+			return errorsx.WithStack(ErrUnapprovedSoftwareStatement.WithHint("The software statement is not signed by a trusted key.").WithWrap(err).WithDebug(err.Error()))
+		}
+		return errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("Unable to parse or validate the software statement.").WithWrap(err).WithDebug(err.Error()))
+	}
+
+	claims, err := json.Marshal(token.Claims)
+	if err != nil {
+		return errorsx.WithStack(ErrInvalidSoftwareStatement.WithWrap(err).WithDebug(err.Error()))
+	} else if err := json.Unmarshal(claims, &request.ClientMetadata); err != nil {
+		return errorsx.WithStack(ErrInvalidSoftwareStatement.WithHint("The software statement contains invalid client metadata.").WithWrap(err).WithDebug(err.Error()))
+	}
+	return nil
+}
+
+func (f *Fosite) newRegistrationResponse(client Client, statusCode int) *RegistrationResponse {
+	response := &RegistrationResponse{
+		ClientMetadata:        clientMetadataFromClient(client),
+		ClientID:              client.GetID(),
+		RegistrationClientURI: strings.TrimSuffix(f.ClientRegistrationURL, "/") + "/" + url.PathEscape(client.GetID()),
+		StatusCode:            statusCode,
+	}
+	if c, ok := client.(ClientWithSecretExpiry); ok && !c.GetSecretExpiresAt().IsZero() {
+		response.ClientSecretExpiresAt = c.GetSecretExpiresAt().Unix()
+	}
+	return response
+}
+
+// issueClientSecret sets a random secret on confidential clients authenticating with a client secret and returns it.
+func issueClientSecret(client *DefaultOpenIDConnectClient) (string, error) {
+	if client.Public || client.TokenEndpointAuthMethod == "private_key_jwt" {
+		return "", nil
+	}
+
+	secret, err := randomRegistrationString()
+	if err != nil {
+		return "", err
+	}
+	client.Secret = []byte(secret)
+	return secret, nil
+}
+
+func randomRegistrationString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error()))
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// registrationAccessTokenSignature returns the value stored instead of the registration access token.
+func registrationAccessTokenSignature(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func bearerTokenFromHeader(r *http.Request) string {
+	split := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(split) != 2 || !strings.EqualFold(split[0], "bearer") {
+		return ""
+	}
+	return split[1]
+}
@@ -0,0 +1,293 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	. "github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+func TestNewRegistrationRequest(t *testing.T) {
+	ctx := context.Background()
+	key := internal.MustRSAKey()
+	untrustedKey := internal.MustRSAKey()
+
+	newProvider := func() *Fosite {
+		store := storage.NewMemoryStore()
+		store.Hasher = &BCrypt{WorkFactor: 4}
+		return &Fosite{
+			Store:                  store,
+			RevocationHandlers:     RevocationHandlers{&oauth2.TokenRevocationHandler{TokenRevocationStorage: store}},
+			Hasher:                 store.Hasher,
+			ClientRegistrationURL:  "https://auth.example.com/clients",
+			ClientRegistrationOpen: true,
+			ClientRegistrationSoftwareStatementKeys: &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+				{KeyID: "software", Use: "sig", Key: &key.PublicKey},
+			}},
+		}
+	}
+	newRequest := func(method, path, token string, body interface{}) *http.Request {
+		var js []byte
+		if body != nil {
+			var err error
+			js, err = json.Marshal(body)
+			require.NoError(t, err)
+		}
+		r := httptest.NewRequest(method, path, bytes.NewReader(js))
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+	metadata := map[string]interface{}{"redirect_uris": []string{"https://client.example.com/cb"}, "scope": "openid offline"}
+
+	t.Run("case=client lifecycle", func(t *testing.T) {
+		f := newProvider()
+
+		registered, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", metadata))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, registered.StatusCode)
+		assert.NotEmpty(t, registered.ClientID)
+		assert.NotEmpty(t, registered.ClientSecret)
+		assert.NotEmpty(t, registered.RegistrationAccessToken)
+		assert.Equal(t, "https://auth.example.com/clients/"+registered.ClientID, registered.RegistrationClientURI)
+		assert.Equal(t, "client_secret_basic", registered.TokenEndpointAuthMethod)
+		assert.Equal(t, []string{"authorization_code"}, registered.GrantTypes)
+		assert.Equal(t, "openid offline", registered.Scope)
+
+		client, err := f.Store.GetClient(ctx, registered.ClientID)
+		require.NoError(t, err)
+		require.IsType(t, &DefaultOpenIDConnectClient{}, client)
+		require.NoError(t, f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(registered.ClientSecret)))
+
+		path := "/clients/" + registered.ClientID
+		read, err := f.NewRegistrationRequest(ctx, newRequest("GET", path, registered.RegistrationAccessToken, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, read.StatusCode)
+		assert.Equal(t, registered.RedirectURIs, read.RedirectURIs)
+		assert.Empty(t, read.ClientSecret)
+		assert.Zero(t, read.ClientSecretExpiresAt)
+
+		secretExpiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+		client.(*DefaultOpenIDConnectClient).SecretExpiresAt = secretExpiresAt
+		require.NoError(t, f.Store.(ClientWriter).UpdateClient(ctx, client))
+		read, err = f.NewRegistrationRequest(ctx, newRequest("GET", path, registered.RegistrationAccessToken, nil))
+		require.NoError(t, err)
+		assert.Equal(t, secretExpiresAt.Unix(), read.ClientSecretExpiresAt)
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("GET", path, "invalid", nil))
+		assert.True(t, errors.Is(err, ErrRequestUnauthorized), "%+v", err)
+		_, err = f.NewRegistrationRequest(ctx, newRequest("GET", "/clients/unknown", registered.RegistrationAccessToken, nil))
+		assert.True(t, errors.Is(err, ErrRequestUnauthorized), "%+v", err)
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("PUT", path, registered.RegistrationAccessToken, map[string]interface{}{
+			"client_id":     "other",
+			"redirect_uris": []string{"https://client.example.com/cb"},
+		}))
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("PUT", path, registered.RegistrationAccessToken, map[string]interface{}{
+			"client_id":     registered.ClientID,
+			"client_secret": "wrong",
+			"redirect_uris": []string{"https://client.example.com/cb"},
+		}))
+		assert.True(t, errors.Is(err, ErrInvalidRequest), "%+v", err)
+
+		updated, err := f.NewRegistrationRequest(ctx, newRequest("PUT", path, registered.RegistrationAccessToken, map[string]interface{}{
+			"client_id":     registered.ClientID,
+			"client_secret": registered.ClientSecret,
+			"redirect_uris": []string{"https://client.example.com/new-cb"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://client.example.com/new-cb"}, updated.RedirectURIs)
+		assert.Empty(t, updated.Scope, "omitted metadata is reset")
+		assert.Empty(t, updated.ClientSecret, "the secret is kept")
+		assert.Equal(t, secretExpiresAt.Unix(), updated.ClientSecretExpiresAt, "the secret expiry is kept")
+		client, err = f.Store.GetClient(ctx, registered.ClientID)
+		require.NoError(t, err)
+		require.NoError(t, f.Hasher.Compare(ctx, client.GetHashedSecret(), []byte(registered.ClientSecret)))
+
+		store := f.Store.(*storage.MemoryStore)
+		grant := &Request{ID: "grant", Client: client, RequestedAt: time.Now(), Session: new(DefaultSession)}
+		require.NoError(t, store.CreateAccessTokenSession(ctx, "access-token", grant))
+		require.NoError(t, store.CreateRefreshTokenSession(ctx, "refresh-token", grant))
+
+		deleted, err := f.NewRegistrationRequest(ctx, newRequest("DELETE", path, registered.RegistrationAccessToken, nil))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, deleted.StatusCode)
+		_, err = f.Store.GetClient(ctx, registered.ClientID)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		_, err = store.GetAccessTokenSession(ctx, "access-token", new(DefaultSession))
+		assert.Error(t, err, "the access tokens of the client are revoked")
+		_, err = store.GetRefreshTokenSession(ctx, "refresh-token", new(DefaultSession))
+		assert.Error(t, err, "the refresh tokens of the client are revoked")
+		_, err = f.NewRegistrationRequest(ctx, newRequest("GET", path, registered.RegistrationAccessToken, nil))
+		assert.True(t, errors.Is(err, ErrRequestUnauthorized), "%+v", err)
+	})
+
+	t.Run("case=public client", func(t *testing.T) {
+		f := newProvider()
+		registered, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", map[string]interface{}{
+			"redirect_uris":              []string{"com.example.app:/cb"},
+			"token_endpoint_auth_method": "none",
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, registered.ClientSecret)
+
+		client, err := f.Store.GetClient(ctx, registered.ClientID)
+		require.NoError(t, err)
+		assert.True(t, client.IsPublic())
+	})
+
+	t.Run("case=invalid metadata", func(t *testing.T) {
+		f := newProvider()
+		_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", map[string]interface{}{
+			"redirect_uris": []string{"http://client.example.com/cb"},
+		}))
+		assert.True(t, errors.Is(err, ErrInvalidRedirectURI), "%+v", err)
+
+		_, err = f.NewRegistrationRequest(ctx, httptest.NewRequest("POST", "/clients", bytes.NewBufferString("not json")))
+		assert.True(t, errors.Is(err, ErrInvalidClientMetadata), "%+v", err)
+	})
+
+	t.Run("case=registration disabled", func(t *testing.T) {
+		f := newProvider()
+		f.ClientRegistrationURL = ""
+		_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", metadata))
+		assert.True(t, errors.Is(err, ErrRegistrationNotSupported), "%+v", err)
+	})
+
+	t.Run("case=initial access token", func(t *testing.T) {
+		f := newProvider()
+		f.ClientRegistrationOpen = false
+		for _, token := range []string{"", "initial"} {
+			_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", token, metadata))
+			assert.True(t, errors.Is(err, ErrRequestUnauthorized), "%+v", err)
+		}
+
+		f.ClientRegistrationInitialAccessTokenStrategy = func(_ context.Context, _ *http.Request, token string) error {
+			if token != "initial" {
+				return errors.New("unknown initial access token")
+			}
+			return nil
+		}
+
+		for _, token := range []string{"", "invalid"} {
+			_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", token, metadata))
+			assert.True(t, errors.Is(err, ErrRequestUnauthorized), "%+v", err)
+		}
+		_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "initial", metadata))
+		require.NoError(t, err)
+	})
+
+	t.Run("case=registration policy", func(t *testing.T) {
+		f := newProvider()
+		f.ClientRegistrationAllowedScopes = []string{"openid", "offline", "photos.*"}
+		f.ClientRegistrationAllowedGrantTypes = []string{"authorization_code", "refresh_token"}
+		f.ClientRegistrationAllowedAudiences = []string{"https://api.example.com"}
+
+		registered, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", map[string]interface{}{
+			"redirect_uris": []string{"https://client.example.com/cb"},
+			"scope":         "openid photos.read",
+			"grant_types":   []string{"authorization_code", "refresh_token"},
+			"audience":      []string{"https://api.example.com/photos"},
+		}))
+		require.NoError(t, err)
+
+		for k, body := range []map[string]interface{}{
+			{"redirect_uris": []string{"https://client.example.com/cb"}, "scope": "openid admin"},
+			{"redirect_uris": []string{"https://client.example.com/cb"}, "grant_types": []string{"authorization_code", "client_credentials"}},
+			{"redirect_uris": []string{"https://client.example.com/cb"}, "audience": []string{"https://admin.example.com"}},
+		} {
+			_, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", body))
+			assert.True(t, errors.Is(err, ErrInvalidClientMetadata), "%d: %+v", k, err)
+
+			body["client_id"] = registered.ClientID
+			_, err = f.NewRegistrationRequest(ctx, newRequest("PUT", "/clients/"+registered.ClientID, registered.RegistrationAccessToken, body))
+			assert.True(t, errors.Is(err, ErrInvalidClientMetadata), "%d: %+v", k, err)
+		}
+	})
+
+	t.Run("case=software statement", func(t *testing.T) {
+		f := newProvider()
+		f.ClientRegistrationSoftwareStatementRequired = true
+		statement := func(key interface{}) map[string]interface{} {
+			token := jwt.NewWithClaims(jose.RS256, jwt.MapClaims{
+				"iss":           "https://software.example.com",
+				"redirect_uris": []string{"https://software.example.com/cb"},
+			})
+			token.Header["kid"] = "software"
+			raw, err := token.SignedString(key)
+			require.NoError(t, err)
+			return map[string]interface{}{"redirect_uris": []string{"https://client.example.com/cb"}, "software_statement": raw}
+		}
+
+		registered, err := f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", statement(key)))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://software.example.com/cb"}, registered.RedirectURIs, "the software statement takes precedence")
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", statement(untrustedKey)))
+		assert.True(t, errors.Is(err, ErrUnapprovedSoftwareStatement), "%+v", err)
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", map[string]interface{}{"software_statement": "not a jwt"}))
+		assert.True(t, errors.Is(err, ErrInvalidSoftwareStatement), "%+v", err)
+
+		_, err = f.NewRegistrationRequest(ctx, newRequest("POST", "/clients", "", metadata))
+		assert.True(t, errors.Is(err, ErrInvalidSoftwareStatement), "%+v", err)
+	})
+}
+
+func TestWriteRegistrationResponse(t *testing.T) {
+	f := new(Fosite)
+
+	rw := httptest.NewRecorder()
+	f.WriteRegistrationResponse(rw, &RegistrationResponse{ClientID: "foo", StatusCode: http.StatusCreated})
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Equal(t, "no-store", rw.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"client_id":"foo","client_secret_expires_at":0}`, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	f.WriteRegistrationResponse(rw, &RegistrationResponse{StatusCode: http.StatusNoContent})
+	assert.Equal(t, http.StatusNoContent, rw.Code)
+	assert.Empty(t, rw.Body.String())
+
+	rw = httptest.NewRecorder()
+	f.WriteRegistrationError(rw, ErrInvalidClientMetadata)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "invalid_client_metadata")
+}
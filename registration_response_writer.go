@@ -0,0 +1,60 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package fosite
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/ory/x/errorsx"
+)
+
+// WriteRegistrationError writes the error as JSON object, see https://tools.ietf.org/html/rfc7591#section-3.2.2.
+func (f *Fosite) WriteRegistrationError(rw http.ResponseWriter, err error) {
+	f.writeJsonError(rw, nil, err)
+}
+
+// WriteRegistrationResponse writes the client information response. Responses to delete requests have no body.
+func (f *Fosite) WriteRegistrationResponse(rw http.ResponseWriter, response *RegistrationResponse) {
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.Header().Set("Pragma", "no-cache")
+
+	if response.StatusCode == http.StatusNoContent {
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	js, err := json.Marshal(response)
+	if err != nil {
+		f.WriteRegistrationError(rw, errorsx.WithStack(ErrServerError.WithWrap(err).WithDebug(err.Error())))
+		return
+	}
+
+	statusCode := response.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+
+	rw.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	rw.WriteHeader(statusCode)
+	_, _ = rw.Write(js)
+}
@@ -86,6 +86,56 @@ func (f *Fosite) NewRevocationRequest(ctx context.Context, r *http.Request) erro
 	return nil
 }
 
+// RevokeBySubject revokes all access and refresh tokens issued for subject using every RevocationHandler which
+// implements BulkRevocationHandler. This is not an OAuth 2.0 endpoint and must only be exposed to administrators.
+func (f *Fosite) RevokeBySubject(ctx context.Context, subject string) error {
+	return f.revokeBulk(func(h BulkRevocationHandler) error {
+		return h.RevokeBySubject(ctx, subject)
+	})
+}
+
+// RevokeByClient revokes all access and refresh tokens issued to the client with the given ID using every
+// RevocationHandler which implements BulkRevocationHandler. This is not an OAuth 2.0 endpoint and must only be exposed
+// to administrators.
+func (f *Fosite) RevokeByClient(ctx context.Context, clientID string) error {
+	return f.revokeBulk(func(h BulkRevocationHandler) error {
+		return h.RevokeByClient(ctx, clientID)
+	})
+}
+
+// RevokeByGrant revokes all access and refresh tokens issued for the request with the given ID using every
+// RevocationHandler which implements BulkRevocationHandler. This is not an OAuth 2.0 endpoint and must only be exposed
+// to administrators.
+func (f *Fosite) RevokeByGrant(ctx context.Context, requestID string) error {
+	return f.revokeBulk(func(h BulkRevocationHandler) error {
+		return h.RevokeByGrant(ctx, requestID)
+	})
+}
+
+func (f *Fosite) revokeBulk(revoke func(h BulkRevocationHandler) error) error {
+	var found = false
+	for _, handler := range f.RevocationHandlers {
+		bulk, ok := handler.(BulkRevocationHandler)
+		if !ok {
+			continue
+		}
+
+		if err := revoke(bulk); err == nil {
+			found = true
+		} else if errors.Is(err, ErrUnknownRequest) {
+			// do nothing
+		} else {
+			return err
+		}
+	}
+
+	if !found {
+		return errorsx.WithStack(ErrServerError.WithDebug("None of the revocation handlers supports bulk revocation."))
+	}
+
+	return nil
+}
+
 // WriteRevocationResponse writes a token revocation response as specified in:
 // https://tools.ietf.org/html/rfc7009#section-2.2
 //
@@ -53,6 +53,7 @@ type Session interface {
 // DefaultSession is a default implementation of the session interface.
 type DefaultSession struct {
 	ExpiresAt map[TokenType]time.Time
+	IssuedAt  map[TokenType]time.Time
 	Username  string
 	Subject   string
 	Extra     map[string]interface{}
@@ -123,3 +124,43 @@ func (s *DefaultSession) GetExtraClaims() map[string]interface{} {
 
 	return s.Extra
 }
+
+// IssuedAtSession is a session which keeps track of when tokens were issued. The time is reported as "iat" during
+// token introspection.
+type IssuedAtSession interface {
+	// SetIssuedAt sets the issuance time of a token.
+	SetIssuedAt(key TokenType, iat time.Time)
+
+	// GetIssuedAt returns the issuance time of a token if set, or time.IsZero() if not.
+	GetIssuedAt(key TokenType) time.Time
+}
+
+// ConfirmationSession is a session whose tokens are bound to a key, for example using mutual TLS or DPoP. The
+// confirmation is reported as "cnf" during token introspection as defined in RFC 7800.
+type ConfirmationSession interface {
+	// GetConfirmation returns the "cnf" claim, or nil if tokens are not bound to a key.
+	GetConfirmation() map[string]interface{}
+}
+
+// SetIssuedAt records the issuance time of a token if session implements IssuedAtSession.
+func SetIssuedAt(session Session, key TokenType, iat time.Time) {
+	if s, ok := session.(IssuedAtSession); ok {
+		s.SetIssuedAt(key, iat)
+	}
+}
+
+// SetIssuedAt implements IssuedAtSession for DefaultSession.
+func (s *DefaultSession) SetIssuedAt(key TokenType, iat time.Time) {
+	if s.IssuedAt == nil {
+		s.IssuedAt = make(map[TokenType]time.Time)
+	}
+	s.IssuedAt[key] = iat
+}
+
+// GetIssuedAt implements IssuedAtSession for DefaultSession.
+func (s *DefaultSession) GetIssuedAt(key TokenType) time.Time {
+	if s == nil {
+		return time.Time{}
+	}
+	return s.IssuedAt[key]
+}
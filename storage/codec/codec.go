@@ -0,0 +1,225 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package codec encodes fosite.Requester values, for example to persist them in a storage backend.
+//
+// Sessions are encoded as JSON together with the name of their type, which is looked up in a Registry. Clients are
+// encoded by ID and resolved again when decoding:
+//
+//	c := codec.New(codec.DefaultRegistry())
+//	data, err := c.Marshal(requester)
+//	// ...
+//	requester, err := c.Unmarshal(ctx, data, clientManager)
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+
+	"github.com/ory/fosite"
+)
+
+// Version is the version of the encoding written by Codec.
+const Version = 1
+
+// ErrUnsupportedVersion is returned when decoding a request encoded with an unknown version.
+var ErrUnsupportedVersion = errors.New("the version of the encoded request is not supported")
+
+// migrations upgrade requests encoded with an older version. The migration at index i upgrades a request from
+// version i+1 to version i+2, so there is one migration less than there are versions.
+var migrations []func(encoded *Request) error
+
+// Request is the encoded form of a fosite.Requester.
+type Request struct {
+	Version              int              `json:"version"`
+	ID                   string           `json:"id"`
+	RequestedAt          time.Time        `json:"requested_at"`
+	ClientID             string           `json:"client_id,omitempty"`
+	RequestedScope       fosite.Arguments `json:"requested_scope,omitempty"`
+	GrantedScope         fosite.Arguments `json:"granted_scope,omitempty"`
+	RequestedAudience    fosite.Arguments `json:"requested_audience,omitempty"`
+	GrantedAudience      fosite.Arguments `json:"granted_audience,omitempty"`
+	Form                 url.Values       `json:"form,omitempty"`
+	Lang                 string           `json:"lang,omitempty"`
+	SubjectTokenClientID string           `json:"subject_token_client_id,omitempty"`
+	SessionType          string           `json:"session_type,omitempty"`
+	Session              json.RawMessage  `json:"session,omitempty"`
+}
+
+// Codec encodes and decodes requests. Requests are decoded as *fosite.Request.
+type Codec struct {
+	// Sessions are the session types of the encoded requests.
+	Sessions *Registry
+}
+
+// New returns a codec for requests with sessions of the types registered in sessions, which defaults to
+// DefaultRegistry().
+func New(sessions *Registry) *Codec {
+	if sessions == nil {
+		sessions = DefaultRegistry()
+	}
+	return &Codec{Sessions: sessions}
+}
+
+// Encode returns the encoded form of req, or nil if req is nil.
+func (c *Codec) Encode(req fosite.Requester) (*Request, error) {
+	if req == nil {
+		return nil, nil
+	}
+
+	encoded := &Request{
+		Version:           Version,
+		ID:                req.GetID(),
+		RequestedAt:       req.GetRequestedAt(),
+		RequestedScope:    req.GetRequestedScopes(),
+		GrantedScope:      req.GetGrantedScopes(),
+		RequestedAudience: req.GetRequestedAudience(),
+		GrantedAudience:   req.GetGrantedAudience(),
+		Form:              req.GetRequestForm(),
+	}
+	if client := req.GetClient(); client != nil {
+		encoded.ClientID = client.GetID()
+	}
+	if client := req.GetSubjectTokenClient(); client != nil {
+		encoded.SubjectTokenClientID = client.GetID()
+	}
+	if g11n, ok := req.(fosite.G11NContext); ok && g11n.GetLang() != language.Und {
+		encoded.Lang = g11n.GetLang().String()
+	}
+
+	if session := req.GetSession(); session != nil {
+		name, data, err := c.Sessions.MarshalSession(session)
+		if err != nil {
+			return nil, err
+		}
+		encoded.SessionType, encoded.Session = name, data
+	}
+	return encoded, nil
+}
+
+// Decode restores an encoded request, or returns nil if encoded is nil. Requests encoded with an older version are
+// migrated to the current version first. Clients are resolved by ID using clients.
+// Clients which are unknown, or all clients if clients is nil, are restored as a fosite.DefaultClient holding the
+// client ID only.
+func (c *Codec) Decode(ctx context.Context, encoded *Request, clients fosite.ClientManager) (fosite.Requester, error) {
+	if encoded == nil {
+		return nil, nil
+	}
+
+	migrated := *encoded
+	encoded = &migrated
+	if err := migrate(encoded); err != nil {
+		return nil, err
+	}
+
+	req := &fosite.Request{
+		ID:                encoded.ID,
+		RequestedAt:       encoded.RequestedAt,
+		RequestedScope:    encoded.RequestedScope,
+		GrantedScope:      encoded.GrantedScope,
+		RequestedAudience: encoded.RequestedAudience,
+		GrantedAudience:   encoded.GrantedAudience,
+		Form:              encoded.Form,
+	}
+	if req.Form == nil {
+		req.Form = url.Values{}
+	}
+
+	var err error
+	if encoded.ClientID != "" {
+		if req.Client, err = resolveClient(ctx, clients, encoded.ClientID); err != nil {
+			return nil, err
+		}
+	}
+	if encoded.SubjectTokenClientID != "" {
+		if req.SubjectTokenClient, err = resolveClient(ctx, clients, encoded.SubjectTokenClientID); err != nil {
+			return nil, err
+		}
+	}
+	if encoded.Lang != "" {
+		if req.Lang, err = language.Parse(encoded.Lang); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if encoded.SessionType != "" {
+		if req.Session, err = c.Sessions.UnmarshalSession(encoded.SessionType, encoded.Session); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+// Marshal encodes req as JSON.
+func (c *Codec) Marshal(req fosite.Requester) ([]byte, error) {
+	encoded, err := c.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return data, nil
+}
+
+// Unmarshal decodes a request encoded as JSON by Marshal. Clients are resolved as described for Decode.
+func (c *Codec) Unmarshal(ctx context.Context, data []byte, clients fosite.ClientManager) (fosite.Requester, error) {
+	var encoded *Request
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.Decode(ctx, encoded, clients)
+}
+
+func migrate(encoded *Request) error {
+	if encoded.Version < 1 || encoded.Version > Version {
+		return errors.Wrapf(ErrUnsupportedVersion, "version %d", encoded.Version)
+	}
+
+	for encoded.Version < Version {
+		if err := migrations[encoded.Version-1](encoded); err != nil {
+			return errors.Wrapf(err, "unable to migrate request from version %d", encoded.Version)
+		}
+		encoded.Version++
+	}
+	return nil
+}
+
+func resolveClient(ctx context.Context, clients fosite.ClientManager, id string) (fosite.Client, error) {
+	if clients == nil {
+		return &fosite.DefaultClient{ID: id}, nil
+	}
+
+	client, err := clients.GetClient(ctx, id)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return &fosite.DefaultClient{ID: id}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
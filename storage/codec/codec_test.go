@@ -0,0 +1,148 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package codec
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type customSession struct {
+	fosite.DefaultSession
+	Tenant string `json:"tenant"`
+}
+
+func TestCodec(t *testing.T) {
+	ctx := context.Background()
+	clients := storage.NewMemoryStore()
+	clients.Clients["foo"] = &fosite.DefaultClient{ID: "foo", Scopes: []string{"offline"}}
+
+	newRequest := func(session fosite.Session) *fosite.Request {
+		return &fosite.Request{
+			ID:                 "request",
+			RequestedAt:        time.Now().UTC().Round(time.Second),
+			Client:             &fosite.DefaultClient{ID: "foo"},
+			SubjectTokenClient: &fosite.DefaultClient{ID: "bar"},
+			RequestedScope:     fosite.Arguments{"openid", "offline"},
+			GrantedScope:       fosite.Arguments{"offline"},
+			GrantedAudience:    fosite.Arguments{"https://api.example.com"},
+			Form:               map[string][]string{"foo": {"bar"}},
+			Lang:               language.German,
+			Session:            session,
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		session fosite.Session
+	}{
+		{name: "fosite.DefaultSession", session: &fosite.DefaultSession{Subject: "peter", Extra: map[string]interface{}{"foo": "bar"}}},
+		{name: "openid.DefaultSession", session: &openid.DefaultSession{Subject: "peter", Claims: &jwt.IDTokenClaims{Subject: "peter", Nonce: "nonce"}, Headers: &jwt.Headers{}}},
+		{name: "oauth2.JWTSession", session: &oauth2.JWTSession{Subject: "peter", JWTClaims: &jwt.JWTClaims{Subject: "peter", Scope: []string{"offline"}}}},
+		{name: "no session"},
+	} {
+		t.Run("session="+tc.name, func(t *testing.T) {
+			c := New(nil)
+			req := newRequest(tc.session)
+
+			data, err := c.Marshal(req)
+			require.NoError(t, err)
+			actual, err := c.Unmarshal(ctx, data, clients)
+			require.NoError(t, err)
+
+			assert.Equal(t, req.ID, actual.GetID())
+			assert.Equal(t, req.RequestedAt, actual.GetRequestedAt())
+			assert.Equal(t, req.RequestedScope, actual.GetRequestedScopes())
+			assert.Equal(t, req.GrantedScope, actual.GetGrantedScopes())
+			assert.Equal(t, req.GrantedAudience, actual.GetGrantedAudience())
+			assert.Equal(t, req.Form, actual.GetRequestForm())
+			assert.Equal(t, language.German, actual.(fosite.G11NContext).GetLang())
+			assert.Equal(t, fosite.Arguments{"offline"}, actual.GetClient().GetScopes())
+			assert.Equal(t, &fosite.DefaultClient{ID: "bar"}, actual.GetSubjectTokenClient())
+			if tc.session == nil {
+				assert.Nil(t, actual.GetSession())
+			} else {
+				assert.Equal(t, tc.session, actual.GetSession())
+			}
+		})
+	}
+
+	t.Run("case=registered session type", func(t *testing.T) {
+		registry := DefaultRegistry()
+		registry.Register("custom", func() fosite.Session { return new(customSession) })
+		c := New(registry)
+
+		data, err := c.Marshal(newRequest(&customSession{Tenant: "acme"}))
+		require.NoError(t, err)
+		actual, err := c.Unmarshal(ctx, data, nil)
+		require.NoError(t, err)
+		assert.Equal(t, &customSession{Tenant: "acme"}, actual.GetSession())
+		assert.Equal(t, &fosite.DefaultClient{ID: "foo"}, actual.GetClient())
+
+		_, err = New(nil).Unmarshal(ctx, data, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("case=unregistered session type", func(t *testing.T) {
+		_, err := New(NewRegistry()).Marshal(newRequest(new(fosite.DefaultSession)))
+		assert.Error(t, err)
+	})
+
+	t.Run("case=unsupported version", func(t *testing.T) {
+		for _, data := range []string{
+			`{"id":"request"}`,
+			fmt.Sprintf(`{"version":%d,"id":"request"}`, Version+1),
+		} {
+			_, err := New(nil).Unmarshal(ctx, []byte(data), nil)
+			assert.True(t, errors.Is(err, ErrUnsupportedVersion), "%s", data)
+		}
+	})
+
+	t.Run("case=migrates older versions", func(t *testing.T) {
+		for version := 1; version <= Version; version++ {
+			actual, err := New(nil).Unmarshal(ctx, []byte(fmt.Sprintf(`{"version":%d,"id":"request"}`, version)), nil)
+			require.NoError(t, err, "version %d", version)
+			assert.Equal(t, "request", actual.GetID())
+		}
+	})
+
+	t.Run("case=nil request", func(t *testing.T) {
+		data, err := New(nil).Marshal(nil)
+		require.NoError(t, err)
+		actual, err := New(nil).Unmarshal(ctx, data, nil)
+		require.NoError(t, err)
+		assert.Nil(t, actual)
+	})
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+)
+
+// Registry maps the names under which sessions are encoded to the types of the sessions. Encoded requests can only
+// be decoded if the type of their session has been registered. Registry is safe for concurrent use.
+type Registry struct {
+	mutex    sync.RWMutex
+	sessions map[string]func() fosite.Session
+	names    map[reflect.Type]string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		sessions: make(map[string]func() fosite.Session),
+		names:    make(map[reflect.Type]string),
+	}
+}
+
+// DefaultRegistry returns a registry of the session types provided by fosite: fosite.DefaultSession,
+// openid.DefaultSession and oauth2.JWTSession.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("fosite.DefaultSession", func() fosite.Session { return new(fosite.DefaultSession) })
+	r.Register("openid.DefaultSession", func() fosite.Session { return new(openid.DefaultSession) })
+	r.Register("oauth2.JWTSession", func() fosite.Session { return new(oauth2.JWTSession) })
+	return r
+}
+
+// Register registers the type of the sessions returned by newSession under name, for example:
+//
+//	registry.Register("myapp.Session", func() fosite.Session { return new(Session) })
+//
+// The name is part of the encoded requests and must not change once requests have been stored. Registering a name
+// again replaces the type registered under it.
+func (r *Registry) Register(name string, newSession func() fosite.Session) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if previous, ok := r.sessions[name]; ok {
+		delete(r.names, reflect.TypeOf(previous()))
+	}
+	r.sessions[name] = newSession
+	r.names[reflect.TypeOf(newSession())] = name
+}
+
+// Name returns the name under which the type of session has been registered.
+func (r *Registry) Name(session fosite.Session) (string, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	name, ok := r.names[reflect.TypeOf(session)]
+	if !ok {
+		return "", errors.Errorf("session type %T has not been registered", session)
+	}
+	return name, nil
+}
+
+// New returns a new session of the type registered under name.
+func (r *Registry) New(name string) (fosite.Session, error) {
+	r.mutex.RLock()
+	newSession, ok := r.sessions[name]
+	r.mutex.RUnlock()
+
+	if !ok {
+		return nil, errors.Errorf("session type %s has not been registered", name)
+	}
+	return newSession(), nil
+}
+
+// MarshalSession encodes session as JSON and returns it together with the name of its type.
+func (r *Registry) MarshalSession(session fosite.Session) (name string, data []byte, err error) {
+	if name, err = r.Name(session); err != nil {
+		return "", nil, err
+	}
+	if data, err = json.Marshal(session); err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+	return name, data, nil
+}
+
+// UnmarshalSession decodes a session of the type registered under name from JSON.
+func (r *Registry) UnmarshalSession(name string, data []byte) (fosite.Session, error) {
+	session, err := r.New(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return session, nil
+}
@@ -0,0 +1,621 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// ErrFileStoreClosed is returned when changing a FileStore which has been closed.
+var ErrFileStoreClosed = errors.New("the file store has been closed")
+
+const defaultFileStoreCompactAfter = 1000
+
+// FileStore is a MemoryStore persisting its tokens, authorize codes, PKCE and OpenID Connect sessions and JTIs to a
+// file. Clients, users and public keys are not persisted and are configured on the MemoryStore as usual.
+//
+// Every change is appended to the file and synced to disk before it is applied in memory and acknowledged. The file
+// is compacted into a
+// snapshot of the current state when the store is opened and after CompactAfter changes. Entries removed by the
+// janitor of the MemoryStore are dropped from the file on the next compaction.
+//
+// Requests are encoded by Codec and restored with their clients resolved by ID from the MemoryStore.
+type FileStore struct {
+	*MemoryStore
+
+	// Codec encodes the stored requests.
+	Codec RequestCodec
+	// CompactAfter is the number of changes after which the file is compacted. Defaults to 1000.
+	CompactAfter int
+
+	path   string
+	file   *os.File
+	logged int
+	mutex  sync.Mutex
+}
+
+type fileTxKey struct{}
+
+// fileTx collects the changes made within a transaction which are appended to the file on commit.
+type fileTx struct {
+	sync.Mutex
+	records []*fileStoreRecord
+}
+
+func fileTxFromContext(ctx context.Context) *fileTx {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(fileTxKey{}).(*fileTx)
+	return tx
+}
+
+const (
+	fileOpSnapshot                           = "snapshot"
+	fileOpBatch                              = "batch"
+	fileOpCreateOpenIDConnectSession         = "create_openid_connect_session"
+	fileOpDeleteOpenIDConnectSession         = "delete_openid_connect_session"
+	fileOpSetClientAssertionJWT              = "set_client_assertion_jwt"
+	fileOpRevokeJTI                          = "revoke_jti"
+	fileOpCreateAuthorizeCodeSession         = "create_authorize_code_session"
+	fileOpInvalidateAuthorizeCodeSession     = "invalidate_authorize_code_session"
+	fileOpCreatePKCERequestSession           = "create_pkce_request_session"
+	fileOpDeletePKCERequestSession           = "delete_pkce_request_session"
+	fileOpCreateAccessTokenSession           = "create_access_token_session"
+	fileOpDeleteAccessTokenSession           = "delete_access_token_session"
+	fileOpCreateRefreshTokenSession          = "create_refresh_token_session"
+	fileOpDeleteRefreshTokenSession          = "delete_refresh_token_session"
+	fileOpRevokeRefreshToken                 = "revoke_refresh_token"
+	fileOpRevokeRefreshTokenMaybeGracePeriod = "revoke_refresh_token_maybe_grace_period"
+	fileOpRotateRefreshTokenWithGracePeriod  = "rotate_refresh_token_with_grace_period"
+	fileOpRotateRefreshTokenFamily           = "rotate_refresh_token_family"
+	fileOpRevokeRefreshTokenFamily           = "revoke_refresh_token_family"
+	fileOpRevokeAccessToken                  = "revoke_access_token"
+	fileOpRevokeTokensBySubject              = "revoke_tokens_by_subject"
+	fileOpRevokeTokensByClient               = "revoke_tokens_by_client"
+)
+
+// fileStoreRecord is a change of a FileStore. The file holds one record per line, prefixed by its CRC-32 checksum.
+type fileStoreRecord struct {
+	Op string `json:"op"`
+	// At is the time the change was made, it is used as the clock of the MemoryStore when replaying the change.
+	At           time.Time          `json:"at"`
+	Key          string             `json:"key,omitempty"`
+	Signature    string             `json:"signature,omitempty"`
+	Request      json.RawMessage    `json:"request,omitempty"`
+	Expiry       time.Time          `json:"expiry,omitempty"`
+	Generation   uint64             `json:"generation,omitempty"`
+	AccessToken  string             `json:"access_token,omitempty"`
+	RefreshToken string             `json:"refresh_token,omitempty"`
+	GracePeriod  time.Duration      `json:"grace_period,omitempty"`
+	Records      []*fileStoreRecord `json:"records,omitempty"`
+	Snapshot     *fileStoreSnapshot `json:"snapshot,omitempty"`
+
+	request    fosite.Requester
+	requestIDs []string
+}
+
+// OpenFileStore opens the FileStore persisted at path, creating the file if it does not exist. The persisted state is
+// restored into memory, which defaults to NewMemoryStore(). Requests are encoded by codec, for example:
+//
+//	store, err := storage.OpenFileStore(path, nil, codec.New(codec.DefaultRegistry()))
+func OpenFileStore(path string, memory *MemoryStore, codec RequestCodec) (*FileStore, error) {
+	if codec == nil {
+		return nil, errors.New("a request codec is required to open a file store")
+	}
+	if memory == nil {
+		memory = NewMemoryStore()
+	}
+
+	f := &FileStore{MemoryStore: memory, Codec: codec, path: path}
+	if err := f.load(); err != nil {
+		return nil, err
+	}
+
+	// Compacting drops the replayed changes together with a torn write at the end of the file.
+	if err := f.compact(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Close closes the file. The store must not be changed afterwards.
+func (f *FileStore) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return errors.WithStack(err)
+}
+
+// Compact replaces the file by a snapshot of the current state.
+func (f *FileStore) Compact() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return errors.WithStack(ErrFileStoreClosed)
+	}
+	return f.compact()
+}
+
+// BeginTX starts a transaction of the MemoryStore. The changes made within the transaction are written to the file
+// when it is committed.
+func (f *FileStore) BeginTX(ctx context.Context) (context.Context, error) {
+	ctx, err := f.MemoryStore.BeginTX(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	return context.WithValue(ctx, fileTxKey{}, new(fileTx)), nil
+}
+
+// Commit commits the transaction of the MemoryStore and writes its changes to the file at once.
+func (f *FileStore) Commit(ctx context.Context) error {
+	tx := fileTxFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction has been started in this context")
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		_ = f.MemoryStore.Rollback(ctx)
+		return errors.WithStack(ErrFileStoreClosed)
+	}
+
+	at := fosite.TimeNow(f.Clock)
+	tx.Lock()
+	defer tx.Unlock()
+
+	// The changes are appended once the transaction has passed its checks and before they are applied in memory.
+	defer f.compactIfDue()
+	return f.MemoryStore.commit(ctx, func() error {
+		for _, record := range tx.records {
+			record.At = at
+		}
+
+		switch len(tx.records) {
+		case 0:
+			return nil
+		case 1:
+			return f.append(tx.records[0])
+		default:
+			// The changes are written as a single record to be replayed like the transaction is committed.
+			return f.append(&fileStoreRecord{Op: fileOpBatch, At: at, Records: tx.records})
+		}
+	})
+}
+
+// Rollback discards the changes of the transaction.
+func (f *FileStore) Rollback(ctx context.Context) error {
+	return f.MemoryStore.Rollback(ctx)
+}
+
+func (f *FileStore) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpCreateOpenIDConnectSession, Key: authorizeCode, request: requester})
+}
+
+func (f *FileStore) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpDeleteOpenIDConnectSession, Key: authorizeCode})
+}
+
+func (f *FileStore) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpSetClientAssertionJWT, Key: jti, Expiry: exp})
+}
+
+func (f *FileStore) MarkJWTUsedForTime(ctx context.Context, jti string, exp time.Time) error {
+	return f.SetClientAssertionJWT(ctx, jti, exp)
+}
+
+func (f *FileStore) RevokeJTI(ctx context.Context, jti string, exp time.Time) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRevokeJTI, Key: jti, Expiry: exp})
+}
+
+func (f *FileStore) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpCreateAuthorizeCodeSession, Key: code, request: req})
+}
+
+func (f *FileStore) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpInvalidateAuthorizeCodeSession, Key: code})
+}
+
+func (f *FileStore) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpCreatePKCERequestSession, Key: code, request: req})
+}
+
+func (f *FileStore) DeletePKCERequestSession(ctx context.Context, code string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpDeletePKCERequestSession, Key: code})
+}
+
+func (f *FileStore) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpCreateAccessTokenSession, Key: signature, request: req})
+}
+
+func (f *FileStore) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpDeleteAccessTokenSession, Key: signature})
+}
+
+func (f *FileStore) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpCreateRefreshTokenSession, Key: signature, request: req})
+}
+
+func (f *FileStore) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpDeleteRefreshTokenSession, Key: signature})
+}
+
+func (f *FileStore) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRevokeRefreshToken, Key: requestID})
+}
+
+func (f *FileStore) RevokeRefreshTokenMaybeGracePeriod(ctx context.Context, requestID string, signature string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRevokeRefreshTokenMaybeGracePeriod, Key: requestID, Signature: signature})
+}
+
+func (f *FileStore) RotateRefreshTokenWithGracePeriod(ctx context.Context, requestID, signature, accessToken, refreshToken string, gracePeriod time.Duration) error {
+	return f.write(ctx, &fileStoreRecord{
+		Op:           fileOpRotateRefreshTokenWithGracePeriod,
+		Key:          requestID,
+		Signature:    signature,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		GracePeriod:  gracePeriod,
+	})
+}
+
+func (f *FileStore) RotateRefreshTokenFamily(ctx context.Context, familyID string, generation uint64) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRotateRefreshTokenFamily, Key: familyID, Generation: generation})
+}
+
+func (f *FileStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRevokeRefreshTokenFamily, Key: familyID})
+}
+
+func (f *FileStore) RevokeAccessToken(ctx context.Context, requestID string) error {
+	return f.write(ctx, &fileStoreRecord{Op: fileOpRevokeAccessToken, Key: requestID})
+}
+
+// RevokeTokensBySubject revokes the access and refresh tokens of all requests of subject and returns their IDs.
+func (f *FileStore) RevokeTokensBySubject(ctx context.Context, subject string) ([]string, error) {
+	record := &fileStoreRecord{Op: fileOpRevokeTokensBySubject, Key: subject}
+	err := f.write(ctx, record)
+	return record.requestIDs, err
+}
+
+// RevokeTokensByClient revokes the access and refresh tokens of all requests of the client and returns their IDs.
+func (f *FileStore) RevokeTokensByClient(ctx context.Context, clientID string) ([]string, error) {
+	record := &fileStoreRecord{Op: fileOpRevokeTokensByClient, Key: clientID}
+	err := f.write(ctx, record)
+	return record.requestIDs, err
+}
+
+// write appends the change to the file and applies it to the MemoryStore. Within a transaction, the change is
+// journaled by the MemoryStore and appended when the transaction is committed.
+func (f *FileStore) write(ctx context.Context, record *fileStoreRecord) error {
+	if record.request != nil {
+		stored, err := f.encodeRequest(record.request)
+		if err != nil {
+			return err
+		}
+		record.Request = stored
+	}
+
+	if tx := fileTxFromContext(ctx); tx != nil {
+		if err := f.apply(ctx, record); err != nil {
+			return err
+		}
+
+		tx.Lock()
+		defer tx.Unlock()
+		tx.records = append(tx.records, record)
+		return nil
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.file == nil {
+		return errors.WithStack(ErrFileStoreClosed)
+	}
+
+	// A change which fails to apply has been appended already. It fails again when it is replayed, which leaves the
+	// state unchanged just like now.
+	record.At = fosite.TimeNow(f.Clock)
+	if err := f.append(record); err != nil {
+		return err
+	}
+	defer f.compactIfDue()
+	return f.apply(ctx, record)
+}
+
+func (f *FileStore) apply(ctx context.Context, record *fileStoreRecord) (err error) {
+	s := f.MemoryStore
+	switch record.Op {
+	case fileOpCreateOpenIDConnectSession:
+		return s.CreateOpenIDConnectSession(ctx, record.Key, record.request)
+	case fileOpDeleteOpenIDConnectSession:
+		return s.DeleteOpenIDConnectSession(ctx, record.Key)
+	case fileOpSetClientAssertionJWT:
+		return s.SetClientAssertionJWT(ctx, record.Key, record.Expiry)
+	case fileOpRevokeJTI:
+		return s.RevokeJTI(ctx, record.Key, record.Expiry)
+	case fileOpCreateAuthorizeCodeSession:
+		return s.CreateAuthorizeCodeSession(ctx, record.Key, record.request)
+	case fileOpInvalidateAuthorizeCodeSession:
+		return s.InvalidateAuthorizeCodeSession(ctx, record.Key)
+	case fileOpCreatePKCERequestSession:
+		return s.CreatePKCERequestSession(ctx, record.Key, record.request)
+	case fileOpDeletePKCERequestSession:
+		return s.DeletePKCERequestSession(ctx, record.Key)
+	case fileOpCreateAccessTokenSession:
+		return s.CreateAccessTokenSession(ctx, record.Key, record.request)
+	case fileOpDeleteAccessTokenSession:
+		return s.DeleteAccessTokenSession(ctx, record.Key)
+	case fileOpCreateRefreshTokenSession:
+		return s.CreateRefreshTokenSession(ctx, record.Key, record.request)
+	case fileOpDeleteRefreshTokenSession:
+		return s.DeleteRefreshTokenSession(ctx, record.Key)
+	case fileOpRevokeRefreshToken:
+		return s.RevokeRefreshToken(ctx, record.Key)
+	case fileOpRevokeRefreshTokenMaybeGracePeriod:
+		return s.RevokeRefreshTokenMaybeGracePeriod(ctx, record.Key, record.Signature)
+	case fileOpRotateRefreshTokenWithGracePeriod:
+		return s.RotateRefreshTokenWithGracePeriod(ctx, record.Key, record.Signature, record.AccessToken, record.RefreshToken, record.GracePeriod)
+	case fileOpRotateRefreshTokenFamily:
+		return s.RotateRefreshTokenFamily(ctx, record.Key, record.Generation)
+	case fileOpRevokeRefreshTokenFamily:
+		return s.RevokeRefreshTokenFamily(ctx, record.Key)
+	case fileOpRevokeAccessToken:
+		return s.RevokeAccessToken(ctx, record.Key)
+	case fileOpRevokeTokensBySubject:
+		record.requestIDs, err = s.RevokeTokensBySubject(ctx, record.Key)
+		return err
+	case fileOpRevokeTokensByClient:
+		record.requestIDs, err = s.RevokeTokensByClient(ctx, record.Key)
+		return err
+	}
+	return errors.Errorf("unknown file store operation %s", record.Op)
+}
+
+// append writes the records to the file and syncs it. f.mutex must be held by the caller.
+//
+// If writing fails, the file is truncated to its previous size, or compacted if that fails as well, so that a partially
+// written record does not prevent later records from being loaded. If the file can not be repaired, it is closed.
+func (f *FileStore) append(records ...*fileStoreRecord) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := encodeFileStoreRecord(record)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+	}
+
+	info, err := f.file.Stat()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err = f.file.Write(buf.Bytes()); err == nil {
+		err = f.file.Sync()
+	}
+	if err != nil {
+		if f.file.Truncate(info.Size()) != nil && f.compact() != nil {
+			_ = f.file.Close()
+			f.file = nil
+		}
+		return errors.WithStack(err)
+	}
+
+	f.logged += len(records)
+	return nil
+}
+
+// compactIfDue compacts the file once CompactAfter records have been appended. The records have been persisted, a
+// failed compaction leaves the file intact and is retried after the next change. f.mutex must be held by the caller.
+func (f *FileStore) compactIfDue() {
+	if f.file != nil && f.logged >= f.compactAfter() {
+		_ = f.compact()
+	}
+}
+
+func (f *FileStore) compactAfter() int {
+	if f.CompactAfter <= 0 {
+		return defaultFileStoreCompactAfter
+	}
+	return f.CompactAfter
+}
+
+// compact atomically replaces the file by a snapshot of the current state. f.mutex must be held by the caller unless
+// the store is being opened.
+func (f *FileStore) compact() error {
+	snapshot, err := f.snapshot()
+	if err != nil {
+		return err
+	}
+
+	line, err := encodeFileStoreRecord(&fileStoreRecord{Op: fileOpSnapshot, At: fosite.TimeNow(f.Clock), Snapshot: snapshot})
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := writeFileSync(tmp, line); err != nil {
+		return err
+	} else if err := os.Rename(tmp, f.path); err != nil {
+		return errors.WithStack(err)
+	} else if err := syncDir(filepath.Dir(f.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if f.file != nil {
+		_ = f.file.Close()
+	}
+	f.file, f.logged = file, 0
+	return nil
+}
+
+// load replays the records of the file. A torn write of the last record is ignored, as the change has not been
+// acknowledged.
+func (f *FileStore) load() error {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	clock := f.Clock
+	defer func() {
+		f.Clock = clock
+	}()
+
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+
+		record, err := decodeFileStoreRecord(data[:end])
+		if err != nil && end == len(data)-1 {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := f.replay(record); err != nil {
+			return err
+		}
+		data = data[end+1:]
+	}
+	return nil
+}
+
+func (f *FileStore) replay(record *fileStoreRecord) error {
+	f.Clock = replayClock(record.At)
+
+	switch record.Op {
+	case fileOpSnapshot:
+		return f.restore(record.Snapshot)
+	case fileOpBatch:
+		// The changes of a transaction are applied like MemoryStore.Commit does, which stops at the first failure.
+		for _, r := range record.Records {
+			if failed, err := f.replayChange(r); err != nil {
+				return err
+			} else if failed {
+				break
+			}
+		}
+		return nil
+	}
+
+	_, err := f.replayChange(record)
+	return err
+}
+
+// replayChange applies a change read from the file and returns true if it failed. Changes are appended before they are
+// applied, so changes which failed when they were made fail again and are skipped like they were then. Like in
+// MemoryStore.Commit, revoking or deleting what has been removed already is not a failure.
+func (f *FileStore) replayChange(record *fileStoreRecord) (failed bool, err error) {
+	f.Clock = replayClock(record.At)
+	if record.request, err = f.decodeRequest(record.Request); err != nil {
+		return false, err
+	}
+
+	err = f.apply(context.Background(), record)
+	return err != nil && !errors.Is(err, fosite.ErrNotFound), nil
+}
+
+func encodeFileStoreRecord(record *fileStoreRecord) ([]byte, error) {
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return []byte(fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE(raw), raw)), nil
+}
+
+func decodeFileStoreRecord(line []byte) (*fileStoreRecord, error) {
+	var checksum uint32
+	if len(line) < 10 || line[8] != ' ' {
+		return nil, errors.New("malformed file store record")
+	} else if _, err := fmt.Sscanf(string(line[:8]), "%08x", &checksum); err != nil {
+		return nil, errors.WithStack(err)
+	} else if crc32.ChecksumIEEE(line[9:]) != checksum {
+		return nil, errors.New("file store record checksum mismatch")
+	}
+
+	var record fileStoreRecord
+	if err := json.Unmarshal(line[9:], &record); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &record, nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(file.Sync())
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer d.Close()
+
+	return errors.WithStack(d.Sync())
+}
+
+type replayClock time.Time
+
+func (c replayClock) Now() time.Time {
+	return time.Time(c)
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+// unusedRequestCodec is a RequestCodec for tests which store no requests.
+type unusedRequestCodec struct{}
+
+func (unusedRequestCodec) Marshal(fosite.Requester) ([]byte, error) {
+	panic("no requests are stored")
+}
+
+func (unusedRequestCodec) Unmarshal(context.Context, []byte, fosite.ClientManager) (fosite.Requester, error) {
+	panic("no requests are stored")
+}
+
+func TestFileStore_FailedAppend(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "store")
+	exp := time.Now().Add(time.Hour)
+
+	store, err := OpenFileStore(path, nil, unusedRequestCodec{})
+	require.NoError(t, err)
+	require.NoError(t, store.RevokeJTI(ctx, "persisted", exp))
+
+	// Writing to a file opened read-only fails, as does truncating it, so the file is compacted.
+	readOnly, err := os.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, store.file.Close())
+	store.file = readOnly
+	assert.Error(t, store.RevokeJTI(ctx, "failed", exp))
+
+	revoked, err := store.IsJTIRevoked(ctx, "failed")
+	require.NoError(t, err)
+	assert.False(t, revoked, "changes which have not been persisted must not be applied")
+
+	require.NoError(t, store.RevokeJTI(ctx, "appended", exp))
+	require.NoError(t, store.Close())
+
+	store, err = OpenFileStore(path, nil, unusedRequestCodec{})
+	require.NoError(t, err)
+	defer store.Close()
+	for jti, expected := range map[string]bool{"persisted": true, "failed": false, "appended": true} {
+		revoked, err := store.IsJTIRevoked(ctx, jti)
+		require.NoError(t, err)
+		assert.Equal(t, expected, revoked, jti)
+	}
+}
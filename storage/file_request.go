@@ -0,0 +1,53 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ory/fosite"
+)
+
+// RequestCodec encodes the requests persisted by a FileStore. It is implemented by codec.Codec of the
+// github.com/ory/fosite/storage/codec package.
+type RequestCodec interface {
+	// Marshal encodes req.
+	Marshal(req fosite.Requester) ([]byte, error)
+	// Unmarshal decodes a request encoded by Marshal, resolving its clients using clients.
+	Unmarshal(ctx context.Context, data []byte, clients fosite.ClientManager) (fosite.Requester, error)
+}
+
+func (f *FileStore) encodeRequest(req fosite.Requester) (json.RawMessage, error) {
+	if req == nil {
+		return nil, nil
+	}
+	return f.Codec.Marshal(req)
+}
+
+// decodeRequest restores a request, resolving its clients from the MemoryStore.
+func (f *FileStore) decodeRequest(stored json.RawMessage) (fosite.Requester, error) {
+	if stored == nil {
+		return nil, nil
+	}
+	return f.Codec.Unmarshal(context.Background(), stored, f.MemoryStore)
+}
@@ -0,0 +1,248 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ory/fosite"
+)
+
+// fileStoreSnapshot is the persisted state of a FileStore.
+type fileStoreSnapshot struct {
+	AuthorizeCodes         map[string]fileStoreAuthorizeCode    `json:"authorize_codes"`
+	IDSessions             map[string]json.RawMessage           `json:"id_sessions"`
+	AccessTokens           map[string]json.RawMessage           `json:"access_tokens"`
+	RefreshTokens          map[string]fileStoreRefreshToken     `json:"refresh_tokens"`
+	PKCES                  map[string]json.RawMessage           `json:"pkces"`
+	BlacklistedJTIs        map[string]time.Time                 `json:"blacklisted_jtis"`
+	RevokedJTIs            map[string]time.Time                 `json:"revoked_jtis"`
+	AccessTokenRequestIDs  map[string]string                    `json:"access_token_request_ids"`
+	RefreshTokenRequestIDs map[string]string                    `json:"refresh_token_request_ids"`
+	RefreshTokenFamilies   map[string]StoreRefreshTokenFamily   `json:"refresh_token_families"`
+	RotatedRefreshTokens   map[string]StoreRotatedRefreshTokens `json:"rotated_refresh_tokens"`
+	SubjectRequestIDs      map[string]map[string]struct{}       `json:"subject_request_ids"`
+	ClientRequestIDs       map[string]map[string]struct{}       `json:"client_request_ids"`
+}
+
+type fileStoreAuthorizeCode struct {
+	Active  bool            `json:"active"`
+	Request json.RawMessage `json:"request"`
+}
+
+type fileStoreRefreshToken struct {
+	Active         bool            `json:"active"`
+	GraceExpiresAt time.Time       `json:"grace_expires_at"`
+	Request        json.RawMessage `json:"request"`
+}
+
+func (f *FileStore) snapshot() (*fileStoreSnapshot, error) {
+	s := f.MemoryStore
+	snapshot := &fileStoreSnapshot{
+		AuthorizeCodes: make(map[string]fileStoreAuthorizeCode),
+		RefreshTokens:  make(map[string]fileStoreRefreshToken),
+	}
+
+	var err error
+	if err = func() error {
+		s.authorizeCodesMutex.RLock()
+		defer s.authorizeCodesMutex.RUnlock()
+
+		for code, rel := range s.AuthorizeCodes {
+			req, err := f.encodeRequest(rel.Requester)
+			if err != nil {
+				return err
+			}
+			snapshot.AuthorizeCodes[code] = fileStoreAuthorizeCode{Active: rel.active, Request: req}
+		}
+		return nil
+	}(); err != nil {
+		return nil, err
+	}
+
+	if err = func() error {
+		s.refreshTokensMutex.RLock()
+		defer s.refreshTokensMutex.RUnlock()
+
+		for signature, rel := range s.RefreshTokens {
+			req, err := f.encodeRequest(rel.Requester)
+			if err != nil {
+				return err
+			}
+			snapshot.RefreshTokens[signature] = fileStoreRefreshToken{Active: rel.active, GraceExpiresAt: rel.graceExpiresAt, Request: req}
+		}
+		return nil
+	}(); err != nil {
+		return nil, err
+	}
+
+	if snapshot.IDSessions, err = f.encodeRequests(&s.idSessionsMutex, s.IDSessions); err != nil {
+		return nil, err
+	} else if snapshot.AccessTokens, err = f.encodeRequests(&s.accessTokensMutex, s.AccessTokens); err != nil {
+		return nil, err
+	} else if snapshot.PKCES, err = f.encodeRequests(&s.pkcesMutex, s.PKCES); err != nil {
+		return nil, err
+	}
+
+	s.blacklistedJTIsMutex.RLock()
+	snapshot.BlacklistedJTIs = copyTimes(s.BlacklistedJTIs)
+	s.blacklistedJTIsMutex.RUnlock()
+
+	s.revokedJTIsMutex.RLock()
+	snapshot.RevokedJTIs = copyTimes(s.RevokedJTIs)
+	s.revokedJTIsMutex.RUnlock()
+
+	s.accessTokenRequestIDsMutex.RLock()
+	snapshot.AccessTokenRequestIDs = copyStrings(s.AccessTokenRequestIDs)
+	s.accessTokenRequestIDsMutex.RUnlock()
+
+	s.refreshTokenRequestIDsMutex.RLock()
+	snapshot.RefreshTokenRequestIDs = copyStrings(s.RefreshTokenRequestIDs)
+	s.refreshTokenRequestIDsMutex.RUnlock()
+
+	s.refreshTokenFamiliesMutex.RLock()
+	snapshot.RefreshTokenFamilies = make(map[string]StoreRefreshTokenFamily, len(s.RefreshTokenFamilies))
+	for familyID, family := range s.RefreshTokenFamilies {
+		snapshot.RefreshTokenFamilies[familyID] = family
+	}
+	s.refreshTokenFamiliesMutex.RUnlock()
+
+	s.rotatedRefreshTokensMutex.RLock()
+	snapshot.RotatedRefreshTokens = make(map[string]StoreRotatedRefreshTokens, len(s.RotatedRefreshTokens))
+	for signature, rotated := range s.RotatedRefreshTokens {
+		snapshot.RotatedRefreshTokens[signature] = rotated
+	}
+	s.rotatedRefreshTokensMutex.RUnlock()
+
+	s.subjectRequestIDsMutex.RLock()
+	snapshot.SubjectRequestIDs = copyIndex(s.SubjectRequestIDs)
+	s.subjectRequestIDsMutex.RUnlock()
+
+	s.clientRequestIDsMutex.RLock()
+	snapshot.ClientRequestIDs = copyIndex(s.ClientRequestIDs)
+	s.clientRequestIDsMutex.RUnlock()
+
+	return snapshot, nil
+}
+
+// restore replaces the state of the MemoryStore by the snapshot. It is called before the store is used.
+func (f *FileStore) restore(snapshot *fileStoreSnapshot) (err error) {
+	s := f.MemoryStore
+
+	s.AuthorizeCodes = make(map[string]StoreAuthorizeCode, len(snapshot.AuthorizeCodes))
+	for code, rel := range snapshot.AuthorizeCodes {
+		req, err := f.decodeRequest(rel.Request)
+		if err != nil {
+			return err
+		}
+		s.AuthorizeCodes[code] = StoreAuthorizeCode{active: rel.Active, Requester: req}
+	}
+
+	s.RefreshTokens = make(map[string]StoreRefreshToken, len(snapshot.RefreshTokens))
+	for signature, rel := range snapshot.RefreshTokens {
+		req, err := f.decodeRequest(rel.Request)
+		if err != nil {
+			return err
+		}
+		s.RefreshTokens[signature] = StoreRefreshToken{active: rel.Active, graceExpiresAt: rel.GraceExpiresAt, Requester: req}
+	}
+
+	if s.IDSessions, err = f.decodeRequests(snapshot.IDSessions); err != nil {
+		return err
+	} else if s.AccessTokens, err = f.decodeRequests(snapshot.AccessTokens); err != nil {
+		return err
+	} else if s.PKCES, err = f.decodeRequests(snapshot.PKCES); err != nil {
+		return err
+	}
+
+	s.BlacklistedJTIs = copyTimes(snapshot.BlacklistedJTIs)
+	s.RevokedJTIs = copyTimes(snapshot.RevokedJTIs)
+	s.AccessTokenRequestIDs = copyStrings(snapshot.AccessTokenRequestIDs)
+	s.RefreshTokenRequestIDs = copyStrings(snapshot.RefreshTokenRequestIDs)
+	s.RefreshTokenFamilies = make(map[string]StoreRefreshTokenFamily, len(snapshot.RefreshTokenFamilies))
+	for familyID, family := range snapshot.RefreshTokenFamilies {
+		s.RefreshTokenFamilies[familyID] = family
+	}
+	s.RotatedRefreshTokens = make(map[string]StoreRotatedRefreshTokens, len(snapshot.RotatedRefreshTokens))
+	for signature, rotated := range snapshot.RotatedRefreshTokens {
+		s.RotatedRefreshTokens[signature] = rotated
+	}
+	s.SubjectRequestIDs = copyIndex(snapshot.SubjectRequestIDs)
+	s.ClientRequestIDs = copyIndex(snapshot.ClientRequestIDs)
+	return nil
+}
+
+func (f *FileStore) encodeRequests(mutex *sync.RWMutex, requests map[string]fosite.Requester) (map[string]json.RawMessage, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	encoded := make(map[string]json.RawMessage, len(requests))
+	for key, req := range requests {
+		stored, err := f.encodeRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		encoded[key] = stored
+	}
+	return encoded, nil
+}
+
+func (f *FileStore) decodeRequests(encoded map[string]json.RawMessage) (map[string]fosite.Requester, error) {
+	requests := make(map[string]fosite.Requester, len(encoded))
+	for key, stored := range encoded {
+		req, err := f.decodeRequest(stored)
+		if err != nil {
+			return nil, err
+		}
+		requests[key] = req
+	}
+	return requests, nil
+}
+
+func copyTimes(m map[string]time.Time) map[string]time.Time {
+	c := make(map[string]time.Time, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func copyIndex(m map[string]map[string]struct{}) map[string]map[string]struct{} {
+	c := make(map[string]map[string]struct{}, len(m))
+	for k, ids := range m {
+		c[k] = make(map[string]struct{}, len(ids))
+		for id := range ids {
+			c[k][id] = struct{}{}
+		}
+	}
+	return c
+}
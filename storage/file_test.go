@@ -0,0 +1,178 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	. "github.com/ory/fosite/storage"
+	"github.com/ory/fosite/storage/codec"
+)
+
+type customSession struct {
+	fosite.DefaultSession
+	Tenant string `json:"tenant"`
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store")
+
+	sessions := codec.DefaultRegistry()
+	sessions.Register("custom", func() fosite.Session { return new(customSession) })
+	open := func(t *testing.T) *FileStore {
+		memory := NewMemoryStore()
+		memory.Clients["foo"] = &fosite.DefaultClient{ID: "foo", Scopes: []string{"offline"}}
+		store, err := OpenFileStore(path, memory, codec.New(sessions))
+		require.NoError(t, err)
+		return store
+	}
+	newRequest := func(id string) *fosite.Request {
+		session := &customSession{DefaultSession: fosite.DefaultSession{Subject: "peter"}, Tenant: "acme"}
+		session.SetExpiresAt(fosite.RefreshToken, time.Now().Add(time.Hour).UTC().Round(time.Second))
+		return &fosite.Request{
+			ID:           id,
+			RequestedAt:  time.Now().UTC().Round(time.Second),
+			Client:       &fosite.DefaultClient{ID: "foo"},
+			GrantedScope: fosite.Arguments{"offline"},
+			Form:         map[string][]string{"foo": {"bar"}},
+			Session:      session,
+		}
+	}
+
+	store := open(t)
+	require.NoError(t, store.CreateAccessTokenSession(ctx, "at", newRequest("grant")))
+	require.NoError(t, store.CreateRefreshTokenSession(ctx, "rt", newRequest("grant")))
+	require.NoError(t, store.CreateAuthorizeCodeSession(ctx, "code", newRequest("code")))
+	require.NoError(t, store.InvalidateAuthorizeCodeSession(ctx, "code"))
+	require.NoError(t, store.SetClientAssertionJWT(ctx, "jti", time.Now().Add(time.Hour)))
+
+	txCtx, err := store.BeginTX(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.RevokeRefreshToken(txCtx, "grant"))
+	require.NoError(t, store.CreateRefreshTokenSession(txCtx, "rt-2", newRequest("grant-2")))
+	require.NoError(t, store.Commit(txCtx))
+
+	txCtx, err = store.BeginTX(ctx)
+	require.NoError(t, err)
+	require.NoError(t, store.CreateAccessTokenSession(txCtx, "rolled-back", newRequest("grant-3")))
+	require.NoError(t, store.Rollback(txCtx))
+	require.NoError(t, store.Close())
+	assert.True(t, errors.Is(store.CreateAccessTokenSession(ctx, "closed", newRequest("grant-4")), ErrFileStoreClosed))
+
+	assertRestored := func(t *testing.T, store *FileStore) {
+		req, err := store.GetAccessTokenSession(ctx, "at", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "grant", req.GetID())
+		assert.Equal(t, fosite.Arguments{"offline"}, req.GetGrantedScopes())
+		assert.Equal(t, "bar", req.GetRequestForm().Get("foo"))
+		assert.Equal(t, fosite.Arguments{"offline"}, req.GetClient().GetScopes())
+		require.IsType(t, new(customSession), req.GetSession())
+		assert.Equal(t, "acme", req.GetSession().(*customSession).Tenant)
+		assert.Equal(t, "peter", req.GetSession().GetSubject())
+
+		req, err = store.GetRefreshTokenSession(ctx, "rt", nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		assert.Equal(t, "grant", req.GetID())
+		_, err = store.GetRefreshTokenSession(ctx, "rt-2", nil)
+		assert.NoError(t, err)
+
+		_, err = store.GetAuthorizeCodeSession(ctx, "code", nil)
+		assert.True(t, errors.Is(err, fosite.ErrInvalidatedAuthorizeCode))
+		assert.True(t, errors.Is(store.ClientAssertionJWTValid(ctx, "jti"), fosite.ErrJTIKnown))
+
+		_, err = store.GetAccessTokenSession(ctx, "rolled-back", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+		assert.Contains(t, store.SubjectRequestIDs["peter"], "grant")
+		assert.Contains(t, store.SubjectRequestIDs["peter"], "grant-2")
+		assert.NotContains(t, store.SubjectRequestIDs["peter"], "grant-3")
+	}
+
+	store = open(t)
+	assertRestored(t, store)
+
+	t.Run("case=torn write is ignored", func(t *testing.T) {
+		require.NoError(t, store.CreateAccessTokenSession(ctx, "at-2", newRequest("grant-5")))
+		require.NoError(t, store.Close())
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+		require.NoError(t, err)
+		_, err = file.WriteString(`00000000 {"op":"create_access`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		store = open(t)
+		assertRestored(t, store)
+		_, err = store.GetAccessTokenSession(ctx, "at-2", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("case=compaction", func(t *testing.T) {
+		store.CompactAfter = 2
+		require.NoError(t, store.DeleteAccessTokenSession(ctx, "at-2"))
+		require.NoError(t, store.RevokeJTI(ctx, "revoked", time.Now().Add(time.Hour)))
+
+		data, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"op":"snapshot"`)
+		assert.NotContains(t, string(data), `"op":"revoke_jti"`)
+		require.NoError(t, store.Close())
+
+		store = open(t)
+		assertRestored(t, store)
+		revoked, err := store.IsJTIRevoked(ctx, "revoked")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("case=failed changes are skipped when replayed", func(t *testing.T) {
+		store := open(t)
+		assert.True(t, errors.Is(store.SetClientAssertionJWT(ctx, "jti", time.Now().Add(2*time.Hour)), fosite.ErrJTIKnown))
+		require.NoError(t, store.Close())
+
+		store = open(t)
+		assertRestored(t, store)
+		require.NoError(t, store.Close())
+	})
+
+	t.Run("case=codec is required", func(t *testing.T) {
+		_, err := OpenFileStore(path, nil, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("case=unregistered session type", func(t *testing.T) {
+		_, err := OpenFileStore(path, nil, codec.New(codec.DefaultRegistry()))
+		assert.Error(t, err)
+	})
+}
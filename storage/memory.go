@@ -26,6 +26,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"gopkg.in/square/go-jose.v2"
 
 	"github.com/ory/fosite"
@@ -52,6 +53,7 @@ type PublicKeyScopes struct {
 }
 
 type MemoryStore struct {
+	// Clients must be changed using the fosite.ClientWriter methods once the store is in use.
 	Clients         map[string]fosite.Client
 	AuthorizeCodes  map[string]StoreAuthorizeCode
 	IDSessions      map[string]fosite.Requester
@@ -60,11 +62,30 @@ type MemoryStore struct {
 	PKCES           map[string]fosite.Requester
 	Users           map[string]MemoryUserRelation
 	BlacklistedJTIs map[string]time.Time
+	// IDs of revoked JSON Web Token access tokens and their expiry
+	RevokedJTIs map[string]time.Time
 	// In-memory request ID to token signatures
 	AccessTokenRequestIDs  map[string]string
 	RefreshTokenRequestIDs map[string]string
 	// Public keys to check signature in auth grant jwt assertion.
 	IssuerPublicKeys map[string]IssuerPublicKeys
+	// Generations of self-contained refresh token families by family ID.
+	RefreshTokenFamilies map[string]StoreRefreshTokenFamily
+	// Tokens issued when rotating refresh tokens within their grace period by refresh token signature
+	RotatedRefreshTokens map[string]StoreRotatedRefreshTokens
+	// In-memory subject and client ID to the IDs of requests with access or refresh tokens
+	SubjectRequestIDs map[string]map[string]struct{}
+	ClientRequestIDs  map[string]map[string]struct{}
+	// In-memory client ID to the signature of its registration access token
+	RegistrationAccessTokenSignatures map[string]string
+	// Clock is used to expire blacklisted JTIs. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+	// RefreshTokenFamilyLifespan is how long the refresh tokens of a family remain valid after the family has last been
+	// rotated or revoked, usually the refresh token lifespan. DeleteExpired removes families which have not been
+	// changed for longer. Defaults to 30 days, families are never removed if it is negative.
+	RefreshTokenFamilyLifespan time.Duration
+	// Hasher hashes the client secrets passed to CreateClient and UpdateClient. Defaults to fosite.BCrypt.
+	Hasher fosite.Hasher
 
 	clientsMutex                sync.RWMutex
 	authorizeCodesMutex         sync.RWMutex
@@ -77,6 +98,24 @@ type MemoryStore struct {
 	accessTokenRequestIDsMutex  sync.RWMutex
 	refreshTokenRequestIDsMutex sync.RWMutex
 	issuerPublicKeysMutex       sync.RWMutex
+	refreshTokenFamiliesMutex   sync.RWMutex
+	subjectRequestIDsMutex      sync.RWMutex
+	clientRequestIDsMutex       sync.RWMutex
+	revokedJTIsMutex            sync.RWMutex
+	rotatedRefreshTokensMutex   sync.RWMutex
+
+	registrationAccessTokenSignaturesMutex sync.RWMutex
+
+	// Expired JTIs are pruned once the number of JTIs reaches these sizes, see pruneJTIs.
+	blacklistedJTIsPruneAt int
+	revokedJTIsPruneAt     int
+
+	// refreshTokenVersions counts the modifications of refresh tokens to detect conflicting transactions.
+	refreshTokenVersions map[string]uint64
+	// commitMutex is held exclusively by commits and shared by the other operations on tokens, codes and sessions.
+	commitMutex sync.RWMutex
+
+	janitor memoryStoreJanitor
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -91,7 +130,12 @@ func NewMemoryStore() *MemoryStore {
 		AccessTokenRequestIDs:  make(map[string]string),
 		RefreshTokenRequestIDs: make(map[string]string),
 		BlacklistedJTIs:        make(map[string]time.Time),
+		RevokedJTIs:            make(map[string]time.Time),
 		IssuerPublicKeys:       make(map[string]IssuerPublicKeys),
+		RefreshTokenFamilies:   make(map[string]StoreRefreshTokenFamily),
+		RotatedRefreshTokens:   make(map[string]StoreRotatedRefreshTokens),
+		SubjectRequestIDs:      make(map[string]map[string]struct{}),
+		ClientRequestIDs:       make(map[string]map[string]struct{}),
 	}
 }
 
@@ -102,9 +146,27 @@ type StoreAuthorizeCode struct {
 
 type StoreRefreshToken struct {
 	active bool
+	// graceExpiresAt is set for rotated refresh tokens which remain active until their grace period ends.
+	graceExpiresAt time.Time
 	fosite.Requester
 }
 
+// StoreRotatedRefreshTokens are the tokens a refresh token has been rotated to. AccessToken and RefreshToken are
+// encrypted by the refresh token handler, see oauth2.RefreshTokenGracePeriodStorage.
+type StoreRotatedRefreshTokens struct {
+	RequestID    string
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+type StoreRefreshTokenFamily struct {
+	Generation uint64
+	Revoked    bool
+	// UpdatedAt is the time the family has last been rotated or revoked.
+	UpdatedAt time.Time
+}
+
 func NewExampleStore() *MemoryStore {
 	return &MemoryStore{
 		IDSessions: make(map[string]fosite.Requester),
@@ -143,10 +205,25 @@ func NewExampleStore() *MemoryStore {
 		AccessTokenRequestIDs:  map[string]string{},
 		RefreshTokenRequestIDs: map[string]string{},
 		IssuerPublicKeys:       map[string]IssuerPublicKeys{},
+		RefreshTokenFamilies:   map[string]StoreRefreshTokenFamily{},
+		RotatedRefreshTokens:   map[string]StoreRotatedRefreshTokens{},
+		RevokedJTIs:            map[string]time.Time{},
+		SubjectRequestIDs:      map[string]map[string]struct{}{},
+		ClientRequestIDs:       map[string]map[string]struct{}{},
 	}
 }
 
-func (s *MemoryStore) CreateOpenIDConnectSession(_ context.Context, authorizeCode string, requester fosite.Requester) error {
+func (s *MemoryStore) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryIDSessions, authorizeCode)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.CreateOpenIDConnectSession(ctx, authorizeCode, requester)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.idSessionsMutex.Lock()
 	defer s.idSessionsMutex.Unlock()
 
@@ -154,7 +231,13 @@ func (s *MemoryStore) CreateOpenIDConnectSession(_ context.Context, authorizeCod
 	return nil
 }
 
-func (s *MemoryStore) GetOpenIDConnectSession(_ context.Context, authorizeCode string, requester fosite.Requester) (fosite.Requester, error) {
+func (s *MemoryStore) GetOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) (fosite.Requester, error) {
+	if pending := s.pendingFor(ctx, memoryIDSessions, authorizeCode); pending != nil {
+		return pending.GetOpenIDConnectSession(context.Background(), authorizeCode, requester)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.idSessionsMutex.RLock()
 	defer s.idSessionsMutex.RUnlock()
 
@@ -166,7 +249,17 @@ func (s *MemoryStore) GetOpenIDConnectSession(_ context.Context, authorizeCode s
 }
 
 // DeleteOpenIDConnectSession is not really called from anywhere and it is deprecated.
-func (s *MemoryStore) DeleteOpenIDConnectSession(_ context.Context, authorizeCode string) error {
+func (s *MemoryStore) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryIDSessions, authorizeCode)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.DeleteOpenIDConnectSession(ctx, authorizeCode)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.idSessionsMutex.Lock()
 	defer s.idSessionsMutex.Unlock()
 
@@ -182,14 +275,14 @@ func (s *MemoryStore) GetClient(_ context.Context, id string) (fosite.Client, er
 	if !ok {
 		return nil, fosite.ErrNotFound
 	}
-	return cl, nil
+	return copyClient(cl), nil
 }
 
 func (s *MemoryStore) ClientAssertionJWTValid(_ context.Context, jti string) error {
 	s.blacklistedJTIsMutex.RLock()
 	defer s.blacklistedJTIsMutex.RUnlock()
 
-	if exp, exists := s.BlacklistedJTIs[jti]; exists && exp.After(time.Now()) {
+	if exp, exists := s.BlacklistedJTIs[jti]; exists && exp.After(fosite.TimeNow(s.Clock)) {
 		return fosite.ErrJTIKnown
 	}
 
@@ -200,22 +293,70 @@ func (s *MemoryStore) SetClientAssertionJWT(_ context.Context, jti string, exp t
 	s.blacklistedJTIsMutex.Lock()
 	defer s.blacklistedJTIsMutex.Unlock()
 
-	// delete expired jtis
-	for j, e := range s.BlacklistedJTIs {
-		if e.Before(time.Now()) {
-			delete(s.BlacklistedJTIs, j)
-		}
-	}
-
-	if _, exists := s.BlacklistedJTIs[jti]; exists {
+	now := fosite.TimeNow(s.Clock)
+	if e, exists := s.BlacklistedJTIs[jti]; exists && e.After(now) {
 		return fosite.ErrJTIKnown
 	}
 
+	pruneJTIs(s.BlacklistedJTIs, &s.blacklistedJTIsPruneAt, now)
+
 	s.BlacklistedJTIs[jti] = exp
 	return nil
 }
 
-func (s *MemoryStore) CreateAuthorizeCodeSession(_ context.Context, code string, req fosite.Requester) error {
+func (s *MemoryStore) RevokeJTI(ctx context.Context, jti string, exp time.Time) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRevokedJTIs, jti)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RevokeJTI(ctx, jti, exp)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.revokeJTI(jti, exp)
+	return nil
+}
+
+func (s *MemoryStore) revokeJTI(jti string, exp time.Time) {
+	s.revokedJTIsMutex.Lock()
+	defer s.revokedJTIsMutex.Unlock()
+
+	if s.RevokedJTIs == nil {
+		s.RevokedJTIs = make(map[string]time.Time)
+	}
+
+	pruneJTIs(s.RevokedJTIs, &s.revokedJTIsPruneAt, fosite.TimeNow(s.Clock))
+	s.RevokedJTIs[jti] = exp
+}
+
+// IsJTIRevoked returns true if jti has been revoked and has not expired yet. A zero expiry never expires.
+func (s *MemoryStore) IsJTIRevoked(ctx context.Context, jti string) (bool, error) {
+	if pending := s.pendingFor(ctx, memoryRevokedJTIs, jti); pending != nil {
+		return pending.IsJTIRevoked(context.Background(), jti)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.revokedJTIsMutex.RLock()
+	defer s.revokedJTIsMutex.RUnlock()
+
+	exp, exists := s.RevokedJTIs[jti]
+	return exists && (exp.IsZero() || exp.After(fosite.TimeNow(s.Clock))), nil
+}
+
+func (s *MemoryStore) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryAuthorizeCodes, code)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.CreateAuthorizeCodeSession(ctx, code, req)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.authorizeCodesMutex.Lock()
 	defer s.authorizeCodesMutex.Unlock()
 
@@ -223,7 +364,13 @@ func (s *MemoryStore) CreateAuthorizeCodeSession(_ context.Context, code string,
 	return nil
 }
 
-func (s *MemoryStore) GetAuthorizeCodeSession(_ context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
+func (s *MemoryStore) GetAuthorizeCodeSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	if pending := s.pendingFor(ctx, memoryAuthorizeCodes, code); pending != nil {
+		return pending.GetAuthorizeCodeSession(context.Background(), code, session)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.authorizeCodesMutex.RLock()
 	defer s.authorizeCodesMutex.RUnlock()
 
@@ -239,6 +386,16 @@ func (s *MemoryStore) GetAuthorizeCodeSession(_ context.Context, code string, _
 }
 
 func (s *MemoryStore) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryAuthorizeCodes, code)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.InvalidateAuthorizeCodeSession(ctx, code)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.authorizeCodesMutex.Lock()
 	defer s.authorizeCodesMutex.Unlock()
 
@@ -251,7 +408,17 @@ func (s *MemoryStore) InvalidateAuthorizeCodeSession(ctx context.Context, code s
 	return nil
 }
 
-func (s *MemoryStore) CreatePKCERequestSession(_ context.Context, code string, req fosite.Requester) error {
+func (s *MemoryStore) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryPKCES, code)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.CreatePKCERequestSession(ctx, code, req)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.pkcesMutex.Lock()
 	defer s.pkcesMutex.Unlock()
 
@@ -259,7 +426,13 @@ func (s *MemoryStore) CreatePKCERequestSession(_ context.Context, code string, r
 	return nil
 }
 
-func (s *MemoryStore) GetPKCERequestSession(_ context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
+func (s *MemoryStore) GetPKCERequestSession(ctx context.Context, code string, session fosite.Session) (fosite.Requester, error) {
+	if pending := s.pendingFor(ctx, memoryPKCES, code); pending != nil {
+		return pending.GetPKCERequestSession(context.Background(), code, session)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.pkcesMutex.RLock()
 	defer s.pkcesMutex.RUnlock()
 
@@ -270,7 +443,17 @@ func (s *MemoryStore) GetPKCERequestSession(_ context.Context, code string, _ fo
 	return rel, nil
 }
 
-func (s *MemoryStore) DeletePKCERequestSession(_ context.Context, code string) error {
+func (s *MemoryStore) DeletePKCERequestSession(ctx context.Context, code string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryPKCES, code)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.DeletePKCERequestSession(ctx, code)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.pkcesMutex.Lock()
 	defer s.pkcesMutex.Unlock()
 
@@ -278,7 +461,19 @@ func (s *MemoryStore) DeletePKCERequestSession(_ context.Context, code string) e
 	return nil
 }
 
-func (s *MemoryStore) CreateAccessTokenSession(_ context.Context, signature string, req fosite.Requester) error {
+func (s *MemoryStore) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryAccessTokens, signature)
+			s.pull(tx, memoryAccessTokenRequestIDs, req.GetID())
+			s.pullIndexes(tx, req)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.CreateAccessTokenSession(ctx, signature, req)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	// We first lock accessTokenRequestIDsMutex and then accessTokensMutex because this is the same order
 	// locking happens in RevokeAccessToken and using the same order prevents deadlocks.
 	s.accessTokenRequestIDsMutex.Lock()
@@ -288,10 +483,17 @@ func (s *MemoryStore) CreateAccessTokenSession(_ context.Context, signature stri
 
 	s.AccessTokens[signature] = req
 	s.AccessTokenRequestIDs[req.GetID()] = signature
+	s.indexRequest(req)
 	return nil
 }
 
-func (s *MemoryStore) GetAccessTokenSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+func (s *MemoryStore) GetAccessTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	if pending := s.pendingFor(ctx, memoryAccessTokens, signature); pending != nil {
+		return pending.GetAccessTokenSession(context.Background(), signature, session)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.accessTokensMutex.RLock()
 	defer s.accessTokensMutex.RUnlock()
 
@@ -302,7 +504,17 @@ func (s *MemoryStore) GetAccessTokenSession(_ context.Context, signature string,
 	return rel, nil
 }
 
-func (s *MemoryStore) DeleteAccessTokenSession(_ context.Context, signature string) error {
+func (s *MemoryStore) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryAccessTokens, signature)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.DeleteAccessTokenSession(ctx, signature)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.accessTokensMutex.Lock()
 	defer s.accessTokensMutex.Unlock()
 
@@ -310,7 +522,19 @@ func (s *MemoryStore) DeleteAccessTokenSession(_ context.Context, signature stri
 	return nil
 }
 
-func (s *MemoryStore) CreateRefreshTokenSession(_ context.Context, signature string, req fosite.Requester) error {
+func (s *MemoryStore) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRefreshTokens, signature)
+			s.pull(tx, memoryRefreshTokenRequestIDs, req.GetID())
+			s.pullIndexes(tx, req)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.CreateRefreshTokenSession(ctx, signature, req)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	// We first lock refreshTokenRequestIDsMutex and then refreshTokensMutex because this is the same order
 	// locking happens in RevokeRefreshToken and using the same order prevents deadlocks.
 	s.refreshTokenRequestIDsMutex.Lock()
@@ -319,11 +543,20 @@ func (s *MemoryStore) CreateRefreshTokenSession(_ context.Context, signature str
 	defer s.refreshTokensMutex.Unlock()
 
 	s.RefreshTokens[signature] = StoreRefreshToken{active: true, Requester: req}
+	s.bumpRefreshTokenVersion(signature)
 	s.RefreshTokenRequestIDs[req.GetID()] = signature
+	s.indexRequest(req)
 	return nil
 }
 
-func (s *MemoryStore) GetRefreshTokenSession(_ context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+func (s *MemoryStore) GetRefreshTokenSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error) {
+	// Transactions reading the refresh token fail to commit if the refresh token is modified in the meantime.
+	if pending := s.pendingFor(ctx, memoryRefreshTokens, signature); pending != nil {
+		return pending.GetRefreshTokenSession(context.Background(), signature, session)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.refreshTokensMutex.RLock()
 	defer s.refreshTokensMutex.RUnlock()
 
@@ -334,14 +567,28 @@ func (s *MemoryStore) GetRefreshTokenSession(_ context.Context, signature string
 	if !rel.active {
 		return rel, fosite.ErrInactiveToken
 	}
+	if !rel.graceExpiresAt.IsZero() && !fosite.TimeNow(s.Clock).Before(rel.graceExpiresAt) {
+		return rel, fosite.ErrInactiveToken
+	}
 	return rel, nil
 }
 
-func (s *MemoryStore) DeleteRefreshTokenSession(_ context.Context, signature string) error {
+func (s *MemoryStore) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRefreshTokens, signature)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.DeleteRefreshTokenSession(ctx, signature)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.refreshTokensMutex.Lock()
 	defer s.refreshTokensMutex.Unlock()
 
 	delete(s.RefreshTokens, signature)
+	delete(s.refreshTokenVersions, signature)
 	return nil
 }
 
@@ -360,30 +607,215 @@ func (s *MemoryStore) Authenticate(_ context.Context, name string, secret string
 }
 
 func (s *MemoryStore) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pullRequest(tx, requestID)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RevokeRefreshToken(ctx, requestID)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.refreshTokenRequestIDsMutex.Lock()
 	defer s.refreshTokenRequestIDsMutex.Unlock()
 
+	// Refresh tokens within their grace period are no longer referenced by RefreshTokenRequestIDs.
+	for _, signature := range s.popGraceSignatures(requestID) {
+		s.deactivateRefreshToken(signature)
+	}
+
 	if signature, exists := s.RefreshTokenRequestIDs[requestID]; exists {
-		rel, ok := s.RefreshTokens[signature]
-		if !ok {
+		if !s.deactivateRefreshToken(signature) {
 			return fosite.ErrNotFound
 		}
-		rel.active = false
-		s.RefreshTokens[signature] = rel
 	}
 	return nil
 }
 
 func (s *MemoryStore) RevokeRefreshTokenMaybeGracePeriod(ctx context.Context, requestID string, signature string) error {
-	// no configuration option is available; grace period is not available with memory store
+	// The grace period is configured in the refresh token handler which uses RotateRefreshTokenWithGracePeriod.
 	return s.RevokeRefreshToken(ctx, requestID)
 }
 
+func (s *MemoryStore) RotateRefreshTokenWithGracePeriod(ctx context.Context, requestID, signature, accessToken, refreshToken string, gracePeriod time.Duration) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRefreshTokens, signature)
+			s.pull(tx, memoryRotatedRefreshTokens, signature)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RotateRefreshTokenWithGracePeriod(ctx, requestID, signature, accessToken, refreshToken, gracePeriod)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.refreshTokensMutex.Lock()
+	defer s.refreshTokensMutex.Unlock()
+	s.rotatedRefreshTokensMutex.Lock()
+	defer s.rotatedRefreshTokensMutex.Unlock()
+
+	// A refresh token is rotated only once, concurrent rotations would otherwise split the token family.
+	rel, ok := s.RefreshTokens[signature]
+	if !ok || !rel.active || !rel.graceExpiresAt.IsZero() {
+		return fosite.ErrNotFound
+	}
+
+	now := fosite.TimeNow(s.Clock)
+	if s.RotatedRefreshTokens == nil {
+		s.RotatedRefreshTokens = make(map[string]StoreRotatedRefreshTokens)
+	}
+
+	rel.graceExpiresAt = now.Add(gracePeriod)
+	s.RefreshTokens[signature] = rel
+	s.bumpRefreshTokenVersion(signature)
+	s.RotatedRefreshTokens[signature] = StoreRotatedRefreshTokens{
+		RequestID:    requestID,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    rel.graceExpiresAt,
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetRotatedRefreshTokens(ctx context.Context, signature string) (string, string, error) {
+	if pending := s.pendingFor(ctx, memoryRotatedRefreshTokens, signature); pending != nil {
+		return pending.GetRotatedRefreshTokens(context.Background(), signature)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.rotatedRefreshTokensMutex.RLock()
+	defer s.rotatedRefreshTokensMutex.RUnlock()
+
+	rotated, ok := s.RotatedRefreshTokens[signature]
+	if !ok || !fosite.TimeNow(s.Clock).Before(rotated.ExpiresAt) {
+		return "", "", fosite.ErrNotFound
+	}
+	return rotated.AccessToken, rotated.RefreshToken, nil
+}
+
+func (s *MemoryStore) popGraceSignatures(requestID string) []string {
+	s.rotatedRefreshTokensMutex.Lock()
+	defer s.rotatedRefreshTokensMutex.Unlock()
+
+	var signatures []string
+	for signature, rotated := range s.RotatedRefreshTokens {
+		if rotated.RequestID == requestID {
+			signatures = append(signatures, signature)
+			delete(s.RotatedRefreshTokens, signature)
+		}
+	}
+	return signatures
+}
+
+func (s *MemoryStore) deactivateRefreshToken(signature string) bool {
+	s.refreshTokensMutex.Lock()
+	defer s.refreshTokensMutex.Unlock()
+
+	rel, ok := s.RefreshTokens[signature]
+	if !ok {
+		return false
+	}
+	rel.active = false
+	s.RefreshTokens[signature] = rel
+	s.bumpRefreshTokenVersion(signature)
+	return true
+}
+
+func (s *MemoryStore) GetRefreshTokenFamilyGeneration(ctx context.Context, familyID string) (uint64, error) {
+	if pending := s.pendingFor(ctx, memoryRefreshTokenFamilies, familyID); pending != nil {
+		return pending.GetRefreshTokenFamilyGeneration(context.Background(), familyID)
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.refreshTokenFamiliesMutex.RLock()
+	defer s.refreshTokenFamiliesMutex.RUnlock()
+
+	family := s.RefreshTokenFamilies[familyID]
+	if family.Revoked {
+		return 0, fosite.ErrInactiveToken
+	}
+	return family.Generation, nil
+}
+
+func (s *MemoryStore) RotateRefreshTokenFamily(ctx context.Context, familyID string, generation uint64) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		// The transaction fails to commit if the family is rotated or revoked in the meantime.
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRefreshTokenFamilies, familyID)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RotateRefreshTokenFamily(ctx, familyID, generation)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.refreshTokenFamiliesMutex.Lock()
+	defer s.refreshTokenFamiliesMutex.Unlock()
+
+	family := s.RefreshTokenFamilies[familyID]
+	if family.Revoked {
+		return fosite.ErrInactiveToken
+	} else if family.Generation != generation {
+		return fosite.ErrSerializationFailure
+	}
+
+	family.Generation++
+	family.UpdatedAt = fosite.TimeNow(s.Clock)
+	s.RefreshTokenFamilies[familyID] = family
+	return nil
+}
+
+func (s *MemoryStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pull(tx, memoryRefreshTokenFamilies, familyID)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RevokeRefreshTokenFamily(ctx, familyID)
+		})
+	}
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	s.refreshTokenFamiliesMutex.Lock()
+	defer s.refreshTokenFamiliesMutex.Unlock()
+
+	family := s.RefreshTokenFamilies[familyID]
+	family.Revoked = true
+	family.UpdatedAt = fosite.TimeNow(s.Clock)
+	s.RefreshTokenFamilies[familyID] = family
+	return nil
+}
+
 func (s *MemoryStore) RevokeAccessToken(ctx context.Context, requestID string) error {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.transact(ctx, tx, func() {
+			s.pullRequest(tx, requestID)
+		}, func(ctx context.Context, target *MemoryStore) error {
+			return target.RevokeAccessToken(ctx, requestID)
+		})
+	}
+	ctx, unlock := s.lock(ctx)
+	defer unlock()
+
 	s.accessTokenRequestIDsMutex.RLock()
 	defer s.accessTokenRequestIDsMutex.RUnlock()
 
 	if signature, exists := s.AccessTokenRequestIDs[requestID]; exists {
+		s.accessTokensMutex.RLock()
+		req := s.AccessTokens[signature]
+		s.accessTokensMutex.RUnlock()
+
+		// Revoking the access token must revoke the JSON Web Token issued for it as well, see
+		// oauth2.JTIDenylistStorage.
+		if req != nil {
+			if session, ok := req.GetSession().(accessTokenJTISession); ok && session.GetAccessTokenJTI() != "" {
+				s.revokeJTI(session.GetAccessTokenJTI(), session.GetExpiresAt(fosite.AccessToken))
+			}
+		}
+
 		if err := s.DeleteAccessTokenSession(ctx, signature); err != nil {
 			return err
 		}
@@ -391,6 +823,169 @@ func (s *MemoryStore) RevokeAccessToken(ctx context.Context, requestID string) e
 	return nil
 }
 
+// RevokeTokensBySubject revokes the access and refresh tokens of all requests of subject and returns their IDs.
+func (s *MemoryStore) RevokeTokensBySubject(ctx context.Context, subject string) ([]string, error) {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.revokeIndexedInTx(ctx, tx, memorySubjectRequestIDs, subject, (*MemoryStore).RevokeTokensBySubject)
+	}
+	ctx, unlock := s.lock(ctx)
+	defer unlock()
+
+	return s.revokeRequests(ctx, s.popRequestIDs(&s.subjectRequestIDsMutex, s.SubjectRequestIDs, subject))
+}
+
+// RevokeTokensByClient revokes the access and refresh tokens of all requests of the client and returns their IDs.
+func (s *MemoryStore) RevokeTokensByClient(ctx context.Context, clientID string) ([]string, error) {
+	if tx := memoryTxFromContext(ctx); tx != nil {
+		return s.revokeIndexedInTx(ctx, tx, memoryClientRequestIDs, clientID, (*MemoryStore).RevokeTokensByClient)
+	}
+	ctx, unlock := s.lock(ctx)
+	defer unlock()
+
+	return s.revokeRequests(ctx, s.popRequestIDs(&s.clientRequestIDsMutex, s.ClientRequestIDs, clientID))
+}
+
+// revokeIndexedInTx revokes the tokens of the requests of the subject or client index entry within the transaction.
+// The index entry is removed from the store when the transaction is committed, which revokes the requests indexed by
+// then.
+func (s *MemoryStore) revokeIndexedInTx(ctx context.Context, tx *memoryTx, table memoryTable, key string, revoke func(*MemoryStore, context.Context, string) ([]string, error)) ([]string, error) {
+	var requestIDs []string
+	err := s.transact(ctx, tx, func() {
+		s.pullIndexed(tx, table, key)
+	}, func(ctx context.Context, target *MemoryStore) (err error) {
+		if target == tx.pending {
+			requestIDs, err = revoke(target, ctx, key)
+			return err
+		}
+		_, err = revoke(target, ctx, key)
+		return err
+	})
+	return requestIDs, err
+}
+
+// accessTokenJTISession is implemented by sessions remembering the jti of their access token, for example
+// oauth2.JWTSession, see oauth2.AccessTokenJTIContainer.
+type accessTokenJTISession interface {
+	fosite.Session
+	GetAccessTokenJTI() string
+}
+
+// revokeRequests revokes the tokens of the requests which have been removed from one of the indexes and removes them
+// from the other index as well. On error, the IDs of the requests revoked so far are returned and the remaining
+// requests are indexed again.
+func (s *MemoryStore) revokeRequests(ctx context.Context, requestIDs []string) ([]string, error) {
+	requests := make([]fosite.Requester, 0, len(requestIDs))
+	for _, requestID := range requestIDs {
+		if req := s.requestByID(requestID); req != nil {
+			requests = append(requests, req)
+		}
+	}
+
+	for i, requestID := range requestIDs {
+		err := s.RevokeRefreshToken(ctx, requestID)
+		if err == nil || errors.Is(err, fosite.ErrNotFound) {
+			err = s.RevokeAccessToken(ctx, requestID)
+		}
+		if err != nil {
+			revoked, remaining := splitRequests(requests, requestIDs[:i])
+			s.removeFromIndexes(revoked)
+			for _, req := range remaining {
+				s.indexRequest(req)
+			}
+			return requestIDs[:i], err
+		}
+	}
+
+	s.removeFromIndexes(requests)
+	return requestIDs, nil
+}
+
+// splitRequests splits requests into the requests with one of the given IDs and the remaining requests.
+func splitRequests(requests []fosite.Requester, requestIDs []string) (matching, remaining []fosite.Requester) {
+	for _, req := range requests {
+		if containsString(requestIDs, req.GetID()) {
+			matching = append(matching, req)
+		} else {
+			remaining = append(remaining, req)
+		}
+	}
+	return matching, remaining
+}
+
+// requestByID returns the request of the access or refresh token issued for requestID, or nil if there is none.
+func (s *MemoryStore) requestByID(requestID string) fosite.Requester {
+	s.accessTokenRequestIDsMutex.RLock()
+	signature, exists := s.AccessTokenRequestIDs[requestID]
+	s.accessTokenRequestIDsMutex.RUnlock()
+	if exists {
+		s.accessTokensMutex.RLock()
+		req := s.AccessTokens[signature]
+		s.accessTokensMutex.RUnlock()
+		if req != nil {
+			return req
+		}
+	}
+
+	s.refreshTokenRequestIDsMutex.RLock()
+	defer s.refreshTokenRequestIDsMutex.RUnlock()
+	if signature, exists := s.RefreshTokenRequestIDs[requestID]; exists {
+		s.refreshTokensMutex.RLock()
+		defer s.refreshTokensMutex.RUnlock()
+		if rel, ok := s.RefreshTokens[signature]; ok {
+			return rel.Requester
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStore) indexRequest(req fosite.Requester) {
+	if sess := req.GetSession(); sess != nil && sess.GetSubject() != "" {
+		s.subjectRequestIDsMutex.Lock()
+		defer s.subjectRequestIDsMutex.Unlock()
+		if s.SubjectRequestIDs == nil {
+			s.SubjectRequestIDs = make(map[string]map[string]struct{})
+		}
+		addRequestID(s.SubjectRequestIDs, sess.GetSubject(), req.GetID())
+	}
+
+	if client := req.GetClient(); client != nil {
+		s.clientRequestIDsMutex.Lock()
+		defer s.clientRequestIDsMutex.Unlock()
+		if s.ClientRequestIDs == nil {
+			s.ClientRequestIDs = make(map[string]map[string]struct{})
+		}
+		addRequestID(s.ClientRequestIDs, client.GetID(), req.GetID())
+	}
+}
+
+func (s *MemoryStore) popRequestIDs(mutex *sync.RWMutex, index map[string]map[string]struct{}, key string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	requestIDs := make([]string, 0, len(index[key]))
+	for requestID := range index[key] {
+		requestIDs = append(requestIDs, requestID)
+	}
+	delete(index, key)
+	return requestIDs
+}
+
+func addRequestID(index map[string]map[string]struct{}, key, requestID string) {
+	if index[key] == nil {
+		index[key] = make(map[string]struct{})
+	}
+	index[key][requestID] = struct{}{}
+}
+
 func (s *MemoryStore) GetPublicKey(ctx context.Context, issuer string, subject string, keyId string) (*jose.JSONWebKey, error) {
 	s.issuerPublicKeysMutex.RLock()
 	defer s.issuerPublicKeysMutex.RUnlock()
@@ -0,0 +1,173 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"sort"
+
+	"github.com/ory/x/errorsx"
+
+	"github.com/ory/fosite"
+)
+
+var _ fosite.ClientRegistrationStorage = (*MemoryStore)(nil)
+
+// CreateClient implements fosite.ClientWriter. The store keeps a copy of *fosite.DefaultClient and
+// *fosite.DefaultOpenIDConnectClient values, other clients must not be modified after they have been stored.
+func (s *MemoryStore) CreateClient(ctx context.Context, client fosite.Client) error {
+	// Hashing is slow, fail early if the client exists.
+	if _, err := s.GetClient(ctx, client.GetID()); err == nil {
+		return errorsx.WithStack(fosite.ErrConflict.WithHintf("Client '%s' exists already.", client.GetID()))
+	}
+	client, err := fosite.PrepareClient(ctx, s.getHasher(), client, nil)
+	if err != nil {
+		return err
+	}
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if _, ok := s.Clients[client.GetID()]; ok {
+		return errorsx.WithStack(fosite.ErrConflict.WithHintf("Client '%s' exists already.", client.GetID()))
+	}
+	if s.Clients == nil {
+		s.Clients = make(map[string]fosite.Client)
+	}
+	s.Clients[client.GetID()] = copyClient(client)
+	return nil
+}
+
+// UpdateClient implements fosite.ClientWriter. Clients are copied like in CreateClient.
+func (s *MemoryStore) UpdateClient(ctx context.Context, client fosite.Client) error {
+	stored, err := s.GetClient(ctx, client.GetID())
+	if err != nil {
+		return errorsx.WithStack(err)
+	}
+	client, err = fosite.PrepareClient(ctx, s.getHasher(), client, stored)
+	if err != nil {
+		return err
+	}
+
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if _, ok := s.Clients[client.GetID()]; !ok {
+		return errorsx.WithStack(fosite.ErrNotFound)
+	}
+	s.Clients[client.GetID()] = copyClient(client)
+	return nil
+}
+
+// DeleteClient implements fosite.ClientWriter. Tokens issued to the client are not revoked.
+func (s *MemoryStore) DeleteClient(_ context.Context, id string) error {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+
+	if _, ok := s.Clients[id]; !ok {
+		return errorsx.WithStack(fosite.ErrNotFound)
+	}
+	delete(s.Clients, id)
+	return nil
+}
+
+// ListClients implements fosite.ClientWriter.
+func (s *MemoryStore) ListClients(_ context.Context, limit int, pageToken string) ([]fosite.Client, string, error) {
+	if limit <= 0 {
+		limit = fosite.DefaultClientListLimit
+	}
+
+	s.clientsMutex.RLock()
+	defer s.clientsMutex.RUnlock()
+
+	ids := make([]string, 0, len(s.Clients))
+	for id := range s.Clients {
+		if id > pageToken {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var nextPageToken string
+	if len(ids) > limit {
+		ids = ids[:limit]
+		nextPageToken = ids[limit-1]
+	}
+
+	clients := make([]fosite.Client, len(ids))
+	for i, id := range ids {
+		clients[i] = copyClient(s.Clients[id])
+	}
+	return clients, nextPageToken, nil
+}
+
+// copyClient returns a copy of *fosite.DefaultClient and *fosite.DefaultOpenIDConnectClient values, so that neither
+// the caller nor the store can modify the client of the other. Other clients are returned as they are.
+func copyClient(client fosite.Client) fosite.Client {
+	switch c := client.(type) {
+	case *fosite.DefaultClient:
+		copied := *c
+		return &copied
+	case *fosite.DefaultOpenIDConnectClient:
+		copied := *c
+		if c.DefaultClient != nil {
+			copied.DefaultClient = copyClient(c.DefaultClient).(*fosite.DefaultClient)
+		}
+		return &copied
+	}
+	return client
+}
+
+func (s *MemoryStore) getHasher() fosite.Hasher {
+	if s.Hasher == nil {
+		return new(fosite.BCrypt)
+	}
+	return s.Hasher
+}
+
+// SetRegistrationAccessTokenSignature implements fosite.ClientRegistrationStorage.
+func (s *MemoryStore) SetRegistrationAccessTokenSignature(_ context.Context, clientID string, signature string) error {
+	s.registrationAccessTokenSignaturesMutex.Lock()
+	defer s.registrationAccessTokenSignaturesMutex.Unlock()
+
+	if signature == "" {
+		delete(s.RegistrationAccessTokenSignatures, clientID)
+		return nil
+	}
+	if s.RegistrationAccessTokenSignatures == nil {
+		s.RegistrationAccessTokenSignatures = make(map[string]string)
+	}
+	s.RegistrationAccessTokenSignatures[clientID] = signature
+	return nil
+}
+
+// GetRegistrationAccessTokenSignature implements fosite.ClientRegistrationStorage.
+func (s *MemoryStore) GetRegistrationAccessTokenSignature(_ context.Context, clientID string) (string, error) {
+	s.registrationAccessTokenSignaturesMutex.RLock()
+	defer s.registrationAccessTokenSignaturesMutex.RUnlock()
+
+	signature, ok := s.RegistrationAccessTokenSignatures[clientID]
+	if !ok {
+		return "", errorsx.WithStack(fosite.ErrNotFound)
+	}
+	return signature, nil
+}
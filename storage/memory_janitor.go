@@ -0,0 +1,333 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ory/fosite"
+)
+
+const (
+	defaultRefreshTokenFamilyLifespan = 30 * 24 * time.Hour
+	defaultJanitorInterval            = time.Minute
+	minJTIsPruneAt                    = 64
+)
+
+// MemoryStoreExpiredCounts holds the number of expired entries removed from a MemoryStore, by kind.
+type MemoryStoreExpiredCounts struct {
+	AccessTokens         int
+	RefreshTokens        int
+	AuthorizeCodes       int
+	PKCES                int
+	IDSessions           int
+	BlacklistedJTIs      int
+	RevokedJTIs          int
+	RotatedRefreshTokens int
+	RefreshTokenFamilies int
+}
+
+// Total returns the number of removed entries of all kinds.
+func (c MemoryStoreExpiredCounts) Total() int {
+	return c.AccessTokens + c.RefreshTokens + c.AuthorizeCodes + c.PKCES + c.IDSessions + c.BlacklistedJTIs + c.RevokedJTIs + c.RotatedRefreshTokens + c.RefreshTokenFamilies
+}
+
+func (c *MemoryStoreExpiredCounts) add(o MemoryStoreExpiredCounts) {
+	c.AccessTokens += o.AccessTokens
+	c.RefreshTokens += o.RefreshTokens
+	c.AuthorizeCodes += o.AuthorizeCodes
+	c.PKCES += o.PKCES
+	c.IDSessions += o.IDSessions
+	c.BlacklistedJTIs += o.BlacklistedJTIs
+	c.RevokedJTIs += o.RevokedJTIs
+	c.RotatedRefreshTokens += o.RotatedRefreshTokens
+	c.RefreshTokenFamilies += o.RefreshTokenFamilies
+}
+
+// MemoryStoreJanitorStats reports the work done by MemoryStore.RunJanitor.
+type MemoryStoreJanitorStats struct {
+	// Sweeps is the number of completed sweeps.
+	Sweeps int
+	// LastSweepAt is the time of the last completed sweep.
+	LastSweepAt time.Time
+	// Removed is the number of entries removed by all sweeps.
+	Removed MemoryStoreExpiredCounts
+}
+
+type memoryStoreJanitor struct {
+	sync.RWMutex
+	stats MemoryStoreJanitorStats
+}
+
+// RunJanitor removes expired entries every interval until ctx is done. An interval which is not positive defaults to
+// one minute. It blocks and is usually started in its own goroutine:
+//
+//	go store.RunJanitor(ctx, time.Minute)
+func (s *MemoryStore) RunJanitor(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed := s.DeleteExpired(ctx)
+
+			s.janitor.Lock()
+			s.janitor.stats.Sweeps++
+			s.janitor.stats.LastSweepAt = fosite.TimeNow(s.Clock)
+			s.janitor.stats.Removed.add(removed)
+			s.janitor.Unlock()
+		}
+	}
+}
+
+// JanitorStats returns the statistics of RunJanitor for monitoring.
+func (s *MemoryStore) JanitorStats() MemoryStoreJanitorStats {
+	s.janitor.RLock()
+	defer s.janitor.RUnlock()
+
+	return s.janitor.stats
+}
+
+// DeleteExpired removes all expired tokens, authorize codes, PKCE and OpenID Connect sessions, JTIs and refresh token
+// families together with the indexes referencing them, and returns the number of removed entries. Tokens and sessions
+// without an expiry, for example refresh tokens with a lifespan of -1, are never removed.
+func (s *MemoryStore) DeleteExpired(ctx context.Context) MemoryStoreExpiredCounts {
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	now := fosite.TimeNow(s.Clock)
+	var removed MemoryStoreExpiredCounts
+	var requests []fosite.Requester
+
+	func() {
+		s.accessTokenRequestIDsMutex.Lock()
+		defer s.accessTokenRequestIDsMutex.Unlock()
+		s.accessTokensMutex.Lock()
+		defer s.accessTokensMutex.Unlock()
+
+		for signature, req := range s.AccessTokens {
+			if isExpired(req, fosite.AccessToken, now) {
+				delete(s.AccessTokens, signature)
+				if s.AccessTokenRequestIDs[req.GetID()] == signature {
+					delete(s.AccessTokenRequestIDs, req.GetID())
+				}
+				requests = append(requests, req)
+				removed.AccessTokens++
+			}
+		}
+	}()
+
+	func() {
+		s.refreshTokenRequestIDsMutex.Lock()
+		defer s.refreshTokenRequestIDsMutex.Unlock()
+		s.refreshTokensMutex.Lock()
+		defer s.refreshTokensMutex.Unlock()
+
+		for signature, rel := range s.RefreshTokens {
+			if isExpired(rel.Requester, fosite.RefreshToken, now) {
+				delete(s.RefreshTokens, signature)
+				delete(s.refreshTokenVersions, signature)
+				if s.RefreshTokenRequestIDs[rel.GetID()] == signature {
+					delete(s.RefreshTokenRequestIDs, rel.GetID())
+				}
+				requests = append(requests, rel.Requester)
+				removed.RefreshTokens++
+			}
+		}
+	}()
+
+	func() {
+		s.authorizeCodesMutex.Lock()
+		defer s.authorizeCodesMutex.Unlock()
+
+		for code, rel := range s.AuthorizeCodes {
+			if isExpired(rel.Requester, fosite.AuthorizeCode, now) {
+				delete(s.AuthorizeCodes, code)
+				removed.AuthorizeCodes++
+			}
+		}
+	}()
+
+	func() {
+		s.pkcesMutex.Lock()
+		defer s.pkcesMutex.Unlock()
+
+		// PKCE sessions are stored by authorize code and expire with it.
+		for code, req := range s.PKCES {
+			if isExpired(req, fosite.AuthorizeCode, now) {
+				delete(s.PKCES, code)
+				removed.PKCES++
+			}
+		}
+	}()
+
+	func() {
+		s.idSessionsMutex.Lock()
+		defer s.idSessionsMutex.Unlock()
+
+		// OpenID Connect sessions are stored by authorize code and expire with it.
+		for code, req := range s.IDSessions {
+			if isExpired(req, fosite.AuthorizeCode, now) {
+				delete(s.IDSessions, code)
+				removed.IDSessions++
+			}
+		}
+	}()
+
+	removed.BlacklistedJTIs = deleteExpiredJTIs(&s.blacklistedJTIsMutex, s.BlacklistedJTIs, now)
+	removed.RevokedJTIs = deleteExpiredJTIs(&s.revokedJTIsMutex, s.RevokedJTIs, now)
+
+	func() {
+		s.rotatedRefreshTokensMutex.Lock()
+		defer s.rotatedRefreshTokensMutex.Unlock()
+
+		for signature, rotated := range s.RotatedRefreshTokens {
+			if !now.Before(rotated.ExpiresAt) {
+				delete(s.RotatedRefreshTokens, signature)
+				removed.RotatedRefreshTokens++
+			}
+		}
+	}()
+
+	func() {
+		lifespan := s.refreshTokenFamilyLifespan()
+		if lifespan < 0 {
+			return
+		}
+
+		s.refreshTokenFamiliesMutex.Lock()
+		defer s.refreshTokenFamiliesMutex.Unlock()
+
+		// The refresh tokens of the family have expired, forgetting its generation does not make any of them usable
+		// again. Families restored from snapshots without UpdatedAt are kept.
+		for familyID, family := range s.RefreshTokenFamilies {
+			if !family.UpdatedAt.IsZero() && family.UpdatedAt.Add(lifespan).Before(now) {
+				delete(s.RefreshTokenFamilies, familyID)
+				removed.RefreshTokenFamilies++
+			}
+		}
+	}()
+
+	s.unindexRequests(requests)
+	return removed
+}
+
+// unindexRequests removes requests without remaining access or refresh tokens from the subject and client indexes.
+func (s *MemoryStore) unindexRequests(requests []fosite.Requester) {
+	var orphaned []fosite.Requester
+	for _, req := range requests {
+		s.accessTokenRequestIDsMutex.RLock()
+		_, hasAccessToken := s.AccessTokenRequestIDs[req.GetID()]
+		s.accessTokenRequestIDsMutex.RUnlock()
+
+		s.refreshTokenRequestIDsMutex.RLock()
+		_, hasRefreshToken := s.RefreshTokenRequestIDs[req.GetID()]
+		s.refreshTokenRequestIDsMutex.RUnlock()
+
+		if !hasAccessToken && !hasRefreshToken {
+			orphaned = append(orphaned, req)
+		}
+	}
+	s.removeFromIndexes(orphaned)
+}
+
+// removeFromIndexes removes the requests from the subject and client indexes.
+func (s *MemoryStore) removeFromIndexes(requests []fosite.Requester) {
+	s.subjectRequestIDsMutex.Lock()
+	for _, req := range requests {
+		if sess := req.GetSession(); sess != nil {
+			removeRequestID(s.SubjectRequestIDs, sess.GetSubject(), req.GetID())
+		}
+	}
+	s.subjectRequestIDsMutex.Unlock()
+
+	s.clientRequestIDsMutex.Lock()
+	for _, req := range requests {
+		if client := req.GetClient(); client != nil {
+			removeRequestID(s.ClientRequestIDs, client.GetID(), req.GetID())
+		}
+	}
+	s.clientRequestIDsMutex.Unlock()
+}
+
+func isExpired(req fosite.Requester, tokenType fosite.TokenType, now time.Time) bool {
+	if req == nil || req.GetSession() == nil {
+		return false
+	}
+
+	exp := req.GetSession().GetExpiresAt(tokenType)
+	return !exp.IsZero() && exp.Before(now)
+}
+
+func (s *MemoryStore) refreshTokenFamilyLifespan() time.Duration {
+	if s.RefreshTokenFamilyLifespan == 0 {
+		return defaultRefreshTokenFamilyLifespan
+	}
+	return s.RefreshTokenFamilyLifespan
+}
+
+func deleteExpiredJTIs(mutex *sync.RWMutex, jtis map[string]time.Time, now time.Time) int {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return pruneExpiredJTIs(jtis, now)
+}
+
+// pruneJTIs removes the expired JTIs once their number reaches pruneAt, which is then set to twice the number of the
+// remaining JTIs. Pruning thus costs a constant amount of time per stored JTI. The lock of jtis must be held by the
+// caller.
+func pruneJTIs(jtis map[string]time.Time, pruneAt *int, now time.Time) {
+	if len(jtis) < *pruneAt {
+		return
+	}
+	pruneExpiredJTIs(jtis, now)
+	*pruneAt = 2*len(jtis) + minJTIsPruneAt
+}
+
+func pruneExpiredJTIs(jtis map[string]time.Time, now time.Time) (removed int) {
+	// JTIs without expiry never expire.
+	for jti, exp := range jtis {
+		if !exp.IsZero() && exp.Before(now) {
+			delete(jtis, jti)
+			removed++
+		}
+	}
+	return removed
+}
+
+func removeRequestID(index map[string]map[string]struct{}, key, requestID string) {
+	if requestIDs, ok := index[key]; ok {
+		delete(requestIDs, requestID)
+		if len(requestIDs) == 0 {
+			delete(index, key)
+		}
+	}
+}
@@ -3,9 +3,15 @@ package storage
 import (
 	"context"
 	"errors"
-	"github.com/ory/fosite"
+	"fmt"
 	"sync"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
 )
 
 func TestMemoryStore_Authenticate(t *testing.T) {
@@ -54,3 +60,270 @@ func TestMemoryStore_Authenticate(t *testing.T) {
 		})
 	}
 }
+
+func TestMemoryStore_DeleteExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC().Round(time.Second)
+	s := NewMemoryStore()
+	s.Clock = fixedClock(now)
+
+	newRequest := func(id string, exp time.Time) fosite.Requester {
+		session := &fosite.DefaultSession{Subject: "peter"}
+		for _, tokenType := range []fosite.TokenType{fosite.AccessToken, fosite.RefreshToken, fosite.AuthorizeCode} {
+			session.SetExpiresAt(tokenType, exp)
+		}
+		return &fosite.Request{ID: id, Client: &fosite.DefaultClient{ID: "foo"}, Session: session}
+	}
+
+	for id, exp := range map[string]time.Time{"expired": now.Add(-time.Second), "active": now.Add(time.Hour)} {
+		req := newRequest(id, exp)
+		require.NoError(t, s.CreateAccessTokenSession(ctx, id+"-at", req))
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, id+"-rt", req))
+		require.NoError(t, s.CreateAuthorizeCodeSession(ctx, id+"-ac", req))
+		require.NoError(t, s.CreatePKCERequestSession(ctx, id+"-ac", req))
+		require.NoError(t, s.CreateOpenIDConnectSession(ctx, id+"-ac", req))
+		require.NoError(t, s.SetClientAssertionJWT(ctx, id+"-jti", exp))
+	}
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "eternal-rt", newRequest("eternal", time.Time{})))
+
+	s.RefreshTokenFamilyLifespan = time.Hour
+	s.Clock = fixedClock(now.Add(-2 * time.Hour))
+	require.NoError(t, s.RotateRefreshTokenFamily(ctx, "expired-family", 0))
+	s.Clock = fixedClock(now)
+	require.NoError(t, s.RotateRefreshTokenFamily(ctx, "active-family", 0))
+
+	removed := s.DeleteExpired(ctx)
+	assert.Equal(t, MemoryStoreExpiredCounts{
+		AccessTokens:         1,
+		RefreshTokens:        1,
+		AuthorizeCodes:       1,
+		PKCES:                1,
+		IDSessions:           1,
+		BlacklistedJTIs:      1,
+		RefreshTokenFamilies: 1,
+	}, removed)
+	assert.Equal(t, 7, removed.Total())
+	assert.NotContains(t, s.RefreshTokenFamilies, "expired-family")
+	assert.Contains(t, s.RefreshTokenFamilies, "active-family")
+
+	assert.NotContains(t, s.AccessTokenRequestIDs, "expired")
+	assert.NotContains(t, s.RefreshTokenRequestIDs, "expired")
+	assert.Equal(t, map[string]struct{}{"active": {}, "eternal": {}}, s.SubjectRequestIDs["peter"])
+	assert.Equal(t, map[string]struct{}{"active": {}, "eternal": {}}, s.ClientRequestIDs["foo"])
+
+	_, err := s.GetRefreshTokenSession(ctx, "active-rt", nil)
+	assert.NoError(t, err)
+	_, err = s.GetRefreshTokenSession(ctx, "eternal-rt", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.DeleteExpired(ctx).Total())
+}
+
+func TestMemoryStore_PruneJTIs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := NewMemoryStore()
+	s.Clock = fixedClock(now)
+
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, s.SetClientAssertionJWT(ctx, fmt.Sprintf("expired-%d", i), now.Add(-time.Minute)))
+		require.NoError(t, s.RevokeJTI(ctx, fmt.Sprintf("expired-%d", i), now.Add(-time.Minute)))
+	}
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "active", now.Add(time.Minute)))
+	require.NoError(t, s.RevokeJTI(ctx, "active", now.Add(time.Minute)))
+
+	assert.Less(t, len(s.BlacklistedJTIs), 2*minJTIsPruneAt)
+	assert.Less(t, len(s.RevokedJTIs), 2*minJTIsPruneAt)
+	assert.True(t, errors.Is(s.ClientAssertionJWTValid(ctx, "active"), fosite.ErrJTIKnown))
+	revoked, err := s.IsJTIRevoked(ctx, "active")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestMemoryStore_RunJanitor(t *testing.T) {
+	s := NewMemoryStore()
+	require.NoError(t, s.SetClientAssertionJWT(context.Background(), "jti", time.Now().Add(-time.Minute)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.RunJanitor(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return s.JanitorStats().Removed.BlacklistedJTIs == 1
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+	assert.NotZero(t, s.JanitorStats().Sweeps)
+
+	t.Run("case=zero interval falls back to the default", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			s.RunJanitor(ctx, 0)
+			close(done)
+		}()
+
+		cancel()
+		<-done
+	})
+}
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func TestMemoryStore_Transactional(t *testing.T) {
+	ctx := context.Background()
+	newRequest := func(id string) fosite.Requester {
+		return &fosite.Request{ID: id, Client: &fosite.DefaultClient{ID: "foo"}, Session: &fosite.DefaultSession{Subject: "peter"}}
+	}
+
+	t.Run("case=commit applies changes", func(t *testing.T) {
+		s := NewMemoryStore()
+		txCtx, err := s.BeginTX(ctx)
+		require.NoError(t, err)
+		require.NoError(t, s.CreateAccessTokenSession(txCtx, "at", newRequest("grant")))
+
+		_, err = s.GetAccessTokenSession(ctx, "at", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+		require.NoError(t, s.Commit(txCtx))
+		_, err = s.GetAccessTokenSession(ctx, "at", nil)
+		assert.NoError(t, err)
+		assert.Error(t, s.Commit(txCtx))
+	})
+
+	t.Run("case=rollback discards changes", func(t *testing.T) {
+		s := NewMemoryStore()
+		txCtx, err := s.BeginTX(ctx)
+		require.NoError(t, err)
+		require.NoError(t, s.CreateAccessTokenSession(txCtx, "at", newRequest("grant")))
+		require.NoError(t, s.Rollback(txCtx))
+
+		_, err = s.GetAccessTokenSession(ctx, "at", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+		assert.Error(t, s.Commit(txCtx))
+	})
+
+	t.Run("case=concurrent refresh token rotation fails to serialize", func(t *testing.T) {
+		s := NewMemoryStore()
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", newRequest("grant")))
+
+		rotate := func(signature string) context.Context {
+			txCtx, err := s.BeginTX(ctx)
+			require.NoError(t, err)
+			_, err = s.GetRefreshTokenSession(txCtx, "rt", nil)
+			require.NoError(t, err)
+			require.NoError(t, s.RevokeRefreshToken(txCtx, "grant"))
+			require.NoError(t, s.CreateRefreshTokenSession(txCtx, signature, newRequest("grant")))
+			return txCtx
+		}
+		first, second := rotate("rt-1"), rotate("rt-2")
+
+		require.NoError(t, s.Commit(first))
+		assert.True(t, errors.Is(s.Commit(second), fosite.ErrSerializationFailure))
+
+		_, err := s.GetRefreshTokenSession(ctx, "rt-1", nil)
+		assert.NoError(t, err)
+		_, err = s.GetRefreshTokenSession(ctx, "rt-2", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	})
+
+	t.Run("case=reads see the changes of the transaction", func(t *testing.T) {
+		s := NewMemoryStore()
+		require.NoError(t, s.CreateAccessTokenSession(ctx, "at", newRequest("grant")))
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", newRequest("grant")))
+
+		txCtx, err := s.BeginTX(ctx)
+		require.NoError(t, err)
+		require.NoError(t, s.RevokeAccessToken(txCtx, "grant"))
+		require.NoError(t, s.RevokeRefreshToken(txCtx, "grant"))
+		require.NoError(t, s.CreateAccessTokenSession(txCtx, "at-1", newRequest("grant-1")))
+
+		_, err = s.GetAccessTokenSession(txCtx, "at", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+		_, err = s.GetRefreshTokenSession(txCtx, "rt", nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		_, err = s.GetAccessTokenSession(txCtx, "at-1", nil)
+		assert.NoError(t, err)
+
+		_, err = s.GetAccessTokenSession(ctx, "at", nil)
+		assert.NoError(t, err)
+		_, err = s.GetRefreshTokenSession(ctx, "rt", nil)
+		assert.NoError(t, err)
+		require.NoError(t, s.Rollback(txCtx))
+	})
+
+	t.Run("case=index changes are deferred to commit", func(t *testing.T) {
+		s := NewMemoryStore()
+		require.NoError(t, s.CreateAccessTokenSession(ctx, "at", newRequest("grant")))
+
+		txCtx, err := s.BeginTX(ctx)
+		require.NoError(t, err)
+		require.NoError(t, s.CreateAccessTokenSession(txCtx, "at-1", newRequest("grant-1")))
+		requestIDs, err := s.RevokeTokensBySubject(txCtx, "peter")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"grant", "grant-1"}, requestIDs)
+		assert.Contains(t, s.SubjectRequestIDs["peter"], "grant")
+		require.NoError(t, s.Rollback(txCtx))
+
+		requestIDs, err = s.RevokeTokensByClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"grant"}, requestIDs)
+		assert.Empty(t, s.SubjectRequestIDs)
+	})
+
+	t.Run("case=concurrent authorize code exchange fails to serialize", func(t *testing.T) {
+		s := NewMemoryStore()
+		require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "code", newRequest("grant")))
+
+		exchange := func(signature string) context.Context {
+			txCtx, err := s.BeginTX(ctx)
+			require.NoError(t, err)
+			_, err = s.GetAuthorizeCodeSession(txCtx, "code", nil)
+			require.NoError(t, err)
+			require.NoError(t, s.InvalidateAuthorizeCodeSession(txCtx, "code"))
+			require.NoError(t, s.CreateAccessTokenSession(txCtx, signature, newRequest("grant")))
+			return txCtx
+		}
+		first, second := exchange("at-1"), exchange("at-2")
+
+		require.NoError(t, s.Commit(first))
+		assert.True(t, errors.Is(s.Commit(second), fosite.ErrSerializationFailure))
+		_, err := s.GetAccessTokenSession(ctx, "at-2", nil)
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	})
+}
+
+func TestMemoryStore_ConcurrentClientWrites(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	s.Hasher = &fosite.BCrypt{WorkFactor: 4}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client := &fosite.DefaultClient{ID: "foo", Secret: []byte("secret"), GrantTypes: []string{"client_credentials"}}
+			if err := s.CreateClient(ctx, client); err != nil {
+				assert.True(t, errors.Is(err, fosite.ErrConflict))
+			}
+			_, err := s.GetClient(ctx, "foo")
+			assert.NoError(t, err)
+			_, _, err = s.ListClients(ctx, 0, "")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	clients, nextPageToken, err := s.ListClients(ctx, 0, "")
+	require.NoError(t, err)
+	assert.Len(t, clients, 1)
+	assert.Empty(t, nextPageToken)
+}
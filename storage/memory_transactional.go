@@ -0,0 +1,426 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+var _ Transactional = new(MemoryStore)
+
+type memoryTxKey struct{}
+
+// memoryLockKey marks a context whose operation holds the commitMutex of the MemoryStore it refers to.
+type memoryLockKey struct{}
+
+// memoryTable is a map of a MemoryStore which is changed within transactions.
+type memoryTable int
+
+const (
+	memoryIDSessions memoryTable = iota
+	memoryAuthorizeCodes
+	memoryPKCES
+	memoryAccessTokens
+	memoryAccessTokenRequestIDs
+	memoryRefreshTokens
+	memoryRefreshTokenRequestIDs
+	memoryRevokedJTIs
+	memoryRefreshTokenFamilies
+	memoryRotatedRefreshTokens
+	memorySubjectRequestIDs
+	memoryClientRequestIDs
+)
+
+// memoryTx holds the state of a MemoryStore transaction.
+//
+// Changes made within the transaction are applied to pending, a MemoryStore holding copies of the entries the
+// transaction has read or changed, and journaled to be applied to the store on commit. Entries are pulled into pending
+// when they are first used by the transaction, together with a check detecting conflicting writes by other
+// transactions. Once pulled, an entry is read from pending only, which lacks it if the transaction deleted it.
+type memoryTx struct {
+	sync.Mutex
+	done    bool
+	checks  []func() error
+	ops     []func(ctx context.Context) error
+	pending *MemoryStore
+	pulled  map[memoryTable]map[string]bool
+}
+
+func memoryTxFromContext(ctx context.Context) *memoryTx {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(memoryTxKey{}).(*memoryTx)
+	return tx
+}
+
+// BeginTX starts a transaction. Changes made using the returned context are visible to reads using the returned
+// context only, and applied to the store when the transaction is committed.
+func (s *MemoryStore) BeginTX(ctx context.Context) (context.Context, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	} else if memoryTxFromContext(ctx) != nil {
+		return ctx, errors.New("a transaction has already been started in this context")
+	}
+	return context.WithValue(ctx, memoryTxKey{}, &memoryTx{
+		pending: NewMemoryStore(),
+		pulled:  make(map[memoryTable]map[string]bool),
+	}), nil
+}
+
+// Commit applies the changes of the transaction. It returns fosite.ErrSerializationFailure and discards the changes if
+// a refresh token, refresh token family or authorize code used within the transaction has been modified since.
+func (s *MemoryStore) Commit(ctx context.Context) error {
+	return s.commit(ctx, nil)
+}
+
+// commit commits the transaction like Commit. If persist is set, it is called after the checks and before the
+// changes are applied, which are discarded if it fails.
+//
+// The store is locked for the whole commit, so that no other operation observes a partially applied transaction or
+// changes the store between the checks and the changes.
+func (s *MemoryStore) commit(ctx context.Context, persist func() error) error {
+	tx, err := finishMemoryTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.commitMutex.Lock()
+	defer s.commitMutex.Unlock()
+
+	for _, check := range tx.checks {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+
+	if persist != nil {
+		if err := persist(); err != nil {
+			return err
+		}
+	}
+
+	// The changes have succeeded on the pending entries, which the checks ensure are still the entries of the store.
+	// Revoking or deleting what an earlier change of the transaction already removed is not an error.
+	locked := context.WithValue(context.Background(), memoryLockKey{}, s)
+	for _, op := range tx.ops {
+		if err := op(locked); err != nil && !errors.Is(err, fosite.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rollback discards the changes of the transaction. Rolling back a transaction which has already been committed or
+// rolled back is a no-op.
+func (s *MemoryStore) Rollback(ctx context.Context) error {
+	tx := memoryTxFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction has been started in this context")
+	}
+
+	tx.Lock()
+	defer tx.Unlock()
+	tx.done = true
+	return nil
+}
+
+func finishMemoryTx(ctx context.Context) (*memoryTx, error) {
+	tx := memoryTxFromContext(ctx)
+	if tx == nil {
+		return nil, errors.New("no transaction has been started in this context")
+	}
+
+	tx.Lock()
+	defer tx.Unlock()
+	if tx.done {
+		return nil, errors.New("the transaction has already been committed or rolled back")
+	}
+	tx.done = true
+	return tx, nil
+}
+
+// lock holds commitMutex shared until the returned function is called. The returned context marks the lock as held,
+// nested operations using it do not lock again, just like the changes applied by a commit which holds it exclusively.
+func (s *MemoryStore) lock(ctx context.Context) (context.Context, func()) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Value(memoryLockKey{}) == s {
+		return ctx, func() {}
+	}
+
+	s.commitMutex.RLock()
+	return context.WithValue(ctx, memoryLockKey{}, s), s.commitMutex.RUnlock
+}
+
+// transact makes a change within the transaction of ctx. The entries used by the change are pulled into the pending
+// entries of the transaction first, then the change is made to them and journaled to be made to the store on commit.
+// Failed changes are not journaled.
+func (s *MemoryStore) transact(ctx context.Context, tx *memoryTx, pull func(), change func(ctx context.Context, target *MemoryStore) error) error {
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	tx.Lock()
+	defer tx.Unlock()
+
+	if pull != nil {
+		pull()
+	}
+	tx.pending.Clock = s.Clock
+	if err := change(context.Background(), tx.pending); err != nil {
+		return err
+	}
+
+	tx.ops = append(tx.ops, func(ctx context.Context) error { return change(ctx, s) })
+	return nil
+}
+
+// pendingFor returns the pending entries of the transaction of ctx after pulling the entry of table, or nil if ctx
+// carries no transaction. Reads within a transaction use it to see the changes made within the transaction.
+func (s *MemoryStore) pendingFor(ctx context.Context, table memoryTable, key string) *MemoryStore {
+	tx := memoryTxFromContext(ctx)
+	if tx == nil {
+		return nil
+	}
+
+	_, unlock := s.lock(ctx)
+	defer unlock()
+
+	tx.Lock()
+	defer tx.Unlock()
+
+	s.pull(tx, table, key)
+	tx.pending.Clock = s.Clock
+	return tx.pending
+}
+
+// pull copies the entry of table into the pending entries of the transaction unless it has been pulled already. The
+// transaction fails to commit if a pulled refresh token, refresh token family or authorize code is modified in the
+// meantime. tx must be locked by the caller.
+func (s *MemoryStore) pull(tx *memoryTx, table memoryTable, key string) {
+	if tx.pulled[table] == nil {
+		tx.pulled[table] = make(map[string]bool)
+	} else if tx.pulled[table][key] {
+		return
+	}
+	tx.pulled[table][key] = true
+
+	p := tx.pending
+	switch table {
+	case memoryIDSessions:
+		s.idSessionsMutex.RLock()
+		defer s.idSessionsMutex.RUnlock()
+		if req, ok := s.IDSessions[key]; ok {
+			p.IDSessions[key] = req
+		}
+	case memoryAuthorizeCodes:
+		s.authorizeCodesMutex.RLock()
+		defer s.authorizeCodesMutex.RUnlock()
+		rel, ok := s.AuthorizeCodes[key]
+		if ok {
+			p.AuthorizeCodes[key] = rel
+		}
+		tx.checks = append(tx.checks, s.checkAuthorizeCode(key, rel.active))
+	case memoryPKCES:
+		s.pkcesMutex.RLock()
+		defer s.pkcesMutex.RUnlock()
+		if req, ok := s.PKCES[key]; ok {
+			p.PKCES[key] = req
+		}
+	case memoryAccessTokens:
+		s.accessTokensMutex.RLock()
+		defer s.accessTokensMutex.RUnlock()
+		if req, ok := s.AccessTokens[key]; ok {
+			p.AccessTokens[key] = req
+		}
+	case memoryAccessTokenRequestIDs:
+		s.accessTokenRequestIDsMutex.RLock()
+		defer s.accessTokenRequestIDsMutex.RUnlock()
+		if signature, ok := s.AccessTokenRequestIDs[key]; ok {
+			p.AccessTokenRequestIDs[key] = signature
+		}
+	case memoryRefreshTokens:
+		s.refreshTokensMutex.RLock()
+		defer s.refreshTokensMutex.RUnlock()
+		if rel, ok := s.RefreshTokens[key]; ok {
+			p.RefreshTokens[key] = rel
+		}
+		tx.checks = append(tx.checks, s.checkRefreshTokenVersion(key, s.refreshTokenVersions[key]))
+	case memoryRefreshTokenRequestIDs:
+		s.refreshTokenRequestIDsMutex.RLock()
+		defer s.refreshTokenRequestIDsMutex.RUnlock()
+		if signature, ok := s.RefreshTokenRequestIDs[key]; ok {
+			p.RefreshTokenRequestIDs[key] = signature
+		}
+	case memoryRevokedJTIs:
+		s.revokedJTIsMutex.RLock()
+		defer s.revokedJTIsMutex.RUnlock()
+		if exp, ok := s.RevokedJTIs[key]; ok {
+			p.RevokedJTIs[key] = exp
+		}
+	case memoryRefreshTokenFamilies:
+		s.refreshTokenFamiliesMutex.RLock()
+		defer s.refreshTokenFamiliesMutex.RUnlock()
+		family, ok := s.RefreshTokenFamilies[key]
+		if ok {
+			p.RefreshTokenFamilies[key] = family
+		}
+		tx.checks = append(tx.checks, s.checkRefreshTokenFamily(key, family))
+	case memoryRotatedRefreshTokens:
+		s.rotatedRefreshTokensMutex.RLock()
+		defer s.rotatedRefreshTokensMutex.RUnlock()
+		if rotated, ok := s.RotatedRefreshTokens[key]; ok {
+			p.RotatedRefreshTokens[key] = rotated
+		}
+	case memorySubjectRequestIDs:
+		s.subjectRequestIDsMutex.RLock()
+		defer s.subjectRequestIDsMutex.RUnlock()
+		for requestID := range s.SubjectRequestIDs[key] {
+			addRequestID(p.SubjectRequestIDs, key, requestID)
+		}
+	case memoryClientRequestIDs:
+		s.clientRequestIDsMutex.RLock()
+		defer s.clientRequestIDsMutex.RUnlock()
+		for requestID := range s.ClientRequestIDs[key] {
+			addRequestID(p.ClientRequestIDs, key, requestID)
+		}
+	}
+}
+
+// pullIndexes pulls the subject and client index entries req is added to.
+func (s *MemoryStore) pullIndexes(tx *memoryTx, req fosite.Requester) {
+	if sess := req.GetSession(); sess != nil && sess.GetSubject() != "" {
+		s.pull(tx, memorySubjectRequestIDs, sess.GetSubject())
+	}
+	if client := req.GetClient(); client != nil {
+		s.pull(tx, memoryClientRequestIDs, client.GetID())
+	}
+}
+
+// pullRequest pulls the access and refresh tokens issued for requestID, including refresh tokens within their grace
+// period and the revoked JTI of the access token.
+func (s *MemoryStore) pullRequest(tx *memoryTx, requestID string) {
+	p := tx.pending
+
+	s.pull(tx, memoryAccessTokenRequestIDs, requestID)
+	if signature, ok := p.AccessTokenRequestIDs[requestID]; ok {
+		s.pull(tx, memoryAccessTokens, signature)
+		if req := p.AccessTokens[signature]; req != nil {
+			if session, ok := req.GetSession().(accessTokenJTISession); ok && session.GetAccessTokenJTI() != "" {
+				s.pull(tx, memoryRevokedJTIs, session.GetAccessTokenJTI())
+			}
+		}
+	}
+
+	s.pull(tx, memoryRefreshTokenRequestIDs, requestID)
+	if signature, ok := p.RefreshTokenRequestIDs[requestID]; ok {
+		s.pull(tx, memoryRefreshTokens, signature)
+	}
+
+	s.rotatedRefreshTokensMutex.RLock()
+	var graceSignatures []string
+	for signature, rotated := range s.RotatedRefreshTokens {
+		if rotated.RequestID == requestID {
+			graceSignatures = append(graceSignatures, signature)
+		}
+	}
+	s.rotatedRefreshTokensMutex.RUnlock()
+
+	for _, signature := range graceSignatures {
+		s.pull(tx, memoryRotatedRefreshTokens, signature)
+		s.pull(tx, memoryRefreshTokens, signature)
+	}
+}
+
+// pullIndexed pulls the requests of the subject or client index entry together with their entries in the other index.
+func (s *MemoryStore) pullIndexed(tx *memoryTx, table memoryTable, key string) {
+	p := tx.pending
+
+	s.pull(tx, table, key)
+	index := p.SubjectRequestIDs
+	if table == memoryClientRequestIDs {
+		index = p.ClientRequestIDs
+	}
+
+	for requestID := range index[key] {
+		s.pullRequest(tx, requestID)
+		if req := p.requestByID(requestID); req != nil {
+			s.pullIndexes(tx, req)
+		}
+	}
+}
+
+// checkRefreshTokenVersion returns a check failing if the refresh token with the given signature is no longer at the
+// given version.
+func (s *MemoryStore) checkRefreshTokenVersion(signature string, version uint64) func() error {
+	return func() error {
+		s.refreshTokensMutex.RLock()
+		defer s.refreshTokensMutex.RUnlock()
+
+		if s.refreshTokenVersions[signature] != version {
+			return errors.WithStack(fosite.ErrSerializationFailure)
+		}
+		return nil
+	}
+}
+
+// checkRefreshTokenFamily returns a check failing if the refresh token family has been rotated or revoked.
+func (s *MemoryStore) checkRefreshTokenFamily(familyID string, family StoreRefreshTokenFamily) func() error {
+	return func() error {
+		s.refreshTokenFamiliesMutex.RLock()
+		defer s.refreshTokenFamiliesMutex.RUnlock()
+
+		if s.RefreshTokenFamilies[familyID] != family {
+			return errors.WithStack(fosite.ErrSerializationFailure)
+		}
+		return nil
+	}
+}
+
+// checkAuthorizeCode returns a check failing if the authorize code has been invalidated, or created, so that it can
+// not be exchanged by concurrent transactions.
+func (s *MemoryStore) checkAuthorizeCode(code string, active bool) func() error {
+	return func() error {
+		s.authorizeCodesMutex.RLock()
+		defer s.authorizeCodesMutex.RUnlock()
+
+		if s.AuthorizeCodes[code].active != active {
+			return errors.WithStack(fosite.ErrSerializationFailure)
+		}
+		return nil
+	}
+}
+
+// bumpRefreshTokenVersion marks the refresh token with the given signature as modified. refreshTokensMutex must be
+// held by the caller.
+func (s *MemoryStore) bumpRefreshTokenVersion(signature string) {
+	if s.refreshTokenVersions == nil {
+		s.refreshTokenVersions = make(map[string]uint64)
+	}
+	s.refreshTokenVersions[signature]++
+}
@@ -0,0 +1,329 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// GetClient returns the client with the given ID as a *fosite.DefaultOpenIDConnectClient.
+func (s *Store) GetClient(ctx context.Context, id string) (fosite.Client, error) {
+	var secret, rotatedSecrets, redirectURIs, grantTypes, responseTypes, scopes, audience string
+	var jwksURI, jwks, requestURIs sql.NullString
+	var secretExpiresAt int64
+	client := &fosite.DefaultOpenIDConnectClient{DefaultClient: &fosite.DefaultClient{ID: id}}
+	if err := s.queryRow(ctx,
+		"SELECT "+clientColumnNames+" FROM fosite_clients WHERE id = ?",
+		id,
+	).Scan(
+		&secret, &secretExpiresAt, &rotatedSecrets, &redirectURIs, &grantTypes, &responseTypes, &scopes, &audience, &client.Public,
+		&client.TokenEndpointAuthMethod, &client.TokenEndpointAuthSigningAlgorithm, &client.RequestObjectSigningAlgorithm,
+		&client.IntrospectionEncryptedResponseAlg, &client.IntrospectionEncryptedResponseEnc, &jwksURI, &jwks, &requestURIs,
+	); err != nil {
+		return nil, notFound(err)
+	}
+
+	client.Secret = []byte(secret)
+	client.SecretExpiresAt = fromUnixNano(secretExpiresAt)
+	client.JSONWebKeysURI = jwksURI.String
+	for dst, src := range map[interface{}]string{
+		&client.RedirectURIs:  redirectURIs,
+		&client.GrantTypes:    grantTypes,
+		&client.ResponseTypes: responseTypes,
+		&client.Scopes:        scopes,
+		&client.Audience:      audience,
+		&client.JSONWebKeys:   jwks.String,
+		&client.RequestURIs:   requestURIs.String,
+	} {
+		// Clients stored before the OpenID Connect metadata was added have NULL columns.
+		if src == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(src), dst); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := decodeRotatedSecrets(client.DefaultClient, rotatedSecrets); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// storedRotatedSecret is the encoding of a rotated secret in the rotated_secrets column.
+type storedRotatedSecret struct {
+	Hash      []byte `json:"hash"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+// decodeRotatedSecrets sets the rotated secrets of the client. Clients stored without secret expiry contain a JSON
+// array of hashes instead of stored rotated secrets.
+func decodeRotatedSecrets(client *fosite.DefaultClient, src string) error {
+	var stored []storedRotatedSecret
+	if err := json.Unmarshal([]byte(src), &stored); err != nil {
+		return errors.WithStack(json.Unmarshal([]byte(src), &client.RotatedSecrets))
+	}
+
+	for _, secret := range stored {
+		client.RotatedSecrets = append(client.RotatedSecrets, secret.Hash)
+		client.RotatedSecretsExpiresAt = append(client.RotatedSecretsExpiresAt, fromUnixNano(secret.ExpiresAt))
+	}
+	return nil
+}
+
+// CreateClient implements fosite.ClientWriter. Clients are stored with the fields of fosite.DefaultOpenIDConnectClient
+// and restored as *fosite.DefaultOpenIDConnectClient.
+func (s *Store) CreateClient(ctx context.Context, client fosite.Client) error {
+	if _, err := s.GetClient(ctx, client.GetID()); err == nil {
+		return errors.WithStack(fosite.ErrConflict.WithHintf("Client '%s' exists already.", client.GetID()))
+	} else if !errors.Is(err, fosite.ErrNotFound) {
+		return err
+	}
+	client, err := fosite.PrepareClient(ctx, s.getHasher(), client, nil)
+	if err != nil {
+		return err
+	}
+
+	columns, err := clientColumns(client)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx,
+		"INSERT INTO fosite_clients ("+clientColumnNames+", id) VALUES (?"+strings.Repeat(", ?", len(columns)-1)+")",
+		columns...,
+	)
+	return err
+}
+
+// UpdateClient implements fosite.ClientWriter.
+func (s *Store) UpdateClient(ctx context.Context, client fosite.Client) error {
+	stored, err := s.GetClient(ctx, client.GetID())
+	if err != nil {
+		return err
+	}
+	client, err = fosite.PrepareClient(ctx, s.getHasher(), client, stored)
+	if err != nil {
+		return err
+	}
+
+	columns, err := clientColumns(client)
+	if err != nil {
+		return err
+	}
+	return s.execAffecting(ctx,
+		"UPDATE fosite_clients SET "+strings.Join(strings.Split(clientColumnNames, ", "), " = ?, ")+" = ? WHERE id = ?",
+		columns...,
+	)
+}
+
+// DeleteClient implements fosite.ClientWriter. Tokens issued to the client are not revoked.
+func (s *Store) DeleteClient(ctx context.Context, id string) error {
+	return s.execAffecting(ctx, "DELETE FROM fosite_clients WHERE id = ?", id)
+}
+
+// ListClients implements fosite.ClientWriter.
+func (s *Store) ListClients(ctx context.Context, limit int, pageToken string) ([]fosite.Client, string, error) {
+	if limit <= 0 {
+		limit = fosite.DefaultClientListLimit
+	}
+
+	// One more client is queried to learn whether there is a next page.
+	rows, err := s.query(ctx, "SELECT id FROM fosite_clients WHERE id > ? ORDER BY id LIMIT ?", pageToken, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, "", errors.WithStack(err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", errors.WithStack(err)
+	}
+	_ = rows.Close()
+
+	var nextPageToken string
+	if len(ids) > limit {
+		ids = ids[:limit]
+		nextPageToken = ids[limit-1]
+	}
+
+	clients := make([]fosite.Client, len(ids))
+	for i, id := range ids {
+		if clients[i], err = s.GetClient(ctx, id); err != nil {
+			return nil, "", err
+		}
+	}
+	return clients, nextPageToken, nil
+}
+
+// SetRegistrationAccessTokenSignature implements fosite.ClientRegistrationStorage.
+func (s *Store) SetRegistrationAccessTokenSignature(ctx context.Context, clientID string, signature string) error {
+	if _, err := s.exec(ctx, "DELETE FROM fosite_registration_access_tokens WHERE client_id = ?", clientID); err != nil || signature == "" {
+		return err
+	}
+	_, err := s.exec(ctx, "INSERT INTO fosite_registration_access_tokens (client_id, signature) VALUES (?, ?)", clientID, signature)
+	return err
+}
+
+// GetRegistrationAccessTokenSignature implements fosite.ClientRegistrationStorage.
+func (s *Store) GetRegistrationAccessTokenSignature(ctx context.Context, clientID string) (string, error) {
+	var signature string
+	if err := s.queryRow(ctx, "SELECT signature FROM fosite_registration_access_tokens WHERE client_id = ?", clientID).Scan(&signature); err != nil {
+		return "", notFound(err)
+	}
+	return signature, nil
+}
+
+// clientColumnNames are the client columns in the order of clientColumns, without the ID.
+const clientColumnNames = "secret, secret_expires_at, rotated_secrets, redirect_uris, grant_types, response_types, scopes, audience, public, " +
+	"token_endpoint_auth_method, token_endpoint_auth_signing_alg, request_object_signing_alg, " +
+	"introspection_encrypted_response_alg, introspection_encrypted_response_enc, jwks_uri, jwks, request_uris"
+
+// clientColumns returns the values of the client columns, ending with the ID. The OpenID Connect metadata is stored if
+// the client is a fosite.OpenIDConnectClient.
+func clientColumns(client fosite.Client) ([]interface{}, error) {
+	rotated := []storedRotatedSecret{}
+	if c, ok := client.(fosite.ClientWithSecretRotation); ok {
+		for _, hash := range c.GetRotatedHashes() {
+			rotated = append(rotated, storedRotatedSecret{Hash: hash})
+		}
+	}
+	var secretExpiresAt time.Time
+	if c, ok := client.(fosite.ClientWithSecretExpiry); ok {
+		secretExpiresAt = c.GetSecretExpiresAt()
+		for i, expiresAt := range c.GetRotatedSecretsExpiresAt() {
+			if i < len(rotated) {
+				rotated[i].ExpiresAt = toUnixNano(expiresAt)
+			}
+		}
+	}
+
+	var rotatedSecrets, redirectURIs, grantTypes, responseTypes, scopes, audience string
+	for dst, src := range map[*string]interface{}{
+		&rotatedSecrets: rotated,
+		&redirectURIs:   client.GetRedirectURIs(),
+		&grantTypes:     client.GetGrantTypes(),
+		&responseTypes:  client.GetResponseTypes(),
+		&scopes:         client.GetScopes(),
+		&audience:       client.GetAudience(),
+	} {
+		if err := marshalString(dst, src); err != nil {
+			return nil, err
+		}
+	}
+	var authMethod, authSigningAlg, requestObjectSigningAlg, introspectionAlg, introspectionEnc, jwksURI string
+	var jwks, requestURIs interface{}
+	if c, ok := client.(fosite.OpenIDConnectClient); ok {
+		authMethod, authSigningAlg, requestObjectSigningAlg = c.GetTokenEndpointAuthMethod(), c.GetTokenEndpointAuthSigningAlgorithm(), c.GetRequestObjectSigningAlgorithm()
+		jwksURI, jwks, requestURIs = c.GetJSONWebKeysURI(), c.GetJSONWebKeys(), c.GetRequestURIs()
+	}
+	if c, ok := client.(fosite.JWTIntrospectionClient); ok {
+		introspectionAlg, introspectionEnc = c.GetIntrospectionEncryptedResponseAlgorithm(), c.GetIntrospectionEncryptedResponseEncryption()
+	}
+	var encodedJWKS, encodedRequestURIs string
+	for dst, src := range map[*string]interface{}{
+		&encodedJWKS:        jwks,
+		&encodedRequestURIs: requestURIs,
+	} {
+		if err := marshalString(dst, src); err != nil {
+			return nil, err
+		}
+	}
+
+	return []interface{}{
+		string(client.GetHashedSecret()), toUnixNano(secretExpiresAt), rotatedSecrets, redirectURIs, grantTypes, responseTypes, scopes, audience,
+		client.IsPublic(), authMethod, authSigningAlg, requestObjectSigningAlg, introspectionAlg, introspectionEnc, jwksURI,
+		encodedJWKS, encodedRequestURIs, client.GetID(),
+	}, nil
+}
+
+// ClientAssertionJWTValid returns fosite.ErrJTIKnown if jti has been used and has not expired yet.
+func (s *Store) ClientAssertionJWTValid(ctx context.Context, jti string) error {
+	if known, err := s.jtiKnown(ctx, "fosite_client_assertion_jtis", jti); err != nil {
+		return err
+	} else if known {
+		return errors.WithStack(fosite.ErrJTIKnown)
+	}
+	return nil
+}
+
+// SetClientAssertionJWT marks jti as used until exp. It returns fosite.ErrJTIKnown if jti is in use already.
+func (s *Store) SetClientAssertionJWT(ctx context.Context, jti string, exp time.Time) error {
+	if err := s.ClientAssertionJWTValid(ctx, jti); err != nil {
+		return err
+	}
+	return s.putJTI(ctx, "fosite_client_assertion_jtis", jti, exp)
+}
+
+func (s *Store) RevokeJTI(ctx context.Context, jti string, exp time.Time) error {
+	return s.putJTI(ctx, "fosite_revoked_jtis", jti, exp)
+}
+
+// IsJTIRevoked returns true if jti has been revoked and has not expired yet. JTIs revoked with a zero expiry never
+// expire.
+func (s *Store) IsJTIRevoked(ctx context.Context, jti string) (bool, error) {
+	return s.jtiKnown(ctx, "fosite_revoked_jtis", jti)
+}
+
+// DeleteExpiredJTIs removes the expired client assertion and revoked JTIs.
+func (s *Store) DeleteExpiredJTIs(ctx context.Context) error {
+	now := toUnixNano(s.now())
+	for _, table := range []string{"fosite_client_assertion_jtis", "fosite_revoked_jtis"} {
+		if _, err := s.exec(ctx, "DELETE FROM "+table+" WHERE expires_at <> 0 AND expires_at < ?", now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jtiKnown returns true if jti is stored in table and has not expired. A zero expiry never expires.
+func (s *Store) jtiKnown(ctx context.Context, table, jti string) (bool, error) {
+	var exp int64
+	if err := s.queryRow(ctx, "SELECT expires_at FROM "+table+" WHERE jti = ?", jti).Scan(&exp); errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return exp == 0 || fromUnixNano(exp).After(s.now()), nil
+}
+
+// putJTI replaces the entry of jti, which may have expired.
+func (s *Store) putJTI(ctx context.Context, table, jti string, exp time.Time) error {
+	if _, err := s.exec(ctx, "DELETE FROM "+table+" WHERE jti = ?", jti); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx, "INSERT INTO "+table+" (jti, expires_at) VALUES (?, ?)", jti, toUnixNano(exp))
+	return err
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// migration upgrades the schema to version.
+type migration struct {
+	version    int
+	statements []string
+}
+
+const (
+	tableAuthorizeCodes = "fosite_authorize_codes"
+	tableAccessTokens   = "fosite_access_tokens"
+	tableRefreshTokens  = "fosite_refresh_tokens"
+	tablePKCERequests   = "fosite_pkce_requests"
+	tableOIDCSessions   = "fosite_oidc_sessions"
+)
+
+// requestTable returns the statements creating a table of requests by signature, indexed by request ID.
+func requestTable(table string, columns string) []string {
+	return []string{
+		fmt.Sprintf(`CREATE TABLE %s (
+	signature               VARCHAR(255) NOT NULL PRIMARY KEY,
+	request_id              VARCHAR(255) NOT NULL,
+	requested_at            BIGINT       NOT NULL,
+	client_id               VARCHAR(255) NOT NULL,
+	subject                 VARCHAR(255) NOT NULL,
+	requested_scope         TEXT         NOT NULL,
+	granted_scope           TEXT         NOT NULL,
+	requested_audience      TEXT         NOT NULL,
+	granted_audience        TEXT         NOT NULL,
+	form                    TEXT         NOT NULL,
+	lang                    VARCHAR(64)  NOT NULL,
+	subject_token_client_id VARCHAR(255) NOT NULL,
+	session_type            VARCHAR(255) NOT NULL,
+	session_data            TEXT         NOT NULL%s
+)`, table, columns),
+		fmt.Sprintf("CREATE INDEX %s_request_id_idx ON %s (request_id)", table, table),
+		fmt.Sprintf("CREATE INDEX %s_subject_idx ON %s (subject)", table, table),
+		fmt.Sprintf("CREATE INDEX %s_client_id_idx ON %s (client_id)", table, table),
+	}
+}
+
+func concat(statements ...[]string) (all []string) {
+	for _, s := range statements {
+		all = append(all, s...)
+	}
+	return all
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		statements: concat(
+			[]string{`CREATE TABLE fosite_clients (
+	id              VARCHAR(255) NOT NULL PRIMARY KEY,
+	secret          TEXT         NOT NULL,
+	rotated_secrets TEXT         NOT NULL,
+	redirect_uris   TEXT         NOT NULL,
+	grant_types     TEXT         NOT NULL,
+	response_types  TEXT         NOT NULL,
+	scopes          TEXT         NOT NULL,
+	audience        TEXT         NOT NULL,
+	public          BOOLEAN      NOT NULL
+)`},
+			requestTable(tableAuthorizeCodes, ",\n\tactive BOOLEAN NOT NULL"),
+			requestTable(tableAccessTokens, ""),
+			requestTable(tableRefreshTokens, ",\n\tactive BOOLEAN NOT NULL,\n\tgrace_expires_at BIGINT NOT NULL"),
+			requestTable(tablePKCERequests, ""),
+			requestTable(tableOIDCSessions, ""),
+			[]string{
+				`CREATE TABLE fosite_client_assertion_jtis (
+	jti        VARCHAR(255) NOT NULL PRIMARY KEY,
+	expires_at BIGINT       NOT NULL
+)`,
+				"CREATE INDEX fosite_client_assertion_jtis_expires_at_idx ON fosite_client_assertion_jtis (expires_at)",
+				`CREATE TABLE fosite_revoked_jtis (
+	jti        VARCHAR(255) NOT NULL PRIMARY KEY,
+	expires_at BIGINT       NOT NULL
+)`,
+				"CREATE INDEX fosite_revoked_jtis_expires_at_idx ON fosite_revoked_jtis (expires_at)",
+			},
+		),
+	},
+	{
+		version: 2,
+		statements: []string{`CREATE TABLE fosite_registration_access_tokens (
+	client_id VARCHAR(255) NOT NULL PRIMARY KEY,
+	signature VARCHAR(255) NOT NULL
+)`},
+	},
+	{
+		version:    3,
+		statements: []string{"ALTER TABLE fosite_clients ADD COLUMN secret_expires_at BIGINT NOT NULL DEFAULT 0"},
+	},
+	{
+		// The metadata of fosite.DefaultOpenIDConnectClient. TEXT columns are nullable because MySQL does not support
+		// defaults for them.
+		version: 4,
+		statements: []string{
+			"ALTER TABLE fosite_clients ADD COLUMN token_endpoint_auth_method VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE fosite_clients ADD COLUMN token_endpoint_auth_signing_alg VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE fosite_clients ADD COLUMN request_object_signing_alg VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE fosite_clients ADD COLUMN introspection_encrypted_response_alg VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE fosite_clients ADD COLUMN introspection_encrypted_response_enc VARCHAR(255) NOT NULL DEFAULT ''",
+			"ALTER TABLE fosite_clients ADD COLUMN jwks_uri TEXT",
+			"ALTER TABLE fosite_clients ADD COLUMN jwks TEXT",
+			"ALTER TABLE fosite_clients ADD COLUMN request_uris TEXT",
+		},
+	},
+	{
+		// The encrypted tokens refresh tokens within their grace period have been rotated to.
+		version: 5,
+		statements: []string{
+			`CREATE TABLE fosite_rotated_refresh_tokens (
+	signature     VARCHAR(255) NOT NULL PRIMARY KEY,
+	request_id    VARCHAR(255) NOT NULL,
+	access_token  TEXT         NOT NULL,
+	refresh_token TEXT         NOT NULL,
+	expires_at    BIGINT       NOT NULL
+)`,
+			"CREATE INDEX fosite_rotated_refresh_tokens_request_id_idx ON fosite_rotated_refresh_tokens (request_id)",
+		},
+	},
+}
+
+// Migrate creates or upgrades the schema. Every migration is applied in its own transaction and recorded in the
+// fosite_migrations table, so Migrate can be called on every start.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS fosite_migrations (
+	version    INTEGER NOT NULL PRIMARY KEY,
+	applied_at BIGINT  NOT NULL
+)`); err != nil {
+		return errors.WithStack(err)
+	}
+
+	applied, err := s.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+		if err := s.applyMigration(ctx, m); err != nil {
+			return errors.WithMessagef(err, "unable to apply migration %d", m.version)
+		}
+	}
+	return nil
+}
+
+func (s *Store) appliedMigrations(ctx context.Context) (map[int]bool, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT version FROM fosite_migrations")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		applied[version] = true
+	}
+	return applied, errors.WithStack(rows.Err())
+}
+
+func (s *Store) applyMigration(ctx context.Context, m migration) (err error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	for _, statement := range m.statements {
+		if _, err = tx.ExecContext(ctx, statement); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, s.Dialect.rebind("INSERT INTO fosite_migrations (version, applied_at) VALUES (?, ?)"), m.version, toUnixNano(s.now())); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tx.Commit())
+}
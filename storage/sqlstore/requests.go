@@ -0,0 +1,171 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sqlstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/language"
+
+	"github.com/ory/fosite"
+)
+
+const requestColumns = "request_id, requested_at, client_id, subject, requested_scope, granted_scope, requested_audience, granted_audience, form, lang, subject_token_client_id, session_type, session_data"
+
+const requestPlaceholders = "?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?"
+
+// requestRow is a request as stored in the columns of requestColumns.
+type requestRow struct {
+	RequestID            string
+	RequestedAt          int64
+	ClientID             string
+	Subject              string
+	RequestedScope       string
+	GrantedScope         string
+	RequestedAudience    string
+	GrantedAudience      string
+	Form                 string
+	Lang                 string
+	SubjectTokenClientID string
+	SessionType          string
+	SessionData          string
+}
+
+func (r *requestRow) values() []interface{} {
+	return []interface{}{
+		r.RequestID, r.RequestedAt, r.ClientID, r.Subject, r.RequestedScope, r.GrantedScope, r.RequestedAudience,
+		r.GrantedAudience, r.Form, r.Lang, r.SubjectTokenClientID, r.SessionType, r.SessionData,
+	}
+}
+
+func (r *requestRow) destinations() []interface{} {
+	return []interface{}{
+		&r.RequestID, &r.RequestedAt, &r.ClientID, &r.Subject, &r.RequestedScope, &r.GrantedScope, &r.RequestedAudience,
+		&r.GrantedAudience, &r.Form, &r.Lang, &r.SubjectTokenClientID, &r.SessionType, &r.SessionData,
+	}
+}
+
+func (s *Store) encodeRequest(req fosite.Requester) (*requestRow, error) {
+	row := &requestRow{
+		RequestID:   req.GetID(),
+		RequestedAt: toUnixNano(req.GetRequestedAt()),
+		Form:        req.GetRequestForm().Encode(),
+	}
+	if client := req.GetClient(); client != nil {
+		row.ClientID = client.GetID()
+	}
+	if client := req.GetSubjectTokenClient(); client != nil {
+		row.SubjectTokenClientID = client.GetID()
+	}
+	if g11n, ok := req.(fosite.G11NContext); ok && g11n.GetLang() != language.Und {
+		row.Lang = g11n.GetLang().String()
+	}
+
+	for dst, args := range map[*string]fosite.Arguments{
+		&row.RequestedScope:    req.GetRequestedScopes(),
+		&row.GrantedScope:      req.GetGrantedScopes(),
+		&row.RequestedAudience: req.GetRequestedAudience(),
+		&row.GrantedAudience:   req.GetGrantedAudience(),
+	} {
+		if err := marshalString(dst, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if session := req.GetSession(); session != nil {
+		name, data, err := s.Sessions.MarshalSession(session)
+		if err != nil {
+			return nil, err
+		}
+		row.SessionType, row.SessionData = name, string(data)
+		row.Subject = session.GetSubject()
+	}
+	return row, nil
+}
+
+// decodeRequest restores a request as a *fosite.Request. Clients which are not stored are restored as a
+// fosite.DefaultClient holding the client ID only.
+func (s *Store) decodeRequest(ctx context.Context, row *requestRow) (fosite.Requester, error) {
+	form, err := url.ParseQuery(row.Form)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	req := &fosite.Request{
+		ID:          row.RequestID,
+		RequestedAt: fromUnixNano(row.RequestedAt),
+		Form:        form,
+	}
+	for dst, src := range map[*fosite.Arguments]string{
+		&req.RequestedScope:    row.RequestedScope,
+		&req.GrantedScope:      row.GrantedScope,
+		&req.RequestedAudience: row.RequestedAudience,
+		&req.GrantedAudience:   row.GrantedAudience,
+	} {
+		if err := json.Unmarshal([]byte(src), dst); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if row.ClientID != "" {
+		if req.Client, err = s.resolveClient(ctx, row.ClientID); err != nil {
+			return nil, err
+		}
+	}
+	if row.SubjectTokenClientID != "" {
+		if req.SubjectTokenClient, err = s.resolveClient(ctx, row.SubjectTokenClientID); err != nil {
+			return nil, err
+		}
+	}
+	if row.Lang != "" {
+		if req.Lang, err = language.Parse(row.Lang); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if row.SessionType != "" {
+		if req.Session, err = s.Sessions.UnmarshalSession(row.SessionType, []byte(row.SessionData)); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (s *Store) resolveClient(ctx context.Context, id string) (fosite.Client, error) {
+	client, err := s.GetClient(ctx, id)
+	if errors.Is(err, fosite.ErrNotFound) {
+		return &fosite.DefaultClient{ID: id}, nil
+	}
+	return client, err
+}
+
+func marshalString(dst *string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	*dst = string(raw)
+	return nil
+}
@@ -0,0 +1,19 @@
+module github.com/ory/fosite/storage/sqlstore/sqlitetest
+
+go 1.14
+
+replace github.com/ory/fosite => ../../..
+
+replace github.com/dgrijalva/jwt-go => github.com/form3tech-oss/jwt-go v3.2.1+incompatible
+
+replace github.com/gogo/protobuf => github.com/gogo/protobuf v1.3.2
+
+replace github.com/gobuffalo/packr => github.com/gobuffalo/packr v1.30.1
+
+require (
+	github.com/ory/fosite v0.29.0
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.7.0
+	gopkg.in/square/go-jose.v2 v2.5.2-0.20210529014059-a5c7eec3c614
+	modernc.org/sqlite v1.10.6
+)
@@ -0,0 +1,316 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sqlitetest
+
+import (
+	"context"
+	"crypto/rsa"
+	"database/sql"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	_ "modernc.org/sqlite"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/internal"
+	"github.com/ory/fosite/storage/sqlstore"
+	"github.com/ory/fosite/storage/storagetest"
+	"github.com/ory/fosite/token/jwt"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func newTestStore(t *testing.T) *sqlstore.Store {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "fosite.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	// SQLite allows a single writer, transactions would otherwise wait for each other.
+	db.SetMaxOpenConns(1)
+
+	s := sqlstore.NewStore(db, sqlstore.DialectSQLite)
+	s.Hasher = &fosite.BCrypt{WorkFactor: 4}
+	require.NoError(t, s.Migrate(context.Background()))
+	return s
+}
+
+func newTestRequest(id string) *fosite.Request {
+	return &fosite.Request{
+		ID:             id,
+		RequestedAt:    time.Now().UTC().Round(time.Second),
+		Client:         &fosite.DefaultClient{ID: "foo"},
+		RequestedScope: fosite.Arguments{"openid", "offline"},
+		GrantedScope:   fosite.Arguments{"offline"},
+		Form:           url.Values{"redirect_uri": {"https://example.com/cb"}},
+		Session:        &fosite.DefaultSession{Subject: "peter", Extra: map[string]interface{}{"foo": "bar"}},
+	}
+}
+
+func TestStore_Migrate(t *testing.T) {
+	s := newTestStore(t)
+	require.NoError(t, s.Migrate(context.Background()))
+
+	var n, latest int
+	require.NoError(t, s.DB.QueryRow("SELECT COUNT(*), MAX(version) FROM fosite_migrations").Scan(&n, &latest))
+	assert.Equal(t, latest, n)
+}
+
+func TestStore_LegacyRotatedSecrets(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	_, err := s.DB.Exec(`INSERT INTO fosite_clients (id, secret, rotated_secrets, redirect_uris, grant_types, response_types, scopes, audience, public)
+VALUES ('legacy', 'hash', '["b2xkLWhhc2g="]', '[]', '[]', '[]', '[]', '[]', false)`)
+	require.NoError(t, err)
+
+	client, err := s.GetClient(ctx, "legacy")
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("old-hash")}, client.(fosite.ClientWithSecretRotation).GetRotatedHashes())
+	assert.True(t, client.(fosite.ClientWithSecretExpiry).GetSecretExpiresAt().IsZero())
+}
+
+func TestStore_OpenIDConnectClient(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	key := internal.MustRSAKey()
+
+	expected := &fosite.DefaultOpenIDConnectClient{
+		DefaultClient: &fosite.DefaultClient{
+			ID:            "oidc",
+			RedirectURIs:  []string{"https://client.example.com/cb"},
+			GrantTypes:    []string{"authorization_code"},
+			ResponseTypes: []string{"code"},
+			Scopes:        []string{"openid"},
+		},
+		TokenEndpointAuthMethod:           "private_key_jwt",
+		TokenEndpointAuthSigningAlgorithm: "RS256",
+		JSONWebKeys:                       &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{KeyID: "sig", Use: "sig", Algorithm: "RS256", Key: &key.PublicKey}}},
+		RequestURIs:                       []string{"https://client.example.com/request"},
+		RequestObjectSigningAlgorithm:     "RS256",
+		IntrospectionEncryptedResponseAlg: "RSA-OAEP-256",
+		IntrospectionEncryptedResponseEnc: "A256GCM",
+	}
+	require.NoError(t, s.CreateClient(ctx, expected))
+
+	client, err := s.GetClient(ctx, "oidc")
+	require.NoError(t, err)
+	require.IsType(t, &fosite.DefaultOpenIDConnectClient{}, client)
+	actual := client.(*fosite.DefaultOpenIDConnectClient)
+	assert.Equal(t, expected.TokenEndpointAuthMethod, actual.TokenEndpointAuthMethod)
+	assert.Equal(t, expected.TokenEndpointAuthSigningAlgorithm, actual.TokenEndpointAuthSigningAlgorithm)
+	require.NotNil(t, actual.JSONWebKeys)
+	assert.Equal(t, expected.JSONWebKeys.Keys[0].KeyID, actual.JSONWebKeys.Keys[0].KeyID)
+	assert.Equal(t, key.PublicKey.N, actual.JSONWebKeys.Keys[0].Key.(*rsa.PublicKey).N)
+	assert.Equal(t, expected.RequestURIs, actual.RequestURIs)
+	assert.Equal(t, expected.RequestObjectSigningAlgorithm, actual.RequestObjectSigningAlgorithm)
+	assert.Equal(t, expected.IntrospectionEncryptedResponseAlg, actual.IntrospectionEncryptedResponseAlg)
+	assert.Equal(t, expected.IntrospectionEncryptedResponseEnc, actual.IntrospectionEncryptedResponseEnc)
+
+	expected.JSONWebKeys, expected.JSONWebKeysURI = nil, "https://client.example.com/jwks.json"
+	require.NoError(t, s.UpdateClient(ctx, expected))
+	client, err = s.GetClient(ctx, "oidc")
+	require.NoError(t, err)
+	assert.Nil(t, client.(fosite.OpenIDConnectClient).GetJSONWebKeys())
+	assert.Equal(t, "https://client.example.com/jwks.json", client.(fosite.OpenIDConnectClient).GetJSONWebKeysURI())
+}
+
+func TestStore_Requests(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	require.NoError(t, s.CreateClient(ctx, &fosite.DefaultClient{ID: "foo", Secret: []byte("secret"), GrantTypes: []string{"client_credentials"}, Scopes: []string{"offline"}}))
+
+	client, err := s.GetClient(ctx, "foo")
+	require.NoError(t, err)
+	assert.Equal(t, fosite.Arguments{"offline"}, client.GetScopes())
+	_, err = s.GetClient(ctx, "bar")
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	req := newTestRequest("grant")
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", req))
+	got, err := s.GetAccessTokenSession(ctx, "at", nil)
+	require.NoError(t, err)
+	assert.Equal(t, req.ID, got.GetID())
+	assert.Equal(t, req.RequestedAt, got.GetRequestedAt())
+	assert.Equal(t, req.RequestedScope, got.GetRequestedScopes())
+	assert.Equal(t, req.GrantedScope, got.GetGrantedScopes())
+	assert.Equal(t, req.Form, got.GetRequestForm())
+	assert.Equal(t, client, got.GetClient())
+	assert.Equal(t, req.Session, got.GetSession())
+
+	oidcReq := newTestRequest("oidc")
+	oidcReq.Session = &openid.DefaultSession{Subject: "peter", Claims: &jwt.IDTokenClaims{Subject: "peter"}, Headers: &jwt.Headers{}}
+	require.NoError(t, s.CreateOpenIDConnectSession(ctx, "code", oidcReq))
+	got, err = s.GetOpenIDConnectSession(ctx, "code", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "peter", got.GetSession().(*openid.DefaultSession).Claims.Subject)
+	require.NoError(t, s.DeleteOpenIDConnectSession(ctx, "code"))
+	_, err = s.GetOpenIDConnectSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "code", req))
+	require.NoError(t, s.InvalidateAuthorizeCodeSession(ctx, "code"))
+	got, err = s.GetAuthorizeCodeSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrInvalidatedAuthorizeCode))
+	assert.Equal(t, "grant", got.GetID())
+	assert.True(t, errors.Is(s.InvalidateAuthorizeCodeSession(ctx, "unknown"), fosite.ErrNotFound))
+
+	require.NoError(t, s.CreatePKCERequestSession(ctx, "code", req))
+	_, err = s.GetPKCERequestSession(ctx, "code", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.DeletePKCERequestSession(ctx, "code"))
+	_, err = s.GetPKCERequestSession(ctx, "code", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+}
+
+func TestStore_Revocation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := newTestStore(t)
+	s.Clock = fixedClock(now)
+
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", newTestRequest("grant")))
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", newTestRequest("grant")))
+	require.NoError(t, s.RevokeAccessToken(ctx, "grant"))
+	require.NoError(t, s.RevokeRefreshToken(ctx, "grant"))
+	assert.True(t, errors.Is(s.RevokeRefreshToken(ctx, "grant"), fosite.ErrNotFound))
+
+	_, err := s.GetAccessTokenSession(ctx, "at", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	got, err := s.GetRefreshTokenSession(ctx, "rt", nil)
+	assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+	assert.Equal(t, "grant", got.GetID())
+
+	t.Run("case=grace period", func(t *testing.T) {
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt-grace", newTestRequest("grace")))
+		require.NoError(t, s.RotateRefreshTokenWithGracePeriod(ctx, "grace", "rt-grace", "sealed-at", "sealed-rt", time.Minute))
+
+		_, err := s.GetRefreshTokenSession(ctx, "rt-grace", nil)
+		assert.NoError(t, err)
+		accessToken, refreshToken, err := s.GetRotatedRefreshTokens(ctx, "rt-grace")
+		require.NoError(t, err)
+		assert.Equal(t, "sealed-at", accessToken)
+		assert.Equal(t, "sealed-rt", refreshToken)
+
+		s.Clock = fixedClock(now.Add(time.Minute))
+		_, err = s.GetRefreshTokenSession(ctx, "rt-grace", nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		_, _, err = s.GetRotatedRefreshTokens(ctx, "rt-grace")
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+	})
+
+	t.Run("case=revoke within grace period", func(t *testing.T) {
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt-grace-revoked", newTestRequest("grace-revoked")))
+		require.NoError(t, s.RotateRefreshTokenWithGracePeriod(ctx, "grace-revoked", "rt-grace-revoked", "sealed-at", "sealed-rt", time.Minute))
+		require.NoError(t, s.RevokeRefreshToken(ctx, "grace-revoked"))
+
+		_, err := s.GetRefreshTokenSession(ctx, "rt-grace-revoked", nil)
+		assert.True(t, errors.Is(err, fosite.ErrInactiveToken))
+		_, _, err = s.GetRotatedRefreshTokens(ctx, "rt-grace-revoked")
+		assert.True(t, errors.Is(err, fosite.ErrNotFound))
+		assert.True(t, errors.Is(s.RotateRefreshTokenWithGracePeriod(ctx, "unknown", "rt-unknown", "", "", time.Minute), fosite.ErrNotFound))
+	})
+
+	t.Run("case=bulk", func(t *testing.T) {
+		require.NoError(t, s.CreateAccessTokenSession(ctx, "at-1", newTestRequest("bulk-1")))
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt-2", newTestRequest("bulk-2")))
+
+		requestIDs, err := s.RevokeTokensBySubject(ctx, "peter")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"bulk-1", "bulk-2"}, requestIDs)
+
+		requestIDs, err = s.RevokeTokensByClient(ctx, "foo")
+		require.NoError(t, err)
+		assert.Empty(t, requestIDs)
+	})
+}
+
+func TestStore_JTIs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := newTestStore(t)
+	s.Clock = fixedClock(now)
+
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", now.Add(time.Minute)))
+	assert.True(t, errors.Is(s.ClientAssertionJWTValid(ctx, "jti"), fosite.ErrJTIKnown))
+	assert.True(t, errors.Is(s.SetClientAssertionJWT(ctx, "jti", now.Add(time.Minute)), fosite.ErrJTIKnown))
+
+	require.NoError(t, s.RevokeJTI(ctx, "revoked", now.Add(time.Minute)))
+	revoked, err := s.IsJTIRevoked(ctx, "revoked")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	s.Clock = fixedClock(now.Add(2 * time.Minute))
+	assert.NoError(t, s.ClientAssertionJWTValid(ctx, "jti"))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", now.Add(time.Hour)))
+	revoked, err = s.IsJTIRevoked(ctx, "revoked")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, s.DeleteExpiredJTIs(ctx))
+	var n int
+	require.NoError(t, s.DB.QueryRow("SELECT COUNT(*) FROM fosite_revoked_jtis").Scan(&n))
+	assert.Zero(t, n)
+}
+
+func TestStore_Transactional(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	txCtx, err := s.BeginTX(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateAccessTokenSession(txCtx, "rolled-back", newTestRequest("grant")))
+	_, err = s.GetAccessTokenSession(txCtx, "rolled-back", nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Rollback(txCtx))
+	require.NoError(t, s.Rollback(txCtx))
+
+	_, err = s.GetAccessTokenSession(ctx, "rolled-back", nil)
+	assert.True(t, errors.Is(err, fosite.ErrNotFound))
+
+	txCtx, err = s.BeginTX(ctx)
+	require.NoError(t, err)
+	_, err = s.BeginTX(txCtx)
+	assert.Error(t, err)
+	require.NoError(t, s.CreateAccessTokenSession(txCtx, "committed", newTestRequest("grant")))
+	require.NoError(t, s.Commit(txCtx))
+
+	_, err = s.GetAccessTokenSession(ctx, "committed", nil)
+	assert.NoError(t, err)
+}
+
+func TestStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Store {
+		s := newTestStore(t)
+		require.NoError(t, s.CreateClient(context.Background(), &fosite.DefaultClient{ID: storagetest.ClientID, Public: true, GrantTypes: []string{"client_credentials"}}))
+		return s
+	})
+}
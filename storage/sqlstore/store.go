@@ -0,0 +1,227 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package sqlstore implements the fosite storage interfaces on top of database/sql.
+//
+// The schema is created and upgraded by Store.Migrate. It has been written to be portable across SQLite, PostgreSQL
+// and MySQL; times are stored as Unix nanoseconds.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/storage/codec"
+)
+
+var (
+	_ fosite.Storage                        = new(Store)
+	_ oauth2.CoreStorage                    = new(Store)
+	_ oauth2.TokenRevocationStorage         = new(Store)
+	_ oauth2.BulkTokenRevocationStorage     = new(Store)
+	_ oauth2.JTIDenylistStorage             = new(Store)
+	_ oauth2.RefreshTokenGracePeriodStorage = new(Store)
+	_ openid.OpenIDConnectRequestStorage    = new(Store)
+	_ storage.Transactional                 = new(Store)
+	_ fosite.ClientRegistrationStorage      = new(Store)
+)
+
+// Dialect identifies the SQL dialect of the database.
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite"
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+)
+
+// rebind replaces the ? placeholders of query by the placeholders of the dialect.
+func (d Dialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Store implements fosite.Storage, oauth2.CoreStorage, oauth2.TokenRevocationStorage,
+// oauth2.RefreshTokenGracePeriodStorage, openid.OpenIDConnectRequestStorage, PKCE request storage, storage.Transactional and fosite.ClientRegistrationStorage using database/sql.
+type Store struct {
+	DB      *sql.DB
+	Dialect Dialect
+
+	// Sessions are the session types of stored requests.
+	Sessions *codec.Registry
+	// Clock is used to expire JTIs and grace periods. Defaults to fosite.SystemClock.
+	Clock fosite.Clock
+	// Hasher hashes the client secrets passed to CreateClient and UpdateClient. Defaults to fosite.BCrypt.
+	Hasher fosite.Hasher
+}
+
+// NewStore returns a Store using db. The schema must be created with Migrate before the store is used.
+func NewStore(db *sql.DB, dialect Dialect) *Store {
+	return &Store{
+		DB:       db,
+		Dialect:  dialect,
+		Sessions: codec.DefaultRegistry(),
+	}
+}
+
+type txKey struct{}
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func txFromContext(ctx context.Context) *sql.Tx {
+	if ctx == nil {
+		return nil
+	}
+	tx, _ := ctx.Value(txKey{}).(*sql.Tx)
+	return tx
+}
+
+// BeginTX starts a database transaction which is used by all operations given the returned context.
+func (s *Store) BeginTX(ctx context.Context) (context.Context, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	} else if txFromContext(ctx) != nil {
+		return ctx, errors.New("a transaction has already been started in this context")
+	}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return ctx, errors.WithStack(err)
+	}
+	return context.WithValue(ctx, txKey{}, tx), nil
+}
+
+// Commit commits the transaction of ctx.
+func (s *Store) Commit(ctx context.Context) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction has been started in this context")
+	}
+	return errors.WithStack(tx.Commit())
+}
+
+// Rollback rolls back the transaction of ctx. Rolling back a transaction which has already been committed or rolled
+// back is a no-op.
+func (s *Store) Rollback(ctx context.Context) error {
+	tx := txFromContext(ctx)
+	if tx == nil {
+		return errors.New("no transaction has been started in this context")
+	}
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (s *Store) executor(ctx context.Context) executor {
+	if tx := txFromContext(ctx); tx != nil {
+		return tx
+	}
+	return s.DB
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	result, err := s.executor(ctx).ExecContext(ctx, s.Dialect.rebind(query), args...)
+	return result, errors.WithStack(err)
+}
+
+// execAffecting executes query and returns fosite.ErrNotFound if no row has been affected.
+func (s *Store) execAffecting(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if n, err := result.RowsAffected(); err != nil {
+		return errors.WithStack(err)
+	} else if n == 0 {
+		return errors.WithStack(fosite.ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	rows, err := s.executor(ctx).QueryContext(ctx, s.Dialect.rebind(query), args...)
+	return rows, errors.WithStack(err)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.executor(ctx).QueryRowContext(ctx, s.Dialect.rebind(query), args...)
+}
+
+func (s *Store) now() time.Time {
+	return fosite.TimeNow(s.Clock)
+}
+
+func (s *Store) getHasher() fosite.Hasher {
+	if s.Hasher == nil {
+		return new(fosite.BCrypt)
+	}
+	return s.Hasher
+}
+
+func toUnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// notFound maps sql.ErrNoRows to fosite.ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.WithStack(fosite.ErrNotFound)
+	}
+	return errors.WithStack(err)
+}
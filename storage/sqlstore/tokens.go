@@ -0,0 +1,282 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+)
+
+// createRequest stores req by signature in table. Extra columns and their values are appended to the request columns.
+func (s *Store) createRequest(ctx context.Context, table, signature string, req fosite.Requester, extra string, extraValues ...interface{}) error {
+	row, err := s.encodeRequest(req)
+	if err != nil {
+		return err
+	}
+
+	placeholders := requestPlaceholders
+	for range extraValues {
+		placeholders += ", ?"
+	}
+
+	values := append([]interface{}{signature}, row.values()...)
+	_, err = s.exec(ctx,
+		fmt.Sprintf("INSERT INTO %s (signature, %s%s) VALUES (?, %s)", table, requestColumns, extra, placeholders),
+		append(values, extraValues...)...,
+	)
+	return err
+}
+
+// getRequest returns the request stored by signature in table. Extra columns are scanned into extraDestinations.
+func (s *Store) getRequest(ctx context.Context, table, signature string, extra string, extraDestinations ...interface{}) (fosite.Requester, error) {
+	var row requestRow
+	if err := s.queryRow(ctx,
+		fmt.Sprintf("SELECT %s%s FROM %s WHERE signature = ?", requestColumns, extra, table),
+		signature,
+	).Scan(append(row.destinations(), extraDestinations...)...); err != nil {
+		return nil, notFound(err)
+	}
+	return s.decodeRequest(ctx, &row)
+}
+
+func (s *Store) deleteRequest(ctx context.Context, table, signature string) error {
+	_, err := s.exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE signature = ?", table), signature)
+	return err
+}
+
+func (s *Store) CreateOpenIDConnectSession(ctx context.Context, authorizeCode string, requester fosite.Requester) error {
+	return s.createRequest(ctx, tableOIDCSessions, authorizeCode, requester, "")
+}
+
+func (s *Store) GetOpenIDConnectSession(ctx context.Context, authorizeCode string, _ fosite.Requester) (fosite.Requester, error) {
+	return s.getRequest(ctx, tableOIDCSessions, authorizeCode, "")
+}
+
+func (s *Store) DeleteOpenIDConnectSession(ctx context.Context, authorizeCode string) error {
+	return s.deleteRequest(ctx, tableOIDCSessions, authorizeCode)
+}
+
+func (s *Store) CreateAuthorizeCodeSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createRequest(ctx, tableAuthorizeCodes, code, req, ", active", true)
+}
+
+func (s *Store) GetAuthorizeCodeSession(ctx context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
+	var active bool
+	req, err := s.getRequest(ctx, tableAuthorizeCodes, code, ", active", &active)
+	if err != nil {
+		return nil, err
+	} else if !active {
+		return req, errors.WithStack(fosite.ErrInvalidatedAuthorizeCode)
+	}
+	return req, nil
+}
+
+func (s *Store) InvalidateAuthorizeCodeSession(ctx context.Context, code string) error {
+	return s.execAffecting(ctx, "UPDATE fosite_authorize_codes SET active = ? WHERE signature = ?", false, code)
+}
+
+func (s *Store) CreatePKCERequestSession(ctx context.Context, code string, req fosite.Requester) error {
+	return s.createRequest(ctx, tablePKCERequests, code, req, "")
+}
+
+func (s *Store) GetPKCERequestSession(ctx context.Context, code string, _ fosite.Session) (fosite.Requester, error) {
+	return s.getRequest(ctx, tablePKCERequests, code, "")
+}
+
+func (s *Store) DeletePKCERequestSession(ctx context.Context, code string) error {
+	return s.deleteRequest(ctx, tablePKCERequests, code)
+}
+
+func (s *Store) CreateAccessTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createRequest(ctx, tableAccessTokens, signature, req, "")
+}
+
+func (s *Store) GetAccessTokenSession(ctx context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+	return s.getRequest(ctx, tableAccessTokens, signature, "")
+}
+
+func (s *Store) DeleteAccessTokenSession(ctx context.Context, signature string) error {
+	return s.deleteRequest(ctx, tableAccessTokens, signature)
+}
+
+func (s *Store) CreateRefreshTokenSession(ctx context.Context, signature string, req fosite.Requester) error {
+	return s.createRequest(ctx, tableRefreshTokens, signature, req, ", active, grace_expires_at", true, int64(0))
+}
+
+// GetRefreshTokenSession returns the request of the refresh token together with fosite.ErrInactiveToken if the token
+// has been revoked or its grace period is over.
+func (s *Store) GetRefreshTokenSession(ctx context.Context, signature string, _ fosite.Session) (fosite.Requester, error) {
+	var active bool
+	var graceExpiresAt int64
+	req, err := s.getRequest(ctx, tableRefreshTokens, signature, ", active, grace_expires_at", &active, &graceExpiresAt)
+	if err != nil {
+		return nil, err
+	} else if !active || (graceExpiresAt != 0 && !s.now().Before(fromUnixNano(graceExpiresAt))) {
+		return req, errors.WithStack(fosite.ErrInactiveToken)
+	}
+	return req, nil
+}
+
+func (s *Store) DeleteRefreshTokenSession(ctx context.Context, signature string) error {
+	return s.deleteRequest(ctx, tableRefreshTokens, signature)
+}
+
+// RevokeRefreshToken revokes all refresh tokens of the request, including those within their grace period. It returns
+// fosite.ErrNotFound if the request has no active refresh tokens.
+func (s *Store) RevokeRefreshToken(ctx context.Context, requestID string) error {
+	if _, err := s.exec(ctx, "DELETE FROM fosite_rotated_refresh_tokens WHERE request_id = ?", requestID); err != nil {
+		return err
+	}
+	return s.execAffecting(ctx, "UPDATE fosite_refresh_tokens SET active = ? WHERE request_id = ? AND active = ?", false, requestID, true)
+}
+
+// RevokeRefreshTokenMaybeGracePeriod revokes the refresh tokens of the request immediately. The grace period is
+// configured in the refresh token handler which uses RotateRefreshTokenWithGracePeriod.
+func (s *Store) RevokeRefreshTokenMaybeGracePeriod(ctx context.Context, requestID string, _ string) error {
+	return s.RevokeRefreshToken(ctx, requestID)
+}
+
+// RotateRefreshTokenWithGracePeriod implements oauth2.RefreshTokenGracePeriodStorage.
+func (s *Store) RotateRefreshTokenWithGracePeriod(ctx context.Context, requestID, signature, sealedAccessToken, sealedRefreshToken string, gracePeriod time.Duration) error {
+	// A refresh token is rotated only once. Of two concurrent rotations only one updates the row, the other one fails
+	// with fosite.ErrNotFound instead of splitting the token family.
+	expiresAt := toUnixNano(s.now().Add(gracePeriod))
+	if err := s.execAffecting(ctx,
+		"UPDATE fosite_refresh_tokens SET grace_expires_at = ? WHERE signature = ? AND active = ? AND grace_expires_at = 0",
+		expiresAt, signature, true,
+	); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx,
+		"INSERT INTO fosite_rotated_refresh_tokens (signature, request_id, access_token, refresh_token, expires_at) VALUES (?, ?, ?, ?, ?)",
+		signature, requestID, sealedAccessToken, sealedRefreshToken, expiresAt,
+	)
+	return err
+}
+
+// GetRotatedRefreshTokens implements oauth2.RefreshTokenGracePeriodStorage.
+func (s *Store) GetRotatedRefreshTokens(ctx context.Context, signature string) (string, string, error) {
+	var accessToken, refreshToken string
+	if err := s.queryRow(ctx,
+		"SELECT access_token, refresh_token FROM fosite_rotated_refresh_tokens WHERE signature = ? AND expires_at > ?",
+		signature, toUnixNano(s.now()),
+	).Scan(&accessToken, &refreshToken); err != nil {
+		return "", "", notFound(err)
+	}
+	return accessToken, refreshToken, nil
+}
+
+// RevokeAccessToken deletes the access tokens of the request and denylists the jti of sessions implementing
+// oauth2.AccessTokenJTIContainer.
+func (s *Store) RevokeAccessToken(ctx context.Context, requestID string) error {
+	if err := s.revokeAccessTokenJTIs(ctx, requestID); err != nil {
+		return err
+	}
+	_, err := s.exec(ctx, "DELETE FROM fosite_access_tokens WHERE request_id = ?", requestID)
+	return err
+}
+
+func (s *Store) revokeAccessTokenJTIs(ctx context.Context, requestID string) error {
+	rows, err := s.query(ctx, "SELECT session_type, session_data FROM fosite_access_tokens WHERE request_id = ?", requestID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var sessions []fosite.Session
+	for rows.Next() {
+		var sessionType, sessionData string
+		if err := rows.Scan(&sessionType, &sessionData); err != nil {
+			return errors.WithStack(err)
+		} else if sessionType == "" {
+			continue
+		}
+
+		session, err := s.Sessions.UnmarshalSession(sessionType, []byte(sessionData))
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	_ = rows.Close()
+
+	for _, session := range sessions {
+		if container, ok := session.(oauth2.AccessTokenJTIContainer); ok && container.GetAccessTokenJTI() != "" {
+			if err := s.RevokeJTI(ctx, container.GetAccessTokenJTI(), session.GetExpiresAt(fosite.AccessToken)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RevokeTokensBySubject revokes the access and refresh tokens of all requests of subject and returns their IDs.
+func (s *Store) RevokeTokensBySubject(ctx context.Context, subject string) ([]string, error) {
+	return s.revokeRequests(ctx, "subject", subject)
+}
+
+// RevokeTokensByClient revokes the access and refresh tokens of all requests of the client and returns their IDs.
+func (s *Store) RevokeTokensByClient(ctx context.Context, clientID string) ([]string, error) {
+	return s.revokeRequests(ctx, "client_id", clientID)
+}
+
+func (s *Store) revokeRequests(ctx context.Context, column, value string) ([]string, error) {
+	rows, err := s.query(ctx, fmt.Sprintf(
+		"SELECT request_id FROM fosite_access_tokens WHERE %[1]s = ? UNION SELECT request_id FROM fosite_refresh_tokens WHERE %[1]s = ? AND active = ? AND (grace_expires_at = 0 OR grace_expires_at > ?)",
+		column,
+	), value, value, true, toUnixNano(s.now()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requestIDs []string
+	for rows.Next() {
+		var requestID string
+		if err := rows.Scan(&requestID); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		requestIDs = append(requestIDs, requestID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_ = rows.Close()
+
+	for i, requestID := range requestIDs {
+		if err := s.RevokeRefreshToken(ctx, requestID); err != nil && !errors.Is(err, fosite.ErrNotFound) {
+			return requestIDs[:i], err
+		} else if err := s.RevokeAccessToken(ctx, requestID); err != nil {
+			return requestIDs[:i], err
+		}
+	}
+	return requestIDs, nil
+}
@@ -0,0 +1,658 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+// Package storagetest provides a conformance test suite for implementations of the fosite storage interfaces.
+//
+// Run the suite from a test of the storage implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storagetest.Store {
+//			return newEmptyStoreKnowingClient(storagetest.ClientID)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/handler/oauth2"
+	"github.com/ory/fosite/handler/openid"
+	"github.com/ory/fosite/storage"
+)
+
+// ClientID is the ID of the client the requests of the suite are made by. Stores returned by the NewStore function
+// passed to Run must know this client.
+const ClientID = "storagetest-client"
+
+// Store is the union of the storage interfaces covered by the suite.
+type Store interface {
+	fosite.Storage
+	oauth2.CoreStorage
+	oauth2.TokenRevocationStorage
+	openid.OpenIDConnectRequestStorage
+
+	CreatePKCERequestSession(ctx context.Context, signature string, requester fosite.Requester) error
+	GetPKCERequestSession(ctx context.Context, signature string, session fosite.Session) (fosite.Requester, error)
+	DeletePKCERequestSession(ctx context.Context, signature string) error
+}
+
+// NewStore returns a new and empty store knowing the client with ClientID. Stores implementing fosite.ClientWriter
+// should hash secrets with a low work factor to keep the suite fast.
+type NewStore func(t *testing.T) Store
+
+// Run runs the conformance test suite against the stores returned by newStore. Stores implementing
+// storage.Transactional are tested for rollback, commit and concurrent refresh token rotation as well, stores
+// implementing oauth2.RefreshTokenGracePeriodStorage for concurrent rotations with a grace period, stores implementing
+// oauth2.JTIDenylistStorage for denylisting the JWT access tokens of revoked requests, stores implementing
+// fosite.ClientWriter and fosite.ClientRegistrationStorage for client management and registration.
+func Run(t *testing.T, newStore NewStore) {
+	for name, test := range map[string]func(t *testing.T, s Store){
+		"ClientManager":               testClientManager,
+		"AuthorizeCodeStorage":        testAuthorizeCodeStorage,
+		"AccessTokenStorage":          testAccessTokenStorage,
+		"RefreshTokenStorage":         testRefreshTokenStorage,
+		"TokenRevocationStorage":      testTokenRevocationStorage,
+		"JTIDenylistStorage":          testJTIDenylistStorage,
+		"OpenIDConnectRequestStorage": testOpenIDConnectRequestStorage,
+		"PKCERequestStorage":          testPKCERequestStorage,
+		"Concurrency":                 testConcurrency,
+		"Transactional":               testTransactional,
+		"TransactionalContention":     testTransactionalContention,
+		"GracePeriodContention":       testGracePeriodContention,
+		"ClientWriter":                testClientWriter,
+		"ClientRegistrationStorage":   testClientRegistrationStorage,
+	} {
+		test := test
+		t.Run("suite="+name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+// NewRequest returns a request of the client with ClientID using a fosite.DefaultSession.
+func NewRequest(id string) *fosite.Request {
+	requestedAt := time.Now().UTC().Round(time.Second)
+	session := &fosite.DefaultSession{Subject: "peter", Username: "peter"}
+	for _, tokenType := range []fosite.TokenType{fosite.AccessToken, fosite.RefreshToken, fosite.AuthorizeCode} {
+		session.SetExpiresAt(tokenType, requestedAt.Add(time.Hour))
+	}
+
+	return &fosite.Request{
+		ID:                id,
+		RequestedAt:       requestedAt,
+		Client:            &fosite.DefaultClient{ID: ClientID},
+		RequestedScope:    fosite.Arguments{"openid", "offline"},
+		GrantedScope:      fosite.Arguments{"openid", "offline"},
+		RequestedAudience: fosite.Arguments{"https://api.example.com"},
+		GrantedAudience:   fosite.Arguments{"https://api.example.com"},
+		Form:              url.Values{"redirect_uri": {"https://client.example.com/callback"}},
+		Session:           session,
+	}
+}
+
+// AssertRequestEqual asserts that actual is the stored form of expected. Clients are compared by ID.
+func AssertRequestEqual(t *testing.T, expected, actual fosite.Requester) {
+	require.NotNil(t, actual)
+	assert.Equal(t, expected.GetID(), actual.GetID())
+	assert.True(t, expected.GetRequestedAt().Equal(actual.GetRequestedAt()), "requested at %s, expected %s", actual.GetRequestedAt(), expected.GetRequestedAt())
+	require.NotNil(t, actual.GetClient())
+	assert.Equal(t, expected.GetClient().GetID(), actual.GetClient().GetID())
+	assert.Equal(t, expected.GetRequestedScopes(), actual.GetRequestedScopes())
+	assert.Equal(t, expected.GetGrantedScopes(), actual.GetGrantedScopes())
+	assert.Equal(t, expected.GetRequestedAudience(), actual.GetRequestedAudience())
+	assert.Equal(t, expected.GetGrantedAudience(), actual.GetGrantedAudience())
+	assert.Equal(t, expected.GetRequestForm(), actual.GetRequestForm())
+
+	require.NotNil(t, actual.GetSession())
+	assert.Equal(t, expected.GetSession().GetSubject(), actual.GetSession().GetSubject())
+	assert.Equal(t, expected.GetSession().GetUsername(), actual.GetSession().GetUsername())
+	for _, tokenType := range []fosite.TokenType{fosite.AccessToken, fosite.RefreshToken, fosite.AuthorizeCode} {
+		assert.True(t, expected.GetSession().GetExpiresAt(tokenType).Equal(actual.GetSession().GetExpiresAt(tokenType)), "expiry of %s", tokenType)
+	}
+}
+
+func assertErrorIs(t *testing.T, err, target error) {
+	assert.True(t, errors.Is(err, target), "expected error %v, got %v", target, err)
+}
+
+func testClientManager(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	client, err := s.GetClient(ctx, ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, ClientID, client.GetID())
+
+	_, err = s.GetClient(ctx, "storagetest-unknown-client")
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	exp := time.Now().Add(time.Hour)
+	assert.NoError(t, s.ClientAssertionJWTValid(ctx, "jti"))
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "jti", exp))
+	assertErrorIs(t, s.ClientAssertionJWTValid(ctx, "jti"), fosite.ErrJTIKnown)
+	assertErrorIs(t, s.SetClientAssertionJWT(ctx, "jti", exp), fosite.ErrJTIKnown)
+
+	// Expired JTIs may be used again.
+	require.NoError(t, s.SetClientAssertionJWT(ctx, "expired-jti", time.Now().Add(-time.Minute)))
+	assert.NoError(t, s.ClientAssertionJWTValid(ctx, "expired-jti"))
+	assert.NoError(t, s.SetClientAssertionJWT(ctx, "expired-jti", exp))
+}
+
+func testAuthorizeCodeStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("authorize-code-request")
+
+	_, err := s.GetAuthorizeCodeSession(ctx, "code", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.CreateAuthorizeCodeSession(ctx, "code", req))
+	actual, err := s.GetAuthorizeCodeSession(ctx, "code", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+
+	require.NoError(t, s.InvalidateAuthorizeCodeSession(ctx, "code"))
+	actual, err = s.GetAuthorizeCodeSession(ctx, "code", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrInvalidatedAuthorizeCode)
+	// The request must be returned together with ErrInvalidatedAuthorizeCode to revoke the tokens issued for it.
+	AssertRequestEqual(t, req, actual)
+}
+
+func testAccessTokenStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("access-token-request")
+
+	_, err := s.GetAccessTokenSession(ctx, "at", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "at", req))
+	actual, err := s.GetAccessTokenSession(ctx, "at", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+
+	require.NoError(t, s.DeleteAccessTokenSession(ctx, "at"))
+	_, err = s.GetAccessTokenSession(ctx, "at", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
+
+func testRefreshTokenStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("refresh-token-request")
+
+	_, err := s.GetRefreshTokenSession(ctx, "rt", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "rt", req))
+	actual, err := s.GetRefreshTokenSession(ctx, "rt", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+
+	require.NoError(t, s.DeleteRefreshTokenSession(ctx, "rt"))
+	_, err = s.GetRefreshTokenSession(ctx, "rt", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
+
+func testTokenRevocationStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("revoked-request")
+	other := NewRequest("other-request")
+
+	for _, r := range []fosite.Requester{req, other} {
+		require.NoError(t, s.CreateAccessTokenSession(ctx, r.GetID()+"-at", r))
+		require.NoError(t, s.CreateRefreshTokenSession(ctx, r.GetID()+"-rt", r))
+	}
+
+	require.NoError(t, s.RevokeAccessToken(ctx, req.GetID()))
+	_, err := s.GetAccessTokenSession(ctx, req.GetID()+"-at", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.RevokeRefreshToken(ctx, req.GetID()))
+	actual, err := s.GetRefreshTokenSession(ctx, req.GetID()+"-rt", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrInactiveToken)
+	// The request must be returned together with ErrInactiveToken to detect refresh token reuse.
+	AssertRequestEqual(t, req, actual)
+
+	// Revoking a request without tokens is not an error, or is reported as fosite.ErrNotFound.
+	if err := s.RevokeAccessToken(ctx, "unknown-request"); err != nil {
+		assertErrorIs(t, err, fosite.ErrNotFound)
+	}
+	if err := s.RevokeRefreshToken(ctx, "unknown-request"); err != nil {
+		assertErrorIs(t, err, fosite.ErrNotFound)
+	}
+
+	// The tokens of other requests are left untouched.
+	_, err = s.GetAccessTokenSession(ctx, other.GetID()+"-at", new(fosite.DefaultSession))
+	assert.NoError(t, err)
+	_, err = s.GetRefreshTokenSession(ctx, other.GetID()+"-rt", new(fosite.DefaultSession))
+	assert.NoError(t, err)
+
+	// Depending on the configured grace period, the refresh token is revoked now or later.
+	if err := s.RevokeRefreshTokenMaybeGracePeriod(ctx, other.GetID(), other.GetID()+"-rt"); err != nil {
+		assertErrorIs(t, err, fosite.ErrNotFound)
+	}
+}
+
+func testJTIDenylistStorage(t *testing.T, s Store) {
+	denylist, ok := s.(oauth2.JTIDenylistStorage)
+	if !ok {
+		t.Skip("The store does not implement oauth2.JTIDenylistStorage.")
+	}
+	ctx := context.Background()
+
+	require.NoError(t, denylist.RevokeJTI(ctx, "expired-jti", time.Now().Add(-time.Hour)))
+	require.NoError(t, denylist.RevokeJTI(ctx, "jti", time.Now().Add(time.Hour)))
+	require.NoError(t, denylist.RevokeJTI(ctx, "jti-without-expiry", time.Time{}))
+	for jti, expected := range map[string]bool{"unknown-jti": false, "expired-jti": false, "jti": true, "jti-without-expiry": true} {
+		revoked, err := denylist.IsJTIRevoked(ctx, jti)
+		require.NoError(t, err)
+		assert.Equal(t, expected, revoked, jti)
+	}
+
+	// Revoking the access token of a request denylists the JWT access token issued for it.
+	req := NewRequest("jwt-request")
+	session := &oauth2.JWTSession{Subject: "peter", AccessTokenJTI: "access-token-jti"}
+	session.SetExpiresAt(fosite.AccessToken, time.Now().Add(time.Hour))
+	req.Session = session
+	require.NoError(t, s.CreateAccessTokenSession(ctx, "jwt-request-at", req))
+
+	revoked, err := denylist.IsJTIRevoked(ctx, "access-token-jti")
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, s.RevokeAccessToken(ctx, req.GetID()))
+	revoked, err = denylist.IsJTIRevoked(ctx, "access-token-jti")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func testOpenIDConnectRequestStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("openid-connect-request")
+
+	_, err := s.GetOpenIDConnectSession(ctx, "code", req)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.CreateOpenIDConnectSession(ctx, "code", req))
+	actual, err := s.GetOpenIDConnectSession(ctx, "code", req)
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+
+	require.NoError(t, s.DeleteOpenIDConnectSession(ctx, "code"))
+	_, err = s.GetOpenIDConnectSession(ctx, "code", req)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
+
+func testPKCERequestStorage(t *testing.T, s Store) {
+	ctx := context.Background()
+	req := NewRequest("pkce-request")
+
+	_, err := s.GetPKCERequestSession(ctx, "code", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, s.CreatePKCERequestSession(ctx, "code", req))
+	actual, err := s.GetPKCERequestSession(ctx, "code", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+
+	require.NoError(t, s.DeletePKCERequestSession(ctx, "code"))
+	_, err = s.GetPKCERequestSession(ctx, "code", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
+
+func testConcurrency(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			req := NewRequest(fmt.Sprintf("concurrent-request-%d", i))
+			signature := fmt.Sprintf("concurrent-%d", i)
+			if !assert.NoError(t, s.CreateAccessTokenSession(ctx, signature, req)) ||
+				!assert.NoError(t, s.CreateRefreshTokenSession(ctx, signature, req)) {
+				return
+			}
+
+			actual, err := s.GetAccessTokenSession(ctx, signature, new(fosite.DefaultSession))
+			if assert.NoError(t, err) {
+				assert.Equal(t, req.GetID(), actual.GetID())
+			}
+
+			assert.NoError(t, s.RevokeRefreshToken(ctx, req.GetID()))
+			_, err = s.GetRefreshTokenSession(ctx, signature, new(fosite.DefaultSession))
+			assertErrorIs(t, err, fosite.ErrInactiveToken)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func testTransactional(t *testing.T, s Store) {
+	transactional, ok := s.(storage.Transactional)
+	if !ok {
+		t.Skip("The store does not implement storage.Transactional.")
+	}
+	ctx := context.Background()
+
+	txCtx, err := transactional.BeginTX(ctx)
+	require.NoError(t, err)
+	require.NoError(t, s.CreateAccessTokenSession(txCtx, "rolled-back", NewRequest("rolled-back-request")))
+	require.NoError(t, s.CreateRefreshTokenSession(txCtx, "rolled-back", NewRequest("rolled-back-request")))
+	require.NoError(t, transactional.Rollback(txCtx))
+
+	_, err = s.GetAccessTokenSession(ctx, "rolled-back", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+	_, err = s.GetRefreshTokenSession(ctx, "rolled-back", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	req := NewRequest("committed-request")
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "committed-old", req))
+
+	// Rotating a refresh token within a transaction.
+	txCtx, err = transactional.BeginTX(ctx)
+	require.NoError(t, err)
+	_, err = s.GetRefreshTokenSession(txCtx, "committed-old", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	require.NoError(t, s.RevokeRefreshToken(txCtx, req.GetID()))
+	require.NoError(t, s.CreateAccessTokenSession(txCtx, "committed", req))
+	require.NoError(t, s.CreateRefreshTokenSession(txCtx, "committed", req))
+	require.NoError(t, transactional.Commit(txCtx))
+
+	actual, err := s.GetAccessTokenSession(ctx, "committed", new(fosite.DefaultSession))
+	require.NoError(t, err)
+	AssertRequestEqual(t, req, actual)
+	_, err = s.GetRefreshTokenSession(ctx, "committed", new(fosite.DefaultSession))
+	assert.NoError(t, err)
+	_, err = s.GetRefreshTokenSession(ctx, "committed-old", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrInactiveToken)
+}
+
+// testTransactionalContention rotates the same refresh token in two concurrent transactions. Exactly one of them may
+// succeed, the other one must fail with fosite.ErrSerializationFailure when committing, or with
+// fosite.ErrInactiveToken when reading the refresh token if the store runs the transactions one after the other.
+func testTransactionalContention(t *testing.T, s Store) {
+	transactional, ok := s.(storage.Transactional)
+	if !ok {
+		t.Skip("The store does not implement storage.Transactional.")
+	}
+	ctx := context.Background()
+
+	req := NewRequest("contended-request")
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "contended", req))
+
+	// Both transactions wait for the other one to read the refresh token before rotating it, unless the store blocks
+	// the second transaction until the first one has finished.
+	var read sync.WaitGroup
+	read.Add(2)
+	waitForRead := make(chan struct{})
+	go func() {
+		read.Wait()
+		close(waitForRead)
+	}()
+
+	rotate := func(signature string) error {
+		txCtx, err := transactional.BeginTX(ctx)
+		if err != nil {
+			read.Done()
+			return err
+		}
+
+		_, err = s.GetRefreshTokenSession(txCtx, "contended", new(fosite.DefaultSession))
+		read.Done()
+		if err != nil {
+			_ = transactional.Rollback(txCtx)
+			return err
+		}
+
+		select {
+		case <-waitForRead:
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if err := s.RevokeRefreshToken(txCtx, req.GetID()); err != nil {
+			_ = transactional.Rollback(txCtx)
+			return err
+		} else if err := s.CreateRefreshTokenSession(txCtx, signature, req); err != nil {
+			_ = transactional.Rollback(txCtx)
+			return err
+		}
+		return transactional.Commit(txCtx)
+	}
+
+	signatures := []string{"contended-1", "contended-2"}
+	errs := make([]error, len(signatures))
+	var wg sync.WaitGroup
+	for i, signature := range signatures {
+		wg.Add(1)
+		go func(i int, signature string) {
+			defer wg.Done()
+			errs[i] = rotate(signature)
+		}(i, signature)
+	}
+	wg.Wait()
+
+	var rotated string
+	for i, err := range errs {
+		if err == nil {
+			require.Empty(t, rotated, "both transactions rotated the refresh token")
+			rotated = signatures[i]
+		} else {
+			assert.True(t, errors.Is(err, fosite.ErrSerializationFailure) || errors.Is(err, fosite.ErrInactiveToken), "%+v", err)
+		}
+	}
+	require.NotEmpty(t, rotated, "no transaction rotated the refresh token")
+
+	for _, signature := range signatures {
+		_, err := s.GetRefreshTokenSession(ctx, signature, new(fosite.DefaultSession))
+		if signature == rotated {
+			assert.NoError(t, err)
+		} else {
+			assertErrorIs(t, err, fosite.ErrNotFound)
+		}
+	}
+	_, err := s.GetRefreshTokenSession(ctx, "contended", new(fosite.DefaultSession))
+	assertErrorIs(t, err, fosite.ErrInactiveToken)
+}
+
+func testGracePeriodContention(t *testing.T, s Store) {
+	graceStorage, ok := s.(oauth2.RefreshTokenGracePeriodStorage)
+	if !ok {
+		t.Skip("The store does not implement oauth2.RefreshTokenGracePeriodStorage.")
+	}
+	ctx := context.Background()
+
+	req := NewRequest("graced-request")
+	require.NoError(t, s.CreateRefreshTokenSession(ctx, "graced", req))
+
+	// A refresh token is rotated only once, otherwise the token family would continue in more than one branch.
+	start := make(chan struct{})
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = graceStorage.RotateRefreshTokenWithGracePeriod(ctx, req.GetID(), "graced", fmt.Sprintf("access-%d", i), fmt.Sprintf("refresh-%d", i), time.Minute)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	rotated := -1
+	for i, err := range errs {
+		if err == nil {
+			require.Equal(t, -1, rotated, "the refresh token was rotated more than once")
+			rotated = i
+		} else {
+			assert.True(t, errors.Is(err, fosite.ErrNotFound) || errors.Is(err, fosite.ErrSerializationFailure), "%+v", err)
+		}
+	}
+	require.NotEqual(t, -1, rotated, "the refresh token was not rotated")
+
+	accessToken, refreshToken, err := graceStorage.GetRotatedRefreshTokens(ctx, "graced")
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("access-%d", rotated), accessToken)
+	assert.Equal(t, fmt.Sprintf("refresh-%d", rotated), refreshToken)
+
+	err = graceStorage.RotateRefreshTokenWithGracePeriod(ctx, req.GetID(), "graced", "access", "refresh", time.Minute)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+	err = graceStorage.RotateRefreshTokenWithGracePeriod(ctx, req.GetID(), "unknown", "access", "refresh", time.Minute)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
+
+func testClientWriter(t *testing.T, s Store) {
+	writer, ok := s.(fosite.ClientWriter)
+	if !ok {
+		t.Skip("The store does not implement fosite.ClientWriter.")
+	}
+	ctx := context.Background()
+
+	newClient := func(id string, secret string) *fosite.DefaultClient {
+		return &fosite.DefaultClient{
+			ID:            id,
+			Secret:        []byte(secret),
+			RedirectURIs:  []string{"https://client.example.com/callback"},
+			GrantTypes:    []string{"authorization_code", "refresh_token"},
+			ResponseTypes: []string{"code"},
+			Scopes:        []string{"openid", "offline"},
+		}
+	}
+
+	require.NoError(t, writer.CreateClient(ctx, newClient("writer-client", "secret")))
+	client, err := s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	hash := client.GetHashedSecret()
+	assert.NotEmpty(t, hash)
+	assert.NotEqual(t, []byte("secret"), hash, "secrets must be stored hashed")
+
+	assertErrorIs(t, writer.CreateClient(ctx, newClient("writer-client", "secret")), fosite.ErrConflict)
+	assertErrorIs(t, writer.CreateClient(ctx, newClient("writer-client-without-secret", "")), fosite.ErrInvalidClientMetadata)
+	invalid := newClient("writer-client-with-fragment", "secret")
+	invalid.RedirectURIs = []string{"https://client.example.com/callback#fragment"}
+	assertErrorIs(t, writer.CreateClient(ctx, invalid), fosite.ErrInvalidRedirectURI)
+
+	// Updating a client without a secret keeps the stored secret.
+	updated := newClient("writer-client", "")
+	updated.Scopes = []string{"offline"}
+	require.NoError(t, writer.UpdateClient(ctx, updated))
+	client, err = s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	assert.Equal(t, fosite.Arguments{"offline"}, client.GetScopes())
+	assert.Equal(t, hash, client.GetHashedSecret())
+
+	// Clients read from the store can be edited and written back without changing the stored client before, and
+	// without hashing the stored hash again.
+	client, err = s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	edited, ok := client.(*fosite.DefaultClient)
+	if !ok {
+		edited = client.(*fosite.DefaultOpenIDConnectClient).DefaultClient
+	}
+	edited.Scopes = []string{"openid"}
+	client, err = s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	assert.Equal(t, fosite.Arguments{"offline"}, client.GetScopes(), "clients returned by the store must be copies")
+	require.NoError(t, writer.UpdateClient(ctx, edited))
+	client, err = s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	assert.Equal(t, fosite.Arguments{"openid"}, client.GetScopes())
+	assert.Equal(t, hash, client.GetHashedSecret())
+
+	created := newClient("writer-client-copy", "secret")
+	require.NoError(t, writer.CreateClient(ctx, created))
+	assert.Equal(t, []byte("secret"), created.Secret, "the client passed in must not be modified")
+	require.NoError(t, writer.DeleteClient(ctx, "writer-client-copy"))
+
+	rotated := newClient("writer-client", "new-secret")
+	secretExpiresAt, cutover := time.Now().UTC().Add(time.Hour).Round(time.Second), time.Now().UTC().Add(time.Minute).Round(time.Second)
+	rotated.RotatedSecrets, rotated.RotatedSecretsExpiresAt = [][]byte{hash, []byte("older-hash")}, []time.Time{cutover}
+	rotated.SecretExpiresAt = secretExpiresAt
+	require.NoError(t, writer.UpdateClient(ctx, rotated))
+	client, err = s.GetClient(ctx, "writer-client")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, client.GetHashedSecret())
+	if expiry, ok := client.(fosite.ClientWithSecretExpiry); assert.True(t, ok, "clients must keep the expiry of their secrets") {
+		assert.True(t, secretExpiresAt.Equal(expiry.GetSecretExpiresAt()))
+		assert.Equal(t, [][]byte{hash, []byte("older-hash")}, expiry.GetRotatedHashes())
+		expiresAt := expiry.GetRotatedSecretsExpiresAt()
+		require.NotEmpty(t, expiresAt)
+		assert.True(t, cutover.Equal(expiresAt[0]))
+		if len(expiresAt) > 1 {
+			assert.True(t, expiresAt[1].IsZero())
+		}
+	}
+	assertErrorIs(t, writer.UpdateClient(ctx, newClient("writer-unknown-client", "secret")), fosite.ErrNotFound)
+
+	for _, id := range []string{"writer-client-a", "writer-client-b", "writer-client-c"} {
+		require.NoError(t, writer.CreateClient(ctx, newClient(id, "secret")))
+	}
+	var ids []string
+	var pageToken string
+	for pages := 0; pages < 10; pages++ {
+		clients, nextPageToken, err := writer.ListClients(ctx, 2, pageToken)
+		require.NoError(t, err)
+		assert.True(t, len(clients) <= 2)
+		for _, client := range clients {
+			ids = append(ids, client.GetID())
+		}
+		if nextPageToken == "" {
+			break
+		}
+		pageToken = nextPageToken
+	}
+	assert.Equal(t, []string{ClientID, "writer-client", "writer-client-a", "writer-client-b", "writer-client-c"}, ids)
+
+	require.NoError(t, writer.DeleteClient(ctx, "writer-client"))
+	_, err = s.GetClient(ctx, "writer-client")
+	assertErrorIs(t, err, fosite.ErrNotFound)
+	assertErrorIs(t, writer.DeleteClient(ctx, "writer-client"), fosite.ErrNotFound)
+}
+
+func testClientRegistrationStorage(t *testing.T, s Store) {
+	registration, ok := s.(fosite.ClientRegistrationStorage)
+	if !ok {
+		t.Skip("The store does not implement fosite.ClientRegistrationStorage.")
+	}
+	ctx := context.Background()
+
+	_, err := registration.GetRegistrationAccessTokenSignature(ctx, ClientID)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+
+	require.NoError(t, registration.SetRegistrationAccessTokenSignature(ctx, ClientID, "signature"))
+	require.NoError(t, registration.SetRegistrationAccessTokenSignature(ctx, ClientID, "rotated-signature"))
+	signature, err := registration.GetRegistrationAccessTokenSignature(ctx, ClientID)
+	require.NoError(t, err)
+	assert.Equal(t, "rotated-signature", signature)
+
+	require.NoError(t, registration.SetRegistrationAccessTokenSignature(ctx, ClientID, ""))
+	_, err = registration.GetRegistrationAccessTokenSignature(ctx, ClientID)
+	assertErrorIs(t, err, fosite.ErrNotFound)
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+	"github.com/ory/fosite/storage"
+	"github.com/ory/fosite/storage/codec"
+	"github.com/ory/fosite/storage/storagetest"
+)
+
+func newConformanceMemoryStore() *storage.MemoryStore {
+	s := storage.NewMemoryStore()
+	s.Hasher = &fosite.BCrypt{WorkFactor: 4}
+	s.Clients[storagetest.ClientID] = &fosite.DefaultClient{ID: storagetest.ClientID}
+	return s
+}
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Store {
+		return newConformanceMemoryStore()
+	})
+}
+
+func TestFileStore_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Store {
+		dir := t.TempDir()
+
+		s, err := storage.OpenFileStore(filepath.Join(dir, "store"), newConformanceMemoryStore(), codec.New(codec.DefaultRegistry()))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	})
+}
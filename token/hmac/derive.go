@@ -0,0 +1,66 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package hmac
+
+import (
+	"crypto/sha512"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// DeriveSecret derives an independent secret for the given purpose, for example a token type, from secret using
+// HKDF with SHA-512/256. The derived secret has the same length as secret, capped at 64 byte, so that the minimum
+// secret length is still enforced.
+//
+// Secrets derived for different purposes can not be used to compute HMACs for one another, which allows a single
+// global secret to be used for multiple token types without the tokens being interchangeable.
+func DeriveSecret(secret []byte, purpose string) []byte {
+	if len(secret) == 0 {
+		return nil
+	}
+
+	size := len(secret)
+	if size > 2*minimumSecretLength {
+		size = 2 * minimumSecretLength
+	}
+
+	derived := make([]byte, size)
+	// Reading fewer than 255 * hash size bytes from HKDF never fails.
+	if _, err := io.ReadFull(hkdf.New(sha512.New512_256, secret, nil, []byte(purpose)), derived); err != nil {
+		panic(err)
+	}
+	return derived
+}
+
+// DeriveSecrets applies DeriveSecret to every secret in secrets.
+func DeriveSecrets(secrets [][]byte, purpose string) [][]byte {
+	if secrets == nil {
+		return nil
+	}
+
+	derived := make([][]byte, len(secrets))
+	for k, secret := range secrets {
+		derived[k] = DeriveSecret(secret, purpose)
+	}
+	return derived
+}
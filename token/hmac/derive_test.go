@@ -0,0 +1,52 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package hmac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func TestDeriveSecret(t *testing.T) {
+	secret := []byte("1234567890123456789012345678901234567890")
+
+	access := DeriveSecret(secret, "access_token")
+	refresh := DeriveSecret(secret, "refresh_token")
+	assert.Len(t, access, len(secret))
+	assert.Equal(t, access, DeriveSecret(secret, "access_token"))
+	assert.NotEqual(t, access, refresh)
+	assert.NotEqual(t, secret, access)
+
+	assert.Len(t, DeriveSecret([]byte("foo"), "access_token"), 3)
+	assert.Nil(t, DeriveSecret(nil, "access_token"))
+	assert.Nil(t, DeriveSecrets(nil, "access_token"))
+
+	token, _, err := (&HMACStrategy{GlobalSecret: access}).Generate(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, (&HMACStrategy{GlobalSecret: refresh, RotatedGlobalSecrets: DeriveSecrets([][]byte{secret}, "access_token")}).Validate(context.Background(), token))
+	require.EqualError(t, (&HMACStrategy{GlobalSecret: refresh}).Validate(context.Background(), token), fosite.ErrTokenSignatureMismatch.Error())
+}
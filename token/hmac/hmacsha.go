@@ -25,6 +25,7 @@
 package hmac
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/base64"
@@ -44,6 +45,15 @@ type HMACStrategy struct {
 	TokenEntropy         int
 	GlobalSecret         []byte
 	RotatedGlobalSecrets [][]byte
+
+	// KeyProvider, if set, computes and verifies the HMACs instead of GlobalSecret and RotatedGlobalSecrets. Use it
+	// when the secrets must not be held in process memory.
+	KeyProvider HMACKeyProvider
+
+	// UseKeyIDs, if set, generates versioned tokens of the form "v1.<key id>.<key>.<signature>". The key id allows
+	// Validate to pick the right secret instead of trying every rotated secret in turn. Tokens in the original
+	// "<key>.<signature>" format remain valid. A custom KeyProvider must implement HMACKeyIDProvider.
+	UseKeyIDs bool
 	sync.Mutex
 }
 
@@ -55,21 +65,21 @@ const (
 	minimumSecretLength = 32
 )
 
+// tokenVersionKeyID marks tokens which carry the identifier of the key they were signed with.
+const tokenVersionKeyID = "v1"
+
 var b64 = base64.URLEncoding.WithPadding(base64.NoPadding)
 
 // Generate generates a token and a matching signature or returns an error.
 // This method implements rfc6819 Section 5.1.4.2.2: Use High Entropy for Secrets.
-func (c *HMACStrategy) Generate() (string, string, error) {
+func (c *HMACStrategy) Generate(ctx context.Context) (string, string, error) {
 	c.Lock()
 	defer c.Unlock()
 
-	if len(c.GlobalSecret) < minimumSecretLength {
+	if c.KeyProvider == nil && len(c.GlobalSecret) < minimumSecretLength {
 		return "", "", errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(c.GlobalSecret))
 	}
 
-	var signingKey [32]byte
-	copy(signingKey[:], c.GlobalSecret)
-
 	if c.TokenEntropy < minimumEntropy {
 		c.TokenEntropy = minimumEntropy
 	}
@@ -86,58 +96,39 @@ func (c *HMACStrategy) Generate() (string, string, error) {
 		return "", "", errorsx.WithStack(err)
 	}
 
-	signature := generateHMAC(tokenKey, &signingKey)
-
-	encodedSignature := b64.EncodeToString(signature)
-	encodedToken := fmt.Sprintf("%s.%s", b64.EncodeToString(tokenKey), encodedSignature)
-	return encodedToken, encodedSignature, nil
-}
-
-// Validate validates a token and returns its signature or an error if the token is not valid.
-func (c *HMACStrategy) Validate(token string) (err error) {
-	var keys [][]byte
-
-	if len(c.GlobalSecret) > 0 {
-		keys = append(keys, c.GlobalSecret)
-	}
+	if !c.UseKeyIDs {
+		signature, err := c.keyProvider().MAC(ctx, tokenKey)
+		if err != nil {
+			return "", "", c.wrapKeyProviderError(err)
+		}
 
-	if len(c.RotatedGlobalSecrets) > 0 {
-		keys = append(keys, c.RotatedGlobalSecrets...)
+		encodedSignature := b64.EncodeToString(signature)
+		encodedToken := fmt.Sprintf("%s.%s", b64.EncodeToString(tokenKey), encodedSignature)
+		return encodedToken, encodedSignature, nil
 	}
 
-	for _, key := range keys {
-		if err = c.validate(key, token); err == nil {
-			return nil
-		} else if errors.Is(err, fosite.ErrTokenSignatureMismatch) {
-		} else {
-			return err
-		}
+	provider, ok := c.keyProvider().(HMACKeyIDProvider)
+	if !ok {
+		return "", "", errors.Errorf("key provider of type %T does not support key ids", c.KeyProvider)
 	}
 
-	if err == nil {
-		return errors.New("a secret for signing HMAC-SHA512/256 is expected to be defined, but none were")
+	signature, keyID, err := provider.MACWithKeyID(ctx, tokenKey)
+	if err != nil {
+		return "", "", c.wrapKeyProviderError(err)
+	} else if keyID == "" || strings.Contains(keyID, ".") {
+		return "", "", errors.Errorf("key id %q must not be empty or contain a dot", keyID)
 	}
 
-	return err
+	encodedSignature := b64.EncodeToString(signature)
+	encodedToken := fmt.Sprintf("%s.%s.%s.%s", tokenVersionKeyID, keyID, b64.EncodeToString(tokenKey), encodedSignature)
+	return encodedToken, encodedSignature, nil
 }
 
-func (c *HMACStrategy) validate(secret []byte, token string) error {
-	if len(secret) < minimumSecretLength {
-		return errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(secret))
-	}
-
-	var signingKey [32]byte
-	copy(signingKey[:], secret)
-
-	split := strings.Split(token, ".")
-	if len(split) != 2 {
-		return errorsx.WithStack(fosite.ErrInvalidTokenFormat)
-	}
-
-	tokenKey := split[0]
-	tokenSignature := split[1]
-	if tokenKey == "" || tokenSignature == "" {
-		return errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+// Validate validates a token and returns its signature or an error if the token is not valid.
+func (c *HMACStrategy) Validate(ctx context.Context, token string) error {
+	keyID, tokenKey, tokenSignature, err := splitToken(token)
+	if err != nil {
+		return err
 	}
 
 	decodedTokenSignature, err := b64.DecodeString(tokenSignature)
@@ -150,23 +141,66 @@ func (c *HMACStrategy) validate(secret []byte, token string) error {
 		return errorsx.WithStack(err)
 	}
 
-	expectedMAC := generateHMAC(decodedTokenKey, &signingKey)
-	if !hmac.Equal(expectedMAC, decodedTokenSignature) {
-		// Hash is invalid
-		return errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+	if keyID == "" {
+		err = c.keyProvider().Verify(ctx, decodedTokenKey, decodedTokenSignature)
+	} else if provider, ok := c.keyProvider().(HMACKeyIDProvider); ok {
+		err = provider.VerifyWithKeyID(ctx, keyID, decodedTokenKey, decodedTokenSignature)
+	} else {
+		return errorsx.WithStack(fosite.ErrInvalidTokenFormat.WithDebugf("Key provider of type %T does not support key ids.", c.KeyProvider))
+	}
+
+	if err != nil {
+		return c.wrapKeyProviderError(err)
 	}
 
 	return nil
 }
 
 func (c *HMACStrategy) Signature(token string) string {
+	_, _, signature, err := splitToken(token)
+	if err != nil {
+		return ""
+	}
+
+	return signature
+}
+
+// splitToken splits a token in either the "<key>.<signature>" or the "v1.<key id>.<key>.<signature>" format.
+func splitToken(token string) (keyID, tokenKey, tokenSignature string, err error) {
 	split := strings.Split(token, ".")
+	switch {
+	case len(split) == 2:
+		tokenKey, tokenSignature = split[0], split[1]
+	case len(split) == 4 && split[0] == tokenVersionKeyID:
+		keyID, tokenKey, tokenSignature = split[1], split[2], split[3]
+		if keyID == "" {
+			return "", "", "", errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+		}
+	default:
+		return "", "", "", errorsx.WithStack(fosite.ErrInvalidTokenFormat)
+	}
 
-	if len(split) != 2 {
-		return ""
+	if tokenKey == "" || tokenSignature == "" {
+		return "", "", "", errorsx.WithStack(fosite.ErrInvalidTokenFormat)
 	}
 
-	return split[1]
+	return keyID, tokenKey, tokenSignature, nil
+}
+
+func (c *HMACStrategy) keyProvider() HMACKeyProvider {
+	if c.KeyProvider != nil {
+		return c.KeyProvider
+	}
+	return &secretKeyProvider{globalSecret: c.GlobalSecret, rotatedGlobalSecrets: c.RotatedGlobalSecrets}
+}
+
+// wrapKeyProviderError reports failures of a custom HMACKeyProvider, for example because a remote key service timed
+// out, as fosite.ErrTemporarilyUnavailable. Signature mismatches and errors of the in-memory secrets are returned as-is.
+func (c *HMACStrategy) wrapKeyProviderError(err error) error {
+	if c.KeyProvider == nil || errors.Is(err, fosite.ErrTokenSignatureMismatch) {
+		return err
+	}
+	return errorsx.WithStack(fosite.ErrTemporarilyUnavailable.WithWrap(err).WithDebug(err.Error()))
 }
 
 func generateHMAC(data []byte, key *[32]byte) []byte {
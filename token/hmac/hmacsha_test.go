@@ -22,6 +22,9 @@
 package hmac
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/ory/fosite"
@@ -32,7 +35,7 @@ import (
 
 func TestGenerateFailsWithShortCredentials(t *testing.T) {
 	cg := HMACStrategy{GlobalSecret: []byte("foo")}
-	challenge, signature, err := cg.Generate()
+	challenge, signature, err := cg.Generate(context.Background())
 	require.Error(t, err)
 	require.Empty(t, challenge)
 	require.Empty(t, signature)
@@ -57,20 +60,20 @@ func TestGenerate(t *testing.T) {
 			TokenEntropy: c.tokenEntropy,
 		}
 
-		token, signature, err := cg.Generate()
+		token, signature, err := cg.Generate(context.Background())
 		require.NoError(t, err)
 		require.NotEmpty(t, token)
 		require.NotEmpty(t, signature)
 		t.Logf("Token: %s\n Signature: %s", token, signature)
 
-		err = cg.Validate(token)
+		err = cg.Validate(context.Background(), token)
 		require.NoError(t, err)
 
 		validateSignature := cg.Signature(token)
 		assert.Equal(t, signature, validateSignature)
 
 		cg.GlobalSecret = []byte("baz")
-		err = cg.Validate(token)
+		err = cg.Validate(context.Background(), token)
 		require.Error(t, err)
 	}
 }
@@ -87,7 +90,7 @@ func TestValidateSignatureRejects(t *testing.T) {
 		"foo.",
 		".foo",
 	} {
-		err = cg.Validate(c)
+		err = cg.Validate(context.Background(), c)
 		assert.Error(t, err)
 		t.Logf("Passed test case %d", k)
 	}
@@ -105,11 +108,11 @@ func TestValidateWithRotatedKey(t *testing.T) {
 		},
 	}
 
-	token, _, err := old.Generate()
+	token, _, err := old.Generate(context.Background())
 	require.NoError(t, err)
 
-	require.EqualError(t, now.Validate("thisisatoken.withaninvalidsignature"), fosite.ErrTokenSignatureMismatch.Error())
-	require.NoError(t, now.Validate(token))
+	require.EqualError(t, now.Validate(context.Background(), "thisisatoken.withaninvalidsignature"), fosite.ErrTokenSignatureMismatch.Error())
+	require.NoError(t, now.Validate(context.Background(), token))
 }
 
 func TestValidateWithRotatedKeyInvalid(t *testing.T) {
@@ -124,10 +127,49 @@ func TestValidateWithRotatedKeyInvalid(t *testing.T) {
 		},
 	}
 
-	token, _, err := old.Generate()
+	token, _, err := old.Generate(context.Background())
 	require.NoError(t, err)
 
-	require.EqualError(t, now.Validate(token), "secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got 31 byte")
+	require.EqualError(t, now.Validate(context.Background(), token), "secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got 31 byte")
 
-	require.EqualError(t, new(HMACStrategy).Validate(token), "a secret for signing HMAC-SHA512/256 is expected to be defined, but none were")
+	require.EqualError(t, new(HMACStrategy).Validate(context.Background(), token), "a secret for signing HMAC-SHA512/256 is expected to be defined, but none were")
+}
+
+func TestValidateWithKeyID(t *testing.T) {
+	old := HMACStrategy{
+		GlobalSecret: []byte("1234567890123456789012345678901234567890"),
+		UseKeyIDs:    true,
+	}
+	now := HMACStrategy{
+		GlobalSecret: []byte("0000000090123456789012345678901234567890"),
+		RotatedGlobalSecrets: [][]byte{
+			[]byte("abcdefgh90123456789012345678901"),
+			[]byte("1234567890123456789012345678901234567890"),
+		},
+		UseKeyIDs: true,
+	}
+
+	token, signature, err := old.Generate(context.Background())
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 4)
+	assert.Equal(t, "v1", parts[0])
+	assert.Equal(t, KeyID(old.GlobalSecret), parts[1])
+	assert.Equal(t, signature, now.Signature(token))
+
+	// The short rotated secret is skipped because the key id points straight to the matching secret.
+	require.NoError(t, now.Validate(context.Background(), token))
+
+	// Tokens without a key id remain valid.
+	legacy, _, err := (&HMACStrategy{GlobalSecret: old.GlobalSecret}).Generate(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, old.Validate(context.Background(), legacy))
+
+	unknown := fmt.Sprintf("v1.%s.%s.%s", KeyID([]byte("ffffffffffffffffffffffffffffffffffffffff")), parts[2], parts[3])
+	require.EqualError(t, now.Validate(context.Background(), unknown), fosite.ErrTokenSignatureMismatch.Error())
+
+	for _, c := range []string{"v2." + strings.Join(parts[1:], "."), "v1.." + parts[2] + "." + parts[3]} {
+		require.EqualError(t, now.Validate(context.Background(), c), fosite.ErrInvalidTokenFormat.Error())
+	}
 }
@@ -0,0 +1,226 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package hmac
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ory/x/errorsx"
+	"github.com/pkg/errors"
+
+	"github.com/ory/fosite"
+)
+
+// HMACKeyProvider computes and verifies the HMAC-SHA512/256 of HMAC tokens. Implementations may delegate to an
+// external key service such as a KMS or HSM so that the secrets never have to be held in process memory.
+type HMACKeyProvider interface {
+	// MAC returns the HMAC of data using the current secret.
+	MAC(ctx context.Context, data []byte) ([]byte, error)
+
+	// Verify checks that mac is the HMAC of data for the current or any of the rotated secrets. It returns
+	// fosite.ErrTokenSignatureMismatch if none of the secrets produced mac.
+	Verify(ctx context.Context, data []byte, mac []byte) error
+}
+
+// HMACKeyIDProvider is a HMACKeyProvider which identifies its secrets. It is required to generate and validate tokens
+// when HMACStrategy.UseKeyIDs is set.
+type HMACKeyIDProvider interface {
+	HMACKeyProvider
+
+	// MACWithKeyID returns the HMAC of data using the current secret and the identifier of that secret. The
+	// identifier must not contain a dot.
+	MACWithKeyID(ctx context.Context, data []byte) (mac []byte, keyID string, err error)
+
+	// VerifyWithKeyID checks that mac is the HMAC of data for the secret identified by keyID. It returns
+	// fosite.ErrTokenSignatureMismatch if the key is unknown or did not produce mac.
+	VerifyWithKeyID(ctx context.Context, keyID string, data []byte, mac []byte) error
+}
+
+// KeyID returns a short identifier for an HMAC secret. It is derived from a SHA-256 hash of the signing key and
+// does not reveal the secret.
+func KeyID(secret []byte) string {
+	var signingKey [32]byte
+	copy(signingKey[:], secret)
+
+	hash := sha256.Sum256(signingKey[:])
+	return b64.EncodeToString(hash[:6])
+}
+
+// secretKeyProvider is the HMACKeyProvider backed by HMACStrategy.GlobalSecret and HMACStrategy.RotatedGlobalSecrets.
+type secretKeyProvider struct {
+	globalSecret         []byte
+	rotatedGlobalSecrets [][]byte
+}
+
+func (p *secretKeyProvider) MAC(_ context.Context, data []byte) ([]byte, error) {
+	if len(p.globalSecret) < minimumSecretLength {
+		return nil, errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(p.globalSecret))
+	}
+
+	var signingKey [32]byte
+	copy(signingKey[:], p.globalSecret)
+	return generateHMAC(data, &signingKey), nil
+}
+
+func (p *secretKeyProvider) MACWithKeyID(ctx context.Context, data []byte) ([]byte, string, error) {
+	mac, err := p.MAC(ctx, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return mac, KeyID(p.globalSecret), nil
+}
+
+func (p *secretKeyProvider) VerifyWithKeyID(_ context.Context, keyID string, data []byte, mac []byte) error {
+	for _, key := range append([][]byte{p.globalSecret}, p.rotatedGlobalSecrets...) {
+		if len(key) > 0 && KeyID(key) == keyID {
+			return verify(key, data, mac)
+		}
+	}
+
+	return errorsx.WithStack(fosite.ErrTokenSignatureMismatch.WithDebugf("The token was signed with unknown key '%s'.", keyID))
+}
+
+func (p *secretKeyProvider) Verify(_ context.Context, data []byte, mac []byte) (err error) {
+	var keys [][]byte
+
+	if len(p.globalSecret) > 0 {
+		keys = append(keys, p.globalSecret)
+	}
+
+	if len(p.rotatedGlobalSecrets) > 0 {
+		keys = append(keys, p.rotatedGlobalSecrets...)
+	}
+
+	for _, key := range keys {
+		if err = verify(key, data, mac); err == nil {
+			return nil
+		} else if errors.Is(err, fosite.ErrTokenSignatureMismatch) {
+		} else {
+			return err
+		}
+	}
+
+	if err == nil {
+		return errors.New("a secret for signing HMAC-SHA512/256 is expected to be defined, but none were")
+	}
+
+	return err
+}
+
+func verify(secret []byte, data []byte, mac []byte) error {
+	if len(secret) < minimumSecretLength {
+		return errors.Errorf("secret for signing HMAC-SHA512/256 is expected to be 32 byte long, got %d byte", len(secret))
+	}
+
+	var signingKey [32]byte
+	copy(signingKey[:], secret)
+
+	expectedMAC := generateHMAC(data, &signingKey)
+	if !hmac.Equal(expectedMAC, mac) {
+		// Hash is invalid
+		return errorsx.WithStack(fosite.ErrTokenSignatureMismatch)
+	}
+
+	return nil
+}
+
+// FileKeyProvider is a HMACKeyProvider which reads the secrets from disk on every operation. It is meant as a local
+// stand-in for a remote key service in tests and development environments and must not be used in production.
+//
+// Leading and trailing whitespace is trimmed from the file contents.
+type FileKeyProvider struct {
+	// Path is the location of the file containing the current secret.
+	Path string
+
+	// RotatedPaths are the locations of files containing rotated secrets which are still accepted during validation.
+	RotatedPaths []string
+}
+
+var _ HMACKeyIDProvider = new(FileKeyProvider)
+
+// MAC returns the HMAC of data using the secret stored at Path.
+func (p *FileKeyProvider) MAC(ctx context.Context, data []byte) ([]byte, error) {
+	secrets, err := p.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.MAC(ctx, data)
+}
+
+// Verify checks mac using the secrets stored at Path and RotatedPaths.
+func (p *FileKeyProvider) Verify(ctx context.Context, data []byte, mac []byte) error {
+	secrets, err := p.load(ctx)
+	if err != nil {
+		return err
+	}
+	return secrets.Verify(ctx, data, mac)
+}
+
+// MACWithKeyID returns the HMAC of data using the secret stored at Path and the identifier of that secret.
+func (p *FileKeyProvider) MACWithKeyID(ctx context.Context, data []byte) ([]byte, string, error) {
+	secrets, err := p.load(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return secrets.MACWithKeyID(ctx, data)
+}
+
+// VerifyWithKeyID checks mac using the secret stored at Path or RotatedPaths which is identified by keyID.
+func (p *FileKeyProvider) VerifyWithKeyID(ctx context.Context, keyID string, data []byte, mac []byte) error {
+	secrets, err := p.load(ctx)
+	if err != nil {
+		return err
+	}
+	return secrets.VerifyWithKeyID(ctx, keyID, data, mac)
+}
+
+func (p *FileKeyProvider) load(ctx context.Context) (*secretKeyProvider, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	global, err := readSecret(p.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated := make([][]byte, len(p.RotatedPaths))
+	for k, path := range p.RotatedPaths {
+		if rotated[k], err = readSecret(path); err != nil {
+			return nil, err
+		}
+	}
+
+	return &secretKeyProvider{globalSecret: global, rotatedGlobalSecrets: rotated}, nil
+}
+
+func readSecret(path string) ([]byte, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errorsx.WithStack(err)
+	}
+	return []byte(strings.TrimSpace(string(raw))), nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package hmac
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/fosite"
+)
+
+func writeSecret(t *testing.T, dir, name, secret string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(secret+"\n"), 0600))
+	return path
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	old := writeSecret(t, dir, "old", "1234567890123456789012345678901234567890")
+	current := writeSecret(t, dir, "current", "0000000090123456789012345678901234567890")
+
+	before := HMACStrategy{KeyProvider: &FileKeyProvider{Path: old}}
+	after := HMACStrategy{KeyProvider: &FileKeyProvider{Path: current, RotatedPaths: []string{old}}}
+
+	token, signature, err := before.Generate(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, signature, before.Signature(token))
+
+	require.NoError(t, before.Validate(context.Background(), token))
+	require.NoError(t, after.Validate(context.Background(), token))
+
+	// The file backed provider must produce the same HMACs as the in-memory secrets.
+	require.NoError(t, (&HMACStrategy{GlobalSecret: []byte("1234567890123456789012345678901234567890")}).Validate(context.Background(), token))
+
+	token, _, err = after.Generate(context.Background())
+	require.NoError(t, err)
+	err = before.Validate(context.Background(), token)
+	assert.True(t, errors.Is(err, fosite.ErrTokenSignatureMismatch))
+	assert.False(t, errors.Is(err, fosite.ErrTemporarilyUnavailable))
+}
+
+func TestKeyProviderErrorsAreTemporarilyUnavailable(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := HMACStrategy{KeyProvider: &FileKeyProvider{Path: writeSecret(t, dir, "current", "1234567890123456789012345678901234567890")}}
+	token, _, err := valid.Generate(context.Background())
+	require.NoError(t, err)
+
+	missing := HMACStrategy{KeyProvider: &FileKeyProvider{Path: filepath.Join(dir, "does-not-exist")}}
+	_, _, err = missing.Generate(context.Background())
+	assert.True(t, errors.Is(err, fosite.ErrTemporarilyUnavailable))
+	assert.True(t, errors.Is(missing.Validate(context.Background(), token), fosite.ErrTemporarilyUnavailable))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err = valid.Generate(ctx)
+	assert.True(t, errors.Is(err, fosite.ErrTemporarilyUnavailable))
+	assert.True(t, errors.Is(err, context.Canceled))
+}
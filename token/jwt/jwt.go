@@ -31,6 +31,7 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"strings"
+	"time"
 
 	"github.com/ory/x/errorsx"
 	"gopkg.in/square/go-jose.v2"
@@ -51,21 +52,34 @@ var SHA256HashSize = crypto.SHA256.Size()
 
 // RS256JWTStrategy is responsible for generating and validating JWT challenges
 type RS256JWTStrategy struct {
+	// PrivateKey is either a *rsa.PrivateKey, a jose.OpaqueSigner or a Signer.
 	PrivateKey interface{}
+
+	// Clock is used to validate the exp, iat and nbf claims. Defaults to TimeFunc.
+	Clock Clock
+
+	// Leeway is the tolerated clock skew when validating the exp, iat and nbf claims.
+	Leeway time.Duration
 }
 
 // Generate generates a new authorize code or returns an error. set secret
 func (j *RS256JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
-	return generateToken(claims, header, jose.RS256, j.PrivateKey)
+	return generateToken(ctx, claims, header, jose.RS256, j.PrivateKey)
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
 func (j *RS256JWTStrategy) Validate(ctx context.Context, token string) (string, error) {
 	switch t := j.PrivateKey.(type) {
 	case *rsa.PrivateKey:
-		return validateToken(token, t.PublicKey)
+		return validateToken(token, t.PublicKey, j.parserOptions()...)
 	case jose.OpaqueSigner:
-		return validateToken(token, t.Public().Key)
+		return validateToken(token, t.Public().Key, j.parserOptions()...)
+	case Signer:
+		public, err := signerPublicKey(ctx, t)
+		if err != nil {
+			return "", err
+		}
+		return validateToken(token, public.Key, j.parserOptions()...)
 	default:
 		return "", errors.New("Unable to validate token. Invalid PrivateKey type")
 	}
@@ -75,9 +89,15 @@ func (j *RS256JWTStrategy) Validate(ctx context.Context, token string) (string,
 func (j *RS256JWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
 	switch t := j.PrivateKey.(type) {
 	case *rsa.PrivateKey:
-		return decodeToken(token, t.PublicKey)
+		return decodeToken(token, t.PublicKey, j.parserOptions()...)
 	case jose.OpaqueSigner:
-		return decodeToken(token, t.Public().Key)
+		return decodeToken(token, t.Public().Key, j.parserOptions()...)
+	case Signer:
+		public, err := signerPublicKey(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		return decodeToken(token, public.Key, j.parserOptions()...)
 	default:
 		return nil, errors.New("Unable to decode token. Invalid PrivateKey type")
 	}
@@ -98,23 +118,40 @@ func (j *RS256JWTStrategy) GetSigningMethodLength() int {
 	return SHA256HashSize
 }
 
+func (j *RS256JWTStrategy) parserOptions() []ParserOption {
+	return []ParserOption{WithClock(j.Clock), WithLeeway(j.Leeway)}
+}
+
 // ES256JWTStrategy is responsible for generating and validating JWT challenges
 type ES256JWTStrategy struct {
+	// PrivateKey is either a *ecdsa.PrivateKey, a jose.OpaqueSigner or a Signer.
 	PrivateKey interface{}
+
+	// Clock is used to validate the exp, iat and nbf claims. Defaults to TimeFunc.
+	Clock Clock
+
+	// Leeway is the tolerated clock skew when validating the exp, iat and nbf claims.
+	Leeway time.Duration
 }
 
 // Generate generates a new authorize code or returns an error. set secret
 func (j *ES256JWTStrategy) Generate(ctx context.Context, claims MapClaims, header Mapper) (string, string, error) {
-	return generateToken(claims, header, jose.ES256, j.PrivateKey)
+	return generateToken(ctx, claims, header, jose.ES256, j.PrivateKey)
 }
 
 // Validate validates a token and returns its signature or an error if the token is not valid.
 func (j *ES256JWTStrategy) Validate(ctx context.Context, token string) (string, error) {
 	switch t := j.PrivateKey.(type) {
 	case *ecdsa.PrivateKey:
-		return validateToken(token, t.PublicKey)
+		return validateToken(token, t.PublicKey, j.parserOptions()...)
 	case jose.OpaqueSigner:
-		return validateToken(token, t.Public().Key)
+		return validateToken(token, t.Public().Key, j.parserOptions()...)
+	case Signer:
+		public, err := signerPublicKey(ctx, t)
+		if err != nil {
+			return "", err
+		}
+		return validateToken(token, public.Key, j.parserOptions()...)
 	default:
 		return "", errors.New("Unable to validate token. Invalid PrivateKey type")
 	}
@@ -124,9 +161,15 @@ func (j *ES256JWTStrategy) Validate(ctx context.Context, token string) (string,
 func (j *ES256JWTStrategy) Decode(ctx context.Context, token string) (*Token, error) {
 	switch t := j.PrivateKey.(type) {
 	case *ecdsa.PrivateKey:
-		return decodeToken(token, t.PublicKey)
+		return decodeToken(token, t.PublicKey, j.parserOptions()...)
 	case jose.OpaqueSigner:
-		return decodeToken(token, t.Public().Key)
+		return decodeToken(token, t.Public().Key, j.parserOptions()...)
+	case Signer:
+		public, err := signerPublicKey(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		return decodeToken(token, public.Key, j.parserOptions()...)
 	default:
 		return nil, errors.New("Unable to decode token. Invalid PrivateKey type")
 	}
@@ -147,12 +190,22 @@ func (j *ES256JWTStrategy) GetSigningMethodLength() int {
 	return SHA256HashSize
 }
 
-func generateToken(claims MapClaims, header Mapper, signingMethod jose.SignatureAlgorithm, privateKey interface{}) (rawToken string, sig string, err error) {
+func (j *ES256JWTStrategy) parserOptions() []ParserOption {
+	return []ParserOption{WithClock(j.Clock), WithLeeway(j.Leeway)}
+}
+
+func generateToken(ctx context.Context, claims MapClaims, header Mapper, signingMethod jose.SignatureAlgorithm, privateKey interface{}) (rawToken string, sig string, err error) {
 	if header == nil || claims == nil {
 		err = errors.New("Either claims or header is nil.")
 		return
 	}
 
+	if signer, ok := privateKey.(Signer); ok {
+		if privateKey, err = newContextSigner(ctx, signer); err != nil {
+			return
+		}
+	}
+
 	token := NewWithClaims(signingMethod, claims)
 	token.Header = assign(token.Header, header.ToMap())
 
@@ -165,13 +218,13 @@ func generateToken(claims MapClaims, header Mapper, signingMethod jose.Signature
 	return
 }
 
-func decodeToken(token string, verificationKey interface{}) (*Token, error) {
+func decodeToken(token string, verificationKey interface{}, opts ...ParserOption) (*Token, error) {
 	keyFunc := func(*Token) (interface{}, error) { return verificationKey, nil }
-	return ParseWithClaims(token, MapClaims{}, keyFunc)
+	return ParseWithClaims(token, MapClaims{}, keyFunc, opts...)
 }
 
-func validateToken(tokenStr string, verificationKey interface{}) (string, error) {
-	_, err := decodeToken(tokenStr, verificationKey)
+func validateToken(tokenStr string, verificationKey interface{}, opts ...ParserOption) (string, error) {
+	_, err := decodeToken(tokenStr, verificationKey, opts...)
 	if err != nil {
 		return "", err
 	}
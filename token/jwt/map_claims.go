@@ -13,6 +13,11 @@ import (
 
 var TimeFunc = time.Now
 
+// Clock returns the current time which is used to validate the time based claims.
+type Clock interface {
+	Now() time.Time
+}
+
 // MapClaims provides backwards compatible validations not available in `go-jose`.
 // It was taken from [here](https://raw.githubusercontent.com/form3tech-oss/jwt-go/master/map_claims.go).
 //
@@ -104,20 +109,27 @@ func (m MapClaims) toInt64(claim string) (int64, bool) {
 // As well, if any of the above claims are not in the token, it will still
 // be considered a valid claim.
 func (m MapClaims) Valid() error {
+	return m.ValidAt(TimeFunc(), 0)
+}
+
+// ValidAt validates the time based claims "exp, iat, nbf" at the given time. Up to leeway of clock skew between the
+// issuer and this server is tolerated. As with Valid, claims which are not in the token are considered valid.
+func (m MapClaims) ValidAt(at time.Time, leeway time.Duration) error {
 	vErr := new(ValidationError)
-	now := TimeFunc().Unix()
+	now := at.Unix()
+	skew := int64(leeway / time.Second)
 
-	if !m.VerifyExpiresAt(now, false) {
+	if !m.VerifyExpiresAt(now-skew, false) {
 		vErr.Inner = errors.New("Token is expired")
 		vErr.Errors |= ValidationErrorExpired
 	}
 
-	if !m.VerifyIssuedAt(now, false) {
+	if !m.VerifyIssuedAt(now+skew, false) {
 		vErr.Inner = errors.New("Token used before issued")
 		vErr.Errors |= ValidationErrorIssuedAt
 	}
 
-	if !m.VerifyNotBefore(now, false) {
+	if !m.VerifyNotBefore(now+skew, false) {
 		vErr.Inner = errors.New("Token is not valid yet")
 		vErr.Errors |= ValidationErrorNotValidYet
 	}
@@ -1,6 +1,9 @@
 package jwt
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 // Test taken from taken from [here](https://raw.githubusercontent.com/form3tech-oss/jwt-go/master/map_claims_test.go).
 func Test_mapClaims_list_aud(t *testing.T) {
@@ -93,3 +96,29 @@ func Test_mapClaims_string_aud_no_claim_not_required(t *testing.T) {
 		t.Fatalf("Failed to verify claims, wanted: %v got %v", want, got)
 	}
 }
+
+func Test_mapClaims_valid_at_with_leeway(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	for k, tc := range []struct {
+		claims MapClaims
+		leeway time.Duration
+		valid  bool
+	}{
+		{claims: MapClaims{"exp": now.Add(-time.Second * 10).Unix()}, valid: false},
+		{claims: MapClaims{"exp": now.Add(-time.Second * 10).Unix()}, leeway: time.Second * 30, valid: true},
+		{claims: MapClaims{"exp": now.Add(-time.Minute).Unix()}, leeway: time.Second * 30, valid: false},
+		{claims: MapClaims{"nbf": now.Add(time.Second * 10).Unix()}, valid: false},
+		{claims: MapClaims{"nbf": now.Add(time.Second * 10).Unix()}, leeway: time.Second * 30, valid: true},
+		{claims: MapClaims{"iat": now.Add(time.Second * 10).Unix()}, valid: false},
+		{claims: MapClaims{"iat": now.Add(time.Second * 10).Unix()}, leeway: time.Second * 30, valid: true},
+		{claims: MapClaims{"iat": now.Add(time.Minute).Unix()}, leeway: time.Second * 30, valid: false},
+	} {
+		err := tc.claims.ValidAt(now, tc.leeway)
+		if tc.valid && err != nil {
+			t.Fatalf("case %d: expected claims to be valid, got %v", k, err)
+		} else if !tc.valid && err == nil {
+			t.Fatalf("case %d: expected claims to be invalid", k)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ErrSignerUnavailable is returned when a Signer could not produce a signature or public key, for example because
+// the remote key service timed out or returned an error.
+var ErrSignerUnavailable = errors.New("the signing key service is currently unavailable")
+
+// Signer signs JWTs using a key which is never held in process memory, for example a key stored in a KMS or HSM.
+//
+// Unlike jose.OpaqueSigner, every call receives the context of the request that triggered it. Implementations
+// should honor the context's deadline and cancellation.
+type Signer interface {
+	// Public returns the public key of the current signing key.
+	Public(ctx context.Context) (*jose.JSONWebKey, error)
+
+	// Algs returns a list of supported signing algorithms.
+	Algs() []jose.SignatureAlgorithm
+
+	// SignPayload signs a payload with the current signing key using the given algorithm.
+	SignPayload(ctx context.Context, payload []byte, alg jose.SignatureAlgorithm) ([]byte, error)
+}
+
+// signerError marks an error returned by a Signer so that it can be detected with errors.Is(err, ErrSignerUnavailable)
+// while keeping the original cause (e.g. context.DeadlineExceeded) in the chain.
+type signerError struct {
+	cause error
+}
+
+func (e *signerError) Error() string {
+	return ErrSignerUnavailable.Error() + ": " + e.cause.Error()
+}
+
+func (e *signerError) Unwrap() error {
+	return e.cause
+}
+
+func (e *signerError) Is(err error) bool {
+	return err == ErrSignerUnavailable
+}
+
+func newSignerError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.WithStack(&signerError{cause: err})
+}
+
+// contextSigner adapts a Signer to jose.OpaqueSigner by binding it to a context. The public key is resolved
+// upfront because jose.OpaqueSigner.Public can not return an error.
+type contextSigner struct {
+	ctx    context.Context
+	signer Signer
+	public *jose.JSONWebKey
+}
+
+var _ jose.OpaqueSigner = new(contextSigner)
+
+func newContextSigner(ctx context.Context, signer Signer) (*contextSigner, error) {
+	public, err := signerPublicKey(ctx, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &contextSigner{ctx: ctx, signer: signer, public: public}, nil
+}
+
+func (s *contextSigner) Public() *jose.JSONWebKey {
+	return s.public
+}
+
+func (s *contextSigner) Algs() []jose.SignatureAlgorithm {
+	return s.signer.Algs()
+}
+
+func (s *contextSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, newSignerError(err)
+	}
+
+	signature, err := s.signer.SignPayload(s.ctx, payload, alg)
+	if err != nil {
+		return nil, newSignerError(err)
+	}
+	return signature, nil
+}
+
+func signerPublicKey(ctx context.Context, signer Signer) (*jose.JSONWebKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, newSignerError(err)
+	}
+
+	public, err := signer.Public(ctx)
+	if err != nil {
+		return nil, newSignerError(err)
+	} else if public == nil {
+		return nil, newSignerError(errors.New("signer returned a nil public key"))
+	}
+	return public, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// FileSigner is a Signer which reads a PEM encoded RSA or ECDSA private key from disk on every operation. It is
+// meant as a local stand-in for a remote key service in tests and development environments and must not be used
+// in production.
+type FileSigner struct {
+	// Path is the location of the PEM encoded private key.
+	Path string
+
+	// KeyID is set as the "kid" of the public key.
+	KeyID string
+}
+
+var _ Signer = new(FileSigner)
+
+// Public returns the public key of the private key stored at Path.
+func (s *FileSigner) Public(ctx context.Context) (*jose.JSONWebKey, error) {
+	key, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var alg jose.SignatureAlgorithm
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		alg = jose.RS256
+	case *ecdsa.PrivateKey:
+		alg = jose.ES256
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", k)
+	}
+
+	return &jose.JSONWebKey{
+		Key:       key.(crypto.Signer).Public(),
+		KeyID:     s.KeyID,
+		Algorithm: string(alg),
+		Use:       "sig",
+	}, nil
+}
+
+// Algs returns the signing algorithms supported by FileSigner.
+func (s *FileSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{jose.RS256, jose.ES256}
+}
+
+// SignPayload signs payload with the private key stored at Path.
+func (s *FileSigner) SignPayload(ctx context.Context, payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	key, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(payload)
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg != jose.RS256 {
+			return nil, errors.Errorf("algorithm %s is not supported by RSA keys", alg)
+		}
+		return rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		if alg != jose.ES256 {
+			return nil, errors.Errorf("algorithm %s is not supported by ECDSA keys", alg)
+		}
+
+		sigR, sigS, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		// JWS ECDSA signatures are the fixed-size concatenation of R and S, see RFC 7518 Section 3.4.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		out := make([]byte, 2*size)
+		rb, sb := sigR.Bytes(), sigS.Bytes()
+		copy(out[size-len(rb):size], rb)
+		copy(out[2*size-len(sb):], sb)
+		return out, nil
+	default:
+		return nil, errors.Errorf("unsupported private key type %T", k)
+	}
+}
+
+func (s *FileSigner) load(ctx context.Context) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	raw, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.Errorf("file %s does not contain a PEM encoded private key", s.Path)
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+}
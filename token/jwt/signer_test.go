@@ -0,0 +1,110 @@
+/*
+ * Copyright © 2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * @author		Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @copyright 	2015-2018 Aeneas Rekkas <aeneas+oss@aeneas.io>
+ * @license 	Apache-2.0
+ *
+ */
+
+package jwt
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePrivateKey(t *testing.T, key interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	return path
+}
+
+func TestSigner(t *testing.T) {
+	for k, tc := range []struct {
+		d        string
+		strategy func(signer Signer) JWTStrategy
+		key      interface{}
+	}{
+		{
+			d:        "RS256JWTStrategy",
+			strategy: func(signer Signer) JWTStrategy { return &RS256JWTStrategy{PrivateKey: signer} },
+			key:      MustRSAKey(),
+		},
+		{
+			d:        "ES256JWTStrategy",
+			strategy: func(signer Signer) JWTStrategy { return &ES256JWTStrategy{PrivateKey: signer} },
+			key:      MustECDSAKey(),
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/strategy=%s", k, tc.d), func(t *testing.T) {
+			signer := &FileSigner{Path: writePrivateKey(t, tc.key), KeyID: "remote-key"}
+			strategy := tc.strategy(signer)
+			claims := &JWTClaims{ExpiresAt: time.Now().UTC().Add(time.Hour)}
+
+			t.Run("case=signs and validates", func(t *testing.T) {
+				token, sig, err := strategy.Generate(context.Background(), claims.ToMapClaims(), header)
+				require.NoError(t, err)
+				require.NotEmpty(t, sig)
+
+				decoded, err := strategy.Decode(context.Background(), token)
+				require.NoError(t, err)
+				assert.Equal(t, "remote-key", decoded.Header["kid"])
+
+				validated, err := strategy.Validate(context.Background(), token)
+				require.NoError(t, err)
+				assert.Equal(t, sig, validated)
+			})
+
+			t.Run("case=fails with a canceled context", func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				_, _, err := strategy.Generate(ctx, claims.ToMapClaims(), header)
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, ErrSignerUnavailable))
+				assert.True(t, errors.Is(err, context.Canceled))
+
+				_, err = strategy.Validate(ctx, "a.b.c")
+				assert.True(t, errors.Is(err, ErrSignerUnavailable))
+			})
+
+			t.Run("case=fails when the key is unavailable", func(t *testing.T) {
+				strategy := tc.strategy(&FileSigner{Path: filepath.Join(filepath.Dir(signer.Path), "does-not-exist.pem")})
+
+				_, _, err := strategy.Generate(context.Background(), claims.ToMapClaims(), header)
+				assert.True(t, errors.Is(err, ErrSignerUnavailable))
+
+				_, err = strategy.Decode(context.Background(), "a.b.c")
+				assert.True(t, errors.Is(err, ErrSignerUnavailable))
+			})
+		})
+	}
+}
@@ -3,8 +3,10 @@ package jwt
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/ory/x/errorsx"
 	"gopkg.in/square/go-jose.v2"
@@ -96,7 +98,9 @@ func (t *Token) SignedString(k interface{}) (rawToken string, err error) {
 	// as claims or structs but not type aliases from maps.
 	claims := map[string]interface{}(t.Claims)
 	rawToken, err = jwt.Signed(signer).Claims(claims).CompactSerialize()
-	if err != nil {
+	if errors.Is(err, ErrSignerUnavailable) {
+		return
+	} else if err != nil {
 		err = &ValidationError{Errors: ValidationErrorClaimsInvalid, Inner: err}
 		return
 	}
@@ -148,14 +152,44 @@ func newToken(parsedToken *jwt.JSONWebToken, claims MapClaims) (*Token, error) {
 // Header of the token (such as `kid`) to identify which key to use.
 type Keyfunc func(*Token) (interface{}, error)
 
-func Parse(tokenString string, keyFunc Keyfunc) (*Token, error) {
-	return ParseWithClaims(tokenString, MapClaims{}, keyFunc)
+// ParserOption configures the validation of the time based claims in ParseWithClaims.
+type ParserOption func(*parserOptions)
+
+type parserOptions struct {
+	clock  Clock
+	leeway time.Duration
+}
+
+// WithClock validates the exp, iat and nbf claims against the time of clock instead of TimeFunc. A nil clock is
+// ignored.
+func WithClock(clock Clock) ParserOption {
+	return func(o *parserOptions) {
+		if clock != nil {
+			o.clock = clock
+		}
+	}
+}
+
+// WithLeeway tolerates the given clock skew when validating the exp, iat and nbf claims.
+func WithLeeway(leeway time.Duration) ParserOption {
+	return func(o *parserOptions) {
+		o.leeway = leeway
+	}
+}
+
+func Parse(tokenString string, keyFunc Keyfunc, opts ...ParserOption) (*Token, error) {
+	return ParseWithClaims(tokenString, MapClaims{}, keyFunc, opts...)
 }
 
 // Parse, validate, and return a token.
 // keyFunc will receive the parsed token and should return the key for validating.
 // If everything is kosher, err will be nil
-func ParseWithClaims(rawToken string, claims MapClaims, keyFunc Keyfunc) (*Token, error) {
+func ParseWithClaims(rawToken string, claims MapClaims, keyFunc Keyfunc, opts ...ParserOption) (*Token, error) {
+	var o parserOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Parse the token.
 	parsedToken, err := jwt.ParseSigned(rawToken)
 	if err != nil {
@@ -214,7 +248,11 @@ func ParseWithClaims(rawToken string, claims MapClaims, keyFunc Keyfunc) (*Token
 	// Validate claims
 	// This validation is performed to be backwards compatible
 	// with jwt-go library behavior
-	if err := claims.Valid(); err != nil {
+	now := TimeFunc()
+	if o.clock != nil {
+		now = o.clock.Now()
+	}
+	if err := claims.ValidAt(now, o.leeway); err != nil {
 		if e, ok := err.(*ValidationError); !ok {
 			err = &ValidationError{Inner: e, Errors: ValidationErrorClaimsInvalid}
 		}